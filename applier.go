@@ -0,0 +1,290 @@
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/onlyac0611/binlog/dump"
+)
+
+//mysql的错误码
+const (
+	mysqlErrDupEntry = 1062 //主键或者唯一键冲突
+)
+
+//ConflictPolicy 重放数据冲突时的处理策略，冲突包括插入时主键或者唯一键重复以及更新或者删除时数据不存在
+type ConflictPolicy int
+
+//冲突处理策略
+const (
+	ConflictOverwrite ConflictPolicy = iota //覆盖，插入重复时使用REPLACE，更新的数据不存在时插入修改后的数据，删除的数据不存在时忽略
+	ConflictSkip                            //跳过冲突的行
+)
+
+//String 打印
+func (c ConflictPolicy) String() string {
+	switch c {
+	case ConflictOverwrite:
+		return "overwrite"
+	case ConflictSkip:
+		return "skip"
+	}
+	return "unknown"
+}
+
+//applierConn 用于执行sql语句的接口，由dump.MysqlConn实现
+type applierConn interface {
+	Exec(string) error
+	Query(string) (dump.MyRows, error)
+	AffectedRows() uint64
+	Close() error
+}
+
+//MysqlApplier 将RowStreamer得到的事务重放到目标mysql中，每个binlog事务作为目标库的一个事务执行，
+//已经执行的binlog位置与数据在同一个事务中写入checkpoint表，重启后从checkpoint继续可以做到exactly-once
+type MysqlApplier struct {
+	conn       applierConn
+	renderer   *SQLRenderer
+	policy     ConflictPolicy
+	checkpoint MysqlTableName
+	name       string
+	applied    Position
+}
+
+//NewMysqlApplier dsn是目标数据库的信息，checkpoint是保存binlog位置的表，不存在时会自动创建，
+//name用于在checkpoint表中区分不同的复制任务
+func NewMysqlApplier(dsn string, checkpoint MysqlTableName, name string) (*MysqlApplier, error) {
+	cfg, err := dump.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("NewMysqlApplier parse dsn fail. err: %v", err)
+	}
+	//更新时使用匹配的行数来判断数据是否存在
+	cfg.ClientFoundRows = true
+
+	conn, err := dump.NewMysqlConn(cfg.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("NewMysqlApplier newMysqlConn fail. err: %v", err)
+	}
+
+	a, err := newMysqlApplier(conn, checkpoint, name)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func newMysqlApplier(conn applierConn, checkpoint MysqlTableName, name string) (*MysqlApplier, error) {
+	a := &MysqlApplier{
+		conn:       conn,
+		renderer:   NewSQLRenderer(),
+		policy:     ConflictOverwrite,
+		checkpoint: checkpoint,
+		name:       name,
+	}
+	if err := a.loadCheckpoint(); err != nil {
+		return nil, fmt.Errorf("NewMysqlApplier loadCheckpoint fail. err: %v", err)
+	}
+	return a, nil
+}
+
+//SetConflictPolicy 设置冲突处理策略，默认为ConflictOverwrite
+func (a *MysqlApplier) SetConflictPolicy(policy ConflictPolicy) {
+	a.policy = policy
+}
+
+//SetPrimaryKey 设置表的主键列，设置后更新以及删除只使用主键列作为条件，没有设置时使用StreamEvent.PrimaryKey
+func (a *MysqlApplier) SetPrimaryKey(table MysqlTableName, fields ...string) {
+	a.renderer.SetPrimaryKey(table, fields...)
+}
+
+//Checkpoint 获取已经执行的binlog位置，可以用于RowStreamer.SetStartBinlogPosition
+func (a *MysqlApplier) Checkpoint() Position {
+	return a.applied
+}
+
+//Close 关闭到目标数据库的连接
+func (a *MysqlApplier) Close() error {
+	return a.conn.Close()
+}
+
+//Apply 执行事务，可以直接作为SendTransactionFunc使用，
+//事务的结束位置不超过checkpoint时说明已经执行过，直接跳过。
+//分块发送的事务无法在目标数据库中作为一个事务执行，返回错误
+func (a *MysqlApplier) Apply(tran *Transaction) error {
+	if tran.Chunk != nil {
+		return fmt.Errorf("Apply unsupported chunked transaction %v", tran.Chunk.TransactionID)
+	}
+	if !a.applied.IsZero() && tran.NextPosition.Compare(a.applied) <= 0 {
+		lw.logger().Debugf("Apply skip transaction in pos: %+v which has been applied, checkpoint: %+v",
+			tran.NowPosition, a.applied)
+		return nil
+	}
+
+	if err := a.conn.Exec("BEGIN"); err != nil {
+		return fmt.Errorf("Apply begin fail. err: %v", err)
+	}
+	if err := a.applyTransaction(tran); err != nil {
+		if rerr := a.conn.Exec("ROLLBACK"); rerr != nil {
+			lw.logger().Errorf("Apply rollback fail. err: %v", rerr)
+		}
+		return fmt.Errorf("Apply transaction in pos: %+v error: %v", tran.NowPosition, err)
+	}
+	if err := a.conn.Exec("COMMIT"); err != nil {
+		return fmt.Errorf("Apply commit fail. err: %v", err)
+	}
+	a.applied = tran.NextPosition
+	return nil
+}
+
+func (a *MysqlApplier) applyTransaction(tran *Transaction) error {
+	for _, ev := range tran.Events {
+		if err := a.applyEvent(ev); err != nil {
+			return err
+		}
+	}
+	return a.conn.Exec(a.saveCheckpointSQL(tran.NextPosition))
+}
+
+func (a *MysqlApplier) applyEvent(ev *StreamEvent) error {
+	if ev.SQL != "" {
+		return a.conn.Exec(ev.SQL)
+	}
+
+	switch ev.Type {
+	case StatementInsert:
+		for _, row := range ev.RowValues {
+			if err := a.applyInsert(ev.Table, row); err != nil {
+				return err
+			}
+		}
+	case StatementUpdate:
+		if len(ev.RowIdentifies) != len(ev.RowValues) {
+			return fmt.Errorf("the length of RowIdentifies(%d) did not equal to the length of RowValues(%d)",
+				len(ev.RowIdentifies), len(ev.RowValues))
+		}
+		for i := range ev.RowValues {
+			if err := a.applyUpdate(ev.Table, ev.PrimaryKey, ev.RowIdentifies[i], ev.RowValues[i]); err != nil {
+				return err
+			}
+		}
+	case StatementDelete:
+		for _, row := range ev.RowIdentifies {
+			if err := a.applyDelete(ev.Table, ev.PrimaryKey, row); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("can't apply %v statement of table %v without sql", ev.Type, ev.Table.String())
+	}
+	return nil
+}
+
+func (a *MysqlApplier) applyInsert(table MysqlTableName, row *RowData) error {
+	query, err := a.renderer.insertSQLWithVerb(table, row, false)
+	if err != nil {
+		return err
+	}
+	err = a.conn.Exec(query)
+	if !isDupEntry(err) {
+		return err
+	}
+
+	lw.logger().Infof("applyInsert duplicate entry in table %v, policy: %v", table.String(), a.policy)
+	if a.policy == ConflictSkip {
+		return nil
+	}
+	if query, err = a.renderer.insertSQLWithVerb(table, row, true); err != nil {
+		return err
+	}
+	return a.conn.Exec(query)
+}
+
+func (a *MysqlApplier) applyUpdate(table MysqlTableName, keys []string, before, after *RowData) error {
+	query, err := a.renderer.updateSQL(table, keys, after, before)
+	if err != nil {
+		return err
+	}
+	if err = a.conn.Exec(query); err != nil || a.conn.AffectedRows() > 0 {
+		return err
+	}
+
+	lw.logger().Infof("applyUpdate row not found in table %v, policy: %v", table.String(), a.policy)
+	if a.policy == ConflictSkip {
+		return nil
+	}
+	if err = checkFullRowImage(after); err != nil {
+		return fmt.Errorf("applyUpdate can't overwrite missing row: %v", err)
+	}
+	if query, err = a.renderer.insertSQLWithVerb(table, after, true); err != nil {
+		return err
+	}
+	return a.conn.Exec(query)
+}
+
+func (a *MysqlApplier) applyDelete(table MysqlTableName, keys []string, before *RowData) error {
+	query, err := a.renderer.deleteSQL(table, keys, before)
+	if err != nil {
+		return err
+	}
+	if err = a.conn.Exec(query); err != nil || a.conn.AffectedRows() > 0 {
+		return err
+	}
+	//要删除的数据不存在，两种策略下都不需要处理
+	lw.logger().Infof("applyDelete row not found in table %v, policy: %v", table.String(), a.policy)
+	return nil
+}
+
+//loadCheckpoint 创建checkpoint表并读取已经执行的binlog位置
+func (a *MysqlApplier) loadCheckpoint() error {
+	table := quoteTableName(a.checkpoint)
+	if err := a.conn.Exec("CREATE TABLE IF NOT EXISTS " + table + " (" +
+		"`name` varchar(64) NOT NULL," +
+		"`filename` varchar(255) NOT NULL," +
+		"`offset` bigint NOT NULL," +
+		"`updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP," +
+		"PRIMARY KEY (`name`))"); err != nil {
+		return err
+	}
+
+	rows, err := a.conn.Query("SELECT `filename`,`offset` FROM " + table +
+		" WHERE `name`=" + a.quoteString(a.name))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	dest := make([]interface{}, 2)
+	if err = rows.Next(dest); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	filename, _ := dest[0].([]byte)
+	offset, _ := dest[1].([]byte)
+	pos := Position{Filename: string(filename)}
+	if pos.Offset, err = strconv.ParseInt(string(offset), 10, 64); err != nil {
+		return fmt.Errorf("invalid offset %q in checkpoint: %v", offset, err)
+	}
+	a.applied = pos
+	return nil
+}
+
+//saveCheckpointSQL 保存binlog位置的语句
+func (a *MysqlApplier) saveCheckpointSQL(pos Position) string {
+	return "INSERT INTO " + quoteTableName(a.checkpoint) + " (`name`,`filename`,`offset`) VALUES (" +
+		a.quoteString(a.name) + "," + a.quoteString(pos.Filename) + "," + strconv.FormatInt(pos.Offset, 10) +
+		") ON DUPLICATE KEY UPDATE `filename`=VALUES(`filename`),`offset`=VALUES(`offset`)"
+}
+
+func (a *MysqlApplier) quoteString(s string) string {
+	return string(a.renderer.appendColumnValue(nil, &ColumnData{Type: ColumnTypeVarchar, Data: []byte(s)}))
+}
+
+//isDupEntry 是否是主键或者唯一键冲突的错误
+func isDupEntry(err error) bool {
+	me, ok := err.(*dump.MySQLError)
+	return ok && me.Number == mysqlErrDupEntry
+}
@@ -0,0 +1,176 @@
+package binlog
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/onlyac0611/binlog/dump"
+)
+
+//mockApplierConn 记录执行的语句，result根据语句返回影响的行数以及错误
+type mockApplierConn struct {
+	execs        []string
+	affectedRows uint64
+	checkpoint   [][]interface{}
+	result       func(query string) (uint64, error)
+}
+
+func (m *mockApplierConn) Exec(query string) error {
+	m.execs = append(m.execs, query)
+	m.affectedRows = 1
+	if m.result == nil {
+		return nil
+	}
+	var err error
+	m.affectedRows, err = m.result(query)
+	return err
+}
+
+func (m *mockApplierConn) Query(query string) (dump.MyRows, error) {
+	if !strings.HasPrefix(query, "SELECT `filename`,`offset` FROM `db`.`ckpt` WHERE `name`='task'") {
+		return nil, fmt.Errorf("unexpected query: %v", query)
+	}
+	return &mockRows{columns: []string{"filename", "offset"}, values: m.checkpoint}, nil
+}
+
+func (m *mockApplierConn) AffectedRows() uint64 {
+	return m.affectedRows
+}
+
+func (m *mockApplierConn) Close() error {
+	return nil
+}
+
+func TestMysqlApplier_Apply(t *testing.T) {
+	table := NewMysqlTableName("vt_test_keyspace", "vt_a")
+	tran := &Transaction{
+		NowPosition:  Position{Filename: "binlog.000005", Offset: 4},
+		NextPosition: Position{Filename: "binlog.000005", Offset: 100},
+		Events: []*StreamEvent{
+			{Type: StatementInsert, Table: table, RowValues: []*RowData{newTestRowData("1", "a")}},
+			{
+				Type:          StatementUpdate,
+				Table:         table,
+				RowIdentifies: []*RowData{newTestRowData("2", "b")},
+				RowValues:     []*RowData{newTestRowData("2", "c")},
+			},
+			{Type: StatementDelete, Table: table, RowIdentifies: []*RowData{newTestRowData("3", "d")}},
+		},
+	}
+	const (
+		insert    = "INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (1,'a')"
+		replace   = "REPLACE INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (1,'a')"
+		update    = "UPDATE `vt_test_keyspace`.`vt_a` SET `id`=2,`message`='c' WHERE `id`=2 LIMIT 1"
+		overwrite = "REPLACE INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (2,'c')"
+		del       = "DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `id`=3 LIMIT 1"
+		save      = "INSERT INTO `db`.`ckpt` (`name`,`filename`,`offset`) VALUES ('task','binlog.000005',100)" +
+			" ON DUPLICATE KEY UPDATE `filename`=VALUES(`filename`),`offset`=VALUES(`offset`)"
+	)
+	conflict := func(query string) (uint64, error) {
+		switch query {
+		case insert:
+			return 0, &dump.MySQLError{Number: mysqlErrDupEntry, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+		case update, del:
+			return 0, nil
+		}
+		return 1, nil
+	}
+
+	testCases := []struct {
+		policy     ConflictPolicy
+		checkpoint [][]interface{}
+		result     func(query string) (uint64, error)
+		want       []string
+		wantErr    bool
+		wantPos    Position
+	}{
+		{
+			policy:  ConflictOverwrite,
+			want:    []string{"BEGIN", insert, update, del, save, "COMMIT"},
+			wantPos: tran.NextPosition,
+		},
+		{
+			policy:  ConflictOverwrite,
+			result:  conflict,
+			want:    []string{"BEGIN", insert, replace, update, overwrite, del, save, "COMMIT"},
+			wantPos: tran.NextPosition,
+		},
+		{
+			policy:  ConflictSkip,
+			result:  conflict,
+			want:    []string{"BEGIN", insert, update, del, save, "COMMIT"},
+			wantPos: tran.NextPosition,
+		},
+		{
+			policy: ConflictSkip,
+			result: func(query string) (uint64, error) {
+				if query == update {
+					return 0, fmt.Errorf("lock wait timeout")
+				}
+				return 1, nil
+			},
+			want:    []string{"BEGIN", insert, update, "ROLLBACK"},
+			wantErr: true,
+			wantPos: Position{},
+		},
+		{
+			policy:     ConflictOverwrite,
+			checkpoint: [][]interface{}{{[]byte("binlog.000005"), []byte("100")}},
+			want:       nil,
+			wantPos:    tran.NextPosition,
+		},
+	}
+
+	for i, v := range testCases {
+		conn := &mockApplierConn{checkpoint: v.checkpoint}
+		a, err := newMysqlApplier(conn, NewMysqlTableName("db", "ckpt"), "task")
+		if err != nil {
+			t.Fatalf("newMysqlApplier err: %v", err)
+		}
+		a.SetPrimaryKey(table, "id")
+		a.SetConflictPolicy(v.policy)
+		conn.execs = nil
+		conn.result = v.result
+
+		if err = a.Apply(tran); (err != nil) != v.wantErr {
+			t.Fatalf("case %d Apply wantErr: %v err: %v", i, v.wantErr, err)
+		}
+		if !reflect.DeepEqual(conn.execs, v.want) {
+			t.Fatalf("case %d Apply want: %q out: %q", i, v.want, conn.execs)
+		}
+		if out := a.Checkpoint(); out != v.wantPos {
+			t.Fatalf("case %d Checkpoint want: %+v out: %+v", i, v.wantPos, out)
+		}
+	}
+
+	//分块的事务不执行任何语句
+	conn := &mockApplierConn{}
+	a, err := newMysqlApplier(conn, NewMysqlTableName("db", "ckpt"), "task")
+	if err != nil {
+		t.Fatalf("newMysqlApplier err: %v", err)
+	}
+	conn.execs = nil
+	chunked := *tran
+	chunked.Chunk = &ChunkInfo{TransactionID: "binlog.000005:4"}
+	if err = a.Apply(&chunked); err == nil || len(conn.execs) != 0 {
+		t.Fatalf("Apply chunked transaction err: %v execs: %q", err, conn.execs)
+	}
+}
+
+func TestConflictPolicy_String(t *testing.T) {
+	testCases := []struct {
+		input ConflictPolicy
+		want  string
+	}{
+		{input: ConflictOverwrite, want: "overwrite"},
+		{input: ConflictSkip, want: "skip"},
+		{input: ConflictPolicy(100), want: "unknown"},
+	}
+	for _, v := range testCases {
+		if out := v.input.String(); out != v.want {
+			t.Fatalf("String want: %v out: %v", v.want, out)
+		}
+	}
+}
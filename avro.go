@@ -0,0 +1,480 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//avro的基本类型以及复合类型
+const (
+	avroNull   = "null"
+	avroInt    = "int"
+	avroLong   = "long"
+	avroFloat  = "float"
+	avroDouble = "double"
+	avroBytes  = "bytes"
+	avroString = "string"
+	avroRecord = "record"
+	avroUnion  = "union"
+)
+
+//avroSourceName debezium中source的记录名
+const avroSourceName = "io.debezium.connector.mysql.Source"
+
+//avro single object encoding的标识以及CRC-64-AVRO的初始值
+var avroSingleObjectMagic = []byte{0xc3, 0x01}
+
+const avroFingerprintEmpty uint64 = 0xc15d213aa4d7a795
+
+var avroFingerprintTable = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (avroFingerprintEmpty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return table
+}()
+
+//avroFingerprint 计算CRC-64-AVRO
+func avroFingerprint(data []byte) uint64 {
+	fp := avroFingerprintEmpty
+	for _, b := range data {
+		fp = (fp >> 8) ^ avroFingerprintTable[byte(fp)^b]
+	}
+	return fp
+}
+
+//avroType avro的类型，record的name为全名
+type avroType struct {
+	typ         string
+	logicalType string
+	precision   int
+	name        string
+	fields      []avroField
+	union       []*avroType
+}
+
+type avroField struct {
+	name string
+	typ  *avroType
+}
+
+func newAvroPrimitive(typ, logicalType string) *avroType {
+	return &avroType{typ: typ, logicalType: logicalType}
+}
+
+func newAvroOptional(t *avroType) *avroType {
+	return &avroType{typ: avroUnion, union: []*avroType{{typ: avroNull}, t}}
+}
+
+//write 将类型写为json，canonical为true时使用Parsing Canonical Form，即去掉logicalType以及default，
+//defined记录已经定义的record，再次出现时只写名字
+func (t *avroType) write(buf *bytes.Buffer, canonical bool, defined map[string]bool) {
+	switch t.typ {
+	case avroUnion:
+		buf.WriteByte('[')
+		for i, u := range t.union {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			u.write(buf, canonical, defined)
+		}
+		buf.WriteByte(']')
+	case avroRecord:
+		if defined[t.name] {
+			buf.WriteString(strconv.Quote(t.name))
+			return
+		}
+		defined[t.name] = true
+		fmt.Fprintf(buf, `{"name":%s,"type":"record","fields":[`, strconv.Quote(t.name))
+		for i, f := range t.fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, `{"name":%s,"type":`, strconv.Quote(f.name))
+			f.typ.write(buf, canonical, defined)
+			if !canonical && f.typ.typ == avroUnion && f.typ.union[0].typ == avroNull {
+				buf.WriteString(`,"default":null`)
+			}
+			buf.WriteByte('}')
+		}
+		buf.WriteString("]}")
+	default:
+		if canonical || t.logicalType == "" {
+			buf.WriteString(strconv.Quote(t.typ))
+			return
+		}
+		fmt.Fprintf(buf, `{"type":%s,"logicalType":%s`, strconv.Quote(t.typ), strconv.Quote(t.logicalType))
+		if t.logicalType == "decimal" {
+			fmt.Fprintf(buf, `,"precision":%d,"scale":0`, t.precision)
+		}
+		buf.WriteByte('}')
+	}
+}
+
+//AvroSchema 一个表的debezium格式的avro schema，记录名为<serverName>.<db>.<table>.Envelope
+type AvroSchema struct {
+	envelope    *avroType
+	columns     []*avroType
+	schema      string
+	canonical   string
+	fingerprint uint64
+}
+
+//NewAvroSchema 根据表结构以及binlog中的列类型生成avro schema，types与table.Columns()一一对应，
+//所有的列都是可以为null的union，TIMESTAMP使用timestamp-micros，DATETIME使用local-timestamp-micros，
+//DATE使用date，TIME使用time-micros，无符号BIGINT使用decimal(20,0)，DECIMAL使用string
+func NewAvroSchema(serverName string, table MysqlTable, types []ColumnType) (*AvroSchema, error) {
+	columns := table.Columns()
+	if len(columns) != len(types) {
+		return nil, fmt.Errorf("NewAvroSchema the length of types(%d) did not equal to "+
+			"the length of columns(%d)", len(types), len(columns))
+	}
+
+	name := table.Name()
+	namespace := avroName(serverName) + "." + avroName(name.DbName) + "." + avroName(name.TableName)
+	value := &avroType{typ: avroRecord, name: namespace + ".Value"}
+	s := &AvroSchema{}
+	for i, c := range columns {
+		t := avroColumnType(types[i], c.IsUnSignedInt())
+		s.columns = append(s.columns, t)
+		value.fields = append(value.fields, avroField{name: avroName(c.Field()), typ: newAvroOptional(t)})
+	}
+
+	source := &avroType{typ: avroRecord, name: avroSourceName, fields: []avroField{
+		{name: "connector", typ: newAvroPrimitive(avroString, "")},
+		{name: "name", typ: newAvroPrimitive(avroString, "")},
+		{name: "ts_ms", typ: newAvroPrimitive(avroLong, "")},
+		{name: "snapshot", typ: newAvroPrimitive(avroString, "")},
+		{name: "db", typ: newAvroPrimitive(avroString, "")},
+		{name: "table", typ: newAvroPrimitive(avroString, "")},
+		{name: "server_id", typ: newAvroPrimitive(avroLong, "")},
+		{name: "gtid", typ: newAvroOptional(newAvroPrimitive(avroString, ""))},
+		{name: "file", typ: newAvroPrimitive(avroString, "")},
+		{name: "pos", typ: newAvroPrimitive(avroLong, "")},
+		{name: "row", typ: newAvroPrimitive(avroInt, "")},
+	}}
+	s.envelope = &avroType{typ: avroRecord, name: namespace + ".Envelope", fields: []avroField{
+		{name: "before", typ: newAvroOptional(value)},
+		{name: "after", typ: newAvroOptional(value)},
+		{name: "source", typ: source},
+		{name: "op", typ: newAvroPrimitive(avroString, "")},
+		{name: "ts_ms", typ: newAvroOptional(newAvroPrimitive(avroLong, ""))},
+	}}
+
+	buf := &bytes.Buffer{}
+	s.envelope.write(buf, false, make(map[string]bool))
+	s.schema = buf.String()
+	buf.Reset()
+	s.envelope.write(buf, true, make(map[string]bool))
+	s.canonical = buf.String()
+	s.fingerprint = avroFingerprint(buf.Bytes())
+	return s, nil
+}
+
+//String 获取schema的json，可以注册到schema registry中
+func (s *AvroSchema) String() string {
+	return s.schema
+}
+
+//Canonical 获取schema的Parsing Canonical Form
+func (s *AvroSchema) Canonical() string {
+	return s.canonical
+}
+
+//Fingerprint 获取Parsing Canonical Form的CRC-64-AVRO指纹
+func (s *AvroSchema) Fingerprint() uint64 {
+	return s.fingerprint
+}
+
+//avroColumnType 列类型对应的avro类型
+func avroColumnType(typ ColumnType, unsigned bool) *avroType {
+	switch {
+	case typ == ColumnTypeLongLong && unsigned:
+		return &avroType{typ: avroBytes, logicalType: "decimal", precision: 20}
+	case typ == ColumnTypeLong || typ == ColumnTypeLongLong:
+		return newAvroPrimitive(avroLong, "")
+	case typ.IsInteger() || typ == ColumnTypeYear:
+		return newAvroPrimitive(avroInt, "")
+	case typ == ColumnTypeFloat:
+		return newAvroPrimitive(avroFloat, "")
+	case typ == ColumnTypeDouble:
+		return newAvroPrimitive(avroDouble, "")
+	case typ.IsDate():
+		return newAvroPrimitive(avroInt, "date")
+	case typ.IsDateTime():
+		return newAvroPrimitive(avroLong, "local-timestamp-micros")
+	case typ.IsTimestamp():
+		return newAvroPrimitive(avroLong, "timestamp-micros")
+	case typ.IsTime():
+		return newAvroPrimitive(avroLong, "time-micros")
+	case typ.IsBlob() || typ.IsBit() || typ.IsGeometry():
+		return newAvroPrimitive(avroBytes, "")
+	default:
+		return newAvroPrimitive(avroString, "")
+	}
+}
+
+//avroName 将名字中avro不允许的字符替换为_
+func avroName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+//AvroEncoder 将每一行的变更编码为一条debezium格式的avro消息，使用single object encoding，
+//即0xC3 0x01、8字节小端的schema指纹以及二进制编码的数据，消费者可以根据指纹找到Schema返回的schema。
+//AvroEncoder不能并发使用
+type AvroEncoder struct {
+	serverName string
+	mapper     MysqlTableMapper
+	now        func() time.Time
+	schemas    map[string]*AvroSchema
+}
+
+//NewAvroEncoder serverName是debezium中的逻辑服务名，mapper用于获取生成schema的表结构
+func NewAvroEncoder(serverName string, mapper MysqlTableMapper) *AvroEncoder {
+	return &AvroEncoder{
+		serverName: serverName,
+		mapper:     mapper,
+		now:        time.Now,
+		schemas:    make(map[string]*AvroSchema),
+	}
+}
+
+//Schema 获取StreamEvent对应的avro schema，相同的表以及列类型会使用缓存
+func (e *AvroEncoder) Schema(ev *StreamEvent) (*AvroSchema, error) {
+	var row *RowData
+	if len(ev.RowValues) > 0 {
+		row = ev.RowValues[0]
+	} else if len(ev.RowIdentifies) > 0 {
+		row = ev.RowIdentifies[0]
+	} else {
+		return nil, fmt.Errorf("Schema no row in event of %v", ev.Table.String())
+	}
+
+	types := make([]ColumnType, len(row.Columns))
+	key := make([]string, 0, len(row.Columns)+1)
+	key = append(key, ev.Table.String())
+	for i, c := range row.Columns {
+		types[i] = c.Type
+		key = append(key, c.Filed+" "+strconv.Itoa(int(c.Type)))
+	}
+	cacheKey := strings.Join(key, ",")
+	if s, ok := e.schemas[cacheKey]; ok {
+		return s, nil
+	}
+
+	table, err := e.mapper.MysqlTable(ev.Table)
+	if err != nil {
+		return nil, fmt.Errorf("Schema MysqlTable fail. table: %v, err: %v", ev.Table.String(), err)
+	}
+	s, err := NewAvroSchema(e.serverName, table, types)
+	if err != nil {
+		return nil, err
+	}
+	e.schemas[cacheKey] = s
+	return s, nil
+}
+
+//Encode 实现Encoder
+func (e *AvroEncoder) Encode(tran *Transaction) ([][]byte, error) {
+	var msgs [][]byte
+	tsMs := e.now().UnixNano() / int64(time.Millisecond)
+	for _, ev := range tran.Events {
+		op := ""
+		switch ev.Type {
+		case StatementInsert:
+			op = debeziumOpCreate
+		case StatementUpdate:
+			op = debeziumOpUpdate
+		case StatementDelete:
+			op = debeziumOpDelete
+		default:
+			continue
+		}
+		if ev.SQL != "" {
+			continue
+		}
+		schema, err := e.Schema(ev)
+		if err != nil {
+			return nil, err
+		}
+
+		rows := len(ev.RowValues)
+		if op == debeziumOpDelete {
+			rows = len(ev.RowIdentifies)
+		}
+		for i := 0; i < rows; i++ {
+			buf := &bytes.Buffer{}
+			buf.Write(avroSingleObjectMagic)
+			binary.Write(buf, binary.LittleEndian, schema.fingerprint)
+
+			var before, after *RowData
+			if op != debeziumOpCreate {
+				before = ev.RowIdentifies[i]
+			}
+			if op != debeziumOpDelete {
+				after = ev.RowValues[i]
+			}
+			for _, row := range []*RowData{before, after} {
+				if err := writeAvroRow(buf, schema, row, tran.Location); err != nil {
+					return nil, err
+				}
+			}
+
+			writeAvroString(buf, "mysql")
+			writeAvroString(buf, e.serverName)
+			writeAvroLong(buf, ev.Timestamp*1000)
+			writeAvroString(buf, "false")
+			writeAvroString(buf, ev.Table.DbName)
+			writeAvroString(buf, ev.Table.TableName)
+			writeAvroLong(buf, int64(tran.ServerID))
+			if tran.GTID == "" {
+				writeAvroLong(buf, 0)
+			} else {
+				writeAvroLong(buf, 1)
+				writeAvroString(buf, tran.GTID)
+			}
+			writeAvroString(buf, tran.NowPosition.Filename)
+			writeAvroLong(buf, tran.NowPosition.Offset)
+			writeAvroLong(buf, int64(i))
+
+			writeAvroString(buf, op)
+			writeAvroLong(buf, 1)
+			writeAvroLong(buf, tsMs)
+			msgs = append(msgs, buf.Bytes())
+		}
+	}
+	return msgs, nil
+}
+
+//writeAvroRow 写入可以为null的Value记录，row为nil时写入null，IsEmpty的列写入null，loc为TIMESTAMP列的时区
+func writeAvroRow(buf *bytes.Buffer, schema *AvroSchema, row *RowData, loc *time.Location) error {
+	if row == nil {
+		writeAvroLong(buf, 0)
+		return nil
+	}
+	if len(row.Columns) != len(schema.columns) {
+		return fmt.Errorf("writeAvroRow the length of columns(%d) did not equal to "+
+			"the length of fields(%d)", len(row.Columns), len(schema.columns))
+	}
+
+	writeAvroLong(buf, 1)
+	for i, c := range row.Columns {
+		if c.IsEmpty {
+			writeAvroLong(buf, 0)
+			continue
+		}
+		v, err := columnValue(c, loc)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			writeAvroLong(buf, 0)
+			continue
+		}
+		writeAvroLong(buf, 1)
+		if err := writeAvroValue(buf, schema.columns[i], v); err != nil {
+			return fmt.Errorf("writeAvroRow column %v err: %v", c.Filed, err)
+		}
+	}
+	return nil
+}
+
+//writeAvroValue 按照列的avro类型写入columnValue得到的值
+func writeAvroValue(buf *bytes.Buffer, t *avroType, v interface{}) error {
+	switch t.typ {
+	case avroInt, avroLong:
+		switch v := v.(type) {
+		case int64:
+			writeAvroLong(buf, v)
+		case epochDays:
+			writeAvroLong(buf, int64(v))
+		case epochMicros:
+			writeAvroLong(buf, int64(v))
+		case timeMicros:
+			writeAvroLong(buf, int64(v))
+		case time.Time:
+			writeAvroLong(buf, v.Unix()*1000000+int64(v.Nanosecond()/1000))
+		default:
+			return fmt.Errorf("invalid %v value %v", t.typ, v)
+		}
+	case avroFloat:
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("invalid float value %v", v)
+		}
+		binary.Write(buf, binary.LittleEndian, math.Float32bits(float32(f)))
+	case avroDouble:
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("invalid double value %v", v)
+		}
+		binary.Write(buf, binary.LittleEndian, math.Float64bits(f))
+	case avroBytes:
+		if t.logicalType == "decimal" {
+			//decimal为大端的补码
+			n := new(big.Int)
+			switch v := v.(type) {
+			case int64:
+				n.SetInt64(v)
+			case uint64:
+				n.SetUint64(v)
+			default:
+				return fmt.Errorf("invalid decimal value %v", v)
+			}
+			b := n.Bytes()
+			if len(b) == 0 || b[0]&0x80 != 0 {
+				b = append([]byte{0}, b...)
+			}
+			writeAvroBytes(buf, b)
+			return nil
+		}
+		b, ok := v.([]byte)
+		if !ok {
+			return fmt.Errorf("invalid bytes value %v", v)
+		}
+		writeAvroBytes(buf, b)
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("invalid string value %v", v)
+		}
+		writeAvroString(buf, s)
+	}
+	return nil
+}
+
+//writeAvroLong 使用zigzag变长编码写入int以及long
+func writeAvroLong(buf *bytes.Buffer, v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	buf.Write(b[:n])
+}
+
+func writeAvroBytes(buf *bytes.Buffer, b []byte) {
+	writeAvroLong(buf, int64(len(b)))
+	buf.Write(b)
+}
+
+func writeAvroString(buf *bytes.Buffer, s string) {
+	writeAvroLong(buf, int64(len(s)))
+	buf.WriteString(s)
+}
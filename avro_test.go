@@ -0,0 +1,161 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestAvroFingerprint(t *testing.T) {
+	testCases := []struct {
+		schema string
+		want   int64
+	}{
+		{schema: `"null"`, want: 7195948357588979594},
+		{schema: `"boolean"`, want: -6970731678124411036},
+		{schema: `"int"`, want: 8247732601305521295},
+		{schema: `"long"`, want: -3434872931120570953},
+	}
+	for _, v := range testCases {
+		if out := int64(avroFingerprint([]byte(v.schema))); out != v.want {
+			t.Fatalf("avroFingerprint(%v) want: %v out: %v", v.schema, v.want, out)
+		}
+	}
+}
+
+func TestWriteAvroLong(t *testing.T) {
+	testCases := []struct {
+		input int64
+		want  []byte
+	}{
+		{input: 0, want: []byte{0x00}},
+		{input: -1, want: []byte{0x01}},
+		{input: 1, want: []byte{0x02}},
+		{input: 64, want: []byte{0x80, 0x01}},
+		{input: -65, want: []byte{0x81, 0x01}},
+	}
+	for _, v := range testCases {
+		buf := &bytes.Buffer{}
+		writeAvroLong(buf, v.input)
+		if !bytes.Equal(buf.Bytes(), v.want) {
+			t.Fatalf("writeAvroLong(%v) want: %x out: %x", v.input, v.want, buf.Bytes())
+		}
+	}
+}
+
+func TestNewAvroSchema(t *testing.T) {
+	s, err := NewAvroSchema("dbserver1", tesInfo, []ColumnType{ColumnTypeLong, ColumnTypeVarchar})
+	if err != nil {
+		t.Fatalf("NewAvroSchema err: %v", err)
+	}
+
+	value := `{"name":"dbserver1.vt_test_keyspace.vt_a.Value","type":"record","fields":[` +
+		`{"name":"id","type":["null","long"]},{"name":"message","type":["null","string"]}]}`
+	source := `{"name":"io.debezium.connector.mysql.Source","type":"record","fields":[` +
+		`{"name":"connector","type":"string"},{"name":"name","type":"string"},{"name":"ts_ms","type":"long"},` +
+		`{"name":"snapshot","type":"string"},{"name":"db","type":"string"},{"name":"table","type":"string"},` +
+		`{"name":"server_id","type":"long"},{"name":"gtid","type":["null","string"]},` +
+		`{"name":"file","type":"string"},{"name":"pos","type":"long"},{"name":"row","type":"int"}]}`
+	want := `{"name":"dbserver1.vt_test_keyspace.vt_a.Envelope","type":"record","fields":[` +
+		`{"name":"before","type":["null",` + value + `]},` +
+		`{"name":"after","type":["null","dbserver1.vt_test_keyspace.vt_a.Value"]},` +
+		`{"name":"source","type":` + source + `},{"name":"op","type":"string"},` +
+		`{"name":"ts_ms","type":["null","long"]}]}`
+	if s.Canonical() != want {
+		t.Fatalf("Canonical\nwant: %v\nout:  %v", want, s.Canonical())
+	}
+	if s.Fingerprint() != avroFingerprint([]byte(want)) {
+		t.Fatalf("Fingerprint want: %x out: %x", avroFingerprint([]byte(want)), s.Fingerprint())
+	}
+
+	if _, err := NewAvroSchema("dbserver1", tesInfo, []ColumnType{ColumnTypeLong}); err == nil {
+		t.Fatalf("NewAvroSchema with wrong types want err")
+	}
+}
+
+func TestAvroColumnType(t *testing.T) {
+	testCases := []struct {
+		typ      ColumnType
+		unsigned bool
+		want     string
+	}{
+		{typ: ColumnTypeTiny, want: `"int"`},
+		{typ: ColumnTypeLongLong, want: `"long"`},
+		{typ: ColumnTypeLongLong, unsigned: true, want: `{"type":"bytes","logicalType":"decimal","precision":20,"scale":0}`},
+		{typ: ColumnTypeDouble, want: `"double"`},
+		{typ: ColumnTypeDate, want: `{"type":"int","logicalType":"date"}`},
+		{typ: ColumnTypeDateTime2, want: `{"type":"long","logicalType":"local-timestamp-micros"}`},
+		{typ: ColumnTypeTimestamp2, want: `{"type":"long","logicalType":"timestamp-micros"}`},
+		{typ: ColumnTypeTime2, want: `{"type":"long","logicalType":"time-micros"}`},
+		{typ: ColumnTypeBlob, want: `"bytes"`},
+		{typ: ColumnTypeNewDecimal, want: `"string"`},
+	}
+	for _, v := range testCases {
+		buf := &bytes.Buffer{}
+		avroColumnType(v.typ, v.unsigned).write(buf, false, make(map[string]bool))
+		if buf.String() != v.want {
+			t.Fatalf("avroColumnType(%v, %v) want: %v out: %v", v.typ, v.unsigned, v.want, buf.String())
+		}
+	}
+}
+
+func TestAvroEncoder_Encode(t *testing.T) {
+	table := NewMysqlTableName("vt_test_keyspace", "vt_a")
+	tran := &Transaction{
+		NowPosition: Position{Filename: "b.1", Offset: 4},
+		ServerID:    1,
+		Events: []*StreamEvent{
+			{
+				Type:      StatementInsert,
+				Table:     table,
+				Timestamp: 1,
+				RowValues: []*RowData{newTestRowData("1", "a")},
+			},
+			{
+				Type:  StatementAlter,
+				Table: table,
+				SQL:   "alter table vt_a add column c int",
+			},
+		},
+	}
+
+	e := NewAvroEncoder("s", newMockMapper())
+	e.now = func() time.Time { return time.Unix(2, 0) }
+	msgs, err := e.Encode(tran)
+	if err != nil {
+		t.Fatalf("Encode err: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Encode want 1 message out: %v", len(msgs))
+	}
+
+	s, err := e.Schema(tran.Events[0])
+	if err != nil {
+		t.Fatalf("Schema err: %v", err)
+	}
+	want := []byte{0xc3, 0x01}
+	want = append(want, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(want[2:], s.Fingerprint())
+	want = append(want,
+		0x00,             // before: null
+		0x02, 0x02, 0x02, // after.id: 1
+		0x02, 0x02, 'a', // after.message: "a"
+		0x0a, 'm', 'y', 's', 'q', 'l', // source.connector
+		0x02, 's', // source.name
+		0xd0, 0x0f, // source.ts_ms: 1000
+		0x0a, 'f', 'a', 'l', 's', 'e', // source.snapshot
+		0x20, 'v', 't', '_', 't', 'e', 's', 't', '_', 'k', 'e', 'y', 's', 'p', 'a', 'c', 'e', // source.db
+		0x08, 'v', 't', '_', 'a', // source.table
+		0x02,                // source.server_id: 1
+		0x00,                // source.gtid: null
+		0x06, 'b', '.', '1', // source.file
+		0x08,      // source.pos: 4
+		0x00,      // source.row: 0
+		0x02, 'c', // op
+		0x02, 0xa0, 0x1f, // ts_ms: 2000
+	)
+	if !bytes.Equal(msgs[0], want) {
+		t.Fatalf("Encode\nwant: %x\nout:  %x", want, msgs[0])
+	}
+}
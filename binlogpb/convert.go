@@ -0,0 +1,142 @@
+package binlogpb
+
+import (
+	"fmt"
+
+	"github.com/onlyac0611/binlog"
+)
+
+//ProtoVersion binlog.proto中Transaction.version的当前版本
+const ProtoVersion = 1
+
+//FromTransaction 将binlog.Transaction转换为protobuf消息
+func FromTransaction(tran *binlog.Transaction) *Transaction {
+	t := &Transaction{
+		Version:      ProtoVersion,
+		NowPosition:  fromPosition(tran.NowPosition),
+		NextPosition: fromPosition(tran.NextPosition),
+		Timestamp:    tran.Timestamp,
+		GTID:         tran.GTID,
+		ServerID:     tran.ServerID,
+		Events:       make([]*StreamEvent, 0, len(tran.Events)),
+	}
+	if c := tran.Chunk; c != nil {
+		t.Chunk = &ChunkInfo{TransactionID: c.TransactionID, Sequence: int64(c.Sequence), Last: c.Last,
+			Rollback: c.Rollback}
+	}
+	for _, ev := range tran.Events {
+		t.Events = append(t.Events, &StreamEvent{
+			Type:          int32(ev.Type),
+			Table:         &TableName{Db: ev.Table.DbName, Table: ev.Table.TableName},
+			SQL:           ev.SQL,
+			Timestamp:     ev.Timestamp,
+			RowValues:     fromRows(ev.RowValues),
+			RowIdentifies: fromRows(ev.RowIdentifies),
+			PrimaryKey:    ev.PrimaryKey,
+		})
+	}
+	return t
+}
+
+func fromPosition(pos binlog.Position) *Position {
+	return &Position{Filename: pos.Filename, Offset: pos.Offset}
+}
+
+func fromRows(rows []*binlog.RowData) []*RowData {
+	out := make([]*RowData, 0, len(rows))
+	for _, row := range rows {
+		r := &RowData{Columns: make([]*ColumnData, 0, len(row.Columns))}
+		for _, c := range row.Columns {
+			r.Columns = append(r.Columns, &ColumnData{
+				Field:   c.Filed,
+				Type:    uint32(c.Type),
+				IsEmpty: c.IsEmpty,
+				IsNull:  !c.IsEmpty && c.Data == nil,
+				Data:    c.Data,
+			})
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+//ToTransaction 将protobuf消息还原为binlog.Transaction，不支持比ProtoVersion更新的版本
+func (t *Transaction) ToTransaction() (*binlog.Transaction, error) {
+	if t.Version > ProtoVersion {
+		return nil, fmt.Errorf("ToTransaction unsupported proto version %v", t.Version)
+	}
+	tran := &binlog.Transaction{
+		NowPosition:  t.NowPosition.toPosition(),
+		NextPosition: t.NextPosition.toPosition(),
+		Timestamp:    t.Timestamp,
+		GTID:         t.GTID,
+		ServerID:     t.ServerID,
+		Events:       make([]*binlog.StreamEvent, 0, len(t.Events)),
+	}
+	if c := t.Chunk; c != nil {
+		tran.Chunk = &binlog.ChunkInfo{TransactionID: c.TransactionID, Sequence: int(c.Sequence), Last: c.Last,
+			Rollback: c.Rollback}
+	}
+	for _, ev := range t.Events {
+		if ev == nil {
+			return nil, fmt.Errorf("ToTransaction nil event")
+		}
+		e := &binlog.StreamEvent{
+			Type:          binlog.StatementType(ev.Type),
+			SQL:           ev.SQL,
+			Timestamp:     ev.Timestamp,
+			RowValues:     toRows(ev.RowValues),
+			RowIdentifies: toRows(ev.RowIdentifies),
+			PrimaryKey:    ev.PrimaryKey,
+		}
+		if ev.Table != nil {
+			e.Table = binlog.MysqlTableName{DbName: ev.Table.Db, TableName: ev.Table.Table}
+		}
+		tran.Events = append(tran.Events, e)
+	}
+	return tran, nil
+}
+
+func (p *Position) toPosition() binlog.Position {
+	if p == nil {
+		return binlog.Position{}
+	}
+	return binlog.Position{Filename: p.Filename, Offset: p.Offset}
+}
+
+func toRows(rows []*RowData) []*binlog.RowData {
+	out := make([]*binlog.RowData, 0, len(rows))
+	for _, row := range rows {
+		r := binlog.NewRowData(len(row.Columns))
+		for _, c := range row.Columns {
+			col := binlog.NewColumnData(c.Field, binlog.ColumnType(c.Type), c.IsEmpty)
+			//protobuf中空的bytes与不存在没有区别，使用is_null区分NULL以及空字符串
+			if !c.IsEmpty && !c.IsNull {
+				col.Data = c.Data
+				if col.Data == nil {
+					col.Data = []byte{}
+				}
+			}
+			r.Columns = append(r.Columns, col)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+//Encoder 将事务编码为一条protobuf消息，实现binlog.Encoder
+type Encoder struct{}
+
+//NewEncoder 创建Encoder
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+//Encode 实现binlog.Encoder
+func (e *Encoder) Encode(tran *binlog.Transaction) ([][]byte, error) {
+	data, err := FromTransaction(tran).Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{data}, nil
+}
@@ -0,0 +1,114 @@
+package binlogpb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/onlyac0611/binlog"
+)
+
+func newTestTransaction() *binlog.Transaction {
+	table := binlog.NewMysqlTableName("vt_test_keyspace", "vt_a")
+	return &binlog.Transaction{
+		NowPosition:  binlog.Position{Filename: "binlog.000001", Offset: 4},
+		NextPosition: binlog.Position{Filename: "binlog.000001", Offset: 300},
+		Timestamp:    1407805592,
+		GTID:         "439192bd-f37c-11e4-bbeb-0242ac11035a:4",
+		ServerID:     62344,
+		Chunk:        &binlog.ChunkInfo{TransactionID: "439192bd-f37c-11e4-bbeb-0242ac11035a:4", Sequence: 2, Last: true},
+		Events: []*binlog.StreamEvent{
+			{
+				Type:       binlog.StatementUpdate,
+				Table:      table,
+				Timestamp:  1407805592,
+				PrimaryKey: []string{"id", ""},
+				RowValues: []*binlog.RowData{
+					{Columns: []*binlog.ColumnData{
+						{Filed: "id", Type: binlog.ColumnTypeLongLong, Data: []byte("18446744073709551615")},
+						{Filed: "price", Type: binlog.ColumnTypeNewDecimal, Data: []byte("1.50")},
+						{Filed: "data", Type: binlog.ColumnTypeBlob, Data: []byte{0, 0xff}},
+						{Filed: "message", Type: binlog.ColumnTypeVarchar, Data: []byte{}},
+						{Filed: "deleted", Type: binlog.ColumnTypeTiny},
+						{Filed: "created", Type: binlog.ColumnTypeTimestamp2, IsEmpty: true},
+					}},
+				},
+				RowIdentifies: []*binlog.RowData{
+					{Columns: []*binlog.ColumnData{
+						{Filed: "id", Type: binlog.ColumnTypeLongLong, Data: []byte("1")},
+						{Filed: "price", Type: binlog.ColumnTypeNewDecimal, IsEmpty: true},
+						{Filed: "data", Type: binlog.ColumnTypeBlob, IsEmpty: true},
+						{Filed: "message", Type: binlog.ColumnTypeVarchar, Data: []byte("abc")},
+						{Filed: "deleted", Type: binlog.ColumnTypeTiny, Data: []byte("0")},
+						{Filed: "created", Type: binlog.ColumnTypeTimestamp2, IsEmpty: true},
+					}},
+				},
+			},
+			{
+				Type:          binlog.StatementAlter,
+				Table:         table,
+				Timestamp:     1407805592,
+				SQL:           "alter table vt_a add column c int",
+				RowValues:     []*binlog.RowData{},
+				RowIdentifies: []*binlog.RowData{},
+			},
+		},
+	}
+}
+
+func TestTransaction_RoundTrip(t *testing.T) {
+	in := newTestTransaction()
+	data, err := FromTransaction(in).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal err: %v", err)
+	}
+
+	var pb Transaction
+	if err := pb.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal err: %v", err)
+	}
+	if pb.Version != ProtoVersion {
+		t.Errorf("Version = %v, want %v", pb.Version, ProtoVersion)
+	}
+	out, err := pb.ToTransaction()
+	if err != nil {
+		t.Fatalf("ToTransaction err: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("ToTransaction = %+v, want %+v", out, in)
+	}
+}
+
+func TestTransaction_ToTransactionVersion(t *testing.T) {
+	pb := &Transaction{Version: ProtoVersion + 1}
+	if _, err := pb.ToTransaction(); err == nil {
+		t.Errorf("ToTransaction expect err for version %v", pb.Version)
+	}
+
+	//缺少位置信息时为零值
+	tran, err := (&Transaction{}).ToTransaction()
+	if err != nil {
+		t.Fatalf("ToTransaction err: %v", err)
+	}
+	if tran.NowPosition != (binlog.Position{}) || len(tran.Events) != 0 {
+		t.Errorf("ToTransaction = %+v", tran)
+	}
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	in := newTestTransaction()
+	msgs, err := NewEncoder().Encode(in)
+	if err != nil {
+		t.Fatalf("Encode err: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Encode got %v messages", len(msgs))
+	}
+
+	js, err := in.MarshalJSONV2()
+	if err != nil {
+		t.Fatalf("MarshalJSONV2 err: %v", err)
+	}
+	if len(msgs[0]) >= len(js) {
+		t.Errorf("protobuf size %v is not smaller than json size %v", len(msgs[0]), len(js))
+	}
+}
@@ -0,0 +1,16 @@
+/*
+Package binlogpb 事务的protobuf格式，消息的定义见binlog.proto，
+其中的Marshal以及Unmarshal是按照proto3的编码规则手写的，不依赖protobuf的库，
+与protoc生成的代码可以互相解析(testdata中保存了protoc编码的事务，用于测试编码是否一致)，
+其他语言可以直接使用binlog.proto生成代码:
+
+	pb := binlogpb.FromTransaction(tran)
+	data, err := pb.Marshal()
+	...
+	var out binlogpb.Transaction
+	if err := out.Unmarshal(data); err != nil {
+		return err
+	}
+	tran, err := out.ToTransaction()
+*/
+package binlogpb
@@ -0,0 +1,413 @@
+package binlogpb
+
+import "fmt"
+
+//Position 对应binlog.proto中的Position
+type Position struct {
+	Filename string
+	Offset   int64
+}
+
+//TableName 对应binlog.proto中的TableName
+type TableName struct {
+	Db    string
+	Table string
+}
+
+//ColumnData 对应binlog.proto中的ColumnData
+type ColumnData struct {
+	Field   string
+	Type    uint32
+	IsEmpty bool
+	IsNull  bool
+	Data    []byte
+}
+
+//RowData 对应binlog.proto中的RowData
+type RowData struct {
+	Columns []*ColumnData
+}
+
+//StreamEvent 对应binlog.proto中的StreamEvent
+type StreamEvent struct {
+	Type          int32
+	Table         *TableName
+	SQL           string
+	Timestamp     int64
+	RowValues     []*RowData
+	RowIdentifies []*RowData
+	PrimaryKey    []string
+}
+
+//ChunkInfo 对应binlog.proto中的ChunkInfo
+type ChunkInfo struct {
+	TransactionID string
+	Sequence      int64
+	Last          bool
+	Rollback      bool
+}
+
+//Transaction 对应binlog.proto中的Transaction
+type Transaction struct {
+	Version      uint32
+	NowPosition  *Position
+	NextPosition *Position
+	Timestamp    int64
+	GTID         string
+	ServerID     uint32
+	Events       []*StreamEvent
+	Chunk        *ChunkInfo
+}
+
+//Marshal 序列化为protobuf格式
+func (p *Position) Marshal() ([]byte, error) {
+	return p.appendTo(nil), nil
+}
+
+func (p *Position) appendTo(b []byte) []byte {
+	b = appendStringField(b, 1, p.Filename)
+	return appendVarintField(b, 2, uint64(p.Offset))
+}
+
+//Unmarshal 解析protobuf格式
+func (p *Position) Unmarshal(data []byte) error {
+	*p = Position{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			p.Filename, err = d.stringField(field, wireType)
+		case 2:
+			var v uint64
+			v, err = d.varintField(field, wireType)
+			p.Offset = int64(v)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return fmt.Errorf("Position: %v", err)
+		}
+	}
+	return nil
+}
+
+//Marshal 序列化为protobuf格式
+func (t *TableName) Marshal() ([]byte, error) {
+	return t.appendTo(nil), nil
+}
+
+func (t *TableName) appendTo(b []byte) []byte {
+	b = appendStringField(b, 1, t.Db)
+	return appendStringField(b, 2, t.Table)
+}
+
+//Unmarshal 解析protobuf格式
+func (t *TableName) Unmarshal(data []byte) error {
+	*t = TableName{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			t.Db, err = d.stringField(field, wireType)
+		case 2:
+			t.Table, err = d.stringField(field, wireType)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return fmt.Errorf("TableName: %v", err)
+		}
+	}
+	return nil
+}
+
+//Marshal 序列化为protobuf格式
+func (c *ColumnData) Marshal() ([]byte, error) {
+	return c.appendTo(nil), nil
+}
+
+func (c *ColumnData) appendTo(b []byte) []byte {
+	b = appendStringField(b, 1, c.Field)
+	b = appendVarintField(b, 2, uint64(c.Type))
+	b = appendBoolField(b, 3, c.IsEmpty)
+	b = appendBoolField(b, 4, c.IsNull)
+	return appendBytesField(b, 5, c.Data)
+}
+
+//Unmarshal 解析protobuf格式
+func (c *ColumnData) Unmarshal(data []byte) error {
+	*c = ColumnData{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		var v uint64
+		switch field {
+		case 1:
+			c.Field, err = d.stringField(field, wireType)
+		case 2:
+			v, err = d.varintField(field, wireType)
+			c.Type = uint32(v)
+		case 3:
+			v, err = d.varintField(field, wireType)
+			c.IsEmpty = v != 0
+		case 4:
+			v, err = d.varintField(field, wireType)
+			c.IsNull = v != 0
+		case 5:
+			var b []byte
+			if b, err = d.bytesField(field, wireType); err == nil {
+				c.Data = append([]byte{}, b...)
+			}
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return fmt.Errorf("ColumnData: %v", err)
+		}
+	}
+	return nil
+}
+
+//Marshal 序列化为protobuf格式
+func (r *RowData) Marshal() ([]byte, error) {
+	return r.appendTo(nil), nil
+}
+
+func (r *RowData) appendTo(b []byte) []byte {
+	for _, c := range r.Columns {
+		b = appendMessageField(b, 1, c.appendTo(nil))
+	}
+	return b
+}
+
+//Unmarshal 解析protobuf格式
+func (r *RowData) Unmarshal(data []byte) error {
+	*r = RowData{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			var b []byte
+			if b, err = d.bytesField(field, wireType); err == nil {
+				c := new(ColumnData)
+				err = c.Unmarshal(b)
+				r.Columns = append(r.Columns, c)
+			}
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return fmt.Errorf("RowData: %v", err)
+		}
+	}
+	return nil
+}
+
+//Marshal 序列化为protobuf格式
+func (s *StreamEvent) Marshal() ([]byte, error) {
+	return s.appendTo(nil), nil
+}
+
+func (s *StreamEvent) appendTo(b []byte) []byte {
+	b = appendVarintField(b, 1, uint64(s.Type))
+	if s.Table != nil {
+		b = appendMessageField(b, 2, s.Table.appendTo(nil))
+	}
+	b = appendStringField(b, 3, s.SQL)
+	b = appendVarintField(b, 4, uint64(s.Timestamp))
+	for _, r := range s.RowValues {
+		b = appendMessageField(b, 5, r.appendTo(nil))
+	}
+	for _, r := range s.RowIdentifies {
+		b = appendMessageField(b, 6, r.appendTo(nil))
+	}
+	for _, key := range s.PrimaryKey {
+		//repeated的元素即使为空也需要写入
+		b = appendMessageField(b, 7, []byte(key))
+	}
+	return b
+}
+
+//Unmarshal 解析protobuf格式
+func (s *StreamEvent) Unmarshal(data []byte) error {
+	*s = StreamEvent{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		var v uint64
+		var b []byte
+		switch field {
+		case 1:
+			v, err = d.varintField(field, wireType)
+			s.Type = int32(v)
+		case 2:
+			if b, err = d.bytesField(field, wireType); err == nil {
+				s.Table = new(TableName)
+				err = s.Table.Unmarshal(b)
+			}
+		case 3:
+			s.SQL, err = d.stringField(field, wireType)
+		case 4:
+			v, err = d.varintField(field, wireType)
+			s.Timestamp = int64(v)
+		case 5, 6:
+			if b, err = d.bytesField(field, wireType); err == nil {
+				r := new(RowData)
+				err = r.Unmarshal(b)
+				if field == 5 {
+					s.RowValues = append(s.RowValues, r)
+				} else {
+					s.RowIdentifies = append(s.RowIdentifies, r)
+				}
+			}
+		case 7:
+			var key string
+			if key, err = d.stringField(field, wireType); err == nil {
+				s.PrimaryKey = append(s.PrimaryKey, key)
+			}
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return fmt.Errorf("StreamEvent: %v", err)
+		}
+	}
+	return nil
+}
+
+//Marshal 序列化为protobuf格式
+func (c *ChunkInfo) Marshal() ([]byte, error) {
+	return c.appendTo(nil), nil
+}
+
+func (c *ChunkInfo) appendTo(b []byte) []byte {
+	b = appendStringField(b, 1, c.TransactionID)
+	b = appendVarintField(b, 2, uint64(c.Sequence))
+	b = appendBoolField(b, 3, c.Last)
+	return appendBoolField(b, 4, c.Rollback)
+}
+
+//Unmarshal 解析protobuf格式
+func (c *ChunkInfo) Unmarshal(data []byte) error {
+	*c = ChunkInfo{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		var v uint64
+		switch field {
+		case 1:
+			c.TransactionID, err = d.stringField(field, wireType)
+		case 2:
+			v, err = d.varintField(field, wireType)
+			c.Sequence = int64(v)
+		case 3:
+			v, err = d.varintField(field, wireType)
+			c.Last = v != 0
+		case 4:
+			v, err = d.varintField(field, wireType)
+			c.Rollback = v != 0
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return fmt.Errorf("ChunkInfo: %v", err)
+		}
+	}
+	return nil
+}
+
+//Marshal 序列化为protobuf格式
+func (t *Transaction) Marshal() ([]byte, error) {
+	b := appendVarintField(nil, 1, uint64(t.Version))
+	if t.NowPosition != nil {
+		b = appendMessageField(b, 2, t.NowPosition.appendTo(nil))
+	}
+	if t.NextPosition != nil {
+		b = appendMessageField(b, 3, t.NextPosition.appendTo(nil))
+	}
+	b = appendVarintField(b, 4, uint64(t.Timestamp))
+	b = appendStringField(b, 5, t.GTID)
+	b = appendVarintField(b, 6, uint64(t.ServerID))
+	for _, ev := range t.Events {
+		b = appendMessageField(b, 7, ev.appendTo(nil))
+	}
+	if t.Chunk != nil {
+		b = appendMessageField(b, 8, t.Chunk.appendTo(nil))
+	}
+	return b, nil
+}
+
+//Unmarshal 解析protobuf格式，未知的字段会被忽略
+func (t *Transaction) Unmarshal(data []byte) error {
+	*t = Transaction{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		var v uint64
+		var b []byte
+		switch field {
+		case 1:
+			v, err = d.varintField(field, wireType)
+			t.Version = uint32(v)
+		case 2:
+			if b, err = d.bytesField(field, wireType); err == nil {
+				t.NowPosition = new(Position)
+				err = t.NowPosition.Unmarshal(b)
+			}
+		case 3:
+			if b, err = d.bytesField(field, wireType); err == nil {
+				t.NextPosition = new(Position)
+				err = t.NextPosition.Unmarshal(b)
+			}
+		case 4:
+			v, err = d.varintField(field, wireType)
+			t.Timestamp = int64(v)
+		case 5:
+			t.GTID, err = d.stringField(field, wireType)
+		case 6:
+			v, err = d.varintField(field, wireType)
+			t.ServerID = uint32(v)
+		case 7:
+			if b, err = d.bytesField(field, wireType); err == nil {
+				ev := new(StreamEvent)
+				err = ev.Unmarshal(b)
+				t.Events = append(t.Events, ev)
+			}
+		case 8:
+			if b, err = d.bytesField(field, wireType); err == nil {
+				t.Chunk = new(ChunkInfo)
+				err = t.Chunk.Unmarshal(b)
+			}
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return fmt.Errorf("Transaction: %v", err)
+		}
+	}
+	return nil
+}
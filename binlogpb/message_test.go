@@ -0,0 +1,175 @@
+package binlogpb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestMarshal_WireFormat(t *testing.T) {
+	testCases := []struct {
+		name string
+		msg  interface {
+			Marshal() ([]byte, error)
+		}
+		want []byte
+	}{
+		{"empty position", &Position{}, []byte{}},
+		{"position", &Position{Filename: "a", Offset: 4}, []byte{0x0a, 0x01, 'a', 0x10, 0x04}},
+		{"negative offset", &Position{Offset: -1},
+			[]byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"null column", &ColumnData{Field: "id", Type: 8, IsNull: true},
+			[]byte{0x0a, 0x02, 'i', 'd', 0x10, 0x08, 0x20, 0x01}},
+		{"column data", &ColumnData{Field: "b", Type: 300, Data: []byte{0}},
+			[]byte{0x0a, 0x01, 'b', 0x10, 0xac, 0x02, 0x2a, 0x01, 0x00}},
+		{"row", &RowData{Columns: []*ColumnData{{IsEmpty: true}}},
+			[]byte{0x0a, 0x02, 0x18, 0x01}},
+		{"event", &StreamEvent{Type: 4, Table: &TableName{Db: "d", Table: "t"}},
+			[]byte{0x08, 0x04, 0x12, 0x06, 0x0a, 0x01, 'd', 0x12, 0x01, 't'}},
+	}
+	for _, tc := range testCases {
+		got, err := tc.msg.Marshal()
+		if err != nil {
+			t.Fatalf("%s: Marshal err: %v", tc.name, err)
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: Marshal = % x, want % x", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTransaction_Unmarshal(t *testing.T) {
+	in := &Transaction{
+		Version:      ProtoVersion,
+		NowPosition:  &Position{Filename: "mysql-bin.000001", Offset: 4},
+		NextPosition: &Position{Filename: "mysql-bin.000001", Offset: 120},
+		Timestamp:    1407805592,
+		GTID:         "439192bd-f37c-11e4-bbeb-0242ac11035a:4",
+		ServerID:     62344,
+		Events: []*StreamEvent{
+			{
+				Type:      4,
+				Table:     &TableName{Db: "db", Table: "t"},
+				Timestamp: 1407805592,
+				RowValues: []*RowData{
+					{Columns: []*ColumnData{
+						{Field: "id", Type: 8, Data: []byte("1")},
+						{Field: "name", Type: 15, IsNull: true},
+					}},
+				},
+				RowIdentifies: []*RowData{
+					{Columns: []*ColumnData{{Field: "id", Type: 8, IsEmpty: true}}},
+				},
+			},
+			{Type: 8, Table: &TableName{}, SQL: "alter table t add c int"},
+		},
+	}
+	data, err := in.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal err: %v", err)
+	}
+	var out Transaction
+	if err := out.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal err: %v", err)
+	}
+	if !reflect.DeepEqual(in, &out) {
+		t.Errorf("Unmarshal = %+v, want %+v", out, in)
+	}
+}
+
+//TestTransaction_Golden 与protoc编码的testdata/transaction.txtpb(即testdata/transaction.bin)比较
+func TestTransaction_Golden(t *testing.T) {
+	want, err := ioutil.ReadFile("testdata/transaction.bin")
+	if err != nil {
+		t.Fatalf("ReadFile err: %v", err)
+	}
+	in := &Transaction{
+		Version:      ProtoVersion,
+		NowPosition:  &Position{Filename: "mysql-bin.000001", Offset: 4},
+		NextPosition: &Position{Filename: "mysql-bin.000001", Offset: 4294967396},
+		Timestamp:    1407805592,
+		GTID:         "439192bd-f37c-11e4-bbeb-0242ac11035a:4",
+		ServerID:     62344,
+		Events: []*StreamEvent{
+			{
+				Type:      5,
+				Table:     &TableName{Db: "db", Table: "t"},
+				Timestamp: 1407805592,
+				RowValues: []*RowData{
+					{Columns: []*ColumnData{
+						{Field: "id", Type: 8, Data: []byte("18446744073709551615")},
+						{Field: "name", Type: 15, Data: []byte("文\x00")},
+						{Field: "deleted", Type: 1, IsNull: true},
+						{Field: "created", Type: 17, IsEmpty: true},
+					}},
+				},
+				RowIdentifies: []*RowData{
+					{Columns: []*ColumnData{{Field: "id", Type: 8, Data: []byte("1")}}},
+				},
+				PrimaryKey: []string{"id", "name"},
+			},
+			{Type: 8, Table: &TableName{}, SQL: "alter table t add c int", Timestamp: -1},
+		},
+		Chunk: &ChunkInfo{
+			TransactionID: "439192bd-f37c-11e4-bbeb-0242ac11035a:4",
+			Sequence:      300,
+			Last:          true,
+			Rollback:      true,
+		},
+	}
+
+	data, err := in.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal err: %v", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("Marshal\nwant: % x\nout:  % x", want, data)
+	}
+	var out Transaction
+	if err = out.Unmarshal(want); err != nil {
+		t.Fatalf("Unmarshal err: %v", err)
+	}
+	if !reflect.DeepEqual(in, &out) {
+		t.Fatalf("Unmarshal = %+v, want %+v", out, in)
+	}
+}
+
+func TestTransaction_UnmarshalUnknownFields(t *testing.T) {
+	data, _ := (&Transaction{Version: ProtoVersion, GTID: "g"}).Marshal()
+	//新版本增加的字段: 100 varint, 101 bytes, 102 fixed64, 103 fixed32
+	data = appendVarintField(data, 100, 1)
+	data = appendStringField(data, 101, "new")
+	data = appendTag(data, 102, wireFixed64)
+	data = append(data, 1, 2, 3, 4, 5, 6, 7, 8)
+	data = appendTag(data, 103, wireFixed32)
+	data = append(data, 1, 2, 3, 4)
+
+	var out Transaction
+	if err := out.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal err: %v", err)
+	}
+	if out.Version != ProtoVersion || out.GTID != "g" {
+		t.Errorf("Unmarshal = %+v", out)
+	}
+}
+
+func TestTransaction_UnmarshalError(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated varint", []byte{0x08, 0x80}},
+		{"truncated bytes", []byte{0x2a, 0x05, 'a'}},
+		{"wrong wire type", []byte{0x2a, 0x01, 0x00, 0x08}},
+		{"field zero", []byte{0x00, 0x01}},
+		{"invalid nested", []byte{0x12, 0x02, 0x0a, 0x05}},
+		{"unsupported wire type", []byte{0x0b}},
+	}
+	for _, tc := range testCases {
+		var out Transaction
+		if err := out.Unmarshal(tc.data); err == nil {
+			t.Errorf("%s: Unmarshal expect err", tc.name)
+		}
+	}
+}
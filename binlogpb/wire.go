@@ -0,0 +1,170 @@
+package binlogpb
+
+import (
+	"errors"
+	"fmt"
+)
+
+//protobuf的wire type
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("protobuf message truncated")
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+//appendVarintField proto3中值为0的字段不写入
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return appendVarint(b, v)
+}
+
+func appendBoolField(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarintField(b, field, 1)
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendStringField(b []byte, field int, v string) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+//appendMessageField 嵌套的消息即使为空也需要写入，用于区分nil
+func appendMessageField(b []byte, field int, msg []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+//decoder 按照字段读取protobuf消息
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) done() bool {
+	return d.pos >= len(d.data)
+}
+
+func (d *decoder) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if d.pos >= len(d.data) {
+			return 0, errTruncated
+		}
+		c := d.data[d.pos]
+		d.pos++
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("protobuf varint overflow")
+}
+
+//next 读取下一个字段的编号以及wire type
+func (d *decoder) next() (int, int, error) {
+	tag, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	field, wireType := int(tag>>3), int(tag&7)
+	if field <= 0 {
+		return 0, 0, fmt.Errorf("protobuf invalid field number %d", field)
+	}
+	return field, wireType, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+//skip 跳过未知的字段，用于兼容新版本增加的字段
+func (d *decoder) skip(wireType int) error {
+	switch wireType {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed64:
+		d.pos += 8
+	case wireFixed32:
+		d.pos += 4
+	default:
+		return fmt.Errorf("protobuf unsupported wire type %d", wireType)
+	}
+	if d.pos > len(d.data) {
+		return errTruncated
+	}
+	return nil
+}
+
+//expect 检查字段的wire type
+func expect(field, wireType, want int) error {
+	if wireType != want {
+		return fmt.Errorf("protobuf field %d wire type %d, want %d", field, wireType, want)
+	}
+	return nil
+}
+
+func (d *decoder) varintField(field, wireType int) (uint64, error) {
+	if err := expect(field, wireType, wireVarint); err != nil {
+		return 0, err
+	}
+	return d.varint()
+}
+
+func (d *decoder) bytesField(field, wireType int) ([]byte, error) {
+	if err := expect(field, wireType, wireBytes); err != nil {
+		return nil, err
+	}
+	return d.bytes()
+}
+
+func (d *decoder) stringField(field, wireType int) (string, error) {
+	b, err := d.bytesField(field, wireType)
+	return string(b), err
+}
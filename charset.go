@@ -0,0 +1,161 @@
+package binlog
+
+import (
+	"fmt"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+//CharsetDecoder 将某个字符集编码的数据转为UTF-8
+type CharsetDecoder func(data []byte) ([]byte, error)
+
+var charsetDecoders = struct {
+	sync.RWMutex
+	decoders map[string]CharsetDecoder
+}{
+	decoders: map[string]CharsetDecoder{
+		"latin1":  decodeLatin1,
+		"ucs2":    decodeUTF16BE,
+		"utf16":   decodeUTF16BE,
+		"utf16le": decodeUTF16LE,
+		"utf32":   decodeUTF32,
+	},
+}
+
+//utf8Charsets 与UTF-8兼容不需要转码的字符集，binary代表二进制数据
+var utf8Charsets = map[string]bool{
+	"":        true,
+	"binary":  true,
+	"ascii":   true,
+	"utf8":    true,
+	"utf8mb3": true,
+	"utf8mb4": true,
+}
+
+//RegisterCharsetDecoder 注册mysql字符集(如gbk)的解码函数，decoder为nil时取消注册，
+//内置了latin1、ucs2、utf16、utf16le以及utf32，gbk、big5等字符集可以使用golang.org/x/text中的解码器注册，
+//没有解码函数的字符集保持原始数据
+func RegisterCharsetDecoder(charset string, decoder CharsetDecoder) {
+	charsetDecoders.Lock()
+	defer charsetDecoders.Unlock()
+	if decoder == nil {
+		delete(charsetDecoders.decoders, charset)
+		return
+	}
+	charsetDecoders.decoders[charset] = decoder
+}
+
+//charsetDecoder 获取字符集的解码函数，与UTF-8兼容以及没有注册的字符集返回false
+func charsetDecoder(charset string) (CharsetDecoder, bool) {
+	if utf8Charsets[charset] {
+		return nil, false
+	}
+	charsetDecoders.RLock()
+	defer charsetDecoders.RUnlock()
+	decoder, ok := charsetDecoders.decoders[charset]
+	return decoder, ok
+}
+
+//columnCharsets 获取每一列的字符集，只有字符类型的列有字符集，优先使用TABLE_MAP_EVENT中的排序规则，
+//没有时使用实现了MysqlCharsetColumn的列的字符集
+func columnCharsets(tm *replication.TableMap, table MysqlTable) []string {
+	charsets := make([]string, len(tm.Types))
+	for c, column := range table.Columns() {
+		if !replication.IsCharacterType(tm.Types[c], tm.Metadata[c]) {
+			continue
+		}
+		if tm.ColumnCollations != nil {
+			charsets[c] = dump.CollationCharset(tm.ColumnCollations[c])
+		}
+		if cc, ok := column.(MysqlCharsetColumn); ok && charsets[c] == "" {
+			charsets[c] = cc.Charset()
+		}
+	}
+	return charsets
+}
+
+//decodeString 记录字符类型列的字符集，并将非UTF-8字符集的数据转为UTF-8
+func (tc *tableCache) decodeString(c int, column *ColumnData) error {
+	if tc.charsets == nil || tc.charsets[c] == "" {
+		return nil
+	}
+	column.Charset = tc.charsets[c]
+	decoder, ok := charsetDecoder(column.Charset)
+	if !ok {
+		return nil
+	}
+	data, err := decoder(column.Data)
+	if err != nil {
+		return fmt.Errorf("decode column %v with charset %v fail. err: %v", column.Filed, column.Charset, err)
+	}
+	if tc.options != nil && tc.options.keepRawBytes {
+		column.Raw = column.Data
+	}
+	column.Data = data
+	return nil
+}
+
+//decodeLatin1 mysql的latin1实际上是cp1252，0x80到0x9f中未定义的字节按照latin1处理
+func decodeLatin1(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data)+len(data)/2)
+	for _, b := range data {
+		r := rune(b)
+		if b >= 0x80 && b < 0xa0 && cp1252[b-0x80] != 0 {
+			r = cp1252[b-0x80]
+		}
+		out = appendRune(out, r)
+	}
+	return out, nil
+}
+
+var cp1252 = [32]rune{
+	0x20ac, 0, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017d, 0,
+	0, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0, 0x017e, 0x0178,
+}
+
+func decodeUTF16BE(data []byte) ([]byte, error) {
+	return decodeUTF16(data, func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) })
+}
+
+func decodeUTF16LE(data []byte) ([]byte, error) {
+	return decodeUTF16(data, func(b []byte) uint16 { return uint16(b[1])<<8 | uint16(b[0]) })
+}
+
+func decodeUTF16(data []byte, read func([]byte) uint16) ([]byte, error) {
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("the length of utf16 data(%d) is odd", len(data))
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i < len(data); i += 2 {
+		units = append(units, read(data[i:]))
+	}
+	out := make([]byte, 0, len(data))
+	for _, r := range utf16.Decode(units) {
+		out = appendRune(out, r)
+	}
+	return out, nil
+}
+
+func decodeUTF32(data []byte) ([]byte, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("the length of utf32 data(%d) is not a multiple of 4", len(data))
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i += 4 {
+		r := rune(data[i])<<24 | rune(data[i+1])<<16 | rune(data[i+2])<<8 | rune(data[i+3])
+		out = appendRune(out, r)
+	}
+	return out, nil
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
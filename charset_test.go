@@ -0,0 +1,176 @@
+package binlog
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/onlyac0611/binlog/replication"
+)
+
+func TestCharsetDecoder(t *testing.T) {
+	testCases := []struct {
+		charset string
+		input   []byte
+		want    string
+		wantErr bool
+	}{
+		{charset: "latin1", input: []byte("caf\xe9 \x80\x81"), want: "café €\u0081"},
+		{charset: "ucs2", input: []byte{0x00, 'a', 0x4e, 0x2d}, want: "a中"},
+		{charset: "utf16", input: []byte{0xd8, 0x3d, 0xde, 0x00}, want: "😀"},
+		{charset: "utf16le", input: []byte{'a', 0x00, 0x2d, 0x4e}, want: "a中"},
+		{charset: "utf32", input: []byte{0, 0, 0, 'a', 0, 1, 0xf6, 0x00}, want: "a😀"},
+		{charset: "utf16", input: []byte{0x00}, wantErr: true},
+		{charset: "utf32", input: []byte{0, 0, 0}, wantErr: true},
+	}
+	for _, v := range testCases {
+		decoder, ok := charsetDecoder(v.charset)
+		if !ok {
+			t.Fatalf("charsetDecoder(%v) not found", v.charset)
+		}
+		out, err := decoder(v.input)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("decode %v wantErr: %v err: %v", v.charset, v.wantErr, err)
+		}
+		if err == nil && string(out) != v.want {
+			t.Fatalf("decode %v want: %q out: %q", v.charset, v.want, out)
+		}
+	}
+
+	for _, charset := range []string{"", "binary", "ascii", "utf8", "utf8mb4", "gbk"} {
+		if _, ok := charsetDecoder(charset); ok {
+			t.Fatalf("charsetDecoder(%v) should not transcode", charset)
+		}
+	}
+}
+
+func TestRegisterCharsetDecoder(t *testing.T) {
+	RegisterCharsetDecoder("gbk", func(data []byte) ([]byte, error) {
+		return bytes.ToUpper(data), nil
+	})
+	decoder, ok := charsetDecoder("gbk")
+	if !ok {
+		t.Fatalf("charsetDecoder(gbk) not found after RegisterCharsetDecoder")
+	}
+	if out, _ := decoder([]byte("abc")); string(out) != "ABC" {
+		t.Fatalf("registered decoder out: %s", out)
+	}
+	RegisterCharsetDecoder("gbk", nil)
+	if _, ok = charsetDecoder("gbk"); ok {
+		t.Fatalf("charsetDecoder(gbk) found after unregister")
+	}
+}
+
+type charsetColumn struct {
+	mysqlColumnAttribute
+	charset string
+}
+
+func (c *charsetColumn) Charset() string {
+	return c.charset
+}
+
+func (c *charsetColumn) Collation() string {
+	return ""
+}
+
+func TestColumnCharsets(t *testing.T) {
+	tm := &replication.TableMap{
+		Types:    []byte{replication.TypeLong, replication.TypeVarchar, replication.TypeBlob},
+		Metadata: []uint16{0, 384, 2},
+	}
+	table := &mysqlTableInfo{
+		columns: []MysqlColumn{
+			&charsetColumn{mysqlColumnAttribute: mysqlColumnAttribute{field: "id"}, charset: "latin1"},
+			&charsetColumn{mysqlColumnAttribute: mysqlColumnAttribute{field: "message"}, charset: "utf16"},
+			&mysqlColumnAttribute{field: "data"},
+		},
+	}
+
+	testCases := []struct {
+		collations []uint64
+		want       []string
+	}{
+		{collations: nil, want: []string{"", "utf16", ""}},
+		{collations: []uint64{0, 8, 63}, want: []string{"", "latin1", "binary"}},
+		{collations: []uint64{0, 0, 0}, want: []string{"", "utf16", ""}},
+	}
+	for _, v := range testCases {
+		tm.ColumnCollations = v.collations
+		if out := columnCharsets(tm, table); !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("columnCharsets with %v want: %q out: %q", v.collations, v.want, out)
+		}
+	}
+}
+
+func TestRowStreamer_parseEvents_Charset(t *testing.T) {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	tableID := uint64(0x102030405060)
+	tm := &replication.TableMap{
+		Database:         "vt_test_keyspace",
+		Name:             "vt_a",
+		Types:            []byte{replication.TypeLong, replication.TypeVarchar},
+		CanBeNull:        replication.NewServerBitmap(2),
+		Metadata:         []uint16{0, 384},
+		ColumnCollations: []uint64{0, 8},
+	}
+	insertRows := replication.Rows{
+		DataColumns: replication.NewServerBitmap(2),
+		Rows: []replication.Row{
+			{
+				NullColumns: replication.NewServerBitmap(2),
+				Data:        []byte{0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 'c', 'a', 'f', 0xe9},
+			},
+		},
+	}
+	insertRows.DataColumns.Set(0, true)
+	insertRows.DataColumns.Set(1, true)
+	input := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, uint64(testBinlogPosParseEvents.Offset), testBinlogPosParseEvents.Filename),
+		replication.NewFormatDescriptionEvent(f, s),
+		replication.NewTableMapEvent(f, s, tableID, tm),
+		replication.NewQueryEvent(f, s, replication.Query{Database: "vt_test_keyspace", SQL: "BEGIN"}),
+		replication.NewWriteRowsEvent(f, s, tableID, insertRows),
+		replication.NewXIDEvent(f, s),
+	}
+
+	for _, keepRaw := range []bool{false, true} {
+		r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.SetStartBinlogPosition(testBinlogPosParseEvents)
+		r.SetKeepRawBytes(keepRaw)
+		var out *Transaction
+		r.sendTransaction = func(tran *Transaction) error {
+			out = tran
+			return nil
+		}
+
+		events := make(chan replication.BinlogEvent, len(input))
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+		if _, err = r.parseEvents(context.Background(), events); err != ErrStreamEOF {
+			t.Fatalf("parseEvents err != %v, err: %v", ErrStreamEOF, err)
+		}
+
+		c := out.Events[0].RowValues[0].Columns[1]
+		if string(c.Data) != "café" || c.Charset != "latin1" {
+			t.Fatalf("latin1 column want: café out: %q charset: %v", c.Data, c.Charset)
+		}
+		wantRaw := ""
+		if keepRaw {
+			wantRaw = "caf\xe9"
+		}
+		if string(c.Raw) != wantRaw {
+			t.Fatalf("keepRaw %v Raw want: %q out: %q", keepRaw, wantRaw, c.Raw)
+		}
+		if id := out.Events[0].RowValues[0].Columns[0]; id.Charset != "" || string(id.Data) != "1" {
+			t.Fatalf("int column out: %s charset: %v", id.Data, id.Charset)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package binlog
+
+import (
+	"fmt"
+
+	"github.com/onlyac0611/binlog/replication"
+)
+
+//ChunkInfo 分块发送的大事务中一块的信息，同一个事务的所有块有相同的TransactionID，
+//Sequence从0开始递增，最后一块的Last为true
+type ChunkInfo struct {
+	TransactionID string `json:"transactionId"` //事务的标识，开启GTID时为GTID，否则为事务开始的binlog位置
+	Sequence      int    `json:"sequence"`      //块的序号，从0开始
+	Last          bool   `json:"last"`          //是否是事务的最后一块
+	Rollback      bool   `json:"rollback"`      //事务是否以ROLLBACK结束，只在最后一块中设置
+}
+
+//chunkOptions 大事务的分块选项
+type chunkOptions struct {
+	maxEvents int
+	maxBytes  int64
+}
+
+//enabled 是否开启了分块
+func (o chunkOptions) enabled() bool {
+	return o.maxEvents > 0 || o.maxBytes > 0
+}
+
+//reached 事务中已缓存的StreamEvent数量或者rows event的字节数是否到达了阈值
+func (o chunkOptions) reached(events int, bytes int64) bool {
+	if o.maxEvents > 0 && events >= o.maxEvents {
+		return true
+	}
+	return o.maxBytes > 0 && bytes >= o.maxBytes
+}
+
+//SetChunkSize 设置大事务的分块发送，事务中缓存的StreamEvent数量到达maxEvents或者rows event的字节数到达maxBytes时，
+//已缓存的部分作为一个Transaction发送，Transaction.Chunk标识了事务以及块的序号，为0时不使用对应的阈值，
+//都为0时不分块，默认不分块。
+//除最后一块外，NowPosition以及NextPosition都是事务开始的位置，BinlogPosition也只在最后一块发送后才前进，
+//所以从checkpoint重新开始时会从事务开始的位置重新发送整个事务，消费者需要自己处理已经收到的块
+func (s *RowStreamer) SetChunkSize(maxEvents int, maxBytes int64) {
+	s.chunkOptions = chunkOptions{maxEvents: maxEvents, maxBytes: maxBytes}
+}
+
+//newChunkInfo 创建事务的第一块的ChunkInfo，start为事务开始的binlog位置
+func newChunkInfo(start Position, gtid replication.GTID) *ChunkInfo {
+	id := fmt.Sprintf("%v:%v", start.Filename, start.Offset)
+	if gtid != nil {
+		id = gtid.String()
+	}
+	return &ChunkInfo{TransactionID: id}
+}
@@ -0,0 +1,247 @@
+package binlog
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/onlyac0611/binlog/replication"
+)
+
+//getChunkInputData 两个事务，第一个事务有GTID以及5个insert，结束位置为100，
+//第二个事务没有GTID，有3个insert，以ROLLBACK结束，结束位置为200
+func getChunkInputData() []replication.BinlogEvent {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	s.ServerID = 62344
+
+	tableID := uint64(0x102030405060)
+	tm := &replication.TableMap{
+		Flags:     0x8090,
+		Database:  "vt_test_keyspace",
+		Name:      "vt_a",
+		Types:     []byte{replication.TypeLong, replication.TypeVarchar},
+		CanBeNull: replication.NewServerBitmap(2),
+		Metadata:  []uint16{0, 384},
+	}
+	tm.CanBeNull.Set(1, true)
+
+	insertRows := replication.Rows{
+		Flags:       0x1234,
+		DataColumns: replication.NewServerBitmap(2),
+		Rows: []replication.Row{
+			{
+				NullColumns: replication.NewServerBitmap(2),
+				Data: []byte{
+					0x10, 0x20, 0x30, 0x40, // long
+					0x04, 0x00, // len('abcd')
+					'a', 'b', 'c', 'd', // 'abcd'
+				},
+			},
+		},
+	}
+	insertRows.DataColumns.Set(0, true)
+	insertRows.DataColumns.Set(1, true)
+	begin := replication.Query{Database: "vt_test_keyspace", SQL: "BEGIN"}
+
+	events := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, uint64(testBinlogPosParseEvents.Offset), testBinlogPosParseEvents.Filename),
+		replication.NewFormatDescriptionEvent(f, s),
+		replication.NewMySQL56GTIDEvent(f, s, replication.Mysql56GTID{Server: testStopSID, Sequence: 1}),
+		replication.NewQueryEvent(f, s, begin),
+		replication.NewTableMapEvent(f, s, tableID, tm),
+	}
+	for i := 0; i < 5; i++ {
+		events = append(events, replication.NewWriteRowsEvent(f, s, tableID, insertRows))
+	}
+	s.LogPosition = 100
+	events = append(events, replication.NewXIDEvent(f, s))
+
+	events = append(events,
+		replication.NewQueryEvent(f, s, begin),
+		replication.NewTableMapEvent(f, s, tableID, tm),
+	)
+	for i := 0; i < 3; i++ {
+		events = append(events, replication.NewWriteRowsEvent(f, s, tableID, insertRows))
+	}
+	s.LogPosition = 200
+	events = append(events, replication.NewQueryEvent(f, s, replication.Query{
+		Database: "vt_test_keyspace",
+		SQL:      "ROLLBACK"}))
+	return events
+}
+
+func TestRowStreamer_parseEvents_Chunk(t *testing.T) {
+	gtid := replication.Mysql56GTID{Server: testStopSID, Sequence: 1}.String()
+	pos := func(offset int64) Position {
+		return Position{Filename: testBinlogPosParseEvents.Filename, Offset: offset}
+	}
+	//rows event去掉CRC32校验和之后的大小，两个rows event到达maxBytes
+	rowsSize := int64(len(getChunkInputData()[5].Bytes()) - 4)
+	type chunkWant struct {
+		events int
+		now    Position
+		next   Position
+		chunk  *ChunkInfo
+	}
+	testCases := []struct {
+		maxEvents int
+		maxBytes  int64
+		want      []chunkWant
+	}{
+		{
+			want: []chunkWant{
+				{events: 5, now: pos(0), next: pos(100)},
+				{events: 0, now: pos(100), next: pos(200)},
+			},
+		},
+		{
+			maxEvents: 2,
+			want: []chunkWant{
+				{events: 2, now: pos(0), next: pos(0), chunk: &ChunkInfo{TransactionID: gtid, Sequence: 0}},
+				{events: 2, now: pos(0), next: pos(0), chunk: &ChunkInfo{TransactionID: gtid, Sequence: 1}},
+				{events: 1, now: pos(0), next: pos(100), chunk: &ChunkInfo{TransactionID: gtid, Sequence: 2, Last: true}},
+				{events: 2, now: pos(100), next: pos(100), chunk: &ChunkInfo{TransactionID: "binlog.000005:100"}},
+				{events: 0, now: pos(100), next: pos(200), chunk: &ChunkInfo{TransactionID: "binlog.000005:100",
+					Sequence: 1, Last: true, Rollback: true}},
+			},
+		},
+		{
+			maxEvents: 5,
+			want: []chunkWant{
+				{events: 5, now: pos(0), next: pos(0), chunk: &ChunkInfo{TransactionID: gtid, Sequence: 0}},
+				{events: 0, now: pos(0), next: pos(100), chunk: &ChunkInfo{TransactionID: gtid, Sequence: 1, Last: true}},
+				{events: 0, now: pos(100), next: pos(200)},
+			},
+		},
+		{
+			maxBytes: 2*rowsSize - 1,
+			want: []chunkWant{
+				{events: 2, now: pos(0), next: pos(0), chunk: &ChunkInfo{TransactionID: gtid, Sequence: 0}},
+				{events: 2, now: pos(0), next: pos(0), chunk: &ChunkInfo{TransactionID: gtid, Sequence: 1}},
+				{events: 1, now: pos(0), next: pos(100), chunk: &ChunkInfo{TransactionID: gtid, Sequence: 2, Last: true}},
+				{events: 2, now: pos(100), next: pos(100), chunk: &ChunkInfo{TransactionID: "binlog.000005:100"}},
+				{events: 0, now: pos(100), next: pos(200), chunk: &ChunkInfo{TransactionID: "binlog.000005:100",
+					Sequence: 1, Last: true, Rollback: true}},
+			},
+		},
+	}
+
+	for _, v := range testCases {
+		r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.SetStartBinlogPosition(testBinlogPosParseEvents)
+		r.SetChunkSize(v.maxEvents, v.maxBytes)
+
+		var trans []*Transaction
+		r.sendTransaction = func(tran *Transaction) error {
+			//只有事务的最后一块发送后进度才前进
+			progress, _ := r.progress.Load().(streamProgress)
+			if progress.pos.Compare(tran.NowPosition) > 0 {
+				t.Fatalf("progress %+v is after transaction %+v", progress.pos, tran.NowPosition)
+			}
+			trans = append(trans, tran)
+			return nil
+		}
+
+		input := getChunkInputData()
+		events := make(chan replication.BinlogEvent, len(input))
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+
+		if _, err = r.parseEvents(context.Background(), events); err != ErrStreamEOF {
+			t.Fatalf("parseEvents want err: %v, out: %v", ErrStreamEOF, err)
+		}
+		if len(trans) != len(v.want) {
+			t.Fatalf("SetChunkSize(%v, %v) transaction count want: %v out: %v",
+				v.maxEvents, v.maxBytes, len(v.want), len(trans))
+		}
+		for i, want := range v.want {
+			tran := trans[i]
+			if len(tran.Events) != want.events || tran.NowPosition != want.now || tran.NextPosition != want.next {
+				t.Fatalf("SetChunkSize(%v, %v) transaction %d want: %+v out: events %v now %+v next %+v",
+					v.maxEvents, v.maxBytes, i, want, len(tran.Events), tran.NowPosition, tran.NextPosition)
+			}
+			if (tran.Chunk == nil) != (want.chunk == nil) || (tran.Chunk != nil && *tran.Chunk != *want.chunk) {
+				t.Fatalf("SetChunkSize(%v, %v) transaction %d chunk want: %+v out: %+v",
+					v.maxEvents, v.maxBytes, i, want.chunk, tran.Chunk)
+			}
+		}
+		if pos := r.BinlogPosition(); pos != testBinlogPosParseEvents {
+			t.Fatalf("BinlogPosition should not be changed by parseEvents, out: %+v", pos)
+		}
+	}
+}
+
+func TestRowStreamer_parseEvents_ChunkStop(t *testing.T) {
+	//事务的时间已经超过结束的时间戳时不发送任何块
+	r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewRowStreamer err: %v", err)
+	}
+	r.SetStartBinlogPosition(testBinlogPosParseEvents)
+	r.SetEndTimestamp(1)
+	r.SetChunkSize(1, 0)
+	r.sendTransaction = func(tran *Transaction) error {
+		t.Fatalf("sendTransaction should not be called, transaction: %+v", tran)
+		return nil
+	}
+
+	input := getChunkInputData()
+	events := make(chan replication.BinlogEvent, len(input))
+	for i := range input {
+		events <- input[i]
+	}
+	close(events)
+	if _, err = r.parseEvents(context.Background(), events); err != ErrStopConditionReached {
+		t.Fatalf("parseEvents want err: %v, out: %v", ErrStopConditionReached, err)
+	}
+}
+
+func TestTransaction_ChunkJSON(t *testing.T) {
+	tran := NewTransaction(testBinlogPosParseEvents, testBinlogPosParseEvents, 1407805592, nil)
+	tran.Chunk = &ChunkInfo{TransactionID: "binlog.000005:4", Sequence: 3}
+
+	data, err := tran.MarshalJSONV2()
+	if err != nil {
+		t.Fatalf("MarshalJSONV2 err: %v", err)
+	}
+	var out Transaction
+	if err = out.UnmarshalJSONV2(data); err != nil {
+		t.Fatalf("UnmarshalJSONV2 err: %v", err)
+	}
+	if out.Chunk == nil || *out.Chunk != *tran.Chunk {
+		t.Fatalf("UnmarshalJSONV2 chunk want: %+v out: %+v", tran.Chunk, out.Chunk)
+	}
+
+	data, err = tran.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON err: %v", err)
+	}
+	var v1 struct {
+		Chunk *ChunkInfo `json:"chunk"`
+	}
+	if err = json.Unmarshal(data, &v1); err != nil {
+		t.Fatalf("json.Unmarshal err: %v", err)
+	}
+	if v1.Chunk == nil || *v1.Chunk != *tran.Chunk {
+		t.Fatalf("MarshalJSON chunk want: %+v out: %s", tran.Chunk, data)
+	}
+
+	//没有分块的事务中没有chunk字段
+	tran.Chunk = nil
+	if data, err = tran.MarshalJSONV2(); err != nil {
+		t.Fatalf("MarshalJSONV2 err: %v", err)
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("json.Unmarshal err: %v", err)
+	}
+	if _, ok := m["chunk"]; ok {
+		t.Fatalf("MarshalJSONV2 should not have chunk: %s", data)
+	}
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/onlyac0611/binlog"
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+//inspectConfig inspect子命令的参数
+type inspectConfig struct {
+	files    []string
+	summary  bool
+	top      int
+	location *time.Location
+}
+
+func parseInspectFlags(args []string, stderr io.Writer) (*inspectConfig, error) {
+	fs := flag.NewFlagSet("binlog inspect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: binlog inspect [flags] file...\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	cfg := &inspectConfig{}
+	var tz string
+	fs.BoolVar(&cfg.summary, "summary", false, "print the row changes per table and the largest transactions "+
+		"instead of the events")
+	fs.IntVar(&cfg.top, "top", 10, "number of the largest transactions printed by -summary")
+	fs.StringVar(&tz, "tz", "UTC", "time zone of event times and TIMESTAMP columns, such as Local or Asia/Shanghai")
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	cfg.files = fs.Args()
+	if len(cfg.files) == 0 {
+		return nil, fmt.Errorf("no binlog file is given")
+	}
+	if cfg.top < 0 {
+		return nil, fmt.Errorf("-top must not be negative")
+	}
+
+	var err error
+	if cfg.location, err = time.LoadLocation(tz); err != nil {
+		return nil, fmt.Errorf("invalid -tz %v: %v", tz, err)
+	}
+	return cfg, nil
+}
+
+func runInspect(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, err := parseInspectFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(stdout)
+	in := newInspector(w, cfg)
+	for _, file := range cfg.files {
+		if err = in.inspectFile(ctx, file); err != nil {
+			break
+		}
+	}
+	if err == nil && cfg.summary {
+		in.stats.write(w, cfg.location)
+	}
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+//inspector 逐个读取本地binlog文件中的event，输出每个event或者汇总的统计
+type inspector struct {
+	w        io.Writer
+	listing  bool
+	location *time.Location
+	stats    *inspectStats
+
+	//以下为当前文件的状态
+	file   string
+	format replication.BinlogFormat
+	tables map[uint64]*replication.TableMap
+	tran   *tranStats
+}
+
+func newInspector(w io.Writer, cfg *inspectConfig) *inspector {
+	return &inspector{
+		w:        w,
+		listing:  !cfg.summary,
+		location: cfg.location,
+		stats:    newInspectStats(cfg.top),
+	}
+}
+
+func (in *inspector) inspectFile(ctx context.Context, path string) error {
+	reader, err := replication.NewBinlogReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	in.file = filepath.Base(path)
+	in.format = replication.BinlogFormat{}
+	in.tables = make(map[uint64]*replication.TableMap)
+	in.tran = nil
+	in.stats.files++
+	if in.listing {
+		fmt.Fprintf(in.w, "# file %v\n", in.file)
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		offset := reader.Position()
+		ev, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v:%v %v", in.file, offset, err)
+		}
+		if err = in.inspectEvent(offset, ev); err != nil {
+			return fmt.Errorf("%v:%v %v", in.file, offset, err)
+		}
+	}
+}
+
+//inspectEvent 输出一个event，格式为: 位置 类型 时间 server_id 大小 详细信息，行数据的event之后输出行数据
+func (in *inspector) inspectEvent(offset int64, ev replication.BinlogEvent) error {
+	if !ev.IsValid() {
+		return fmt.Errorf("invalid event")
+	}
+	size := int64(len(ev.Bytes()))
+	name := replication.EventTypeName(ev)
+	in.stats.observeEvent(name, ev.Timestamp())
+
+	var details string
+	var rowsEvent *inspectRows
+	if ev.IsFormatDescription() {
+		f, err := ev.Format()
+		if err != nil {
+			return err
+		}
+		in.format = f
+		details = fmt.Sprintf("version=%v checksum=%v", f.ServerVersion, checksumName(f.ChecksumAlgorithm))
+	} else {
+		if in.format.IsZero() {
+			return fmt.Errorf("%v event before FORMAT_DESCRIPTION_EVENT", name)
+		}
+		if err := replication.VerifyChecksum(in.format, ev); err != nil {
+			in.stats.checksumErrors++
+			in.writeEvent(offset, name, ev, size, err.Error())
+			return nil
+		}
+		stripped, _, err := ev.StripChecksum(in.format)
+		if err != nil {
+			return err
+		}
+		if details, rowsEvent, err = in.describe(stripped, offset, size); err != nil {
+			return err
+		}
+	}
+
+	in.writeEvent(offset, name, ev, size, details)
+	if rowsEvent != nil && in.listing {
+		in.writeRows(rowsEvent)
+	}
+	return nil
+}
+
+func (in *inspector) writeEvent(offset int64, name string, ev replication.BinlogEvent, size int64, details string) {
+	if !in.listing {
+		return
+	}
+	fmt.Fprintf(in.w, "# at %-10d %-14s %v server_id=%v size=%v", offset, name,
+		in.formatTimestamp(ev.Timestamp()), ev.ServerID(), size)
+	if details != "" {
+		fmt.Fprintf(in.w, "  %v", details)
+	}
+	fmt.Fprintln(in.w)
+}
+
+//describe 解析event的详细信息，同时记录事务以及行数据的统计
+func (in *inspector) describe(ev replication.BinlogEvent, offset, size int64) (string, *inspectRows, error) {
+	f := in.format
+	switch {
+	case ev.IsGTID():
+		gtid, hasBegin, err := ev.GTID(f)
+		if err != nil {
+			return "", nil, err
+		}
+		in.beginTran(offset)
+		in.tran.gtid = gtid.String()
+		in.tran.begun = hasBegin
+		in.tran.add(size)
+		return "gtid=" + gtid.String(), nil, nil
+
+	case ev.IsQuery():
+		q, err := ev.Query(f)
+		if err != nil {
+			return "", nil, err
+		}
+		switch typ := binlog.GetStatementCategory(q.SQL); typ {
+		case binlog.StatementBegin:
+			if in.tran == nil {
+				in.beginTran(offset)
+			}
+			in.tran.begun = true
+			in.tran.add(size)
+		case binlog.StatementCommit, binlog.StatementRollback:
+			in.endTran(offset, size)
+		default:
+			//没有BEGIN的语句(如DDL)自成一个事务
+			if in.tran == nil || !in.tran.begun {
+				in.endTran(offset, size)
+			} else {
+				in.tran.add(size)
+			}
+		}
+		return fmt.Sprintf("db=%v query=%v", q.Database, strconv.Quote(q.SQL)), nil, nil
+
+	case ev.IsXID():
+		in.endTran(offset, size)
+		data := ev.Bytes()
+		if len(data) < int(f.HeaderLength)+8 {
+			return "", nil, nil
+		}
+		return fmt.Sprintf("xid=%v", binary.LittleEndian.Uint64(data[f.HeaderLength:])), nil, nil
+
+	case ev.IsRotate():
+		next, position, err := ev.Rotate(f)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("next=%v:%v", next, position), nil, nil
+
+	case ev.IsTableMap():
+		tableID := ev.TableID(f)
+		tm, err := ev.TableMap(f)
+		if err != nil {
+			return "", nil, err
+		}
+		in.tables[tableID] = tm
+		in.addToTran(size)
+		return fmt.Sprintf("table_id=%v table=%v columns=%v", tableID, tableName(tm), len(tm.Types)), nil, nil
+
+	case ev.IsWriteRows(), ev.IsUpdateRows(), ev.IsDeleteRows():
+		tableID := ev.TableID(f)
+		//没有BEGIN的行数据(自动提交)由之后的XID_EVENT结束
+		if in.tran == nil {
+			in.beginTran(offset)
+		}
+		in.tran.add(size)
+		tm, ok := in.tables[tableID]
+		if !ok {
+			return fmt.Sprintf("table_id=%v unknown table", tableID), nil, nil
+		}
+		rows, err := ev.Rows(f, tm)
+		if err != nil {
+			return "", nil, err
+		}
+		r := &inspectRows{tableMap: tm, rows: rows}
+		switch {
+		case ev.IsWriteRows():
+			r.typ = binlog.StatementInsert
+		case ev.IsUpdateRows():
+			r.typ = binlog.StatementUpdate
+		default:
+			r.typ = binlog.StatementDelete
+		}
+		in.stats.observeRows(tableName(tm), r.typ, len(rows.Rows))
+		in.tran.rows += len(rows.Rows)
+		return fmt.Sprintf("table_id=%v table=%v rows=%v", tableID, tableName(tm), len(rows.Rows)), r, nil
+	}
+
+	in.addToTran(size)
+	return "", nil, nil
+}
+
+//beginTran 开始一个新的事务，之前没有结束的事务被丢弃
+func (in *inspector) beginTran(offset int64) {
+	in.tran = &tranStats{file: in.file, offset: offset}
+}
+
+//addToTran 将event计入当前的事务，不在事务中时忽略
+func (in *inspector) addToTran(size int64) {
+	if in.tran != nil {
+		in.tran.add(size)
+	}
+}
+
+//endTran 将结束事务的event计入当前的事务并结束事务，不在事务中时该event自成一个事务
+func (in *inspector) endTran(offset, size int64) {
+	if in.tran == nil {
+		in.beginTran(offset)
+	}
+	in.tran.add(size)
+	in.tran.end = offset + size
+	in.stats.addTran(in.tran)
+	in.tran = nil
+}
+
+func (in *inspector) formatTimestamp(ts uint32) string {
+	return time.Unix(int64(ts), 0).In(in.location).Format(timeLayout)
+}
+
+//inspectRows 行数据的event
+type inspectRows struct {
+	typ      binlog.StatementType
+	tableMap *replication.TableMap
+	rows     replication.Rows
+}
+
+//writeRows 按照mysqlbinlog -vv的格式输出行数据，列名使用@序号，整数按照有符号数输出
+func (in *inspector) writeRows(r *inspectRows) {
+	table := tableName(r.tableMap)
+	for i := range r.rows.Rows {
+		row := &r.rows.Rows[i]
+		var err error
+		switch r.typ {
+		case binlog.StatementInsert:
+			fmt.Fprintf(in.w, "### INSERT INTO %v\n### SET\n", table)
+			err = in.writeRowImage(r.tableMap, &r.rows.DataColumns, &row.NullColumns, row.Data)
+		case binlog.StatementUpdate:
+			fmt.Fprintf(in.w, "### UPDATE %v\n### WHERE\n", table)
+			err = in.writeRowImage(r.tableMap, &r.rows.IdentifyColumns, &row.NullIdentifyColumns, row.Identify)
+			if err == nil {
+				fmt.Fprintf(in.w, "### SET\n")
+				err = in.writeRowImage(r.tableMap, &r.rows.DataColumns, &row.NullColumns, row.Data)
+			}
+		case binlog.StatementDelete:
+			fmt.Fprintf(in.w, "### DELETE FROM %v\n### WHERE\n", table)
+			err = in.writeRowImage(r.tableMap, &r.rows.IdentifyColumns, &row.NullIdentifyColumns, row.Identify)
+		}
+		if err != nil {
+			fmt.Fprintf(in.w, "### error: %v\n", err)
+		}
+	}
+}
+
+func (in *inspector) writeRowImage(tm *replication.TableMap, columns, nulls *replication.Bitmap, data []byte) error {
+	pos := 0
+	valueIndex := 0
+	for c := 0; c < columns.Count(); c++ {
+		if !columns.Bit(c) {
+			continue
+		}
+		typ, metadata := tm.Types[c], tm.Metadata[c]
+		isNull := nulls.Bit(valueIndex)
+		valueIndex++
+
+		value := "NULL"
+		if !isNull {
+			cell, l, err := replication.CellBytesInLocation(data, pos, typ, metadata, false, in.location)
+			if err != nil {
+				return fmt.Errorf("column @%d: %v", c+1, err)
+			}
+			pos += l
+			value = formatCell(binlog.ColumnType(replication.RealType(typ, metadata)), cell)
+		}
+		fmt.Fprintf(in.w, "###   @%d=%v /* %v meta=%v nullable=%v is_null=%v */\n", c+1, value,
+			binlog.ColumnType(replication.RealType(typ, metadata)), metadata, boolInt(tm.CanBeNull.Bit(c)),
+			boolInt(isNull))
+	}
+	return nil
+}
+
+//formatCell 将列数据变为可读的文本:数值原样输出，bit与几何类型输出16进制，其他使用带转义的字符串
+func formatCell(typ binlog.ColumnType, data []byte) string {
+	switch {
+	case typ.IsInteger(), typ.IsFloat(), typ.IsDecimal(), typ == binlog.ColumnTypeYear,
+		typ == binlog.ColumnTypeEnum, typ == binlog.ColumnTypeSet:
+		return string(data)
+	case typ.IsBit(), typ.IsGeometry():
+		return "0x" + hex.EncodeToString(data)
+	}
+	buf := append([]byte{'\''}, dump.EscapeBytesBackslash(nil, data)...)
+	return string(append(buf, '\''))
+}
+
+func tableName(tm *replication.TableMap) string {
+	return "`" + tm.Database + "`.`" + tm.Name + "`"
+}
+
+func checksumName(alg byte) string {
+	switch alg {
+	case replication.BinlogChecksumAlgOff:
+		return "NONE"
+	case replication.BinlogChecksumAlgCRC32:
+		return "CRC32"
+	case replication.BinlogChecksumAlgUndef:
+		return "UNDEF"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", alg)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+//tranStats 一个事务的统计
+type tranStats struct {
+	file   string
+	offset int64
+	end    int64
+	gtid   string
+	begun  bool
+	events int
+	rows   int
+	size   int64
+}
+
+func (t *tranStats) add(size int64) {
+	t.events++
+	t.size += size
+}
+
+//tableStats 一个表的行变更统计
+type tableStats struct {
+	name   string
+	insert int
+	update int
+	delete int
+}
+
+func (t *tableStats) total() int {
+	return t.insert + t.update + t.delete
+}
+
+//inspectStats 汇总的统计，只保留最大的top个事务
+type inspectStats struct {
+	top            int
+	files          int
+	events         int
+	checksumErrors int
+	transactions   int
+	first          uint32
+	last           uint32
+	types          map[string]int
+	tables         map[string]*tableStats
+	largest        []*tranStats
+}
+
+func newInspectStats(top int) *inspectStats {
+	return &inspectStats{
+		top:    top,
+		types:  make(map[string]int),
+		tables: make(map[string]*tableStats),
+	}
+}
+
+func (s *inspectStats) observeEvent(name string, timestamp uint32) {
+	s.events++
+	s.types[name]++
+	//ROTATE_EVENT等人工生成的event时间为0
+	if timestamp == 0 {
+		return
+	}
+	if s.first == 0 || timestamp < s.first {
+		s.first = timestamp
+	}
+	if timestamp > s.last {
+		s.last = timestamp
+	}
+}
+
+func (s *inspectStats) observeRows(table string, typ binlog.StatementType, n int) {
+	t, ok := s.tables[table]
+	if !ok {
+		t = &tableStats{name: table}
+		s.tables[table] = t
+	}
+	switch typ {
+	case binlog.StatementInsert:
+		t.insert += n
+	case binlog.StatementUpdate:
+		t.update += n
+	case binlog.StatementDelete:
+		t.delete += n
+	}
+}
+
+//addTran 记录一个结束的事务，largest按照大小降序排列，大小相同时先出现的在前
+func (s *inspectStats) addTran(t *tranStats) {
+	s.transactions++
+	i := sort.Search(len(s.largest), func(i int) bool { return s.largest[i].size < t.size })
+	if i >= s.top {
+		return
+	}
+	s.largest = append(s.largest, nil)
+	copy(s.largest[i+1:], s.largest[i:])
+	s.largest[i] = t
+	if len(s.largest) > s.top {
+		s.largest = s.largest[:s.top]
+	}
+}
+
+func (s *inspectStats) write(w io.Writer, loc *time.Location) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	formatTime := func(ts uint32) string {
+		if ts == 0 {
+			return "-"
+		}
+		return time.Unix(int64(ts), 0).In(loc).Format(timeLayout)
+	}
+	fmt.Fprintf(tw, "files\t%v\n", s.files)
+	fmt.Fprintf(tw, "events\t%v\n", s.events)
+	fmt.Fprintf(tw, "transactions\t%v\n", s.transactions)
+	fmt.Fprintf(tw, "first event\t%v\n", formatTime(s.first))
+	fmt.Fprintf(tw, "last event\t%v\n", formatTime(s.last))
+	if s.checksumErrors > 0 {
+		fmt.Fprintf(tw, "checksum errors\t%v\n", s.checksumErrors)
+	}
+
+	types := make([]string, 0, len(s.types))
+	for name := range s.types {
+		types = append(types, name)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if s.types[types[i]] != s.types[types[j]] {
+			return s.types[types[i]] > s.types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	fmt.Fprintf(tw, "\nEVENT TYPE\tCOUNT\n")
+	for _, name := range types {
+		fmt.Fprintf(tw, "%v\t%v\n", name, s.types[name])
+	}
+
+	tables := make([]*tableStats, 0, len(s.tables))
+	for _, t := range s.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].total() != tables[j].total() {
+			return tables[i].total() > tables[j].total()
+		}
+		return tables[i].name < tables[j].name
+	})
+	fmt.Fprintf(tw, "\nTABLE\tINSERT\tUPDATE\tDELETE\tTOTAL\n")
+	for _, t := range tables {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", t.name, t.insert, t.update, t.delete, t.total())
+	}
+
+	if s.top > 0 {
+		fmt.Fprintf(tw, "\nLARGEST TRANSACTIONS\nPOSITION\tEND\tSIZE\tEVENTS\tROWS\tGTID\n")
+		for _, t := range s.largest {
+			gtid := t.gtid
+			if gtid == "" {
+				gtid = "-"
+			}
+			fmt.Fprintf(tw, "%v:%v\t%v\t%v\t%v\t%v\t%v\n", t.file, t.offset, t.end, t.size, t.events, t.rows, gtid)
+		}
+	}
+	tw.Flush()
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onlyac0611/binlog/replication"
+)
+
+var testInspectSID = replication.SID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+//writeInspectBinlog 写入一个binlog文件，包含4个事务:
+//插入两行、更新一行、一个DDL以及删除一行，返回文件路径
+func writeInspectBinlog(t *testing.T, dir string) string {
+	w, err := replication.NewBinlogWriter(dir, "mysql-bin", replication.NewMySQL56BinlogFormat(), 1,
+		replication.DefaultBinlogMaxSize)
+	if err != nil {
+		t.Fatalf("NewBinlogWriter err: %v", err)
+	}
+	f := w.Format()
+	s := replication.NewFakeBinlogStream()
+	s.Timestamp = 1407805592
+
+	tm := &replication.TableMap{
+		Database:  "vt_test_keyspace",
+		Name:      "vt_a",
+		Types:     []byte{replication.TypeLong, replication.TypeVarchar},
+		CanBeNull: replication.NewServerBitmap(2),
+		Metadata:  []uint16{0, 384},
+	}
+	tm.CanBeNull.Set(1, true)
+	//只设置event中存在的bitmap，否则NewWriteRowsEvent等会在末尾多出空字节
+	newRows := func(identify, data bool) replication.Rows {
+		var rows replication.Rows
+		if identify {
+			rows.IdentifyColumns = replication.NewServerBitmap(2)
+			rows.IdentifyColumns.Set(0, true)
+			rows.IdentifyColumns.Set(1, true)
+		}
+		if data {
+			rows.DataColumns = replication.NewServerBitmap(2)
+			rows.DataColumns.Set(0, true)
+			rows.DataColumns.Set(1, true)
+		}
+		return rows
+	}
+	newRow := func(id byte, message string) (replication.Bitmap, []byte) {
+		nulls := replication.NewServerBitmap(2)
+		if message == "" {
+			nulls.Set(1, true)
+			return nulls, []byte{id, 0, 0, 0}
+		}
+		return nulls, append([]byte{id, 0, 0, 0, byte(len(message)), 0}, message...)
+	}
+	begin := replication.Query{Database: "vt_test_keyspace", SQL: "BEGIN"}
+
+	insert := newRows(false, true)
+	for _, v := range []struct {
+		id      byte
+		message string
+	}{{1, "ab"}, {2, ""}} {
+		nulls, data := newRow(v.id, v.message)
+		insert.Rows = append(insert.Rows, replication.Row{NullColumns: nulls, Data: data})
+	}
+	update := newRows(true, true)
+	whereNulls, where := newRow(1, "ab")
+	setNulls, set := newRow(1, "it's")
+	update.Rows = []replication.Row{{NullIdentifyColumns: whereNulls, Identify: where, NullColumns: setNulls, Data: set}}
+	del := newRows(true, false)
+	whereNulls, where = newRow(2, "")
+	del.Rows = []replication.Row{{NullIdentifyColumns: whereNulls, Identify: where}}
+
+	events := []replication.BinlogEvent{
+		replication.NewMySQL56GTIDEvent(f, s, replication.Mysql56GTID{Server: testInspectSID, Sequence: 5}),
+		replication.NewQueryEvent(f, s, begin),
+		replication.NewTableMapEvent(f, s, 1, tm),
+		replication.NewWriteRowsEvent(f, s, 1, insert),
+		replication.NewXIDEvent(f, s),
+
+		replication.NewQueryEvent(f, s, begin),
+		replication.NewTableMapEvent(f, s, 1, tm),
+		replication.NewUpdateRowsEvent(f, s, 1, update),
+		replication.NewXIDEvent(f, s),
+
+		replication.NewQueryEvent(f, s, replication.Query{Database: "vt_test_keyspace",
+			SQL: "create table vt_b (id int)"}),
+
+		replication.NewQueryEvent(f, s, begin),
+		replication.NewTableMapEvent(f, s, 1, tm),
+		replication.NewDeleteRowsEvent(f, s, 1, del),
+		replication.NewXIDEvent(f, s),
+	}
+	for _, ev := range events {
+		if err = w.WriteEvent(ev); err != nil {
+			t.Fatalf("WriteEvent err: %v", err)
+		}
+	}
+	filename, _ := w.Position()
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close err: %v", err)
+	}
+	return filepath.Join(dir, filename)
+}
+
+func TestParseInspectFlags(t *testing.T) {
+	testCases := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{args: []string{"mysql-bin.000001", "mysql-bin.000002"}},
+		{args: []string{"-summary", "-top", "0", "-tz", "Local", "mysql-bin.000001"}},
+		{args: nil, wantErr: true},
+		{args: []string{"-top", "-1", "mysql-bin.000001"}, wantErr: true},
+		{args: []string{"-tz", "Mars/Base", "mysql-bin.000001"}, wantErr: true},
+		{args: []string{"-unknown", "mysql-bin.000001"}, wantErr: true},
+	}
+	for _, v := range testCases {
+		cfg, err := parseInspectFlags(v.args, ioutil.Discard)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("parseInspectFlags %v wantErr: %v err: %v", v.args, v.wantErr, err)
+		}
+		if err == nil && len(cfg.files) == 0 {
+			t.Fatalf("parseInspectFlags %v no files", v.args)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog-inspect")
+	if err != nil {
+		t.Fatalf("TempDir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeInspectBinlog(t, dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run(context.Background(), []string{"inspect", path}, stdout, stderr); code != 0 {
+		t.Fatalf("inspect exit code: %v stderr: %s", code, stderr)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"# file mysql-bin.000001\n",
+		"# at 4          Format_desc    ",
+		"version=5.6.33-0ubuntu0.14.04.1-log checksum=CRC32\n",
+		"# at 120        Gtid           2014-08-12 01:06:32 server_id=1 size=48  ",
+		"gtid=" + testInspectSID.String() + ":5\n",
+		`db=vt_test_keyspace query="BEGIN"` + "\n",
+		"table_id=1 table=`vt_test_keyspace`.`vt_a` columns=2\n",
+		"table_id=1 table=`vt_test_keyspace`.`vt_a` rows=2\n" +
+			"### INSERT INTO `vt_test_keyspace`.`vt_a`\n" +
+			"### SET\n" +
+			"###   @1=1 /* Long meta=0 nullable=0 is_null=0 */\n" +
+			"###   @2='ab' /* Varchar meta=384 nullable=1 is_null=0 */\n" +
+			"### INSERT INTO `vt_test_keyspace`.`vt_a`\n" +
+			"### SET\n" +
+			"###   @1=2 /* Long meta=0 nullable=0 is_null=0 */\n" +
+			"###   @2=NULL /* Varchar meta=384 nullable=1 is_null=1 */\n",
+		"### UPDATE `vt_test_keyspace`.`vt_a`\n" +
+			"### WHERE\n" +
+			"###   @1=1 /* Long meta=0 nullable=0 is_null=0 */\n" +
+			"###   @2='ab' /* Varchar meta=384 nullable=1 is_null=0 */\n" +
+			"### SET\n" +
+			"###   @1=1 /* Long meta=0 nullable=0 is_null=0 */\n" +
+			"###   @2='it\\'s' /* Varchar meta=384 nullable=1 is_null=0 */\n",
+		`query="create table vt_b (id int)"`,
+		"### DELETE FROM `vt_test_keyspace`.`vt_a`\n" +
+			"### WHERE\n" +
+			"###   @1=2 /* Long meta=0 nullable=0 is_null=0 */\n" +
+			"###   @2=NULL /* Varchar meta=384 nullable=1 is_null=1 */\n",
+		"Xid            ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("inspect output should contain %q\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "# at "); n != 15 {
+		t.Fatalf("inspect want 15 events, out: %v\n%s", n, out)
+	}
+}
+
+func TestInspect_Summary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog-inspect")
+	if err != nil {
+		t.Fatalf("TempDir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeInspectBinlog(t, dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	args := []string{"inspect", "-summary", "-top", "2", path}
+	if code := run(context.Background(), args, stdout, stderr); code != 0 {
+		t.Fatalf("inspect exit code: %v stderr: %s", code, stderr)
+	}
+	lines := make(map[string][]string)
+	var largest [][]string
+	inLargest := false
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "LARGEST" || fields[0] == "POSITION" {
+			inLargest = true
+			continue
+		}
+		if inLargest {
+			largest = append(largest, fields)
+		}
+		lines[fields[0]] = fields
+	}
+
+	for _, want := range [][]string{
+		{"files", "1"},
+		{"events", "15"},
+		{"transactions", "4"},
+		{"first", "event", "2014-08-12", "01:06:32"},
+		{"Xid", "3"},
+		{"Table_map", "3"},
+		{"`vt_test_keyspace`.`vt_a`", "2", "1", "1", "4"},
+	} {
+		if out := strings.Join(lines[want[0]], " "); out != strings.Join(want, " ") {
+			t.Fatalf("summary want: %v out: %v\n%s", want, out, stdout)
+		}
+	}
+	if _, ok := lines["checksum"]; ok {
+		t.Fatalf("summary should not have checksum errors\n%s", stdout)
+	}
+
+	//第一个事务有GTID且插入两行，是最大的事务
+	if len(largest) != 2 {
+		t.Fatalf("summary want 2 largest transactions, out: %v\n%s", len(largest), stdout)
+	}
+	if out := largest[0]; out[0] != "mysql-bin.000001:120" || out[3] != "5" || out[4] != "2" || out[5] != testInspectSID.String()+":5" {
+		t.Fatalf("summary largest transaction: %v\n%s", out, stdout)
+	}
+}
+
+func TestInspect_Errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog-inspect")
+	if err != nil {
+		t.Fatalf("TempDir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeInspectBinlog(t, dir)
+
+	//破坏最后一个event的checksum，inspect报告错误并继续
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile err: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile err: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	if code := run(context.Background(), []string{"inspect", "-summary", path}, stdout, ioutil.Discard); code != 0 {
+		t.Fatalf("inspect exit code: %v", code)
+	}
+	if !strings.Contains(stdout.String(), "checksum errors  1\n") {
+		t.Fatalf("summary should have a checksum error\n%s", stdout)
+	}
+
+	notBinlog := filepath.Join(dir, "not-binlog")
+	if err = ioutil.WriteFile(notBinlog, []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile err: %v", err)
+	}
+	stderr := &bytes.Buffer{}
+	if code := run(context.Background(), []string{"inspect", notBinlog}, ioutil.Discard, stderr); code != 1 ||
+		!strings.Contains(stderr.String(), "is not a binlog file") {
+		t.Fatalf("inspect of a bad file exit code: %v stderr: %s", code, stderr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if code := run(ctx, []string{"inspect", path}, ioutil.Discard, ioutil.Discard); code != 1 {
+		t.Fatalf("inspect with a cancelled context exit code: %v", code)
+	}
+}
@@ -0,0 +1,76 @@
+//binlog 命令行工具，使用方式:
+//
+//	binlog tail -dsn 'root:123456@tcp(127.0.0.1:3306)/mysql' -server-id 1234 -start mysql-bin.000001:4
+//	binlog inspect -summary /var/lib/mysql/mysql-bin.000001
+//
+//安装: go install github.com/onlyac0611/binlog/cmd/binlog
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+//command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{name: "tail", usage: "stream decoded transactions from a mysql master", run: runTail},
+	{name: "inspect", usage: "list the events of local binlog files or summarize them", run: runInspect},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: binlog <command> [flags]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(w, "\nrun 'binlog <command> -h' for the flags of a command\n")
+}
+
+//run 执行子命令，返回进程的退出码
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		if err := c.run(ctx, args[1:], stdout, stderr); err != nil {
+			if err != errUsage {
+				fmt.Fprintf(stderr, "binlog %v: %v\n", c.name, err)
+			}
+			return 1
+		}
+		return 0
+	}
+	if args[0] != "-h" && args[0] != "-help" && args[0] != "help" {
+		fmt.Fprintf(stderr, "binlog: unknown command %q\n", args[0])
+	}
+	usage(stderr)
+	return 2
+}
+
+func main() {
+	//收到SIGINT或者SIGTERM时取消ctx，子命令保存checkpoint后退出
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	cancel()
+	os.Exit(code)
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onlyac0611/binlog"
+)
+
+//errUsage 参数错误，flag已经打印了错误以及用法
+var errUsage = errors.New("usage")
+
+const timeLayout = "2006-01-02 15:04:05"
+
+//tailConfig tail子命令的参数
+type tailConfig struct {
+	dsn        string
+	serverID   uint32
+	start      binlog.Position
+	startGTID  string
+	startTime  time.Time
+	checkpoint string
+	tables     tableFilter
+	format     string
+	stop       binlog.StopCondition
+	nonBlock   bool
+	location   *time.Location
+}
+
+func parseTailFlags(args []string, stderr io.Writer) (*tailConfig, error) {
+	fs := flag.NewFlagSet("binlog tail", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg := &tailConfig{}
+	var serverID uint
+	var start, startTime, tables, stop, stopTime, tz string
+	fs.StringVar(&cfg.dsn, "dsn", "", "mysql dsn, such as root:123456@tcp(127.0.0.1:3306)/mysql (required)")
+	fs.UintVar(&serverID, "server-id", 0, "server id used to dump binlog, must be unique among the replicas (required)")
+	fs.StringVar(&start, "start", "", "start position as file:offset, such as mysql-bin.000001:4")
+	fs.StringVar(&cfg.startGTID, "start-gtid", "", "start from the transaction of this gtid")
+	fs.StringVar(&startTime, "start-time", "", "start from the first transaction not earlier than this time, "+
+		"RFC3339 or '"+timeLayout+"' in -tz")
+	fs.StringVar(&cfg.checkpoint, "checkpoint", "", "checkpoint file, used as the start position if it exists and "+
+		"no other start is given, saved on exit and on SIGINT/SIGTERM")
+	fs.StringVar(&tables, "tables", "", "comma separated db.table filters, * matches any characters, such as db1.*,db2.user")
+	fs.StringVar(&cfg.format, "format", "json", "output format: json, json2, sql or flashback")
+	fs.StringVar(&stop, "stop", "", "stop after the transaction reaching this position (file:offset)")
+	fs.StringVar(&cfg.stop.GTID, "stop-gtid", "", "stop after the transaction of this gtid")
+	fs.StringVar(&stopTime, "stop-time", "", "stop before the first transaction later than this time")
+	fs.BoolVar(&cfg.nonBlock, "non-block", false, "stop at the end of the current binlog instead of waiting")
+	fs.StringVar(&tz, "tz", "UTC", "time zone of TIMESTAMP columns and -start-time/-stop-time, such as Local or Asia/Shanghai")
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments: %v\n", fs.Args())
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	if cfg.dsn == "" {
+		return nil, fmt.Errorf("-dsn is required")
+	}
+	if serverID == 0 || serverID > 1<<32-1 {
+		return nil, fmt.Errorf("-server-id must be in [1, %d]", uint32(1<<32-1))
+	}
+	cfg.serverID = uint32(serverID)
+
+	var err error
+	if cfg.location, err = time.LoadLocation(tz); err != nil {
+		return nil, fmt.Errorf("invalid -tz %v: %v", tz, err)
+	}
+
+	starts := 0
+	if start != "" {
+		starts++
+		if cfg.start, err = parsePosition(start); err != nil {
+			return nil, fmt.Errorf("invalid -start: %v", err)
+		}
+	}
+	if cfg.startGTID != "" {
+		starts++
+	}
+	if startTime != "" {
+		starts++
+		if cfg.startTime, err = parseTime(startTime, cfg.location); err != nil {
+			return nil, fmt.Errorf("invalid -start-time: %v", err)
+		}
+	}
+	if starts > 1 {
+		return nil, fmt.Errorf("only one of -start, -start-gtid and -start-time can be given")
+	}
+
+	if stop != "" {
+		if cfg.stop.Position, err = parsePosition(stop); err != nil {
+			return nil, fmt.Errorf("invalid -stop: %v", err)
+		}
+	}
+	if stopTime != "" {
+		t, err := parseTime(stopTime, cfg.location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -stop-time: %v", err)
+		}
+		cfg.stop.Timestamp = t.Unix()
+	}
+
+	if cfg.tables, err = parseTableFilter(tables); err != nil {
+		return nil, fmt.Errorf("invalid -tables: %v", err)
+	}
+	switch cfg.format {
+	case "json", "json2", "sql":
+	case "flashback":
+		if cfg.stop.IsZero() && !cfg.nonBlock {
+			return nil, fmt.Errorf("-format flashback needs a stop condition or -non-block")
+		}
+	default:
+		return nil, fmt.Errorf("unknown -format %v", cfg.format)
+	}
+	return cfg, nil
+}
+
+//parsePosition 解析file:offset格式的binlog位置
+func parsePosition(s string) (binlog.Position, error) {
+	i := strings.LastIndexByte(s, ':')
+	if i <= 0 {
+		return binlog.Position{}, fmt.Errorf("%v is not file:offset", s)
+	}
+	offset, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil || offset <= 0 {
+		return binlog.Position{}, fmt.Errorf("invalid offset in %v", s)
+	}
+	return binlog.Position{Filename: s[:i], Offset: offset}, nil
+}
+
+//parseTime 解析RFC3339或者loc中的"2006-01-02 15:04:05"格式的时间
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(timeLayout, s, loc)
+}
+
+//tableFilter 表名过滤，为空时不过滤
+type tableFilter []string
+
+func parseTableFilter(s string) (tableFilter, error) {
+	var filter tableFilter
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if !strings.Contains(pattern, ".") {
+			return nil, fmt.Errorf("%v is not db.table", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad pattern %v: %v", pattern, err)
+		}
+		filter = append(filter, pattern)
+	}
+	return filter, nil
+}
+
+func (f tableFilter) match(name binlog.MysqlTableName) bool {
+	if len(f) == 0 {
+		return true
+	}
+	table := name.DbName + "." + name.TableName
+	for _, pattern := range f {
+		if ok, _ := path.Match(pattern, table); ok {
+			return true
+		}
+	}
+	return false
+}
+
+//filter 去掉不匹配的事件，没有表名的事件(如没有解析出表名的sql)只在不过滤时保留
+func (f tableFilter) filter(tran *binlog.Transaction) *binlog.Transaction {
+	if len(f) == 0 {
+		return tran
+	}
+	out := *tran
+	out.Events = nil
+	for _, ev := range tran.Events {
+		if ev.Table.DbName != "" && f.match(ev.Table) {
+			out.Events = append(out.Events, ev)
+		}
+	}
+	return &out
+}
+
+//loadCheckpoint 读取checkpoint文件，文件不存在时返回零值
+func loadCheckpoint(filename string) (binlog.Position, error) {
+	var pos binlog.Position
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return pos, nil
+	}
+	if err != nil {
+		return pos, err
+	}
+	if err = json.Unmarshal(data, &pos); err != nil {
+		return pos, fmt.Errorf("invalid checkpoint file %v: %v", filename, err)
+	}
+	return pos, nil
+}
+
+//saveCheckpoint 先写入临时文件再重命名，避免中途退出时checkpoint文件损坏
+func saveCheckpoint(filename string, pos binlog.Position) error {
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(append(data, '\n')); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+//tailOutput 输出解码后的事务
+type tailOutput interface {
+	write(tran *binlog.Transaction) error
+	flush() error
+}
+
+func newTailOutput(format string, w io.Writer) (tailOutput, error) {
+	switch format {
+	case "json", "json2":
+		e := binlog.NewJSONEncoder()
+		if format == "json2" {
+			if err := e.SetVersion(binlog.JSONVersion2); err != nil {
+				return nil, err
+			}
+		}
+		return &encoderOutput{w: w, encoder: e}, nil
+	case "sql":
+		return &sqlOutput{w: w, renderer: binlog.NewSQLRenderer()}, nil
+	case "flashback":
+		return &flashbackOutput{w: w, flashback: binlog.NewFlashback()}, nil
+	}
+	return nil, fmt.Errorf("unknown format %v", format)
+}
+
+//encoderOutput 每行输出一条Encoder编码的消息
+type encoderOutput struct {
+	w       io.Writer
+	encoder binlog.Encoder
+}
+
+func (o *encoderOutput) write(tran *binlog.Transaction) error {
+	msgs, err := o.encoder.Encode(tran)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if _, err = fmt.Fprintf(o.w, "%s\n", msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *encoderOutput) flush() error {
+	return nil
+}
+
+//sqlOutput 输出每个事务的sql，以事务的位置作为注释
+type sqlOutput struct {
+	w        io.Writer
+	renderer *binlog.SQLRenderer
+}
+
+func writeTransactionComment(w io.Writer, tran *binlog.Transaction) error {
+	ts := time.Unix(tran.Timestamp, 0)
+	if tran.Location != nil {
+		ts = ts.In(tran.Location)
+	}
+	comment := fmt.Sprintf("# at %v:%v end %v:%v time %v", tran.NowPosition.Filename, tran.NowPosition.Offset,
+		tran.NextPosition.Filename, tran.NextPosition.Offset, ts.Format(timeLayout))
+	if tran.GTID != "" {
+		comment += " gtid " + tran.GTID
+	}
+	_, err := fmt.Fprintln(w, comment)
+	return err
+}
+
+func writeStatements(w io.Writer, sqls []string) error {
+	for _, sql := range sqls {
+		if _, err := fmt.Fprintf(w, "%s;\n", sql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *sqlOutput) write(tran *binlog.Transaction) error {
+	sqls, err := o.renderer.RenderTransaction(tran)
+	if err != nil {
+		return err
+	}
+	if err = writeTransactionComment(o.w, tran); err != nil {
+		return err
+	}
+	return writeStatements(o.w, append(append([]string{"BEGIN"}, sqls...), "COMMIT"))
+}
+
+func (o *sqlOutput) flush() error {
+	return nil
+}
+
+//flashbackOutput 保存所有的事务，结束时按照逆序输出逆向的sql
+type flashbackOutput struct {
+	w         io.Writer
+	flashback *binlog.Flashback
+	trans     []*binlog.Transaction
+}
+
+func (o *flashbackOutput) write(tran *binlog.Transaction) error {
+	o.trans = append(o.trans, tran)
+	return nil
+}
+
+func (o *flashbackOutput) flush() error {
+	sqls, err := o.flashback.Generate(o.trans)
+	if err != nil {
+		return err
+	}
+	if len(sqls) == 0 {
+		return nil
+	}
+	first, last := o.trans[0], o.trans[len(o.trans)-1]
+	if _, err = fmt.Fprintf(o.w, "# flashback of %v:%v to %v:%v\n", first.NowPosition.Filename,
+		first.NowPosition.Offset, last.NextPosition.Filename, last.NextPosition.Offset); err != nil {
+		return err
+	}
+	return writeStatements(o.w, append(append([]string{"BEGIN"}, sqls...), "COMMIT"))
+}
+
+//startPosition 按照-start、-start-gtid、-start-time以及checkpoint文件的顺序确定开始位置，都没有时从当前位置开始
+func startPosition(ctx context.Context, cfg *tailConfig) (binlog.Position, error) {
+	switch {
+	case !cfg.start.IsZero():
+		return cfg.start, nil
+	case cfg.startGTID != "":
+		return binlog.ResolvePositionForGTID(ctx, cfg.dsn, cfg.startGTID)
+	case !cfg.startTime.IsZero():
+		return binlog.ResolvePositionForTime(ctx, cfg.dsn, cfg.startTime)
+	}
+	if cfg.checkpoint != "" {
+		pos, err := loadCheckpoint(cfg.checkpoint)
+		if err != nil || !pos.IsZero() {
+			return pos, err
+		}
+	}
+	return binlog.ResolvePositionForTime(ctx, cfg.dsn, time.Now())
+}
+
+func runTail(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	cfg, err := parseTailFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+	return tail(ctx, cfg, stdout)
+}
+
+func tail(ctx context.Context, cfg *tailConfig, stdout io.Writer) error {
+	start, err := startPosition(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("get start position fail. err: %v", err)
+	}
+
+	mapper := binlog.NewSchemaTableMapper(cfg.dsn)
+	defer mapper.Close()
+	r, err := binlog.NewRowStreamer(cfg.dsn, cfg.serverID, mapper)
+	if err != nil {
+		return err
+	}
+	r.SetStartBinlogPosition(start)
+	r.SetNonBlock(cfg.nonBlock)
+	r.SetLocation(cfg.location)
+	if err = r.SetStopCondition(cfg.stop); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(stdout)
+	out, err := newTailOutput(cfg.format, w)
+	if err != nil {
+		return err
+	}
+	streamErr := r.Stream(ctx, func(tran *binlog.Transaction) error {
+		tran = cfg.tables.filter(tran)
+		if len(tran.Events) == 0 {
+			return nil
+		}
+		if err := out.write(tran); err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+	if streamErr == binlog.ErrStopConditionReached || ctx.Err() != nil {
+		streamErr = nil
+	}
+
+	if err = out.flush(); err == nil {
+		err = w.Flush()
+	}
+	if cfg.checkpoint != "" {
+		if saveErr := saveCheckpoint(cfg.checkpoint, r.BinlogPosition()); saveErr != nil && err == nil {
+			err = fmt.Errorf("save checkpoint fail. err: %v", saveErr)
+		}
+	}
+	if streamErr != nil {
+		return streamErr
+	}
+	return err
+}
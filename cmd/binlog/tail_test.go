@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onlyac0611/binlog"
+	"github.com/onlyac0611/binlog/fakemaster"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+func TestParseTailFlags(t *testing.T) {
+	base := []string{"-dsn", "root:123456@tcp(127.0.0.1:3306)/mysql", "-server-id", "1234"}
+	testCases := []struct {
+		args    []string
+		check   func(cfg *tailConfig) bool
+		wantErr bool
+	}{
+		{
+			args: base,
+			check: func(cfg *tailConfig) bool {
+				return cfg.serverID == 1234 && cfg.format == "json" && cfg.start.IsZero() &&
+					cfg.location == time.UTC && cfg.stop.IsZero()
+			},
+		},
+		{
+			args: append([]string{"-start", "mysql-bin.000001:4", "-format", "sql", "-tables", "db1.*, db2.user",
+				"-stop", "mysql-bin.000002:120", "-stop-gtid", "0-1-100", "-tz", "Local"}, base...),
+			check: func(cfg *tailConfig) bool {
+				return cfg.start == binlog.Position{Filename: "mysql-bin.000001", Offset: 4} &&
+					len(cfg.tables) == 2 && cfg.stop.GTID == "0-1-100" && cfg.location == time.Local &&
+					cfg.stop.Position == binlog.Position{Filename: "mysql-bin.000002", Offset: 120}
+			},
+		},
+		{
+			args: append([]string{"-start-time", "2019-03-01 10:20:30", "-stop-time", "2019-03-01T11:00:00Z",
+				"-tz", "UTC"}, base...),
+			check: func(cfg *tailConfig) bool {
+				return cfg.startTime.Unix() == time.Date(2019, 3, 1, 10, 20, 30, 0, time.UTC).Unix() &&
+					cfg.stop.Timestamp == time.Date(2019, 3, 1, 11, 0, 0, 0, time.UTC).Unix()
+			},
+		},
+		{
+			args:  append([]string{"-format", "flashback", "-non-block"}, base...),
+			check: func(cfg *tailConfig) bool { return cfg.nonBlock },
+		},
+		{args: []string{"-server-id", "1"}, wantErr: true},
+		{args: []string{"-dsn", "x"}, wantErr: true},
+		{args: append([]string{"-start", "mysql-bin.000001"}, base...), wantErr: true},
+		{args: append([]string{"-start", "f:4", "-start-gtid", "0-1-1"}, base...), wantErr: true},
+		{args: append([]string{"-start-time", "yesterday"}, base...), wantErr: true},
+		{args: append([]string{"-tables", "user"}, base...), wantErr: true},
+		{args: append([]string{"-format", "xml"}, base...), wantErr: true},
+		{args: append([]string{"-format", "flashback"}, base...), wantErr: true},
+		{args: append([]string{"-tz", "Mars/Base"}, base...), wantErr: true},
+		{args: append([]string{"-unknown"}, base...), wantErr: true},
+		{args: append(base, "extra"), wantErr: true},
+	}
+
+	for i, v := range testCases {
+		cfg, err := parseTailFlags(v.args, ioutil.Discard)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("case %d %v wantErr: %v err: %v", i, v.args, v.wantErr, err)
+		}
+		if err == nil && !v.check(cfg) {
+			t.Fatalf("case %d %v unexpected config: %+v", i, v.args, cfg)
+		}
+	}
+}
+
+func TestTableFilter(t *testing.T) {
+	filter, err := parseTableFilter("db1.*,db2.user_?")
+	if err != nil {
+		t.Fatalf("parseTableFilter err: %v", err)
+	}
+	testCases := []struct {
+		name binlog.MysqlTableName
+		want bool
+	}{
+		{name: binlog.NewMysqlTableName("db1", "a"), want: true},
+		{name: binlog.NewMysqlTableName("db2", "user_1"), want: true},
+		{name: binlog.NewMysqlTableName("db2", "user"), want: false},
+		{name: binlog.NewMysqlTableName("db3", "a"), want: false},
+	}
+	for _, v := range testCases {
+		if out := filter.match(v.name); out != v.want {
+			t.Fatalf("match %v want: %v out: %v", v.name, v.want, out)
+		}
+	}
+
+	tran := &binlog.Transaction{Events: []*binlog.StreamEvent{
+		{Table: binlog.NewMysqlTableName("db1", "a")},
+		{Table: binlog.NewMysqlTableName("db3", "a")},
+		{SQL: "FLUSH PRIVILEGES"},
+	}}
+	if out := filter.filter(tran); len(out.Events) != 1 || len(tran.Events) != 3 {
+		t.Fatalf("filter want 1 event and keep the input, out: %v input: %v", len(out.Events), len(tran.Events))
+	}
+	if out := tableFilter(nil).filter(tran); out != tran {
+		t.Fatalf("empty filter should return the input")
+	}
+	if _, err = parseTableFilter("db.[a"); err == nil {
+		t.Fatalf("parseTableFilter bad pattern want err")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog-checkpoint")
+	if err != nil {
+		t.Fatalf("TempDir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "checkpoint.json")
+
+	if pos, err := loadCheckpoint(filename); err != nil || !pos.IsZero() {
+		t.Fatalf("loadCheckpoint of a missing file want zero, out: %+v err: %v", pos, err)
+	}
+	want := binlog.Position{Filename: "mysql-bin.000002", Offset: 120}
+	if err = saveCheckpoint(filename, want); err != nil {
+		t.Fatalf("saveCheckpoint err: %v", err)
+	}
+	if pos, err := loadCheckpoint(filename); err != nil || pos != want {
+		t.Fatalf("loadCheckpoint want: %+v out: %+v err: %v", want, pos, err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("saveCheckpoint should not leave temporary files, files: %v", len(files))
+	}
+
+	if err = ioutil.WriteFile(filename, []byte("mysql-bin"), 0644); err != nil {
+		t.Fatalf("WriteFile err: %v", err)
+	}
+	if _, err = loadCheckpoint(filename); err == nil {
+		t.Fatalf("loadCheckpoint of a bad file want err")
+	}
+}
+
+//setTestSchema 设置SchemaTableMapper查询表结构的结果，表结构为(id int primary key, message varchar(128))
+func setTestSchema(m *fakemaster.Master, table string) {
+	m.SetQueryResult("SELECT COLUMN_NAME, COLUMN_TYPE, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, "+
+		"CHARACTER_SET_NAME, COLLATION_NAME, COLUMN_KEY, EXTRA FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = 'vt_test_keyspace' AND TABLE_NAME = '"+table+"' ORDER BY ORDINAL_POSITION",
+		&fakemaster.Result{
+			Columns: []string{"COLUMN_NAME", "COLUMN_TYPE", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT",
+				"CHARACTER_SET_NAME", "COLLATION_NAME", "COLUMN_KEY", "EXTRA"},
+			Rows: [][]interface{}{
+				{"id", "int(11)", "int", "NO", nil, nil, nil, "PRI", ""},
+				{"message", "varchar(128)", "varchar", "YES", nil, "utf8", "utf8_general_ci", "", ""},
+			},
+		})
+	m.SetQueryResult("SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME FROM information_schema.STATISTICS "+
+		"WHERE TABLE_SCHEMA = 'vt_test_keyspace' AND TABLE_NAME = '"+table+"' ORDER BY INDEX_NAME, SEQ_IN_INDEX",
+		&fakemaster.Result{
+			Columns: []string{"INDEX_NAME", "NON_UNIQUE", "COLUMN_NAME"},
+			Rows:    [][]interface{}{{"PRIMARY", 0, "id"}},
+		})
+}
+
+//appendInsert 追加一个插入(id, message)的事务
+func appendInsert(m *fakemaster.Master, tableID uint64, table string, id byte, message string) {
+	f, s := m.Format(), m.Stream()
+	tm := &replication.TableMap{
+		Database:  "vt_test_keyspace",
+		Name:      table,
+		Types:     []byte{replication.TypeLong, replication.TypeVarchar},
+		CanBeNull: replication.NewServerBitmap(2),
+		Metadata:  []uint16{0, 384},
+	}
+	rows := replication.Rows{
+		DataColumns: replication.NewServerBitmap(2),
+		Rows: []replication.Row{{
+			NullColumns: replication.NewServerBitmap(2),
+			Data:        append([]byte{id, 0, 0, 0, byte(len(message)), 0}, message...),
+		}},
+	}
+	rows.DataColumns.Set(0, true)
+	rows.DataColumns.Set(1, true)
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "vt_test_keyspace", SQL: "BEGIN"}),
+		replication.NewTableMapEvent(f, s, tableID, tm),
+		replication.NewWriteRowsEvent(f, s, tableID, rows),
+		replication.NewXIDEvent(f, s),
+	)
+}
+
+func TestTail_FakeMaster(t *testing.T) {
+	m, err := fakemaster.NewMaster("127.0.0.1:0", "root", "123456")
+	if err != nil {
+		t.Fatalf("NewMaster err: %v", err)
+	}
+	defer m.Close()
+	setTestSchema(m, "vt_a")
+	setTestSchema(m, "vt_b")
+	m.Stream().Timestamp = 1407805592
+	appendInsert(m, 1, "vt_a", 1, "abcd")
+	appendInsert(m, 2, "vt_b", 2, "skip")
+	appendInsert(m, 1, "vt_a", 3, "efg")
+	filename, end := m.Position()
+
+	dir, err := ioutil.TempDir("", "binlog-tail")
+	if err != nil {
+		t.Fatalf("TempDir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+
+	testCases := []struct {
+		format string
+		want   []string
+	}{
+		{
+			format: "sql",
+			want: []string{
+				"# at " + filename + ":4 end",
+				"BEGIN;",
+				"INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (1,'abcd');",
+				"COMMIT;",
+				"INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (3,'efg');",
+			},
+		},
+		{
+			format: "flashback",
+			want: []string{
+				"DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `id`=3 LIMIT 1;\n" +
+					"DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `id`=1 LIMIT 1;",
+			},
+		},
+		{
+			format: "json2",
+			want:   []string{`"primaryKey":["id"]`, `"data":"efg"`},
+		},
+	}
+	for _, v := range testCases {
+		os.Remove(checkpoint)
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		args := []string{"tail", "-dsn", m.DSN(), "-server-id", "1234", "-start", filename + ":4",
+			"-tables", "vt_test_keyspace.vt_a", "-non-block", "-format", v.format, "-checkpoint", checkpoint}
+		if code := run(context.Background(), args, stdout, stderr); code != 0 {
+			t.Fatalf("%v exit code: %v stderr: %s", v.format, code, stderr)
+		}
+		for _, want := range v.want {
+			if !strings.Contains(stdout.String(), want) {
+				t.Fatalf("%v output should contain %q\n%s", v.format, want, stdout)
+			}
+		}
+		if strings.Contains(stdout.String(), "skip") {
+			t.Fatalf("%v output should not contain filtered table vt_b\n%s", v.format, stdout)
+		}
+		pos, err := loadCheckpoint(checkpoint)
+		if want := (binlog.Position{Filename: filename, Offset: end}); err != nil || pos != want {
+			t.Fatalf("%v checkpoint want: %+v out: %+v err: %v", v.format, want, pos, err)
+		}
+	}
+
+	//从checkpoint继续，没有新的事务
+	stdout := &bytes.Buffer{}
+	args := []string{"tail", "-dsn", m.DSN(), "-server-id", "1234", "-non-block", "-checkpoint", checkpoint}
+	if code := run(context.Background(), args, stdout, ioutil.Discard); code != 0 || stdout.Len() != 0 {
+		t.Fatalf("tail from checkpoint exit code: %v output: %s", code, stdout)
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	testCases := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{args: nil, code: 2, want: "usage: binlog"},
+		{args: []string{"unknown"}, code: 2, want: `unknown command "unknown"`},
+		{args: []string{"tail", "-h"}, code: 1, want: "-server-id"},
+		{args: []string{"tail", "-server-id", "1"}, code: 1, want: "-dsn is required"},
+	}
+	for _, v := range testCases {
+		stderr := &bytes.Buffer{}
+		if code := run(context.Background(), v.args, ioutil.Discard, stderr); code != v.code {
+			t.Fatalf("run %v want code: %v out: %v", v.args, v.code, code)
+		}
+		if !strings.Contains(stderr.String(), v.want) {
+			t.Fatalf("run %v stderr should contain %q\n%s", v.args, v.want, stderr)
+		}
+	}
+}
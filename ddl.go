@@ -0,0 +1,203 @@
+package binlog
+
+import "strings"
+
+//ddlTableNames 获取DDL语句修改的表，database为执行语句时的默认数据库。
+//只修改数据库时返回TableName为空的表名，无法解析时返回database下的所有表即{DbName: database}
+func ddlTableNames(database, sql string) []MysqlTableName {
+	all := []MysqlTableName{{DbName: database}}
+	tokens := splitDDLTokens(sql)
+	if len(tokens) < 2 {
+		return all
+	}
+
+	var names []MysqlTableName
+	i := 1
+	switch strings.ToLower(tokens[0]) {
+	case "alter", "create", "drop":
+		//跳过ONLINE、IGNORE、TEMPORARY等修饰词
+		for ; i < len(tokens); i++ {
+			switch strings.ToLower(tokens[i]) {
+			case "table":
+				names = ddlTableList(database, tokens[i+1:], tokens[0])
+				if names == nil {
+					return all
+				}
+				return names
+			case "database", "schema":
+				name := ddlSkipIfExists(tokens[i+1:])
+				if len(name) == 0 {
+					return all
+				}
+				return []MysqlTableName{{DbName: unquoteIdentifier(name[0])}}
+			case "index":
+				//CREATE INDEX idx ON t (...)以及DROP INDEX idx ON t
+				for j := i + 1; j+1 < len(tokens); j++ {
+					if strings.EqualFold(tokens[j], "on") {
+						return []MysqlTableName{parseDDLTableName(database, tokens[j+1])}
+					}
+				}
+				return all
+			case "view", "trigger", "procedure", "function", "event", "user":
+				return nil
+			}
+		}
+	case "truncate":
+		if strings.EqualFold(tokens[i], "table") {
+			i++
+		}
+		if i < len(tokens) {
+			return []MysqlTableName{parseDDLTableName(database, tokens[i])}
+		}
+	case "rename":
+		if strings.EqualFold(tokens[i], "table") {
+			//RENAME TABLE a TO b, c TO d
+			for _, token := range tokens[i+1:] {
+				if token == "," || strings.EqualFold(token, "to") {
+					continue
+				}
+				names = append(names, parseDDLTableName(database, token))
+			}
+			if names != nil {
+				return names
+			}
+		}
+	}
+	return all
+}
+
+//ddlTableList 获取TABLE关键字之后的表名，DROP TABLE可以是逗号分隔的多个表，
+//ALTER TABLE a RENAME TO b时同时返回a以及b
+func ddlTableList(database string, tokens []string, verb string) []MysqlTableName {
+	tokens = ddlSkipIfExists(tokens)
+	if len(tokens) == 0 {
+		return nil
+	}
+	names := []MysqlTableName{parseDDLTableName(database, tokens[0])}
+	switch strings.ToLower(verb) {
+	case "drop":
+		for i := 1; i+1 < len(tokens) && tokens[i] == ","; i += 2 {
+			names = append(names, parseDDLTableName(database, tokens[i+1]))
+		}
+	case "alter":
+		for i := 1; i+1 < len(tokens); i++ {
+			if !strings.EqualFold(tokens[i], "rename") {
+				continue
+			}
+			j := i + 1
+			if strings.EqualFold(tokens[j], "to") || strings.EqualFold(tokens[j], "as") {
+				j++
+			}
+			//ALTER TABLE t RENAME COLUMN/INDEX/KEY不修改表名
+			if j < len(tokens) && !isRenameTarget(tokens[j]) {
+				names = append(names, parseDDLTableName(database, tokens[j]))
+			}
+			break
+		}
+	}
+	return names
+}
+
+func isRenameTarget(token string) bool {
+	switch strings.ToLower(token) {
+	case "column", "index", "key":
+		return true
+	}
+	return false
+}
+
+//ddlSkipIfExists 跳过IF EXISTS以及IF NOT EXISTS
+func ddlSkipIfExists(tokens []string) []string {
+	if len(tokens) == 0 || !strings.EqualFold(tokens[0], "if") {
+		return tokens
+	}
+	for i, token := range tokens {
+		if strings.EqualFold(token, "exists") {
+			return tokens[i+1:]
+		}
+	}
+	return nil
+}
+
+//parseDDLTableName 解析db.t、`db`.`t`以及t格式的表名
+func parseDDLTableName(database, token string) MysqlTableName {
+	parts := splitQualifiedName(token)
+	if len(parts) >= 2 {
+		return NewMysqlTableName(unquoteIdentifier(parts[0]), unquoteIdentifier(parts[1]))
+	}
+	return NewMysqlTableName(database, unquoteIdentifier(token))
+}
+
+//splitQualifiedName 按照反引号之外的点分隔名字
+func splitQualifiedName(token string) []string {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(token); i++ {
+		switch {
+		case token[i] == '`':
+			inQuote = !inQuote
+		case token[i] == '.' && !inQuote:
+			parts = append(parts, token[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, token[start:])
+}
+
+//unquoteIdentifier 去掉反引号，``代表一个反引号
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 && name[0] == '`' && name[len(name)-1] == '`' {
+		return strings.Replace(name[1:len(name)-1], "``", "`", -1)
+	}
+	return name
+}
+
+//splitDDLTokens 按照空白分隔DDL语句，逗号为单独的token，遇到括号、分号或者注释时结束当前token，
+//反引号中的内容保持不变
+func splitDDLTokens(sql string) []string {
+	var tokens []string
+	var token []byte
+	flush := func() {
+		if len(token) > 0 {
+			tokens = append(tokens, string(token))
+			token = token[:0]
+		}
+	}
+	inQuote := false
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		if inQuote {
+			token = append(token, ch)
+			if ch == '`' {
+				inQuote = false
+			}
+			continue
+		}
+		switch ch {
+		case '`':
+			inQuote = true
+			token = append(token, ch)
+		case ' ', '\t', '\r', '\n', '(', ')', ';':
+			flush()
+		case ',':
+			flush()
+			tokens = append(tokens, ",")
+		case '/':
+			//跳过/* */注释
+			if i+1 < len(sql) && sql[i+1] == '*' {
+				flush()
+				if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+					i += end + 3
+					continue
+				}
+				return tokens
+			}
+			token = append(token, ch)
+		default:
+			token = append(token, ch)
+		}
+	}
+	flush()
+	return tokens
+}
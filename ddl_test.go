@@ -0,0 +1,42 @@
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDdlTableNames(t *testing.T) {
+	db := func(name string) MysqlTableName { return MysqlTableName{DbName: name} }
+	tbl := NewMysqlTableName
+	testCases := []struct {
+		sql  string
+		want []MysqlTableName
+	}{
+		{"ALTER TABLE t ADD COLUMN c int", []MysqlTableName{tbl("test", "t")}},
+		{"alter online ignore table `db`.`t` add c int", []MysqlTableName{tbl("db", "t")}},
+		{"ALTER TABLE t RENAME TO db2.t2", []MysqlTableName{tbl("test", "t"), tbl("db2", "t2")}},
+		{"ALTER TABLE t RENAME COLUMN a TO b", []MysqlTableName{tbl("test", "t")}},
+		{"CREATE TABLE IF NOT EXISTS t2(id int primary key)", []MysqlTableName{tbl("test", "t2")}},
+		{"create temporary table `a``b` (id int)", []MysqlTableName{tbl("test", "a`b")}},
+		{"CREATE TABLE `my.db`.t (id int)", []MysqlTableName{tbl("my.db", "t")}},
+		{"CREATE UNIQUE INDEX idx ON t (c)", []MysqlTableName{tbl("test", "t")}},
+		{"DROP INDEX idx ON db.t", []MysqlTableName{tbl("db", "t")}},
+		{"DROP TABLE IF EXISTS a, db.b, `c` /* generated by server */",
+			[]MysqlTableName{tbl("test", "a"), tbl("db", "b"), tbl("test", "c")}},
+		{"TRUNCATE TABLE t", []MysqlTableName{tbl("test", "t")}},
+		{"truncate t;", []MysqlTableName{tbl("test", "t")}},
+		{"RENAME TABLE a TO b, db.c TO db.d",
+			[]MysqlTableName{tbl("test", "a"), tbl("test", "b"), tbl("db", "c"), tbl("db", "d")}},
+		{"DROP DATABASE IF EXISTS db2", []MysqlTableName{db("db2")}},
+		{"CREATE SCHEMA s", []MysqlTableName{db("s")}},
+		{"CREATE VIEW v AS SELECT 1", nil},
+		{"CREATE DEFINER=`root`@`%` TRIGGER tr BEFORE INSERT ON t FOR EACH ROW SET @a=1", nil},
+		{"ALTER", []MysqlTableName{db("test")}},
+		{"CREATE something unknown", []MysqlTableName{db("test")}},
+	}
+	for _, v := range testCases {
+		if out := ddlTableNames("test", v.sql); !reflect.DeepEqual(out, v.want) {
+			t.Errorf("ddlTableNames sql: %v want: %v out: %v", v.sql, v.want, out)
+		}
+	}
+}
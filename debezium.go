@@ -0,0 +1,145 @@
+package binlog
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+//debezium中的操作类型
+const (
+	debeziumOpCreate = "c"
+	debeziumOpUpdate = "u"
+	debeziumOpDelete = "d"
+)
+
+//DebeziumEncoder 将每一行的变更编码为一条debezium格式的json消息（不带schema），包括before、after、source、op以及ts_ms，
+//source中的信息来自事务的Position、ServerID以及GTID。没有行数据的sql语句（如DDL）不会产生消息
+type DebeziumEncoder struct {
+	serverName string
+	now        func() time.Time
+}
+
+//NewDebeziumEncoder serverName是debezium中的逻辑服务名，即source.name以及topic的前缀
+func NewDebeziumEncoder(serverName string) *DebeziumEncoder {
+	return &DebeziumEncoder{
+		serverName: serverName,
+		now:        time.Now,
+	}
+}
+
+//debeziumSource debezium消息中的source
+type debeziumSource struct {
+	Connector string  `json:"connector"`
+	Name      string  `json:"name"`
+	TsMs      int64   `json:"ts_ms"`
+	Snapshot  string  `json:"snapshot"`
+	Db        string  `json:"db"`
+	Table     string  `json:"table"`
+	ServerID  uint32  `json:"server_id"`
+	GTID      *string `json:"gtid"`
+	File      string  `json:"file"`
+	Pos       int64   `json:"pos"`
+	Row       int     `json:"row"`
+}
+
+//debeziumEnvelope debezium消息
+type debeziumEnvelope struct {
+	Before *orderedRow     `json:"before"`
+	After  *orderedRow     `json:"after"`
+	Source *debeziumSource `json:"source"`
+	Op     string          `json:"op"`
+	TsMs   int64           `json:"ts_ms"`
+}
+
+//Encode 实现Encoder
+func (e *DebeziumEncoder) Encode(tran *Transaction) ([][]byte, error) {
+	var msgs [][]byte
+	tsMs := e.now().UnixNano() / int64(time.Millisecond)
+	for _, ev := range tran.Events {
+		op := ""
+		switch ev.Type {
+		case StatementInsert:
+			op = debeziumOpCreate
+		case StatementUpdate:
+			op = debeziumOpUpdate
+		case StatementDelete:
+			op = debeziumOpDelete
+		default:
+			continue
+		}
+		if ev.SQL != "" {
+			continue
+		}
+
+		rows := len(ev.RowValues)
+		if op == debeziumOpDelete {
+			rows = len(ev.RowIdentifies)
+		}
+		value := func(c *ColumnData) (interface{}, error) {
+			return debeziumValue(c, tran.Location)
+		}
+		for i := 0; i < rows; i++ {
+			env := debeziumEnvelope{
+				Source: e.source(tran, ev, i),
+				Op:     op,
+				TsMs:   tsMs,
+			}
+			if op != debeziumOpCreate {
+				env.Before = &orderedRow{columns: ev.RowIdentifies[i].Columns, value: value}
+			}
+			if op != debeziumOpDelete {
+				env.After = &orderedRow{columns: ev.RowValues[i].Columns, value: value}
+			}
+
+			msg, err := json.Marshal(env)
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+func (e *DebeziumEncoder) source(tran *Transaction, ev *StreamEvent, row int) *debeziumSource {
+	s := &debeziumSource{
+		Connector: "mysql",
+		Name:      e.serverName,
+		TsMs:      ev.Timestamp * 1000,
+		Snapshot:  "false",
+		Db:        ev.Table.DbName,
+		Table:     ev.Table.TableName,
+		ServerID:  tran.ServerID,
+		File:      tran.NowPosition.Filename,
+		Pos:       tran.NowPosition.Offset,
+		Row:       row,
+	}
+	if tran.GTID != "" {
+		gtid := tran.GTID
+		s.GTID = &gtid
+	}
+	return s
+}
+
+//debeziumValue 按照debezium的默认配置转换列数据：DATE为天数，DATETIME以及TIME为微秒数，
+//TIMESTAMP为UTC的ISO-8601字符串，DECIMAL为字符串，二进制数据为base64
+func debeziumValue(c *ColumnData, loc *time.Location) (interface{}, error) {
+	v, err := columnValue(c, loc)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case epochDays:
+		return int32(v), nil
+	case epochMicros:
+		return int64(v), nil
+	case timeMicros:
+		return int64(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	}
+	return v, nil
+}
@@ -0,0 +1,97 @@
+package binlog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebeziumEncoder_Encode(t *testing.T) {
+	table := NewMysqlTableName("vt_test_keyspace", "vt_a")
+	tran := &Transaction{
+		NowPosition:  Position{Filename: "binlog.000001", Offset: 4},
+		NextPosition: Position{Filename: "binlog.000001", Offset: 300},
+		Timestamp:    1407805592,
+		ServerID:     62344,
+		GTID:         "439192bd-f37c-11e4-bbeb-0242ac11035a:4",
+		Events: []*StreamEvent{
+			{
+				Type:      StatementInsert,
+				Table:     table,
+				Timestamp: 1407805592,
+				RowValues: []*RowData{newTestRowData("1", "a"), newTestRowData("2", "b")},
+			},
+			{
+				Type:          StatementUpdate,
+				Table:         table,
+				Timestamp:     1407805592,
+				RowValues:     []*RowData{newTestRowData("1", "c")},
+				RowIdentifies: []*RowData{newTestRowData("1", "a")},
+			},
+			{
+				Type:          StatementDelete,
+				Table:         table,
+				Timestamp:     1407805592,
+				RowIdentifies: []*RowData{newTestRowData("2", "b")},
+			},
+			{
+				Type:  StatementAlter,
+				Table: table,
+				SQL:   "alter table vt_a add column c int",
+			},
+		},
+	}
+
+	e := NewDebeziumEncoder("dbserver1")
+	e.now = func() time.Time { return time.Unix(1407805600, 0) }
+	msgs, err := e.Encode(tran)
+	if err != nil {
+		t.Fatalf("Encode err: %v", err)
+	}
+
+	source := func(row int) string {
+		return `"source":{"connector":"mysql","name":"dbserver1","ts_ms":1407805592000,"snapshot":"false",` +
+			`"db":"vt_test_keyspace","table":"vt_a","server_id":62344,` +
+			`"gtid":"439192bd-f37c-11e4-bbeb-0242ac11035a:4","file":"binlog.000001","pos":4,"row":` +
+			string('0'+rune(row)) + `}`
+	}
+	want := []string{
+		`{"before":null,"after":{"id":1,"message":"a"},` + source(0) + `,"op":"c","ts_ms":1407805600000}`,
+		`{"before":null,"after":{"id":2,"message":"b"},` + source(1) + `,"op":"c","ts_ms":1407805600000}`,
+		`{"before":{"id":1,"message":"a"},"after":{"id":1,"message":"c"},` + source(0) + `,"op":"u","ts_ms":1407805600000}`,
+		`{"before":{"id":2,"message":"b"},"after":null,` + source(0) + `,"op":"d","ts_ms":1407805600000}`,
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("Encode want %d messages out: %d", len(want), len(msgs))
+	}
+	for i := range want {
+		if string(msgs[i]) != want[i] {
+			t.Fatalf("message %d\nwant: %s\nout:  %s", i, want[i], msgs[i])
+		}
+	}
+}
+
+func TestDebeziumValue(t *testing.T) {
+	testCases := []struct {
+		column *ColumnData
+		want   interface{}
+	}{
+		{column: &ColumnData{Type: ColumnTypeDate, Data: []byte("1970-01-11")}, want: int32(10)},
+		{column: &ColumnData{Type: ColumnTypeDate, Data: []byte("2019-02-30")}, want: nil},
+		{column: &ColumnData{Type: ColumnTypeDateTime, Data: []byte("1970-01-01 00:00:01")}, want: int64(1000000)},
+		{column: &ColumnData{Type: ColumnTypeTime, Data: []byte("00:00:01")}, want: int64(1000000)},
+		{column: &ColumnData{Type: ColumnTypeLongLong, Data: []byte("18446744073709551615")}, want: "18446744073709551615"},
+	}
+
+	for i, v := range testCases {
+		out, err := debeziumValue(v.column, nil)
+		if err != nil {
+			t.Fatalf("case %d err: %v", i, err)
+		}
+		if s, ok := out.(interface{ String() string }); ok {
+			out = s.String()
+		}
+		if out != v.want {
+			t.Fatalf("case %d want: %#v out: %#v", i, v.want, out)
+		}
+	}
+}
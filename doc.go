@@ -2,7 +2,9 @@
 Package binlog 将自己伪装成slave获取mysql主从复杂流来
 获取mysql数据库的数据变更，提供轻量级，快速的dump协议交互
 以及binlog的row模式下的格式解析。使用方式较为简单，首先你
-要实现一个MysqlTableMapper
+需要一个MysqlTableMapper，一般直接使用NewSchemaTableMapper，它从
+information_schema获取表信息并缓存，遇到DDL时自动失效。也可以自己
+实现一个MysqlTableMapper
 
 	type mysqlColumnAttribute struct {
 		field         string
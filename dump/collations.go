@@ -1,5 +1,7 @@
 package dump
 
+import "strings"
+
 const defaultCollation = "utf8_general_ci"
 
 // A list of available collations mapped to the internal ID.
@@ -225,6 +227,31 @@ var collations = map[string]byte{
 	"utf8mb4_croatian_ci":      245,
 	"utf8mb4_unicode_520_ci":   246,
 	"utf8mb4_vietnamese_ci":    247,
+	"utf8mb4_0900_ai_ci":       255,
+}
+
+//collationNames 从id到排序规则名称的映射
+var collationNames = func() map[uint64]string {
+	names := make(map[uint64]string, len(collations))
+	for name, id := range collations {
+		names[uint64(id)] = name
+	}
+	return names
+}()
+
+//CollationCharset 获取排序规则id对应的字符集名称，如45为utf8mb4，63为binary，未知的id返回空字符串
+func CollationCharset(id uint64) string {
+	if name, ok := collationNames[id]; ok {
+		if i := strings.IndexByte(name, '_'); i > 0 {
+			return name[:i]
+		}
+		return name
+	}
+	//mysql 8.0新增的utf8mb4_0900系列排序规则
+	if id >= 255 && id <= 323 {
+		return "utf8mb4"
+	}
+	return ""
 }
 
 // A blacklist of collations which is unsafe to interpolate parameters.
@@ -0,0 +1,25 @@
+package dump
+
+import "testing"
+
+func TestCollationCharset(t *testing.T) {
+	testCases := []struct {
+		id   uint64
+		want string
+	}{
+		{id: 8, want: "latin1"},
+		{id: 28, want: "gbk"},
+		{id: 33, want: "utf8"},
+		{id: 45, want: "utf8mb4"},
+		{id: 63, want: "binary"},
+		{id: 255, want: "utf8mb4"},
+		{id: 309, want: "utf8mb4"},
+		{id: 0, want: ""},
+		{id: 1000, want: ""},
+	}
+	for _, v := range testCases {
+		if out := CollationCharset(v.id); out != v.want {
+			t.Fatalf("CollationCharset(%v) want: %v out: %v", v.id, v.want, out)
+		}
+	}
+}
@@ -26,6 +26,7 @@ type MysqlConn struct {
 	sequence     uint8
 	parseTime    bool
 	strict       bool
+	logger       Logger
 }
 
 //NewMysqlConn dsn是数据库连接信息
@@ -143,6 +144,18 @@ func (mc *MysqlConn) handleParams() (err error) {
 	return
 }
 
+//SetLogger 设置该连接的错误日志，为nil时使用包级别SetLogger设置的日志
+func (mc *MysqlConn) SetLogger(logger Logger) {
+	mc.logger = logger
+}
+
+func (mc *MysqlConn) log() Logger {
+	if mc.logger != nil {
+		return mc.logger
+	}
+	return errLog
+}
+
 //Close 用于关闭连接和清理连接信息
 func (mc *MysqlConn) Close() (err error) {
 	// Makes Close idempotent
@@ -193,7 +206,7 @@ func (mc *MysqlConn) cleanup() {
 	// Makes cleanup idempotent
 	if mc.netConn != nil {
 		if err := mc.netConn.Close(); err != nil {
-			errLog.Print(err)
+			mc.log().Print(err)
 		}
 		mc.netConn = nil
 	}
@@ -205,6 +218,16 @@ func (mc *MysqlConn) Exec(query string) error {
 	return mc.exec(query)
 }
 
+//Query 执行查询语句并返回结果集，每一列的数据为[]byte或者nil，读取完毕后需要调用Close
+func (mc *MysqlConn) Query(query string) (MyRows, error) {
+	return mc.query(query)
+}
+
+//AffectedRows 获取上一条Exec语句影响的行数，DSN中设置clientFoundRows=true时为匹配的行数
+func (mc *MysqlConn) AffectedRows() uint64 {
+	return mc.affectedRows
+}
+
 //NoticeDump 通知开始从哪个binlog位置开始以serverID为编号开始同步数据
 func (mc *MysqlConn) NoticeDump(serverID, offset uint32, filename string, flags uint16) error {
 	return mc.writeDumpBinlogPosPacket(serverID, offset, filename, flags)
@@ -215,6 +238,17 @@ func (mc *MysqlConn) ReadPacket() ([]byte, error) {
 	return mc.readPacket()
 }
 
+//ReadInterrupter 返回一个可以在其他goroutine中调用的函数，调用后阻塞的ReadPacket会立即返回错误，
+//需要在调用ReadPacket的goroutine中获取，连接仍然只能由该goroutine关闭
+func (mc *MysqlConn) ReadInterrupter() func() {
+	nc := mc.netConn
+	return func() {
+		if nc != nil {
+			nc.SetReadDeadline(time.Now())
+		}
+	}
+}
+
 //HandleErrorPacket 处理mysql返回的错误
 func (mc *MysqlConn) HandleErrorPacket(data []byte) error {
 	return mc.handleErrorPacket(data)
@@ -23,6 +23,12 @@ const (
 	PacketERR = iERR //用于标识mysql反馈包是错误信息
 )
 
+// binlog dump的标志位
+// https://dev.mysql.com/doc/internals/en/com-binlog-dump.html
+const (
+	BinlogDumpNonBlock uint16 = 0x01 //主库发送完当前所有的binlog后返回EOF包，而不是阻塞等待新的binlog
+)
+
 // https://dev.mysql.com/doc/internals/en/capability-flags.html#packet-Protocol::CapabilityFlags
 type clientFlag uint32
 
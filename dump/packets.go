@@ -39,7 +39,7 @@ func (mc *MysqlConn) readPacket() ([]byte, error) {
 		}
 
 		if _, err := io.ReadFull(mc.reader, header[:]); err != nil {
-			errLog.Print(fmt.Errorf("io.ReadFull(header size) failed: %v", err))
+			mc.log().Print(fmt.Errorf("io.ReadFull(header size) failed: %v", err))
 			mc.Close()
 			return nil, ErrBadConn
 		}
@@ -64,7 +64,7 @@ func (mc *MysqlConn) readPacket() ([]byte, error) {
 		if pktLen == 0 {
 			// there was no previous packet
 			if prevData == nil {
-				errLog.Print(ErrMalformPkt)
+				mc.log().Print(ErrMalformPkt)
 				mc.Close()
 				return nil, ErrBadConn
 			}
@@ -79,7 +79,7 @@ func (mc *MysqlConn) readPacket() ([]byte, error) {
 
 		data := make([]byte, pktLen)
 		if _, err := io.ReadFull(mc.reader, data); err != nil {
-			errLog.Print("io.ReadFull(packet body of length %v) failed: %v", pktLen, err)
+			mc.log().Print(fmt.Errorf("io.ReadFull(packet body of length %v) failed: %v", pktLen, err))
 			mc.Close()
 			return nil, ErrBadConn
 		}
@@ -144,9 +144,9 @@ func (mc *MysqlConn) writePacket(data []byte) error {
 
 		// Handle error
 		if err == nil { // n != len(data)
-			errLog.Print(ErrMalformPkt)
+			mc.log().Print(ErrMalformPkt)
 		} else {
-			errLog.Print(err)
+			mc.log().Print(err)
 		}
 		return ErrBadConn
 	}
@@ -281,7 +281,7 @@ func (mc *MysqlConn) writeAuthPacket(cipher []byte) error {
 	data := make([]byte, pktLen+4)
 	if data == nil {
 		// can not take the buffer. Something must be wrong with the connection
-		errLog.Print(ErrBusyBuffer)
+		mc.log().Print(ErrBusyBuffer)
 		return ErrBadConn
 	}
 
@@ -366,7 +366,7 @@ func (mc *MysqlConn) writeOldAuthPacket(cipher []byte) error {
 	data := make([]byte, 4+pktLen)
 	if data == nil {
 		// can not take the buffer. Something must be wrong with the connection
-		errLog.Print(ErrBusyBuffer)
+		mc.log().Print(ErrBusyBuffer)
 		return ErrBadConn
 	}
 
@@ -385,7 +385,7 @@ func (mc *MysqlConn) writeClearAuthPacket() error {
 	data := make([]byte, 4+pktLen)
 	if data == nil {
 		// can not take the buffer. Something must be wrong with the connection
-		errLog.Print(ErrBusyBuffer)
+		mc.log().Print(ErrBusyBuffer)
 		return ErrBadConn
 	}
 
@@ -406,7 +406,7 @@ func (mc *MysqlConn) writeNativeAuthPacket(cipher []byte) error {
 	data := make([]byte, 4+pktLen)
 	if data == nil {
 		// can not take the buffer. Something must be wrong with the connection
-		errLog.Print(ErrBusyBuffer)
+		mc.log().Print(ErrBusyBuffer)
 		return ErrBadConn
 	}
 
@@ -428,7 +428,7 @@ func (mc *MysqlConn) writeCommandPacketStr(command byte, arg string) error {
 	data := make([]byte, pktLen+4)
 	if data == nil {
 		// can not take the buffer. Something must be wrong with the connection
-		errLog.Print(ErrBusyBuffer)
+		mc.log().Print(ErrBusyBuffer)
 		return ErrBadConn
 	}
 
@@ -449,7 +449,7 @@ func (mc *MysqlConn) writeCommandPacket(command byte) error {
 	data := make([]byte, 4+1)
 	if data == nil {
 		// can not take the buffer. Something must be wrong with the connection
-		errLog.Print(ErrBusyBuffer)
+		mc.log().Print(ErrBusyBuffer)
 		return ErrBadConn
 	}
 
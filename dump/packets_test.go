@@ -2,7 +2,9 @@ package dump
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
+	"log"
 	"net"
 	"testing"
 	"time"
@@ -271,3 +273,69 @@ func TestReadPacketFail(t *testing.T) {
 		t.Errorf("expected ErrBadConn, got %v", err)
 	}
 }
+
+func TestReadPacketFailConnLogger(t *testing.T) {
+	previous := errLog
+	defer func() {
+		errLog = previous
+	}()
+	global := bytes.NewBuffer(nil)
+	SetLogger(log.New(global, "", 0))
+
+	conn := new(mockConn)
+	buffer := bytes.NewBuffer(nil)
+	mc := &MysqlConn{
+		reader: bufio.NewReaderSize(conn, defaultBufSize),
+	}
+	mc.SetLogger(log.New(buffer, "", 0))
+
+	// fail to read body
+	conn.data = []byte{0x05, 0x00, 0x00, 0x00, 0x01}
+	conn.maxReads = 1
+	if _, err := mc.readPacket(); err != ErrBadConn {
+		t.Errorf("expected ErrBadConn, got %v", err)
+	}
+	const expected = "io.ReadFull(packet body of length 5) failed: too many reads\n"
+	if actual := buffer.String(); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	if global.Len() != 0 {
+		t.Errorf("expected nothing in global logger, got %q", global.String())
+	}
+}
+
+func TestReadInterrupter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		//服务端不发送任何数据，readPacket会一直阻塞
+		if c, err := ln.Accept(); err == nil {
+			defer c.Close()
+			bufio.NewReader(c).ReadBytes(0)
+		}
+	}()
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := &MysqlConn{
+		netConn: nc,
+		reader:  bufio.NewReaderSize(nc, defaultBufSize),
+	}
+	interrupt := mc.ReadInterrupter()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		interrupt()
+	}()
+	if _, err = mc.readPacket(); err != ErrBadConn {
+		t.Fatalf("expected ErrBadConn, got %v", err)
+	}
+	if mc.netConn != nil {
+		t.Fatalf("connection should be closed by readPacket")
+	}
+	//连接关闭后调用也不会出错
+	interrupt()
+}
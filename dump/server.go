@@ -0,0 +1,358 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// 服务端使用的命令类型
+const (
+	ComQuit       = comQuit       //关闭连接
+	ComInitDB     = comInitDB     //切换数据库
+	ComQuery      = comQuery      //查询
+	ComPing       = comPing       //ping
+	ComBinlogDump = comBinlogDump //binlog dump
+)
+
+//服务端声明支持的能力
+const serverCapability = clientLongPassword |
+	clientFoundRows |
+	clientLongFlag |
+	clientConnectWithDB |
+	clientProtocol41 |
+	clientTransactions |
+	clientSecureConn |
+	clientMultiResults |
+	clientPluginAuth
+
+const (
+	serverStatusAutocommit uint16 = 0x0002
+	serverCharsetUTF8      byte   = 33 // utf8_general_ci
+	nativePasswordAuthName        = "mysql_native_password"
+)
+
+//ErrAccessDenied 服务端鉴权失败
+var ErrAccessDenied = errors.New("access denied")
+
+//ServerConn 服务端的mysql协议连接，实现握手、mysql_native_password鉴权、OK/ERR/EOF包、
+//文本结果集以及binlog dump的应答，用于实现模拟的mysql主库
+type ServerConn struct {
+	netConn      net.Conn
+	reader       *bufio.Reader
+	sequence     uint8
+	connectionID uint32
+	salt         []byte
+	user         string
+	dbName       string
+	flags        clientFlag
+}
+
+//NewServerConn 使用已经建立的网络连接创建ServerConn，connectionID是该连接的编号
+func NewServerConn(conn net.Conn, connectionID uint32) *ServerConn {
+	return &ServerConn{
+		netConn:      conn,
+		reader:       bufio.NewReaderSize(conn, defaultBufSize),
+		connectionID: connectionID,
+	}
+}
+
+//User 获取客户端登录的用户名
+func (c *ServerConn) User() string {
+	return c.user
+}
+
+//DBName 获取客户端登录时指定的数据库
+func (c *ServerConn) DBName() string {
+	return c.dbName
+}
+
+//Close 关闭连接
+func (c *ServerConn) Close() error {
+	return c.netConn.Close()
+}
+
+//Handshake 发送握手包并读取客户端的鉴权包，使用mysql_native_password检查密码，
+//users为用户名到密码的映射，鉴权失败时发送错误包并返回ErrAccessDenied
+func (c *ServerConn) Handshake(serverVersion string, users map[string]string) error {
+	c.salt = make([]byte, 20)
+	if _, err := rand.Read(c.salt); err != nil {
+		return err
+	}
+	//salt中不能包含0
+	for i := range c.salt {
+		c.salt[i] = c.salt[i]&0x7f | 0x01
+	}
+
+	if err := c.writeHandshakePacket(serverVersion); err != nil {
+		return err
+	}
+
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	authResponse, err := c.parseAuthPacket(data)
+	if err != nil {
+		return err
+	}
+
+	password, ok := users[c.user]
+	if !ok || !bytes.Equal(authResponse, scramblePassword(c.salt, []byte(password))) {
+		msg := fmt.Sprintf("Access denied for user '%s'", c.user)
+		if err := c.WriteError(1045, "28000", msg); err != nil {
+			return err
+		}
+		return ErrAccessDenied
+	}
+	return c.WriteOK(0, 0)
+}
+
+// Handshake Initialization Packet
+// http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeV10
+func (c *ServerConn) writeHandshakePacket(serverVersion string) error {
+	data := make([]byte, 4, 128)
+	data = append(data, minProtocolVersion)
+	data = append(data, serverVersion...)
+	data = append(data, 0x00)
+	data = appendUint32(data, c.connectionID)
+	data = append(data, c.salt[:8]...)
+	data = append(data, 0x00)
+	data = appendUint16(data, uint16(serverCapability&0xffff))
+	data = append(data, serverCharsetUTF8)
+	data = appendUint16(data, serverStatusAutocommit)
+	data = appendUint16(data, uint16(serverCapability>>16))
+	data = append(data, byte(len(c.salt)+1))
+	data = append(data, make([]byte, 10)...)
+	data = append(data, c.salt[8:]...)
+	data = append(data, 0x00)
+	data = append(data, nativePasswordAuthName...)
+	data = append(data, 0x00)
+	return c.writePacket(data)
+}
+
+// Client Authentication Packet
+// http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeResponse
+func (c *ServerConn) parseAuthPacket(data []byte) ([]byte, error) {
+	if len(data) < 4+4+1+23 {
+		return nil, ErrMalformPkt
+	}
+	c.flags = clientFlag(binary.LittleEndian.Uint32(data[:4]))
+	if c.flags&clientProtocol41 == 0 {
+		return nil, ErrOldProtocol
+	}
+	pos := 4 + 4 + 1 + 23
+
+	// User [null terminated string]
+	end := bytes.IndexByte(data[pos:], 0x00)
+	if end < 0 {
+		return nil, ErrMalformPkt
+	}
+	c.user = string(data[pos : pos+end])
+	pos += end + 1
+
+	// ScrambleBuffer [length encoded string]
+	authResponse, _, n, err := readLengthEncodedString(data[pos:])
+	if err != nil {
+		return nil, err
+	}
+	pos += n
+
+	// Databasename [null terminated string]
+	if c.flags&clientConnectWithDB != 0 && pos < len(data) {
+		end = bytes.IndexByte(data[pos:], 0x00)
+		if end < 0 {
+			return nil, ErrMalformPkt
+		}
+		c.dbName = string(data[pos : pos+end])
+	}
+	return authResponse, nil
+}
+
+//ReadCommand 读取客户端的命令包，返回命令类型以及参数
+func (c *ServerConn) ReadCommand() (byte, []byte, error) {
+	c.sequence = 0
+	data, err := c.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) == 0 {
+		return 0, nil, ErrMalformPkt
+	}
+	return data[0], data[1:], nil
+}
+
+//ParseBinlogDump 解析binlog dump命令的参数
+// https://dev.mysql.com/doc/internals/en/com-binlog-dump.html
+func ParseBinlogDump(data []byte) (serverID, offset uint32, filename string, flags uint16, err error) {
+	if len(data) < 4+2+4 {
+		return 0, 0, "", 0, ErrMalformPkt
+	}
+	offset = binary.LittleEndian.Uint32(data[0:4])
+	flags = binary.LittleEndian.Uint16(data[4:6])
+	serverID = binary.LittleEndian.Uint32(data[6:10])
+	filename = string(data[10:])
+	return serverID, offset, filename, flags, nil
+}
+
+//WriteOK 发送OK包
+// http://dev.mysql.com/doc/internals/en/generic-response-packets.html#packet-OK_Packet
+func (c *ServerConn) WriteOK(affectedRows, insertID uint64) error {
+	data := make([]byte, 4, 32)
+	data = append(data, iOK)
+	data = appendLengthEncodedInteger(data, affectedRows)
+	data = appendLengthEncodedInteger(data, insertID)
+	data = appendUint16(data, serverStatusAutocommit)
+	data = appendUint16(data, 0)
+	return c.writePacket(data)
+}
+
+//WriteError 发送错误包，sqlState为5个字符的sql状态，如HY000
+// http://dev.mysql.com/doc/internals/en/generic-response-packets.html#packet-ERR_Packet
+func (c *ServerConn) WriteError(number uint16, sqlState, message string) error {
+	if len(sqlState) != 5 {
+		sqlState = "HY000"
+	}
+	data := make([]byte, 4, 4+1+2+1+5+len(message))
+	data = append(data, iERR)
+	data = appendUint16(data, number)
+	data = append(data, '#')
+	data = append(data, sqlState...)
+	data = append(data, message...)
+	return c.writePacket(data)
+}
+
+//WriteEOF 发送EOF包，binlog dump时用于通知客户端binlog已经发送完毕
+// http://dev.mysql.com/doc/internals/en/generic-response-packets.html#packet-EOF_Packet
+func (c *ServerConn) WriteEOF() error {
+	data := make([]byte, 4, 9)
+	data = append(data, iEOF)
+	data = appendUint16(data, 0)
+	data = appendUint16(data, serverStatusAutocommit)
+	return c.writePacket(data)
+}
+
+//WriteTextResult 发送文本结果集，所有的列都是字符串类型，rows中的nil代表NULL
+// http://dev.mysql.com/doc/internals/en/com-query-response.html#packet-ProtocolText::Resultset
+func (c *ServerConn) WriteTextResult(columns []string, rows [][][]byte) error {
+	if err := c.writePacket(appendLengthEncodedInteger(make([]byte, 4), uint64(len(columns)))); err != nil {
+		return err
+	}
+
+	for _, name := range columns {
+		data := make([]byte, 4, 64)
+		data = appendLengthEncodedString(data, []byte("def")) // catalog
+		data = appendLengthEncodedString(data, nil)           // schema
+		data = appendLengthEncodedString(data, nil)           // table
+		data = appendLengthEncodedString(data, nil)           // org_table
+		data = appendLengthEncodedString(data, []byte(name))  // name
+		data = appendLengthEncodedString(data, nil)           // org_name
+		data = append(data, 0x0c)                             // length of fixed-length fields
+		data = appendUint16(data, uint16(serverCharsetUTF8))
+		data = appendUint32(data, 1024) // column length
+		data = append(data, byte(fieldTypeVarString))
+		data = appendUint16(data, 0) // flags
+		data = append(data, 0)       // decimals
+		data = appendUint16(data, 0) // filler
+		if err := c.writePacket(data); err != nil {
+			return err
+		}
+	}
+	if err := c.WriteEOF(); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			return fmt.Errorf("the length of row(%d) did not equal to the length of columns(%d)",
+				len(row), len(columns))
+		}
+		data := make([]byte, 4, 64)
+		for _, v := range row {
+			if v == nil {
+				data = append(data, 0xfb)
+				continue
+			}
+			data = appendLengthEncodedString(data, v)
+		}
+		if err := c.writePacket(data); err != nil {
+			return err
+		}
+	}
+	return c.WriteEOF()
+}
+
+//WriteBinlogEvent 发送binlog dump的一个binlog event
+func (c *ServerConn) WriteBinlogEvent(event []byte) error {
+	data := make([]byte, 4, 4+1+len(event))
+	data = append(data, iOK)
+	data = append(data, event...)
+	return c.writePacket(data)
+}
+
+func (c *ServerConn) readPacket() ([]byte, error) {
+	var prevData []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return nil, err
+		}
+		pktLen := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		if header[3] != c.sequence {
+			return nil, ErrPktSync
+		}
+		c.sequence++
+
+		data := make([]byte, pktLen)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		prevData = append(prevData, data...)
+		if pktLen < maxPacketSize {
+			return prevData, nil
+		}
+	}
+}
+
+//writePacket data的前4个字节为包头
+func (c *ServerConn) writePacket(data []byte) error {
+	pktLen := len(data) - 4
+	for {
+		size := pktLen
+		if size >= maxPacketSize {
+			size = maxPacketSize
+		}
+		data[0] = byte(size)
+		data[1] = byte(size >> 8)
+		data[2] = byte(size >> 16)
+		data[3] = c.sequence
+
+		if _, err := c.netConn.Write(data[:4+size]); err != nil {
+			return err
+		}
+		c.sequence++
+		if size != maxPacketSize {
+			return nil
+		}
+		pktLen -= size
+		data = data[size:]
+	}
+}
+
+func appendUint16(b []byte, n uint16) []byte {
+	return append(b, byte(n), byte(n>>8))
+}
+
+func appendUint32(b []byte, n uint32) []byte {
+	return append(b, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+}
+
+func appendLengthEncodedString(b []byte, s []byte) []byte {
+	b = appendLengthEncodedInteger(b, uint64(len(s)))
+	return append(b, s...)
+}
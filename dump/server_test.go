@@ -0,0 +1,25 @@
+package dump
+
+import (
+	"testing"
+)
+
+func TestParseBinlogDump(t *testing.T) {
+	data := []byte{
+		0x04, 0x00, 0x00, 0x00, // offset
+		0x01, 0x00, // flags
+		0xd2, 0x04, 0x00, 0x00, // server id
+		'b', 'i', 'n', 'l', 'o', 'g', '.', '0', '0', '0', '0', '0', '1',
+	}
+	serverID, offset, filename, flags, err := ParseBinlogDump(data)
+	if err != nil {
+		t.Fatalf("ParseBinlogDump err: %v", err)
+	}
+	if serverID != 1234 || offset != 4 || filename != "binlog.000001" || flags != BinlogDumpNonBlock {
+		t.Fatalf("ParseBinlogDump out: %v %v %v %v", serverID, offset, filename, flags)
+	}
+
+	if _, _, _, _, err = ParseBinlogDump(data[:9]); err == nil {
+		t.Fatalf("ParseBinlogDump short packet want err")
+	}
+}
@@ -730,3 +730,13 @@ func escapeStringQuotes(buf []byte, v string) []byte {
 
 	return buf[:pos]
 }
+
+//EscapeBytesBackslash 使用反斜杠转义v并追加到buf中，用于生成默认sql_mode下的字符串字面量
+func EscapeBytesBackslash(buf, v []byte) []byte {
+	return escapeBytesBackslash(buf, v)
+}
+
+//EscapeBytesQuotes 将v中的单引号变为两个单引号并追加到buf中，用于NO_BACKSLASH_ESCAPES的sql_mode
+func EscapeBytesQuotes(buf, v []byte) []byte {
+	return escapeBytesQuotes(buf, v)
+}
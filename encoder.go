@@ -0,0 +1,203 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Encoder 将事务编码为下游可以直接消费的消息，返回的每个元素是一条消息
+type Encoder interface {
+	Encode(tran *Transaction) ([][]byte, error)
+}
+
+//JSONEncoder 将整个事务编码为一条json消息，默认使用JSONVersion1即Transaction.MarshalJSON
+type JSONEncoder struct {
+	version int
+}
+
+//NewJSONEncoder 创建JSONEncoder
+func NewJSONEncoder() *JSONEncoder {
+	return &JSONEncoder{version: JSONVersion1}
+}
+
+//SetVersion 设置json的格式版本，JSONVersion2使用Transaction.MarshalJSONV2
+func (e *JSONEncoder) SetVersion(version int) error {
+	if version != JSONVersion1 && version != JSONVersion2 {
+		return fmt.Errorf("SetVersion unsupported json version %v", version)
+	}
+	e.version = version
+	return nil
+}
+
+//Encode 实现Encoder
+func (e *JSONEncoder) Encode(tran *Transaction) ([][]byte, error) {
+	var msg []byte
+	var err error
+	if e.version == JSONVersion2 {
+		msg, err = tran.MarshalJSONV2()
+	} else {
+		msg, err = json.Marshal(tran)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{msg}, nil
+}
+
+//epochDays DATE类型的值，距离1970-01-01的天数
+type epochDays int32
+
+//epochMicros DATETIME类型的值，将其视为UTC时间后距离1970-01-01 00:00:00的微秒数
+type epochMicros int64
+
+//timeMicros TIME类型的值，距离00:00:00的微秒数，可以为负数
+type timeMicros int64
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04:05.999999"
+)
+
+//columnValue 将CellBytes得到的文本转换为有类型的值，供各个Encoder使用，
+//NULL以及零值或者非法日期(如'2019-02-30')返回nil，整形为int64（超过int64的无符号整形为uint64），实数为float64，
+//DATE、DATETIME、TIME分别为epochDays、epochMicros、timeMicros，TIMESTAMP为UTC的time.Time，
+//blob、bit以及几何类型为[]byte(几何类型总是mysql格式的数据)，其余类型为string，loc为格式化TIMESTAMP列时使用的时区(Transaction.Location)，nil时为UTC
+func columnValue(c *ColumnData, loc *time.Location) (interface{}, error) {
+	if c.Data == nil {
+		return nil, nil
+	}
+	s := string(c.Data)
+	switch {
+	case c.Type.IsInteger() || c.Type == ColumnTypeYear:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v, nil
+		}
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("columnValue invalid integer %v of %v", s, c.Filed)
+		}
+		return v, nil
+	case c.Type.IsFloat():
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("columnValue invalid float %v of %v", s, c.Filed)
+		}
+		return v, nil
+	case c.Type.IsDate():
+		if isInvalidDate(s) {
+			return nil, nil
+		}
+		t, err := time.ParseInLocation(dateLayout, s, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("columnValue invalid date %v of %v", s, c.Filed)
+		}
+		return epochDays(t.Unix() / 86400), nil
+	case c.Type.IsDateTime():
+		if isInvalidDate(s) {
+			return nil, nil
+		}
+		t, err := time.ParseInLocation(dateTimeLayout, s, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("columnValue invalid datetime %v of %v", s, c.Filed)
+		}
+		return epochMicros(t.Unix()*1000000 + int64(t.Nanosecond()/1000)), nil
+	case c.Type.IsTimestamp():
+		if isInvalidDate(s) {
+			return nil, nil
+		}
+		if loc == nil {
+			loc = time.UTC
+		}
+		t, err := time.ParseInLocation(dateTimeLayout, s, loc)
+		if err != nil {
+			return nil, fmt.Errorf("columnValue invalid timestamp %v of %v", s, c.Filed)
+		}
+		return t.UTC(), nil
+	case c.Type.IsTime():
+		v, err := parseTimeMicros(s)
+		if err != nil {
+			return nil, fmt.Errorf("columnValue invalid time %v of %v", s, c.Filed)
+		}
+		return v, nil
+	case c.Type.IsGeometry():
+		return c.geometryWKB(), nil
+	case c.Type.IsBlob() || c.Type.IsBit():
+		return c.Data, nil
+	default:
+		return s, nil
+	}
+}
+
+//parseTimeMicros 解析[-]HHH:MM:SS[.ffffff]格式的TIME
+func parseTimeMicros(s string) (timeMicros, error) {
+	sign := int64(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+	frac := int64(0)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits := (s[i+1:] + "000000")[:6]
+		v, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		frac, s = v, s[:i]
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %v", s)
+	}
+	var hms [3]int64
+	for i, p := range parts {
+		v, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		hms[i] = v
+	}
+	micros := ((hms[0]*60+hms[1])*60+hms[2])*1000000 + frac
+	return timeMicros(sign * micros), nil
+}
+
+//orderedRow 按照列的顺序将行数据序列化为json对象，IsEmpty的列不输出
+type orderedRow struct {
+	columns []*ColumnData
+	value   func(*ColumnData) (interface{}, error)
+}
+
+//MarshalJSON 实现orderedRow的json序列化
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	first := true
+	for _, c := range r.columns {
+		if c.IsEmpty {
+			continue
+		}
+		v, err := r.value(c)
+		if err != nil {
+			return nil, err
+		}
+		key, err := json.Marshal(c.Filed)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,67 @@
+package binlog
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestColumnValue(t *testing.T) {
+	ts := time.Date(2019, time.March, 1, 10, 20, 30, 0, time.UTC)
+	testCases := []struct {
+		column  *ColumnData
+		loc     *time.Location
+		want    interface{}
+		wantErr bool
+	}{
+		{column: &ColumnData{Type: ColumnTypeLong}, want: nil},
+		{column: &ColumnData{Type: ColumnTypeLong, Data: []byte("-12")}, want: int64(-12)},
+		{column: &ColumnData{Type: ColumnTypeLongLong, Data: []byte("18446744073709551615")}, want: uint64(18446744073709551615)},
+		{column: &ColumnData{Type: ColumnTypeLong, Data: []byte("x")}, wantErr: true},
+		{column: &ColumnData{Type: ColumnTypeYear, Data: []byte("2019")}, want: int64(2019)},
+		{column: &ColumnData{Type: ColumnTypeDouble, Data: []byte("1.5")}, want: 1.5},
+		{column: &ColumnData{Type: ColumnTypeNewDecimal, Data: []byte("1.50")}, want: "1.50"},
+		{column: &ColumnData{Type: ColumnTypeDate, Data: []byte("1970-01-11")}, want: epochDays(10)},
+		{column: &ColumnData{Type: ColumnTypeDate, Data: []byte("0000-00-00")}, want: nil},
+		{column: &ColumnData{Type: ColumnTypeDate, Data: []byte("2019-02-00")}, want: nil},
+		{column: &ColumnData{Type: ColumnTypeDateTime2, Data: []byte("2019-02-30 10:20:30")}, want: nil},
+		{column: &ColumnData{Type: ColumnTypeDateTime2, Data: []byte("1970-01-01 00:00:01.5")}, want: epochMicros(1500000)},
+		{column: &ColumnData{Type: ColumnTypeTimestamp2, Data: []byte("2019-03-01 10:20:30")}, want: ts},
+		{column: &ColumnData{Type: ColumnTypeTimestamp2, Data: []byte("2019-03-01 18:20:30")},
+			loc: time.FixedZone("UTC+8", 8*3600), want: ts},
+		{column: &ColumnData{Type: ColumnTypeTimestamp, Data: []byte("0000-00-00 00:00:00")}, want: nil},
+		{column: &ColumnData{Type: ColumnTypeTimestamp2, Data: []byte("2019-00-01 00:00:00")}, want: nil},
+		{column: &ColumnData{Type: ColumnTypeTime2, Data: []byte("-838:59:59.000001")}, want: timeMicros(-3020399000001)},
+		{column: &ColumnData{Type: ColumnTypeTime, Data: []byte("01:02")}, wantErr: true},
+		{column: &ColumnData{Type: ColumnTypeBlob, Data: []byte{0, 1}}, want: []byte{0, 1}},
+		{column: &ColumnData{Type: ColumnTypeGeometry, Data: []byte("POINT(1 2)"), Raw: []byte{0, 1}}, want: []byte{0, 1}},
+		{column: &ColumnData{Type: ColumnTypeVarchar, Data: []byte("abc")}, want: "abc"},
+	}
+
+	for i, v := range testCases {
+		out, err := columnValue(v.column, v.loc)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("case %d wantErr: %v err: %v", i, v.wantErr, err)
+		}
+		if err == nil && !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("case %d want: %#v out: %#v", i, v.want, out)
+		}
+	}
+}
+
+func TestJSONEncoder_Encode(t *testing.T) {
+	tran := &Transaction{
+		NowPosition:  Position{Filename: "binlog.000001", Offset: 4},
+		NextPosition: Position{Filename: "binlog.000001", Offset: 100},
+		Events:       []*StreamEvent{},
+	}
+	msgs, err := NewJSONEncoder().Encode(tran)
+	if err != nil {
+		t.Fatalf("Encode err: %v", err)
+	}
+	want, _ := json.Marshal(tran)
+	if len(msgs) != 1 || string(msgs[0]) != string(want) {
+		t.Fatalf("Encode want: %s out: %s", want, msgs)
+	}
+}
@@ -0,0 +1,100 @@
+package binlog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+//columnEnumValues 获取ENUM以及SET列的成员，优先使用TABLE_MAP_EVENT中的成员(binlog_row_metadata=FULL)，
+//没有时使用实现了MysqlEnumColumn的列的成员，都没有时为nil
+func columnEnumValues(tm *replication.TableMap, table MysqlTable) [][]string {
+	var values [][]string
+	for c, column := range table.Columns() {
+		if !replication.IsEnumOrSetType(tm.Types[c], tm.Metadata[c]) {
+			continue
+		}
+		var members []string
+		if tm.EnumValues != nil && tm.EnumValues[c] != nil {
+			members = decodeEnumValues(tm, c, tm.EnumValues[c])
+		} else if ec, ok := column.(MysqlEnumColumn); ok {
+			members = ec.EnumValues()
+		}
+		if members == nil {
+			continue
+		}
+		if values == nil {
+			values = make([][]string, len(tm.Types))
+		}
+		values[c] = members
+	}
+	return values
+}
+
+//decodeEnumValues TABLE_MAP_EVENT中的成员使用列的字符集编码，将其转为UTF-8，无法转码时保持原样
+func decodeEnumValues(tm *replication.TableMap, c int, members []string) []string {
+	if tm.ColumnCollations == nil {
+		return members
+	}
+	decoder, ok := charsetDecoder(dump.CollationCharset(tm.ColumnCollations[c]))
+	if !ok {
+		return members
+	}
+	out := make([]string, 0, len(members))
+	for _, member := range members {
+		data, err := decoder([]byte(member))
+		if err != nil {
+			return members
+		}
+		out = append(out, string(data))
+	}
+	return out
+}
+
+//decodeEnum 将ENUM列的索引以及SET列的位图转为成员名，设置Labels以及Data，成员未知时保持原样
+func (tc *tableCache) decodeEnum(c int, column *ColumnData) error {
+	if tc.enumValues == nil || tc.enumValues[c] == nil {
+		return nil
+	}
+	n, err := strconv.ParseUint(string(column.Data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("decode enum column %v fail. data: %s err: %v", column.Filed, column.Data, err)
+	}
+
+	labels, err := enumLabels(replication.RealType(tc.tableMap.Types[c], tc.tableMap.Metadata[c]),
+		tc.enumValues[c], n)
+	if err != nil {
+		return fmt.Errorf("decode enum column %v fail. err: %v", column.Filed, err)
+	}
+	column.Labels = labels
+	column.Data = []byte(strings.Join(labels, ","))
+	return nil
+}
+
+//enumLabels ENUM的索引从1开始，0表示插入了非法值，对应空字符串且没有成员；SET的第i位对应第i个成员
+func enumLabels(typ byte, members []string, n uint64) ([]string, error) {
+	labels := []string{}
+	if typ == replication.TypeEnum {
+		if n == 0 {
+			return labels, nil
+		}
+		if n > uint64(len(members)) {
+			return nil, fmt.Errorf("enum index %v out of range %v", n, len(members))
+		}
+		return append(labels, members[n-1]), nil
+	}
+
+	for i := uint(0); n != 0; i++ {
+		if n&1 != 0 {
+			if i >= uint(len(members)) {
+				return nil, fmt.Errorf("set bit %v out of range %v", i, len(members))
+			}
+			labels = append(labels, members[i])
+		}
+		n >>= 1
+	}
+	return labels, nil
+}
@@ -0,0 +1,184 @@
+package binlog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/onlyac0611/binlog/replication"
+)
+
+type enumColumn struct {
+	mysqlColumnAttribute
+	values []string
+}
+
+func (c *enumColumn) EnumValues() []string {
+	return c.values
+}
+
+//staticMapper 总是返回同一个表信息
+type staticMapper struct {
+	table MysqlTable
+}
+
+func (m *staticMapper) MysqlTable(name MysqlTableName) (MysqlTable, error) {
+	return m.table, nil
+}
+
+func TestEnumLabels(t *testing.T) {
+	members := []string{"a", "b", "c"}
+	testCases := []struct {
+		typ     byte
+		n       uint64
+		want    []string
+		wantErr bool
+	}{
+		{typ: replication.TypeEnum, n: 0, want: []string{}},
+		{typ: replication.TypeEnum, n: 2, want: []string{"b"}},
+		{typ: replication.TypeEnum, n: 4, wantErr: true},
+		{typ: replication.TypeSet, n: 0, want: []string{}},
+		{typ: replication.TypeSet, n: 5, want: []string{"a", "c"}},
+		{typ: replication.TypeSet, n: 8, wantErr: true},
+	}
+	for _, v := range testCases {
+		out, err := enumLabels(v.typ, members, v.n)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("enumLabels(%v, %v) wantErr: %v err: %v", v.typ, v.n, v.wantErr, err)
+		}
+		if err == nil && !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("enumLabels(%v, %v) want: %q out: %q", v.typ, v.n, v.want, out)
+		}
+	}
+}
+
+func TestColumnEnumValues(t *testing.T) {
+	tm := &replication.TableMap{
+		Types:    []byte{replication.TypeLong, replication.TypeString, replication.TypeString},
+		Metadata: []uint16{0, uint16(replication.TypeEnum)<<8 | 1, uint16(replication.TypeSet)<<8 | 1},
+	}
+	table := &mysqlTableInfo{
+		columns: []MysqlColumn{
+			&mysqlColumnAttribute{field: "id"},
+			&enumColumn{mysqlColumnAttribute: mysqlColumnAttribute{field: "status"}, values: []string{"on", "off"}},
+			&mysqlColumnAttribute{field: "tags"},
+		},
+	}
+
+	testCases := []struct {
+		enumValues [][]string
+		collations []uint64
+		want       [][]string
+	}{
+		{want: [][]string{nil, {"on", "off"}, nil}},
+		{
+			enumValues: [][]string{nil, {"a"}, {"x", "y"}},
+			want:       [][]string{nil, {"a"}, {"x", "y"}},
+		},
+		{
+			enumValues: [][]string{nil, nil, {"caf\xe9"}},
+			collations: []uint64{0, 45, 8},
+			want:       [][]string{nil, {"on", "off"}, {"café"}},
+		},
+	}
+	for _, v := range testCases {
+		tm.EnumValues = v.enumValues
+		tm.ColumnCollations = v.collations
+		if out := columnEnumValues(tm, table); !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("columnEnumValues with %q want: %q out: %q", v.enumValues, v.want, out)
+		}
+	}
+
+	tm.EnumValues = nil
+	table.columns[1] = &mysqlColumnAttribute{field: "status"}
+	if out := columnEnumValues(tm, table); out != nil {
+		t.Fatalf("columnEnumValues without members want nil out: %q", out)
+	}
+}
+
+func TestRowStreamer_parseEvents_Enum(t *testing.T) {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	tableID := uint64(0x102030405060)
+	tm := &replication.TableMap{
+		Database:   "vt_test_keyspace",
+		Name:       "vt_a",
+		Types:      []byte{replication.TypeLong, replication.TypeString, replication.TypeString},
+		CanBeNull:  replication.NewServerBitmap(3),
+		Metadata:   []uint16{0, uint16(replication.TypeEnum)<<8 | 1, uint16(replication.TypeSet)<<8 | 1},
+		EnumValues: [][]string{nil, {"on", "off"}, {"x", "y", "z"}},
+	}
+	insertRows := replication.Rows{
+		DataColumns: replication.NewServerBitmap(3),
+		Rows: []replication.Row{
+			{
+				NullColumns: replication.NewServerBitmap(3),
+				Data:        []byte{0x01, 0x00, 0x00, 0x00, 0x02, 0x05},
+			},
+		},
+	}
+	for c := 0; c < 3; c++ {
+		insertRows.DataColumns.Set(c, true)
+	}
+	input := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, uint64(testBinlogPosParseEvents.Offset), testBinlogPosParseEvents.Filename),
+		replication.NewFormatDescriptionEvent(f, s),
+		replication.NewTableMapEvent(f, s, tableID, tm),
+		replication.NewQueryEvent(f, s, replication.Query{Database: "vt_test_keyspace", SQL: "BEGIN"}),
+		replication.NewWriteRowsEvent(f, s, tableID, insertRows),
+		replication.NewXIDEvent(f, s),
+	}
+
+	m := &staticMapper{
+		table: &mysqlTableInfo{
+			name: NewMysqlTableName("vt_test_keyspace", "vt_a"),
+			columns: []MysqlColumn{
+				&mysqlColumnAttribute{field: "id"},
+				&enumColumn{mysqlColumnAttribute: mysqlColumnAttribute{field: "status"}, values: []string{"a", "b"}},
+				&enumColumn{mysqlColumnAttribute: mysqlColumnAttribute{field: "tags"}},
+			},
+		},
+	}
+	r, err := NewRowStreamer(testDSN, testServerID, m)
+	if err != nil {
+		t.Fatalf("NewRowStreamer err: %v", err)
+	}
+	r.SetStartBinlogPosition(testBinlogPosParseEvents)
+	var out *Transaction
+	r.sendTransaction = func(tran *Transaction) error {
+		out = tran
+		return nil
+	}
+
+	events := make(chan replication.BinlogEvent, len(input))
+	for i := range input {
+		events <- input[i]
+	}
+	close(events)
+	if _, err = r.parseEvents(context.Background(), events); err != ErrStreamEOF {
+		t.Fatalf("parseEvents err != %v, err: %v", ErrStreamEOF, err)
+	}
+
+	testCases := []struct {
+		data   string
+		labels []string
+	}{
+		{data: "1"},
+		{data: "off", labels: []string{"off"}},
+		{data: "x,z", labels: []string{"x", "z"}},
+	}
+	for i, v := range testCases {
+		c := out.Events[0].RowValues[0].Columns[i]
+		if string(c.Data) != v.data || !reflect.DeepEqual(c.Labels, v.labels) {
+			t.Fatalf("column %v want: %v %q out: %s %q", c.Filed, v.data, v.labels, c.Data, c.Labels)
+		}
+	}
+
+	sql, err := NewSQLRenderer().Render(out.Events[0])
+	if err != nil {
+		t.Fatalf("Render err: %v", err)
+	}
+	if want := "INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`status`,`tags`) VALUES (1,'off','x,z')"; sql[0] != want {
+		t.Fatalf("Render want: %v out: %v", want, sql[0])
+	}
+}
@@ -33,6 +33,23 @@ func (m *mysqlColumnAttribute) IsUnSignedInt() bool {
 	return strings.Contains(m.typ, mysqlUnsigned)
 }
 
+//IsPrimaryKey 实现MysqlKeyColumn，desc中联合主键的每一列都是PRI
+func (m *mysqlColumnAttribute) IsPrimaryKey() bool {
+	return m.key == mysqlPrimaryKeyDescription
+}
+
+func (m *mysqlColumnAttribute) IsUnique() bool {
+	return m.key == mysqlUniqueKeyDescription
+}
+
+func (m *mysqlColumnAttribute) IsNullable() bool {
+	return m.null == "YES"
+}
+
+func (m *mysqlColumnAttribute) Default() []byte {
+	return m.columnDefault
+}
+
 type mysqlTableInfo struct {
 	name    MysqlTableName
 	columns []MysqlColumn
@@ -103,7 +120,7 @@ func showTransaction(t *Transaction) {
 		lw.logger().Errorf("MarshalJSON fail. err: %v", err)
 		return
 	}
-	lw.logger().Print("%v", string(b))
+	lw.logger().Print(string(b))
 }
 
 func ExampleRowStreamer_Stream() {
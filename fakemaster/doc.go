@@ -0,0 +1,19 @@
+/*
+Package fakemaster 模拟的mysql主库，使用真实的mysql协议进行握手、鉴权、查询以及binlog dump，
+binlog event由replication.FakeBinlogStream以及replication中的New*Event生成，
+用于RowStreamer在没有真实mysql时进行网络层的集成测试以及本地开发:
+
+	m, err := fakemaster.NewMaster("127.0.0.1:0", "root", "123456")
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	f, s := m.Format(), m.Stream()
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	r, err := binlog.NewRowStreamer(m.DSN(), 1234, mapper)
+*/
+package fakemaster
@@ -0,0 +1,413 @@
+package fakemaster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+const (
+	defaultServerVersion = "5.7.25-log"
+	defaultServerID      = 1
+	firstBinlogFilename  = "mysql-bin.000001"
+	binlogMagicSize      = 4
+
+	//binlog event header中next_position以及flags的偏移
+	eventNextPositionOffset = 13
+	eventFlagsOffset        = 17
+	//LOG_EVENT_ARTIFICIAL_F 主库在dump开始时发送的fake ROTATE_EVENT的标志
+	logEventArtificialF = 0x20
+
+	mysqlErrUnknownCommand = 1047
+	mysqlErrNotSupported   = 1235
+	mysqlErrMasterFatal    = 1236
+)
+
+//Result 查询的结果集，Rows中的nil代表NULL，其他的值使用fmt.Sprint变为字符串
+type Result struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+//binlogFile 一个binlog文件中的所有binlog event
+type binlogFile struct {
+	name   string
+	events [][]byte
+	size   int64
+}
+
+//Master 模拟的mysql主库，支持SELECT @@变量、SET、SHOW BINARY LOGS、SHOW MASTER STATUS
+//以及通过SetQueryResult注册的查询，binlog dump支持阻塞和非阻塞两种方式
+type Master struct {
+	listener net.Listener
+	user     string
+	password string
+	format   replication.BinlogFormat
+	stream   *replication.FakeBinlogStream
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	closed    bool
+	conns     map[*dump.ServerConn]struct{}
+	nextID    uint32
+	files     []*binlogFile
+	variables map[string]string
+	results   map[string]*Result
+}
+
+//NewMaster 在addr上监听，如127.0.0.1:0使用随机端口，user以及password是允许登录的账号，
+//创建后有一个只包含FORMAT_DESCRIPTION_EVENT的binlog文件mysql-bin.000001
+func NewMaster(addr, user, password string) (*Master, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("NewMaster listen fail. err: %v", err)
+	}
+
+	m := &Master{
+		listener: l,
+		user:     user,
+		password: password,
+		format:   replication.NewMySQL56BinlogFormat(),
+		stream:   replication.NewFakeBinlogStream(),
+		conns:    make(map[*dump.ServerConn]struct{}),
+		variables: map[string]string{
+			"max_allowed_packet": "16777216",
+			"version":            defaultServerVersion,
+			"server_id":          strconv.Itoa(defaultServerID),
+			"binlog_format":      "ROW",
+			"binlog_checksum":    "CRC32",
+			"binlog_row_image":   "FULL",
+			"time_zone":          "SYSTEM",
+			"system_time_zone":   "UTC",
+		},
+		results: make(map[string]*Result),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	m.stream.ServerID = defaultServerID
+	m.files = []*binlogFile{m.newBinlogFile(firstBinlogFilename)}
+
+	m.wg.Add(1)
+	go m.serve()
+	return m, nil
+}
+
+//Addr 获取监听的地址
+func (m *Master) Addr() string {
+	return m.listener.Addr().String()
+}
+
+//DSN 获取连接该主库的dsn
+func (m *Master) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s)/mysql", m.user, m.password, m.Addr())
+}
+
+//Format 获取binlog的格式，用于replication中的New*Event生成binlog event
+func (m *Master) Format() replication.BinlogFormat {
+	return m.format
+}
+
+//Stream 获取生成binlog event的FakeBinlogStream，可以修改其中的Timestamp
+func (m *Master) Stream() *replication.FakeBinlogStream {
+	return m.stream
+}
+
+//SetVariable 设置SELECT @@name查询返回的系统变量
+func (m *Master) SetVariable(name, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.variables[strings.ToLower(name)] = value
+}
+
+//SetQueryResult 注册查询语句的结果集，result为nil时返回OK包
+func (m *Master) SetQueryResult(query string, result *Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[normalizeQuery(query)] = result
+}
+
+//AppendEvents 将binlog event追加到当前的binlog文件中，主库会按照文件中的实际位置改写event的next_position，
+//阻塞方式的binlog dump会收到这些binlog event
+func (m *Master) AppendEvents(events ...replication.BinlogEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.files[len(m.files)-1]
+	for _, ev := range events {
+		m.appendEvent(f, ev.Bytes())
+	}
+	m.cond.Broadcast()
+}
+
+//RotateTo 在当前的binlog文件末尾写入ROTATE_EVENT，并切换到新的binlog文件filename
+func (m *Master) RotateTo(filename string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.files[len(m.files)-1]
+	m.appendEvent(f, replication.NewRotateEvent(m.format, m.stream, binlogMagicSize, filename).Bytes())
+	m.files = append(m.files, m.newBinlogFile(filename))
+	m.cond.Broadcast()
+}
+
+//Position 获取当前binlog的结束位置，即SHOW MASTER STATUS的结果
+func (m *Master) Position() (string, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.files[len(m.files)-1]
+	return f.name, f.size
+}
+
+//Close 停止监听并关闭所有的连接
+func (m *Master) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	for c := range m.conns {
+		c.Close()
+	}
+	m.cond.Broadcast()
+	m.mu.Unlock()
+
+	err := m.listener.Close()
+	m.wg.Wait()
+	return err
+}
+
+func (m *Master) newBinlogFile(name string) *binlogFile {
+	f := &binlogFile{name: name, size: binlogMagicSize}
+	m.appendEvent(f, replication.NewFormatDescriptionEvent(m.format, m.stream).Bytes())
+	return f
+}
+
+//appendEvent 复制event并改写其next_position，需要持有锁
+func (m *Master) appendEvent(f *binlogFile, event []byte) {
+	ev := make([]byte, len(event))
+	copy(ev, event)
+	f.size += int64(len(ev))
+	if len(ev) >= eventNextPositionOffset+4 {
+		binary.LittleEndian.PutUint32(ev[eventNextPositionOffset:], uint32(f.size))
+	}
+	f.events = append(f.events, ev)
+}
+
+func (m *Master) serve() {
+	defer m.wg.Done()
+	for {
+		netConn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			netConn.Close()
+			return
+		}
+		m.nextID++
+		c := dump.NewServerConn(netConn, m.nextID)
+		m.conns[c] = struct{}{}
+		m.mu.Unlock()
+
+		m.wg.Add(1)
+		go m.handle(c)
+	}
+}
+
+func (m *Master) handle(c *dump.ServerConn) {
+	defer m.wg.Done()
+	defer func() {
+		m.mu.Lock()
+		delete(m.conns, c)
+		m.mu.Unlock()
+		c.Close()
+	}()
+
+	if err := c.Handshake(defaultServerVersion, map[string]string{m.user: m.password}); err != nil {
+		return
+	}
+
+	for {
+		cmd, data, err := c.ReadCommand()
+		if err != nil {
+			return
+		}
+
+		switch cmd {
+		case dump.ComQuit:
+			return
+		case dump.ComPing, dump.ComInitDB:
+			err = c.WriteOK(0, 0)
+		case dump.ComQuery:
+			err = m.handleQuery(c, string(data))
+		case dump.ComBinlogDump:
+			err = m.handleBinlogDump(c, data)
+		default:
+			err = c.WriteError(mysqlErrUnknownCommand, "08S01", fmt.Sprintf("unknown command %d", cmd))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (m *Master) handleQuery(c *dump.ServerConn, query string) error {
+	normalized := normalizeQuery(query)
+	lower := strings.ToLower(normalized)
+
+	m.mu.Lock()
+	result, ok := m.results[normalized]
+	m.mu.Unlock()
+	if ok {
+		if result == nil {
+			return c.WriteOK(0, 0)
+		}
+		return writeResult(c, result)
+	}
+
+	switch {
+	case strings.HasPrefix(lower, "select @@"):
+		return m.handleSelectVariable(c, normalized)
+	case strings.HasPrefix(lower, "set "), lower == "begin", lower == "commit", lower == "rollback":
+		return c.WriteOK(0, 0)
+	case lower == "show binary logs" || lower == "show master logs":
+		m.mu.Lock()
+		result = &Result{Columns: []string{"Log_name", "File_size"}}
+		for _, f := range m.files {
+			result.Rows = append(result.Rows, []interface{}{f.name, f.size})
+		}
+		m.mu.Unlock()
+		return writeResult(c, result)
+	case lower == "show master status":
+		name, size := m.Position()
+		return writeResult(c, &Result{
+			Columns: []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+			Rows:    [][]interface{}{{name, size, "", "", ""}},
+		})
+	}
+	return c.WriteError(mysqlErrNotSupported, "42000", fmt.Sprintf("fake master does not support query: %s", query))
+}
+
+//handleSelectVariable 处理SELECT @@name以及SELECT @@global.name
+func (m *Master) handleSelectVariable(c *dump.ServerConn, query string) error {
+	column := strings.TrimSpace(query[len("select "):])
+	name := strings.ToLower(strings.TrimPrefix(column, "@@"))
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "global."), "session.")
+
+	m.mu.Lock()
+	value, ok := m.variables[name]
+	m.mu.Unlock()
+	if !ok {
+		return c.WriteError(1193, "HY000", fmt.Sprintf("Unknown system variable '%s'", name))
+	}
+	return writeResult(c, &Result{Columns: []string{column}, Rows: [][]interface{}{{value}}})
+}
+
+//handleBinlogDump 先发送fake ROTATE_EVENT以及FORMAT_DESCRIPTION_EVENT，然后从指定位置开始发送binlog event，
+//非阻塞方式在发送完所有的binlog event后发送EOF包，阻塞方式等待新的binlog event直到主库关闭
+func (m *Master) handleBinlogDump(c *dump.ServerConn, data []byte) error {
+	_, offset, filename, flags, err := dump.ParseBinlogDump(data)
+	if err != nil {
+		return c.WriteError(mysqlErrMasterFatal, "HY000", err.Error())
+	}
+
+	m.mu.Lock()
+	fileIndex := -1
+	for i, f := range m.files {
+		if f.name == filename {
+			fileIndex = i
+		}
+	}
+	m.mu.Unlock()
+	if fileIndex < 0 {
+		return c.WriteError(mysqlErrMasterFatal, "HY000",
+			"Could not find first log file name in binary log index file")
+	}
+	if offset < binlogMagicSize {
+		offset = binlogMagicSize
+	}
+
+	if err := c.WriteBinlogEvent(m.fakeRotateEvent(filename, offset)); err != nil {
+		return err
+	}
+
+	eventIndex := 0
+	pos := int64(binlogMagicSize)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		if m.closed {
+			return fmt.Errorf("master closed")
+		}
+		f := m.files[fileIndex]
+		for ; eventIndex < len(f.events); eventIndex++ {
+			ev := f.events[eventIndex]
+			start := pos
+			pos += int64(len(ev))
+			//从文件中间开始dump时仍然需要发送FORMAT_DESCRIPTION_EVENT
+			if start < int64(offset) && eventIndex != 0 {
+				continue
+			}
+			if start < int64(offset) {
+				ev = append([]byte(nil), ev...)
+				binary.LittleEndian.PutUint32(ev[eventNextPositionOffset:], 0)
+			}
+			m.mu.Unlock()
+			err := c.WriteBinlogEvent(ev)
+			m.mu.Lock()
+			if err != nil {
+				return err
+			}
+		}
+
+		if fileIndex+1 < len(m.files) {
+			fileIndex++
+			eventIndex = 0
+			pos = binlogMagicSize
+			offset = binlogMagicSize
+			continue
+		}
+		if flags&dump.BinlogDumpNonBlock != 0 {
+			m.mu.Unlock()
+			err := c.WriteEOF()
+			m.mu.Lock()
+			return err
+		}
+		m.cond.Wait()
+	}
+}
+
+//fakeRotateEvent dump开始时通知从库当前的binlog文件名
+func (m *Master) fakeRotateEvent(filename string, offset uint32) []byte {
+	s := &replication.FakeBinlogStream{ServerID: m.stream.ServerID}
+	ev := replication.NewRotateEvent(m.format, s, uint64(offset), filename).Bytes()
+	binary.LittleEndian.PutUint16(ev[eventFlagsOffset:], logEventArtificialF)
+	return ev
+}
+
+func writeResult(c *dump.ServerConn, result *Result) error {
+	rows := make([][][]byte, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		values := make([][]byte, len(row))
+		for i, v := range row {
+			switch v := v.(type) {
+			case nil:
+			case []byte:
+				values[i] = v
+			default:
+				values[i] = []byte(fmt.Sprint(v))
+			}
+		}
+		rows = append(rows, values)
+	}
+	return c.WriteTextResult(result.Columns, rows)
+}
+
+//normalizeQuery 去掉查询语句首尾的空白以及分号
+func normalizeQuery(query string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+}
@@ -0,0 +1,168 @@
+package fakemaster
+
+import (
+	"io"
+	"testing"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+func newTestMaster(t *testing.T) *Master {
+	m, err := NewMaster("127.0.0.1:0", "root", "123456")
+	if err != nil {
+		t.Fatalf("NewMaster err: %v", err)
+	}
+	f, s := m.Format(), m.Stream()
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	m.RotateTo("mysql-bin.000002")
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	return m
+}
+
+func queryRows(t *testing.T, conn *dump.MysqlConn, query string) [][]string {
+	rows, err := conn.Query(query)
+	if err != nil {
+		t.Fatalf("Query %v err: %v", query, err)
+	}
+	defer rows.Close()
+
+	var out [][]string
+	for {
+		dest := make([]interface{}, len(rows.Columns()))
+		if err = rows.Next(dest); err == io.EOF {
+			return out
+		} else if err != nil {
+			t.Fatalf("Next err: %v", err)
+		}
+		row := make([]string, len(dest))
+		for i := range dest {
+			if b, ok := dest[i].([]byte); ok {
+				row[i] = string(b)
+			} else {
+				row[i] = "NULL"
+			}
+		}
+		out = append(out, row)
+	}
+}
+
+func TestMaster_Query(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Close()
+	m.SetQueryResult("SELECT a, b FROM t", &Result{
+		Columns: []string{"a", "b"},
+		Rows:    [][]interface{}{{1, nil}},
+	})
+
+	if _, err := dump.NewMysqlConn("root:wrong@tcp(" + m.Addr() + ")/mysql"); err == nil {
+		t.Fatalf("NewMysqlConn with wrong password want err")
+	}
+
+	conn, err := dump.NewMysqlConn(m.DSN())
+	if err != nil {
+		t.Fatalf("NewMysqlConn err: %v", err)
+	}
+	defer conn.Close()
+
+	if err = conn.Exec("SET @master_binlog_checksum=@@global.binlog_checksum"); err != nil {
+		t.Fatalf("Exec err: %v", err)
+	}
+
+	testCases := []struct {
+		query string
+		want  [][]string
+	}{
+		{query: "SELECT @@binlog_format", want: [][]string{{"ROW"}}},
+		{query: "SHOW BINARY LOGS", want: [][]string{{"mysql-bin.000001", "242"}, {"mysql-bin.000002", "195"}}},
+		{query: "SHOW MASTER STATUS", want: [][]string{{"mysql-bin.000002", "195", "", "", ""}}},
+		{query: "SELECT a, b FROM t;", want: [][]string{{"1", "NULL"}}},
+	}
+	for _, v := range testCases {
+		out := queryRows(t, conn, v.query)
+		if len(out) != len(v.want) {
+			t.Fatalf("Query %v want: %v out: %v", v.query, v.want, out)
+		}
+		for i := range out {
+			for j := range out[i] {
+				if out[i][j] != v.want[i][j] {
+					t.Fatalf("Query %v want: %v out: %v", v.query, v.want, out)
+				}
+			}
+		}
+	}
+
+	if _, err = conn.Query("SELECT * FROM unknown"); err == nil {
+		t.Fatalf("Query unsupported want err")
+	}
+}
+
+func TestMaster_BinlogDump(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Close()
+
+	testCases := []struct {
+		filename string
+		offset   uint32
+		want     []int64
+	}{
+		//fake rotate, FDE, BEGIN, XID, ROTATE, FDE, BEGIN, XID
+		{filename: "mysql-bin.000001", offset: 4, want: []int64{0, 120, 164, 195, 242, 120, 164, 195}},
+		//从文件中间开始时FORMAT_DESCRIPTION_EVENT的next_position为0
+		{filename: "mysql-bin.000002", offset: 164, want: []int64{0, 0, 195}},
+	}
+
+	for _, v := range testCases {
+		conn, err := dump.NewMysqlConn(m.DSN())
+		if err != nil {
+			t.Fatalf("NewMysqlConn err: %v", err)
+		}
+		if err = conn.NoticeDump(1234, v.offset, v.filename, dump.BinlogDumpNonBlock); err != nil {
+			t.Fatalf("NoticeDump err: %v", err)
+		}
+
+		var out []int64
+		for {
+			data, err := conn.ReadPacket()
+			if err != nil {
+				t.Fatalf("ReadPacket err: %v", err)
+			}
+			if data[0] == dump.PacketEOF {
+				break
+			}
+			out = append(out, replication.NewMysql56BinlogEvent(data[1:]).NextPosition())
+		}
+		conn.Close()
+
+		if len(out) != len(v.want) {
+			t.Fatalf("BinlogDump %v:%v want: %v out: %v", v.filename, v.offset, v.want, out)
+		}
+		for i := range out {
+			if out[i] != v.want[i] {
+				t.Fatalf("BinlogDump %v:%v want: %v out: %v", v.filename, v.offset, v.want, out)
+			}
+		}
+	}
+
+	conn, err := dump.NewMysqlConn(m.DSN())
+	if err != nil {
+		t.Fatalf("NewMysqlConn err: %v", err)
+	}
+	defer conn.Close()
+	if err = conn.NoticeDump(1234, 4, "mysql-bin.000003", dump.BinlogDumpNonBlock); err != nil {
+		t.Fatalf("NoticeDump err: %v", err)
+	}
+	data, err := conn.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket err: %v", err)
+	}
+	if data[0] != dump.PacketERR {
+		t.Fatalf("BinlogDump unknown file want error packet, out: %v", data)
+	}
+}
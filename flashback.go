@@ -0,0 +1,93 @@
+package binlog
+
+import (
+	"fmt"
+)
+
+//Flashback 闪回，将一组事务中的行变更变为逆向的sql，用于撤销误操作的DELETE以及UPDATE：
+//DELETE变为INSERT，INSERT变为DELETE，UPDATE交换修改前后的数据，
+//逆向的sql与原事务的顺序相反，需要binlog_row_image=FULL
+type Flashback struct {
+	renderer *SQLRenderer
+}
+
+//NewFlashback 创建Flashback
+func NewFlashback() *Flashback {
+	return &Flashback{
+		renderer: NewSQLRenderer(),
+	}
+}
+
+//SetPrimaryKey 设置表的主键列，设置后where条件只使用主键列，没有设置时使用StreamEvent.PrimaryKey，都没有时使用所有的列
+func (f *Flashback) SetPrimaryKey(table MysqlTableName, fields ...string) {
+	f.renderer.SetPrimaryKey(table, fields...)
+}
+
+//Generate 生成trans的逆向sql，按照事务、事件以及行的逆序排列
+func (f *Flashback) Generate(trans []*Transaction) ([]string, error) {
+	var sqls []string
+	for i := len(trans) - 1; i >= 0; i-- {
+		events := trans[i].Events
+		for j := len(events) - 1; j >= 0; j-- {
+			s, err := f.generateEvent(events[j])
+			if err != nil {
+				return nil, fmt.Errorf("Generate transaction in pos: %+v error: %v", trans[i].NowPosition, err)
+			}
+			sqls = append(sqls, s...)
+		}
+	}
+	return sqls, nil
+}
+
+func (f *Flashback) generateEvent(ev *StreamEvent) ([]string, error) {
+	var sqls []string
+	switch ev.Type {
+	case StatementInsert:
+		for i := len(ev.RowValues) - 1; i >= 0; i-- {
+			s, err := f.renderer.deleteSQL(ev.Table, ev.PrimaryKey, ev.RowValues[i])
+			if err != nil {
+				return nil, err
+			}
+			sqls = append(sqls, s)
+		}
+	case StatementDelete:
+		for i := len(ev.RowIdentifies) - 1; i >= 0; i-- {
+			if err := checkFullRowImage(ev.RowIdentifies[i]); err != nil {
+				return nil, err
+			}
+			s, err := f.renderer.insertSQL(ev.Table, ev.RowIdentifies[i])
+			if err != nil {
+				return nil, err
+			}
+			sqls = append(sqls, s)
+		}
+	case StatementUpdate:
+		if len(ev.RowIdentifies) != len(ev.RowValues) {
+			return nil, fmt.Errorf("the length of RowIdentifies(%d) did not equal to the length of RowValues(%d)",
+				len(ev.RowIdentifies), len(ev.RowValues))
+		}
+		for i := len(ev.RowValues) - 1; i >= 0; i-- {
+			if err := checkFullRowImage(ev.RowIdentifies[i]); err != nil {
+				return nil, err
+			}
+			s, err := f.renderer.updateSQL(ev.Table, ev.PrimaryKey, ev.RowIdentifies[i], ev.RowValues[i])
+			if err != nil {
+				return nil, err
+			}
+			sqls = append(sqls, s)
+		}
+	default:
+		return nil, fmt.Errorf("can't flashback %v statement of table %v", ev.Type, ev.Table.String())
+	}
+	return sqls, nil
+}
+
+//checkFullRowImage 检查行数据是否包含所有列
+func checkFullRowImage(row *RowData) error {
+	for _, c := range row.Columns {
+		if c.IsEmpty {
+			return fmt.Errorf("column %v is empty, flashback needs binlog_row_image=FULL", c.Filed)
+		}
+	}
+	return nil
+}
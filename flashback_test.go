@@ -0,0 +1,113 @@
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestRowData(id, message string) *RowData {
+	return &RowData{
+		Columns: []*ColumnData{
+			{Filed: "id", Type: ColumnTypeLong, Data: []byte(id)},
+			{Filed: "message", Type: ColumnTypeVarchar, Data: []byte(message)},
+		},
+	}
+}
+
+func TestFlashback_Generate(t *testing.T) {
+	table := NewMysqlTableName("vt_test_keyspace", "vt_a")
+	trans := []*Transaction{
+		{
+			Events: []*StreamEvent{
+				{
+					Type:      StatementInsert,
+					Table:     table,
+					RowValues: []*RowData{newTestRowData("1", "a"), newTestRowData("2", "b")},
+				},
+				{
+					Type:          StatementUpdate,
+					Table:         table,
+					RowIdentifies: []*RowData{newTestRowData("1", "a")},
+					RowValues:     []*RowData{newTestRowData("1", "c")},
+				},
+			},
+		},
+		{
+			Events: []*StreamEvent{
+				{
+					Type:          StatementDelete,
+					Table:         table,
+					RowIdentifies: []*RowData{newTestRowData("2", "b")},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		primaryKey []string
+		want       []string
+	}{
+		{
+			want: []string{
+				"INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (2,'b')",
+				"UPDATE `vt_test_keyspace`.`vt_a` SET `id`=1,`message`='a' WHERE `id`=1 AND `message`='c' LIMIT 1",
+				"DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `id`=2 AND `message`='b' LIMIT 1",
+				"DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `id`=1 AND `message`='a' LIMIT 1",
+			},
+		},
+		{
+			primaryKey: []string{"id"},
+			want: []string{
+				"INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (2,'b')",
+				"UPDATE `vt_test_keyspace`.`vt_a` SET `id`=1,`message`='a' WHERE `id`=1 LIMIT 1",
+				"DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `id`=2 LIMIT 1",
+				"DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `id`=1 LIMIT 1",
+			},
+		},
+	}
+
+	for _, v := range testCases {
+		f := NewFlashback()
+		f.SetPrimaryKey(table, v.primaryKey...)
+		out, err := f.Generate(trans)
+		if err != nil {
+			t.Fatalf("Generate err: %v", err)
+		}
+		if !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("Generate want: %q out: %q", v.want, out)
+		}
+	}
+}
+
+func TestFlashback_Generate_Error(t *testing.T) {
+	table := NewMysqlTableName("vt_test_keyspace", "vt_a")
+	minimal := newTestRowData("1", "a")
+	minimal.Columns[1].IsEmpty = true
+
+	testCases := []struct {
+		primaryKey []string
+		input      *StreamEvent
+	}{
+		{
+			input: &StreamEvent{Type: StatementDelete, Table: table, RowIdentifies: []*RowData{minimal}},
+		},
+		{
+			primaryKey: []string{"uid"},
+			input:      &StreamEvent{Type: StatementInsert, Table: table, RowValues: []*RowData{newTestRowData("1", "a")}},
+		},
+		{
+			input: &StreamEvent{Type: StatementUpdate, Table: table, RowIdentifies: []*RowData{newTestRowData("1", "a")}},
+		},
+		{
+			input: &StreamEvent{Type: StatementAlter, Table: table, SQL: "alter table vt_a add column c int"},
+		},
+	}
+
+	for _, v := range testCases {
+		f := NewFlashback()
+		f.SetPrimaryKey(table, v.primaryKey...)
+		if _, err := f.Generate([]*Transaction{{Events: []*StreamEvent{v.input}}}); err == nil {
+			t.Fatalf("Generate %v want err", v.input.Type)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+//GeometryType WKB中的几何类型
+type GeometryType uint32
+
+//mysql支持的几何类型，只有二维坐标
+const (
+	GeometryPoint              GeometryType = 1
+	GeometryLineString         GeometryType = 2
+	GeometryPolygon            GeometryType = 3
+	GeometryMultiPoint         GeometryType = 4
+	GeometryMultiLineString    GeometryType = 5
+	GeometryMultiPolygon       GeometryType = 6
+	GeometryGeometryCollection GeometryType = 7
+)
+
+var geometryTypeNames = map[GeometryType][2]string{
+	GeometryPoint:              {"POINT", "Point"},
+	GeometryLineString:         {"LINESTRING", "LineString"},
+	GeometryPolygon:            {"POLYGON", "Polygon"},
+	GeometryMultiPoint:         {"MULTIPOINT", "MultiPoint"},
+	GeometryMultiLineString:    {"MULTILINESTRING", "MultiLineString"},
+	GeometryMultiPolygon:       {"MULTIPOLYGON", "MultiPolygon"},
+	GeometryGeometryCollection: {"GEOMETRYCOLLECTION", "GeometryCollection"},
+}
+
+//String WKT中的类型名
+func (t GeometryType) String() string {
+	if names, ok := geometryTypeNames[t]; ok {
+		return names[0]
+	}
+	return fmt.Sprintf("GeometryType(%d)", uint32(t))
+}
+
+//GeometryFormat 几何列在ColumnData.Data中的格式
+type GeometryFormat int
+
+//几何列的格式
+const (
+	GeometryWKB     GeometryFormat = iota //mysql的原始格式，即4字节SRID加上WKB，默认的格式
+	GeometryWKT                           //WKT格式的文本，如POINT(1 2)
+	GeometryGeoJSON                       //GeoJSON格式的geometry对象
+)
+
+//SetGeometryFormat 设置几何列在ColumnData.Data中的格式，默认为GeometryWKB，解析失败时Stream返回错误。
+//为GeometryWKT或者GeometryGeoJSON时Data中不包含SRID，ColumnData.Raw中保存转换前的原始数据(4字节SRID加上WKB)，
+//各个Encoder以及SQLRenderer仍然使用原始数据
+func (s *RowStreamer) SetGeometryFormat(format GeometryFormat) {
+	s.decodeOptions.geometryFormat = format
+}
+
+//decodeGeometry 按照GeometryFormat将几何列的数据转为WKT或者GeoJSON
+func (tc *tableCache) decodeGeometry(column *ColumnData) error {
+	if tc.options == nil || tc.options.geometryFormat == GeometryWKB ||
+		!column.Type.IsGeometry() || column.Data == nil {
+		return nil
+	}
+	g, err := ParseGeometry(column.Data)
+	if err != nil {
+		return fmt.Errorf("decode geometry column %v fail. err: %v", column.Filed, err)
+	}
+	var data []byte
+	switch tc.options.geometryFormat {
+	case GeometryWKT:
+		data = []byte(g.WKT())
+	case GeometryGeoJSON:
+		if data, err = g.GeoJSON(); err != nil {
+			return fmt.Errorf("decode geometry column %v fail. err: %v", column.Filed, err)
+		}
+	default:
+		return fmt.Errorf("unknown geometry format %v", tc.options.geometryFormat)
+	}
+	column.Raw = column.Data
+	column.Data = data
+	return nil
+}
+
+//geometryWKB 几何列mysql格式的数据，SetGeometryFormat转换过的列使用Raw
+func (c *ColumnData) geometryWKB() []byte {
+	if c.Raw != nil {
+		return c.Raw
+	}
+	return c.Data
+}
+
+//Point 二维坐标
+type Point struct {
+	X float64
+	Y float64
+}
+
+//Geometry 解析后的几何数据，根据Type使用不同的字段:
+//Point以及LineString使用Points，Polygon使用Rings，MultiPoint、MultiLineString、MultiPolygon
+//以及GeometryCollection使用Geometries，其成员的SRID与外层相同
+type Geometry struct {
+	SRID       uint32       //空间参考系统标识，0表示没有指定
+	Type       GeometryType //几何类型
+	Points     []Point      //Point的坐标(只有一个)或者LineString的点
+	Rings      [][]Point    //Polygon的环，第一个为外环，其余为内环
+	Geometries []*Geometry  //Multi*以及GeometryCollection的成员
+}
+
+//ParseGeometry 解析mysql几何列的数据，格式为4字节小端序的SRID加上WKB
+func ParseGeometry(data []byte) (*Geometry, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("ParseGeometry data is too short: %v", len(data))
+	}
+	r := &wkbReader{data: data, pos: 4}
+	g, err := r.readGeometry(binary.LittleEndian.Uint32(data))
+	if err != nil {
+		return nil, fmt.Errorf("ParseGeometry fail. err: %v", err)
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("ParseGeometry unexpected end: got %v was expecting %v", r.pos, len(data))
+	}
+	return g, nil
+}
+
+//Geometry 将几何列的数据解析为Geometry，非几何列以及NULL返回错误
+func (c *ColumnData) Geometry() (*Geometry, error) {
+	if !c.Type.IsGeometry() {
+		return nil, fmt.Errorf("column %v is not geometry but %v", c.Filed, c.Type)
+	}
+	if c.Data == nil {
+		return nil, fmt.Errorf("column %v is null", c.Filed)
+	}
+	return ParseGeometry(c.geometryWKB())
+}
+
+//WKT 输出WKT格式的文本，如POINT(1 2)，不包含SRID
+func (g *Geometry) WKT() string {
+	buf := bytes.NewBufferString(g.Type.String())
+	if g.isEmpty() {
+		buf.WriteString(" EMPTY")
+		return buf.String()
+	}
+	g.appendWKT(buf)
+	return buf.String()
+}
+
+//GeoJSON 输出GeoJSON格式的geometry对象，不包含SRID
+func (g *Geometry) GeoJSON() ([]byte, error) {
+	return json.Marshal(g.geoJSON())
+}
+
+func (g *Geometry) isEmpty() bool {
+	return len(g.Points) == 0 && len(g.Rings) == 0 && len(g.Geometries) == 0
+}
+
+//appendWKT 追加类型名之后带括号的部分
+func (g *Geometry) appendWKT(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	switch g.Type {
+	case GeometryPoint, GeometryLineString:
+		appendWKTPoints(buf, g.Points)
+	case GeometryPolygon:
+		appendWKTRings(buf, g.Rings)
+	case GeometryMultiPoint, GeometryMultiLineString, GeometryMultiPolygon:
+		for i, member := range g.Geometries {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			member.appendWKT(buf)
+		}
+	case GeometryGeometryCollection:
+		for i, member := range g.Geometries {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(member.WKT())
+		}
+	}
+	buf.WriteByte(')')
+}
+
+func appendWKTPoints(buf *bytes.Buffer, points []Point) {
+	for i, p := range points {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.FormatFloat(p.X, 'f', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(p.Y, 'f', -1, 64))
+	}
+}
+
+func appendWKTRings(buf *bytes.Buffer, rings [][]Point) {
+	for i, ring := range rings {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('(')
+		appendWKTPoints(buf, ring)
+		buf.WriteByte(')')
+	}
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJSONCollection struct {
+	Type       string        `json:"type"`
+	Geometries []interface{} `json:"geometries"`
+}
+
+func (g *Geometry) geoJSON() interface{} {
+	typ := geometryTypeNames[g.Type][1]
+	if g.Type == GeometryGeometryCollection {
+		geometries := make([]interface{}, 0, len(g.Geometries))
+		for _, member := range g.Geometries {
+			geometries = append(geometries, member.geoJSON())
+		}
+		return &geoJSONCollection{Type: typ, Geometries: geometries}
+	}
+	return &geoJSONGeometry{Type: typ, Coordinates: g.coordinates()}
+}
+
+//coordinates GeoJSON中的coordinates，坐标为[x, y]
+func (g *Geometry) coordinates() interface{} {
+	switch g.Type {
+	case GeometryPoint:
+		if len(g.Points) == 0 {
+			return []float64{}
+		}
+		return [2]float64{g.Points[0].X, g.Points[0].Y}
+	case GeometryLineString:
+		return geoJSONPoints(g.Points)
+	case GeometryPolygon:
+		rings := make([][][2]float64, 0, len(g.Rings))
+		for _, ring := range g.Rings {
+			rings = append(rings, geoJSONPoints(ring))
+		}
+		return rings
+	default:
+		members := make([]interface{}, 0, len(g.Geometries))
+		for _, member := range g.Geometries {
+			members = append(members, member.coordinates())
+		}
+		return members
+	}
+}
+
+func geoJSONPoints(points []Point) [][2]float64 {
+	out := make([][2]float64, 0, len(points))
+	for _, p := range points {
+		out = append(out, [2]float64{p.X, p.Y})
+	}
+	return out
+}
+
+//wkbReader 读取WKB，每个几何对象都以字节序开头:0为大端序，1为小端序
+type wkbReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *wkbReader) readByteOrder() (binary.ByteOrder, error) {
+	if r.pos >= len(r.data) {
+		return nil, fmt.Errorf("byte order is too short (pos: %v)", r.pos)
+	}
+	b := r.data[r.pos]
+	r.pos++
+	switch b {
+	case 0:
+		return binary.BigEndian, nil
+	case 1:
+		return binary.LittleEndian, nil
+	}
+	return nil, fmt.Errorf("unknown byte order %v (pos: %v)", b, r.pos-1)
+}
+
+func (r *wkbReader) readUint32(order binary.ByteOrder) (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, fmt.Errorf("uint32 is too short (pos: %v)", r.pos)
+	}
+	v := order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+//readCount 读取数量，并检查剩余的数据至少能容纳count个大小为minSize的对象
+func (r *wkbReader) readCount(order binary.ByteOrder, minSize int) (int, error) {
+	n, err := r.readUint32(order)
+	if err != nil {
+		return 0, err
+	}
+	if uint64(n)*uint64(minSize) > uint64(len(r.data)-r.pos) {
+		return 0, fmt.Errorf("count %v is too large (pos: %v)", n, r.pos)
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) readPoints(order binary.ByteOrder, n int) ([]Point, error) {
+	if (len(r.data)-r.pos)/16 < n {
+		return nil, fmt.Errorf("points are too short (pos: %v)", r.pos)
+	}
+	points := make([]Point, 0, n)
+	for i := 0; i < n; i++ {
+		points = append(points, Point{
+			X: math.Float64frombits(order.Uint64(r.data[r.pos:])),
+			Y: math.Float64frombits(order.Uint64(r.data[r.pos+8:])),
+		})
+		r.pos += 16
+	}
+	return points, nil
+}
+
+func (r *wkbReader) readGeometry(srid uint32) (*Geometry, error) {
+	order, err := r.readByteOrder()
+	if err != nil {
+		return nil, err
+	}
+	typ, err := r.readUint32(order)
+	if err != nil {
+		return nil, err
+	}
+	g := &Geometry{SRID: srid, Type: GeometryType(typ)}
+
+	switch g.Type {
+	case GeometryPoint:
+		g.Points, err = r.readPoints(order, 1)
+	case GeometryLineString:
+		var n int
+		if n, err = r.readCount(order, 16); err == nil {
+			g.Points, err = r.readPoints(order, n)
+		}
+	case GeometryPolygon:
+		var rings int
+		if rings, err = r.readCount(order, 4); err != nil {
+			return nil, err
+		}
+		g.Rings = make([][]Point, 0, rings)
+		for i := 0; i < rings && err == nil; i++ {
+			var n int
+			var ring []Point
+			if n, err = r.readCount(order, 16); err == nil {
+				ring, err = r.readPoints(order, n)
+				g.Rings = append(g.Rings, ring)
+			}
+		}
+	case GeometryMultiPoint, GeometryMultiLineString, GeometryMultiPolygon, GeometryGeometryCollection:
+		var n int
+		if n, err = r.readCount(order, 5); err != nil {
+			return nil, err
+		}
+		g.Geometries = make([]*Geometry, 0, n)
+		for i := 0; i < n; i++ {
+			member, err := r.readGeometry(srid)
+			if err != nil {
+				return nil, err
+			}
+			//MultiPoint、MultiLineString以及MultiPolygon的成员分别为Point、LineString以及Polygon
+			if g.Type != GeometryGeometryCollection && member.Type != g.Type-3 {
+				return nil, fmt.Errorf("%v can not contain %v", g.Type, member.Type)
+			}
+			g.Geometries = append(g.Geometries, member)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %v (pos: %v)", typ, r.pos-4)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
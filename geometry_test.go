@@ -0,0 +1,183 @@
+package binlog
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseGeometry(t *testing.T) {
+	testCases := []struct {
+		name    string
+		wkb     string //4字节SRID加上WKB
+		srid    uint32
+		wkt     string
+		geoJSON string
+	}{
+		{
+			name:    "point",
+			wkb:     "e61000000101000000000000000000f03f0000000000000040",
+			srid:    4326,
+			wkt:     "POINT(1 2)",
+			geoJSON: `{"type":"Point","coordinates":[1,2]}`,
+		},
+		{
+			name:    "big endian point",
+			wkb:     "000000000000000001bff80000000000004002000000000000",
+			wkt:     "POINT(-1.5 2.25)",
+			geoJSON: `{"type":"Point","coordinates":[-1.5,2.25]}`,
+		},
+		{
+			name: "linestring",
+			wkb: "000000000102000000030000000000000000000000000000000000000000000000" +
+				"0000f03f000000000000f03f00000000000000400000000000000000",
+			wkt:     "LINESTRING(0 0,1 1,2 0)",
+			geoJSON: `{"type":"LineString","coordinates":[[0,0],[1,1],[2,0]]}`,
+		},
+		{
+			name: "polygon with a hole",
+			wkb: "00000000010300000002000000040000000000000000000000000000000000000000000000000024400000" +
+				"000000000000000000000000244000000000000024400000000000000000000000000000000004000000" +
+				"000000000000f03f000000000000f03f0000000000000040000000000000f03f00000000000000400000" +
+				"000000000040000000000000f03f000000000000f03f",
+			wkt: "POLYGON((0 0,10 0,10 10,0 0),(1 1,2 1,2 2,1 1))",
+			geoJSON: `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,0]],` +
+				`[[1,1],[2,1],[2,2],[1,1]]]}`,
+		},
+		{
+			name: "multipoint",
+			wkb: "000000000104000000020000000101000000000000000000f03f00000000000000400101000000000000" +
+				"00000008400000000000001040",
+			wkt:     "MULTIPOINT((1 2),(3 4))",
+			geoJSON: `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`,
+		},
+		{
+			name: "multilinestring",
+			wkb: "000000000105000000010000000102000000020000000000000000000000000000000000000000000000" +
+				"0000f03f000000000000f03f",
+			wkt:     "MULTILINESTRING((0 0,1 1))",
+			geoJSON: `{"type":"MultiLineString","coordinates":[[[0,0],[1,1]]]}`,
+		},
+		{
+			name: "multipolygon",
+			wkb: "000000000106000000010000000103000000010000000400000000000000000000000000000000000000" +
+				"000000000000f03f0000000000000000000000000000f03f000000000000f03f00000000000000000000" +
+				"000000000000",
+			wkt:     "MULTIPOLYGON(((0 0,1 0,1 1,0 0)))",
+			geoJSON: `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]]]}`,
+		},
+		{
+			name: "geometrycollection",
+			wkb: "000000000107000000020000000101000000000000000000f03f000000000000004001020000000200" +
+				"000000000000000000000000000000000000000000000000f03f000000000000f03f",
+			wkt: "GEOMETRYCOLLECTION(POINT(1 2),LINESTRING(0 0,1 1))",
+			geoJSON: `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},` +
+				`{"type":"LineString","coordinates":[[0,0],[1,1]]}]}`,
+		},
+		{
+			name:    "empty geometrycollection",
+			wkb:     "00000000010700000000000000",
+			wkt:     "GEOMETRYCOLLECTION EMPTY",
+			geoJSON: `{"type":"GeometryCollection","geometries":[]}`,
+		},
+	}
+
+	for _, v := range testCases {
+		data, err := hex.DecodeString(v.wkb)
+		if err != nil {
+			t.Fatalf("%v invalid fixture: %v", v.name, err)
+		}
+		c := NewColumnData("geo", ColumnTypeGeometry, false)
+		c.Data = data
+		g, err := c.Geometry()
+		if err != nil {
+			t.Fatalf("%v Geometry err: %v", v.name, err)
+		}
+		if g.SRID != v.srid {
+			t.Fatalf("%v SRID want: %v out: %v", v.name, v.srid, g.SRID)
+		}
+		if out := g.WKT(); out != v.wkt {
+			t.Fatalf("%v WKT want: %v out: %v", v.name, v.wkt, out)
+		}
+		out, err := g.GeoJSON()
+		if err != nil {
+			t.Fatalf("%v GeoJSON err: %v", v.name, err)
+		}
+		if string(out) != v.geoJSON {
+			t.Fatalf("%v GeoJSON want: %v out: %s", v.name, v.geoJSON, out)
+		}
+	}
+}
+
+func TestParseGeometry_Error(t *testing.T) {
+	testCases := []struct {
+		name string
+		wkb  string
+	}{
+		{name: "no srid", wkb: "e610"},
+		{name: "unknown byte order", wkb: "000000000201000000"},
+		{name: "unsupported type", wkb: "0000000001e9030000"},
+		{name: "short point", wkb: "000000000101000000000000000000f03f"},
+		{name: "too many points", wkb: "000000000102000000ffffffff"},
+		{name: "multipoint with linestring", wkb: "00000000010400000001000000010200000000000000"},
+		{name: "trailing data", wkb: "00000000010700000000000000ff"},
+	}
+	for _, v := range testCases {
+		data, err := hex.DecodeString(v.wkb)
+		if err != nil {
+			t.Fatalf("%v invalid fixture: %v", v.name, err)
+		}
+		if _, err = ParseGeometry(data); err == nil {
+			t.Fatalf("ParseGeometry %v want err", v.name)
+		}
+	}
+
+	c := NewColumnData("message", ColumnTypeVarchar, false)
+	c.Data = []byte("a")
+	if _, err := c.Geometry(); err == nil {
+		t.Fatalf("Geometry of varchar want err")
+	}
+	c = NewColumnData("geo", ColumnTypeGeometry, false)
+	if _, err := c.Geometry(); err == nil {
+		t.Fatalf("Geometry of null want err")
+	}
+}
+
+func TestTableCache_decodeGeometry(t *testing.T) {
+	wkb, _ := hex.DecodeString("e61000000101000000000000000000f03f0000000000000040")
+	testCases := []struct {
+		format  GeometryFormat
+		typ     ColumnType
+		data    []byte
+		want    string
+		wantRaw bool
+		wantErr bool
+	}{
+		{format: GeometryWKB, typ: ColumnTypeGeometry, data: wkb, want: string(wkb)},
+		{format: GeometryWKT, typ: ColumnTypeGeometry, data: wkb, want: "POINT(1 2)", wantRaw: true},
+		{format: GeometryGeoJSON, typ: ColumnTypeGeometry, data: wkb,
+			want: `{"type":"Point","coordinates":[1,2]}`, wantRaw: true},
+		{format: GeometryWKT, typ: ColumnTypeBlob, data: wkb, want: string(wkb)},
+		{format: GeometryWKT, typ: ColumnTypeGeometry, data: wkb[:10], wantErr: true},
+	}
+	for i, v := range testCases {
+		tc := &tableCache{options: &decodeOptions{geometryFormat: v.format}}
+		c := NewColumnData("geo", v.typ, false)
+		c.Data = v.data
+		err := tc.decodeGeometry(c)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("case %d wantErr: %v err: %v", i, v.wantErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if string(c.Data) != v.want || (c.Raw != nil) != v.wantRaw {
+			t.Fatalf("case %d want: %q raw: %v out: %q raw: %x", i, v.want, v.wantRaw, c.Data, c.Raw)
+		}
+		if v.wantRaw {
+			//转换后仍然可以通过Geometry解析
+			if g, err := c.Geometry(); err != nil || g.WKT() != "POINT(1 2)" || g.SRID != 4326 {
+				t.Fatalf("case %d Geometry err: %v out: %+v", i, err, g)
+			}
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package binlog
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/onlyac0611/binlog/dump"
@@ -50,21 +53,34 @@ func NewDefaultLogger(writer io.Writer, level LogLevel) Logger {
 	return d
 }
 
+//Enabled 实现LevelEnabler
+func (d *defaultLogger) Enabled(level LogLevel) bool {
+	return d.level <= level
+}
+
+//output 打印level级别的日志，calldepth与log.Logger.Output相同，从output的调用者开始计算，
+//printfLogger通过它输出调用日志的文件以及行号，而不是printfLogger自己的位置
+func (d *defaultLogger) output(level LogLevel, calldepth int, s string) {
+	if d.level <= level {
+		d.logger.Output(calldepth+1, s)
+	}
+}
+
 func (d *defaultLogger) Errorf(format string, args ...interface{}) {
 	if d.level <= ErrorLevel {
-		d.logger.Output(2, fmt.Sprintf(format, args...))
+		d.output(ErrorLevel, 2, fmt.Sprintf(format, args...))
 	}
 }
 
 func (d *defaultLogger) Infof(format string, args ...interface{}) {
 	if d.level <= InfoLevel {
-		d.logger.Output(2, fmt.Sprintf(format, args...))
+		d.output(InfoLevel, 2, fmt.Sprintf(format, args...))
 	}
 }
 
 func (d *defaultLogger) Debugf(format string, args ...interface{}) {
 	if d.level <= DebugLevel {
-		d.logger.Output(2, fmt.Sprintf(format, args...))
+		d.output(DebugLevel, 2, fmt.Sprintf(format, args...))
 	}
 }
 
@@ -98,3 +114,143 @@ func SetLogger(logger Logger) {
 	lw.setLogger(logger)
 	dump.SetLogger(logger)
 }
+
+//结构化日志中常用的键
+const (
+	FieldPosition  = "position"   //binlog位置
+	FieldTable     = "table"      //表名
+	FieldEventType = "event_type" //binlog event的类型
+	FieldServerID  = "server_id"  //server id
+)
+
+//Field 结构化日志的键值对
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+//F 创建Field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+//StructuredLogger 带有键值对的分级日志，通过RowStreamer.SetLogger为每个RowStreamer单独设置
+type StructuredLogger interface {
+	Debug(msg string, fields ...Field)     //调试日志打印
+	Info(msg string, fields ...Field)      //进程日志打印
+	Error(msg string, fields ...Field)     //错误日志打印
+	With(fields ...Field) StructuredLogger //返回一个每条日志都带有fields的日志
+}
+
+//LevelEnabler Logger可以选择实现的接口，返回level级别的日志是否会被打印，
+//NewPrintfLogger适配的Logger实现了该接口时，不打印的日志不会格式化键值对
+type LevelEnabler interface {
+	Enabled(level LogLevel) bool
+}
+
+//printfLogger 将Logger适配为StructuredLogger，键值对以key=value的格式追加在日志后面
+type printfLogger struct {
+	logger func() Logger
+	fields []Field
+}
+
+//NewPrintfLogger 将Logger适配为StructuredLogger
+func NewPrintfLogger(logger Logger) StructuredLogger {
+	return &printfLogger{logger: func() Logger { return logger }}
+}
+
+//newGlobalLogger 使用SetLogger设置的全局日志，SetLogger修改后立即生效
+func newGlobalLogger() StructuredLogger {
+	return &printfLogger{logger: lw.logger}
+}
+
+//Enabled 实现LevelEnabler，Logger没有实现LevelEnabler时总是返回true
+func (p *printfLogger) Enabled(level LogLevel) bool {
+	return loggerEnabled(p.logger(), level)
+}
+
+func (p *printfLogger) Debug(msg string, fields ...Field) {
+	p.log(DebugLevel, 3, msg, fields)
+}
+
+func (p *printfLogger) Info(msg string, fields ...Field) {
+	p.log(InfoLevel, 3, msg, fields)
+}
+
+func (p *printfLogger) Error(msg string, fields ...Field) {
+	p.log(ErrorLevel, 3, msg, fields)
+}
+
+//log 打印日志，calldepth与log.Logger.Output相同，从log开始计算，
+//使用默认的Logger时输出的文件以及行号为Debug、Info或者Error的调用者
+func (p *printfLogger) log(level LogLevel, calldepth int, msg string, fields []Field) {
+	logger := p.logger()
+	if !loggerEnabled(logger, level) {
+		return
+	}
+	s := p.format(msg, fields)
+	if d, ok := logger.(*defaultLogger); ok {
+		d.output(level, calldepth, s)
+		return
+	}
+	switch level {
+	case DebugLevel:
+		logger.Debugf("%s", s)
+	case InfoLevel:
+		logger.Infof("%s", s)
+	default:
+		logger.Errorf("%s", s)
+	}
+}
+
+func loggerEnabled(logger Logger, level LogLevel) bool {
+	if le, ok := logger.(LevelEnabler); ok {
+		return le.Enabled(level)
+	}
+	return true
+}
+
+func (p *printfLogger) With(fields ...Field) StructuredLogger {
+	all := make([]Field, 0, len(p.fields)+len(fields))
+	all = append(all, p.fields...)
+	return &printfLogger{logger: p.logger, fields: append(all, fields...)}
+}
+
+func (p *printfLogger) format(msg string, fields []Field) string {
+	buf := bytes.NewBufferString(msg)
+	for _, list := range [][]Field{p.fields, fields} {
+		for _, f := range list {
+			value := formatFieldValue(f.Value)
+			if strings.ContainsAny(value, " \t\n\"=") {
+				value = strconv.Quote(value)
+			}
+			fmt.Fprintf(buf, " %s=%s", f.Key, value)
+		}
+	}
+	return buf.String()
+}
+
+func formatFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case Position:
+		return fmt.Sprintf("%s:%d", v.Filename, v.Offset)
+	case MysqlTableName:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	return fmt.Sprintf("%+v", value)
+}
+
+//dumpLogger 将StructuredLogger适配为dump.Logger，dump连接的日志都是错误日志
+type dumpLogger struct {
+	logger StructuredLogger
+}
+
+func (d dumpLogger) Print(args ...interface{}) {
+	if p, ok := d.logger.(*printfLogger); ok {
+		p.log(ErrorLevel, 3, fmt.Sprint(args...), nil)
+		return
+	}
+	d.logger.Error(fmt.Sprint(args...))
+}
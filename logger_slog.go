@@ -0,0 +1,66 @@
+//go:build go1.21
+// +build go1.21
+
+package binlog
+
+import (
+	"context"
+	"log/slog"
+)
+
+//slogLogger 将*slog.Logger适配为StructuredLogger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+//NewSlogLogger 将标准库的*slog.Logger适配为StructuredLogger，Field转换为slog的属性，
+//logger为nil时使用slog.Default()
+func NewSlogLogger(logger *slog.Logger) StructuredLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogLogger{logger: logger}
+}
+
+func (s *slogLogger) Debug(msg string, fields ...Field) {
+	s.log(slog.LevelDebug, msg, fields)
+}
+
+func (s *slogLogger) Info(msg string, fields ...Field) {
+	s.log(slog.LevelInfo, msg, fields)
+}
+
+func (s *slogLogger) Error(msg string, fields ...Field) {
+	s.log(slog.LevelError, msg, fields)
+}
+
+func (s *slogLogger) With(fields ...Field) StructuredLogger {
+	args := make([]interface{}, 0, len(fields))
+	for _, attr := range slogAttrs(fields) {
+		args = append(args, attr)
+	}
+	return &slogLogger{logger: s.logger.With(args...)}
+}
+
+func (s *slogLogger) log(level slog.Level, msg string, fields []Field) {
+	ctx := context.Background()
+	if !s.logger.Enabled(ctx, level) {
+		return
+	}
+	s.logger.LogAttrs(ctx, level, msg, slogAttrs(fields)...)
+}
+
+func slogAttrs(fields []Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		switch v := f.Value.(type) {
+		case Position:
+			attrs = append(attrs, slog.Group(f.Key, slog.String("filename", v.Filename), slog.Int64("offset", v.Offset)))
+		case MysqlTableName:
+			attrs = append(attrs, slog.String(f.Key, v.String()))
+		default:
+			attrs = append(attrs, slog.Any(f.Key, f.Value))
+		}
+	}
+	return attrs
+}
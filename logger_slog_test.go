@@ -0,0 +1,47 @@
+//go:build go1.21
+// +build go1.21
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	logger = logger.With(F(FieldServerID, uint32(1234)))
+
+	logger.Debug("debug is disabled")
+	if buf.Len() != 0 {
+		t.Fatalf("Debug out: %v", buf.String())
+	}
+
+	logger.Error("parse fail", F(FieldPosition, Position{Filename: "mysql-bin.000001", Offset: 4}),
+		F(FieldTable, NewMysqlTableName("db", "t")), F(FieldEventType, EventTypeWriteRows))
+	var out map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Unmarshal %v err: %v", buf.String(), err)
+	}
+	want := map[string]interface{}{
+		"level":        "ERROR",
+		"msg":          "parse fail",
+		FieldServerID:  float64(1234),
+		FieldPosition:  map[string]interface{}{"filename": "mysql-bin.000001", "offset": float64(4)},
+		FieldTable:     "`db`.`t`",
+		FieldEventType: EventTypeWriteRows,
+	}
+	for k, v := range want {
+		if b, _ := json.Marshal(out[k]); string(b) != mustMarshal(v) {
+			t.Errorf("slog %v want: %v out: %v", k, v, out[k])
+		}
+	}
+}
+
+func mustMarshal(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
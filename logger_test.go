@@ -2,10 +2,13 @@ package binlog
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"sync"
 	"testing"
+
+	"github.com/onlyac0611/binlog/dump"
 )
 
 type mockWriter struct {
@@ -92,3 +95,193 @@ func TestDefaultLogger_Print(t *testing.T) {
 		}
 	}
 }
+
+func TestNewPrintfLogger(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := NewPrintfLogger(NewDefaultLogger(newMockWriter(buf), InfoLevel)).With(F(FieldServerID, 1234))
+
+	//不打印的日志不格式化键值对
+	formatted := 0
+	logger.Debug("debug is disabled", F("value", stringerFunc(func() string {
+		formatted++
+		return "value"
+	})))
+	if buf.Len() != 0 || formatted != 0 {
+		t.Fatalf("Debug out: %v formatted: %v", buf.String(), formatted)
+	}
+	if le, ok := logger.(LevelEnabler); !ok || le.Enabled(DebugLevel) || !le.Enabled(InfoLevel) {
+		t.Fatalf("printfLogger Enabled want debug disabled and info enabled")
+	}
+
+	testCases := []struct {
+		log    func(string, ...Field)
+		msg    string
+		fields []Field
+		want   string
+	}{
+		{
+			log:  logger.Info,
+			msg:  "info",
+			want: "info server_id=1234",
+		},
+		{
+			log: logger.Error,
+			msg: "error",
+			fields: []Field{
+				F(FieldPosition, Position{Filename: "mysql-bin.000001", Offset: 4}),
+				F(FieldTable, NewMysqlTableName("db", "t")),
+				F("query", "select 1"),
+				F("error", fmt.Errorf("bad")),
+			},
+			want: "error server_id=1234 position=mysql-bin.000001:4 table=`db`.`t` query=\"select 1\" error=bad",
+		},
+	}
+	for _, v := range testCases {
+		buf.Reset()
+		v.log(v.msg, v.fields...)
+		if out := strings.TrimSuffix(buf.String(), "\n"); !strings.HasSuffix(out, ": "+v.want) {
+			t.Fatalf("want: %v out: %v", v.want, out)
+		}
+	}
+}
+
+type stringerFunc func() string
+
+func (f stringerFunc) String() string {
+	return f()
+}
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+//recordLogger 记录所有日志的StructuredLogger
+type recordLogger struct {
+	mu      *sync.Mutex
+	entries *[]logEntry
+	fields  []Field
+}
+
+func newRecordLogger() *recordLogger {
+	return &recordLogger{mu: &sync.Mutex{}, entries: &[]logEntry{}}
+}
+
+func (r *recordLogger) record(level, msg string, fields []Field) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := logEntry{level: level, msg: msg, fields: make(map[string]interface{})}
+	for _, f := range append(append([]Field{}, r.fields...), fields...) {
+		e.fields[f.Key] = f.Value
+	}
+	*r.entries = append(*r.entries, e)
+}
+
+func (r *recordLogger) Debug(msg string, fields ...Field) { r.record("debug", msg, fields) }
+func (r *recordLogger) Info(msg string, fields ...Field)  { r.record("info", msg, fields) }
+func (r *recordLogger) Error(msg string, fields ...Field) { r.record("error", msg, fields) }
+
+func (r *recordLogger) With(fields ...Field) StructuredLogger {
+	return &recordLogger{mu: r.mu, entries: r.entries, fields: append(append([]Field{}, r.fields...), fields...)}
+}
+
+func (r *recordLogger) all() []logEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]logEntry{}, *r.entries...)
+}
+
+type loggerPacketConn struct {
+	*mockPacketConn
+	logger dump.Logger
+}
+
+func (l *loggerPacketConn) SetLogger(logger dump.Logger) {
+	l.logger = logger
+}
+
+func TestRowStreamer_SetLogger(t *testing.T) {
+	global := bytes.NewBuffer(nil)
+	SetLogger(NewDefaultLogger(newMockWriter(global), DebugLevel))
+	defer SetLogger(newNilLogger())
+
+	for _, serverID := range []uint32{1001, 1002} {
+		conn := &loggerPacketConn{mockPacketConn: newMockPacketConn(getInputData())}
+		conn.packets = append(conn.packets, []byte{dump.PacketEOF})
+
+		r, err := NewRowStreamer(testDSN, serverID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.newDumpConn = func() (dumpConn, error) {
+			return conn, nil
+		}
+		r.SetStartBinlogPosition(testBinlogPosParseEvents)
+		r.SetNonBlock(true)
+		logger := newRecordLogger()
+		r.SetLogger(logger)
+
+		if err = r.Stream(context.Background(), func(tran *Transaction) error {
+			return nil
+		}); err != nil {
+			t.Fatalf("Stream err: %v", err)
+		}
+
+		entries := logger.all()
+		if len(entries) == 0 {
+			t.Fatalf("SetLogger no log recorded")
+		}
+		var tableLogged bool
+		for _, e := range entries {
+			if e.fields[FieldServerID] != serverID {
+				t.Fatalf("log %q server_id want: %v out: %v", e.msg, serverID, e.fields[FieldServerID])
+			}
+			if e.fields[FieldEventType] == EventTypeWriteRows {
+				tableLogged = e.fields[FieldTable] == tesInfo.name
+				if _, ok := e.fields[FieldPosition].(Position); !ok {
+					t.Fatalf("log %q without position: %+v", e.msg, e.fields)
+				}
+			}
+		}
+		if !tableLogged {
+			t.Fatalf("SetLogger table not logged: %+v", entries)
+		}
+
+		//dump连接的日志也使用该日志
+		conn.logger.Print("dump error")
+		last := logger.all()[len(logger.all())-1]
+		if last.level != "error" || last.msg != "dump error" {
+			t.Fatalf("dump logger out: %+v", last)
+		}
+	}
+	if global.Len() != 0 {
+		t.Fatalf("global logger used: %v", global.String())
+	}
+}
+
+func TestPrintfLogger_Caller(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	SetLogger(NewDefaultLogger(newMockWriter(buf), DebugLevel))
+	defer SetLogger(newNilLogger())
+
+	//使用默认的Logger时输出的是调用日志的位置而不是logger.go
+	loggers := []StructuredLogger{
+		NewPrintfLogger(NewDefaultLogger(newMockWriter(buf), DebugLevel)),
+		newGlobalLogger().With(F(FieldServerID, 1)),
+	}
+	for i, logger := range loggers {
+		for _, log := range []func(string, ...Field){logger.Debug, logger.Info, logger.Error} {
+			buf.Reset()
+			log("caller")
+			if !strings.Contains(buf.String(), " logger_test.go:") {
+				t.Fatalf("logger %d caller want logger_test.go out: %v", i, buf.String())
+			}
+		}
+		buf.Reset()
+		dumpLogger{logger: logger}.Print("caller")
+		if !strings.Contains(buf.String(), " logger_test.go:") {
+			t.Fatalf("dumpLogger %d caller want logger_test.go out: %v", i, buf.String())
+		}
+	}
+}
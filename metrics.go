@@ -0,0 +1,47 @@
+package binlog
+
+import "time"
+
+//binlog event的类型，用于MetricsHook.ObserveEvent
+const (
+	EventTypeFormatDescription = "format_description" //FORMAT_DESCRIPTION_EVENT
+	EventTypeRotate            = "rotate"             //ROTATE_EVENT
+	EventTypeXID               = "xid"                //XID_EVENT
+	EventTypeQuery             = "query"              //QUERY_EVENT
+	EventTypeTableMap          = "table_map"          //TABLE_MAP_EVENT
+	EventTypeWriteRows         = "write_rows"         //WRITE_ROWS_EVENT
+	EventTypeUpdateRows        = "update_rows"        //UPDATE_ROWS_EVENT
+	EventTypeDeleteRows        = "delete_rows"        //DELETE_ROWS_EVENT
+	EventTypeGTID              = "gtid"               //GTID_EVENT
+	EventTypePreviousGTIDs     = "previous_gtids"     //PREVIOUS_GTIDS_EVENT
+	EventTypeOther             = "other"              //其他event
+)
+
+//MetricsHook 用于收集RowStreamer运行指标的接口，通过RowStreamer.SetMetricsHook设置，
+//ObserveConnect以及ObserveBytes在dump连接的goroutine中调用，其他方法在Stream的goroutine中调用，
+//实现需要保证并发安全且不能阻塞
+type MetricsHook interface {
+	ObserveConnect(err error)                              //建立dump连接，err为连接失败的原因
+	ObserveBytes(n int)                                    //从主库读取了n个字节的binlog数据包
+	ObserveEvent(typ string, decode time.Duration)         //处理了一个binlog event，decode为解析耗时
+	ObserveTransaction(tran *Transaction, d time.Duration) //发送了一个事务，d为SendTransactionFunc的耗时
+	ObservePosition(pos Position, timestamp int64)         //当前处理到的位置以及binlog event的执行时间
+	ObserveTableCache(size int)                            //表信息缓存的大小
+}
+
+type nopMetricsHook struct{}
+
+func (nopMetricsHook) ObserveConnect(error)                           {}
+func (nopMetricsHook) ObserveBytes(int)                               {}
+func (nopMetricsHook) ObserveEvent(string, time.Duration)             {}
+func (nopMetricsHook) ObserveTransaction(*Transaction, time.Duration) {}
+func (nopMetricsHook) ObservePosition(Position, int64)                {}
+func (nopMetricsHook) ObserveTableCache(int)                          {}
+
+//metricsOrNop 未设置MetricsHook时不收集指标
+func metricsOrNop(m MetricsHook) MetricsHook {
+	if m == nil {
+		return nopMetricsHook{}
+	}
+	return m
+}
@@ -0,0 +1,247 @@
+package binlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//defaultLatencyBuckets 耗时直方图的上界(秒)
+var defaultLatencyBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+type histogram struct {
+	buckets []uint64 //每个上界的数量，非累计
+	sum     float64
+	count   uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{buckets: make([]uint64, len(defaultLatencyBuckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, upper := range defaultLatencyBuckets {
+		if v <= upper {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+//PrometheusMetrics 以prometheus文本格式输出指标的MetricsHook，实现了http.Handler，
+//可以直接注册到http服务的/metrics，多个RowStreamer不要共用同一个PrometheusMetrics
+type PrometheusMetrics struct {
+	mu            sync.Mutex
+	connects      uint64
+	connectErrors uint64
+	bytesRead     uint64
+	events        map[string]uint64
+	decode        map[string]*histogram
+	transactions  uint64
+	statements    map[string]uint64
+	callback      *histogram
+	pos           Position
+	lastTimestamp int64
+	tableCache    int
+	now           func() time.Time
+}
+
+//NewPrometheusMetrics 创建PrometheusMetrics
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		events:     make(map[string]uint64),
+		decode:     make(map[string]*histogram),
+		statements: make(map[string]uint64),
+		callback:   newHistogram(),
+		now:        time.Now,
+	}
+}
+
+//ObserveConnect 实现MetricsHook
+func (p *PrometheusMetrics) ObserveConnect(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.connects++
+	if err != nil {
+		p.connectErrors++
+	}
+}
+
+//ObserveBytes 实现MetricsHook
+func (p *PrometheusMetrics) ObserveBytes(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytesRead += uint64(n)
+}
+
+//ObserveEvent 实现MetricsHook
+func (p *PrometheusMetrics) ObserveEvent(typ string, decode time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events[typ]++
+	h, ok := p.decode[typ]
+	if !ok {
+		h = newHistogram()
+		p.decode[typ] = h
+	}
+	h.observe(decode.Seconds())
+}
+
+//ObserveTransaction 实现MetricsHook，DDL不会作为事务发送，所以只统计行变更的语句
+func (p *PrometheusMetrics) ObserveTransaction(tran *Transaction, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ev := range tran.Events {
+		p.statements[ev.Type.String()]++
+	}
+	p.transactions++
+	p.callback.observe(d.Seconds())
+}
+
+//ObservePosition 实现MetricsHook，记录当前位置以及最新的binlog event执行时间
+func (p *PrometheusMetrics) ObservePosition(pos Position, timestamp int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pos = pos
+	if timestamp > 0 {
+		p.lastTimestamp = timestamp
+	}
+}
+
+//lag 复制延迟，为输出指标时的当前时间与最新的binlog event执行时间的差，
+//没有新的binlog event时(包括主库空闲以及dump阻塞)会一直增长，还没有binlog event时为0
+func (p *PrometheusMetrics) lag() float64 {
+	if p.lastTimestamp <= 0 {
+		return 0
+	}
+	lag := p.now().Sub(time.Unix(p.lastTimestamp, 0)).Seconds()
+	if lag < 0 {
+		return 0
+	}
+	return lag
+}
+
+//ObserveTableCache 实现MetricsHook
+func (p *PrometheusMetrics) ObserveTableCache(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tableCache = size
+}
+
+//WriteTo 以prometheus文本格式输出所有指标
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	buf := bytes.NewBuffer(nil)
+
+	p.mu.Lock()
+	writeHeader(buf, "binlog_connects_total", "counter", "Number of binlog dump connection attempts by result.")
+	fmt.Fprintf(buf, "binlog_connects_total{result=\"ok\"} %d\n", p.connects-p.connectErrors)
+	fmt.Fprintf(buf, "binlog_connects_total{result=\"error\"} %d\n", p.connectErrors)
+	var reconnects uint64
+	if p.connects > 0 {
+		reconnects = p.connects - 1
+	}
+	writeHeader(buf, "binlog_reconnects_total", "counter", "Number of binlog dump connection attempts after the first one.")
+	fmt.Fprintf(buf, "binlog_reconnects_total %d\n", reconnects)
+	writeHeader(buf, "binlog_read_bytes_total", "counter", "Number of bytes read from the binlog dump connection.")
+	fmt.Fprintf(buf, "binlog_read_bytes_total %d\n", p.bytesRead)
+
+	writeHeader(buf, "binlog_events_total", "counter", "Number of binlog events processed by type.")
+	writeCounters(buf, "binlog_events_total", p.events)
+	writeHeader(buf, "binlog_event_decode_seconds", "histogram", "Time spent decoding binlog events by type.")
+	for _, typ := range sortedHistogramKeys(p.decode) {
+		writeHistogram(buf, "binlog_event_decode_seconds", labelPair("type", typ), p.decode[typ])
+	}
+
+	writeHeader(buf, "binlog_transactions_total", "counter", "Number of transactions sent.")
+	fmt.Fprintf(buf, "binlog_transactions_total %d\n", p.transactions)
+	writeHeader(buf, "binlog_statements_total", "counter", "Number of statements in sent transactions by type.")
+	writeCounters(buf, "binlog_statements_total", p.statements)
+	writeHeader(buf, "binlog_send_transaction_seconds", "histogram", "Time spent in the transaction callback.")
+	writeHistogram(buf, "binlog_send_transaction_seconds", "", p.callback)
+
+	writeHeader(buf, "binlog_position_offset", "gauge", "Offset of the current binlog position.")
+	fmt.Fprintf(buf, "binlog_position_offset{%s} %d\n", labelPair("file", p.pos.Filename), p.pos.Offset)
+	if seq, ok := binlogFileSequence(p.pos.Filename); ok {
+		writeHeader(buf, "binlog_position_file_sequence", "gauge", "Sequence number of the current binlog file.")
+		fmt.Fprintf(buf, "binlog_position_file_sequence %d\n", seq)
+	}
+	writeHeader(buf, "binlog_last_event_timestamp_seconds", "gauge", "Timestamp of the last processed binlog event.")
+	fmt.Fprintf(buf, "binlog_last_event_timestamp_seconds %d\n", p.lastTimestamp)
+	writeHeader(buf, "binlog_replication_lag_seconds", "gauge", "Time elapsed since the timestamp of the last processed binlog event.")
+	fmt.Fprintf(buf, "binlog_replication_lag_seconds %s\n", formatFloat(p.lag()))
+	writeHeader(buf, "binlog_table_cache_size", "gauge", "Number of tables in the table map cache.")
+	fmt.Fprintf(buf, "binlog_table_cache_size %d\n", p.tableCache)
+	p.mu.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+//ServeHTTP 实现http.Handler
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+func writeHeader(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeCounters(buf *bytes.Buffer, name string, counters map[string]uint64) {
+	for _, typ := range sortedCounterKeys(counters) {
+		fmt.Fprintf(buf, "%s{%s} %d\n", name, labelPair("type", typ), counters[typ])
+	}
+}
+
+func writeHistogram(buf *bytes.Buffer, name, labels string, h *histogram) {
+	prefix := ""
+	if labels != "" {
+		prefix = labels + ","
+	}
+	var cumulative uint64
+	for i, upper := range defaultLatencyBuckets {
+		cumulative += h.buckets[i]
+		fmt.Fprintf(buf, "%s_bucket{%sle=\"%s\"} %d\n", name, prefix, formatFloat(upper), cumulative)
+	}
+	fmt.Fprintf(buf, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(buf, "%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(buf, "%s_count%s %d\n", name, labels, h.count)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelPair(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedCounterKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedHistogramKeys(m map[string]*histogram) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
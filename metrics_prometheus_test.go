@@ -0,0 +1,153 @@
+package binlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onlyac0611/binlog/dump"
+)
+
+func TestPrometheusMetrics_WriteTo(t *testing.T) {
+	p := NewPrometheusMetrics()
+	p.now = func() time.Time { return time.Unix(1407805600, 0) }
+
+	p.ObserveConnect(nil)
+	p.ObserveConnect(errors.New("connection refused"))
+	p.ObserveConnect(nil)
+	p.ObserveBytes(100)
+	p.ObserveBytes(23)
+	p.ObserveEvent(EventTypeWriteRows, 20*time.Microsecond)
+	p.ObserveEvent(EventTypeWriteRows, 2*time.Millisecond)
+	p.ObserveEvent(EventTypeXID, 0)
+	p.ObserveTransaction(&Transaction{Events: []*StreamEvent{
+		{Type: StatementInsert}, {Type: StatementInsert}, {Type: StatementUpdate},
+	}}, 3*time.Millisecond)
+	p.ObserveTransaction(&Transaction{Events: []*StreamEvent{{Type: StatementAlter}}}, 10*time.Second)
+	p.ObservePosition(Position{Filename: "mysql-bin.000003", Offset: 1234}, 1407805592)
+	p.ObserveTableCache(2)
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := p.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo err: %v", err)
+	}
+	out := buf.String()
+
+	wants := []string{
+		"# TYPE binlog_connects_total counter",
+		`binlog_connects_total{result="ok"} 2`,
+		`binlog_connects_total{result="error"} 1`,
+		"binlog_reconnects_total 2",
+		"binlog_read_bytes_total 123",
+		`binlog_events_total{type="write_rows"} 2`,
+		`binlog_events_total{type="xid"} 1`,
+		"# TYPE binlog_event_decode_seconds histogram",
+		`binlog_event_decode_seconds_bucket{type="write_rows",le="1e-05"} 0`,
+		`binlog_event_decode_seconds_bucket{type="write_rows",le="5e-05"} 1`,
+		`binlog_event_decode_seconds_bucket{type="write_rows",le="0.005"} 2`,
+		`binlog_event_decode_seconds_bucket{type="write_rows",le="+Inf"} 2`,
+		`binlog_event_decode_seconds_count{type="write_rows"} 2`,
+		`binlog_event_decode_seconds_bucket{type="xid",le="1e-05"} 1`,
+		"binlog_transactions_total 2",
+		`binlog_statements_total{type="insert"} 2`,
+		`binlog_statements_total{type="update"} 1`,
+		`binlog_statements_total{type="alter"} 1`,
+		`binlog_send_transaction_seconds_bucket{le="0.005"} 1`,
+		`binlog_send_transaction_seconds_bucket{le="5"} 1`,
+		`binlog_send_transaction_seconds_bucket{le="+Inf"} 2`,
+		"binlog_send_transaction_seconds_sum 10.003",
+		"binlog_send_transaction_seconds_count 2",
+		`binlog_position_offset{file="mysql-bin.000003"} 1234`,
+		"binlog_position_file_sequence 3",
+		"binlog_last_event_timestamp_seconds 1407805592",
+		"binlog_replication_lag_seconds 8",
+		"binlog_table_cache_size 2",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("WriteTo missing %q in:\n%s", want, out)
+		}
+	}
+
+	//没有新的binlog event时延迟继续增长
+	p.now = func() time.Time { return time.Unix(1407805700, 0) }
+	p.ObservePosition(Position{Filename: "mysql-bin.000004", Offset: 4}, 0)
+	buf.Reset()
+	p.WriteTo(buf)
+	if want := "binlog_replication_lag_seconds 108\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("WriteTo missing %q in:\n%s", want, buf.String())
+	}
+}
+
+func TestPrometheusMetrics_ServeHTTP(t *testing.T) {
+	p := NewPrometheusMetrics()
+	p.ObservePosition(Position{Filename: `a"b`, Offset: 4}, 0)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %v", ct)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `binlog_position_offset{file="a\"b"} 4`) {
+		t.Errorf("ServeHTTP label not escaped:\n%s", body)
+	}
+	if strings.Contains(body, "binlog_position_file_sequence") {
+		t.Errorf("ServeHTTP unexpected file sequence:\n%s", body)
+	}
+	if !strings.Contains(body, "binlog_reconnects_total 0\n") {
+		t.Errorf("ServeHTTP missing reconnects:\n%s", body)
+	}
+}
+
+func TestRowStreamer_SetMetricsHook(t *testing.T) {
+	conn := newMockPacketConn(getInputData())
+	conn.packets = append(conn.packets, []byte{dump.PacketEOF})
+
+	r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewRowStreamer err: %v", err)
+	}
+	r.newDumpConn = func() (dumpConn, error) {
+		return conn, nil
+	}
+	r.SetStartBinlogPosition(testBinlogPosParseEvents)
+	r.SetNonBlock(true)
+	p := NewPrometheusMetrics()
+	r.SetMetricsHook(p)
+
+	if err = r.Stream(context.Background(), func(tran *Transaction) error {
+		return nil
+	}); err != nil {
+		t.Fatalf("Stream err: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	p.WriteTo(buf)
+	out := buf.String()
+	wants := []string{
+		`binlog_connects_total{result="ok"} 1`,
+		"binlog_reconnects_total 0",
+		`binlog_events_total{type="format_description"} 1`,
+		`binlog_events_total{type="table_map"} 1`,
+		`binlog_events_total{type="write_rows"} 1`,
+		`binlog_events_total{type="update_rows"} 1`,
+		`binlog_events_total{type="delete_rows"} 1`,
+		"binlog_transactions_total 1",
+		`binlog_statements_total{type="insert"} 1`,
+		`binlog_position_offset{file="` + testBinlogPosParseEvents.Filename + `"} 4`,
+		"binlog_table_cache_size 1",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("metrics missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "binlog_read_bytes_total 0\n") {
+		t.Errorf("metrics read bytes not observed:\n%s", out)
+	}
+}
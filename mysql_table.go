@@ -12,6 +12,61 @@ type MysqlTable interface {
 	Columns() []MysqlColumn //所有列
 }
 
+//MysqlKeyTable MysqlTable可选实现的接口，用于获取主键以及唯一索引，
+//没有实现时从实现了MysqlKeyColumn的列中获取主键
+type MysqlKeyTable interface {
+	PrimaryKey() []string   //主键的所有列，按照索引中的顺序
+	UniqueKeys() [][]string //所有唯一索引(不包括主键)的列，按照索引中的顺序
+}
+
+//MysqlKeyColumn MysqlColumn可选实现的接口，用于获取列是否属于主键或者唯一索引
+type MysqlKeyColumn interface {
+	IsPrimaryKey() bool //是否是主键的一部分
+	IsUnique() bool     //是否单独作为主键或者唯一索引
+}
+
+//MysqlNullableColumn MysqlColumn可选实现的接口，用于获取列是否可以为NULL
+type MysqlNullableColumn interface {
+	IsNullable() bool //是否可以为NULL
+}
+
+//MysqlDefaultColumn MysqlColumn可选实现的接口，用于获取列的默认值
+type MysqlDefaultColumn interface {
+	Default() []byte //默认值的文本，没有默认值或者默认值为NULL时为nil
+}
+
+//MysqlCharsetColumn MysqlColumn可选实现的接口，用于获取字符类型列的字符集以及排序规则
+type MysqlCharsetColumn interface {
+	Charset() string   //字符集，如utf8mb4，非字符类型为空
+	Collation() string //排序规则，如utf8mb4_general_ci，非字符类型为空
+}
+
+//MysqlEnumColumn MysqlColumn可选实现的接口，用于获取ENUM以及SET列的成员，
+//TABLE_MAP_EVENT中没有成员信息(binlog_row_metadata不为FULL)时使用它将索引或者位图转为成员名
+type MysqlEnumColumn interface {
+	EnumValues() []string //按定义顺序排列的成员，非ENUM以及SET列为nil
+}
+
+//MysqlTypedColumn MysqlColumn可选实现的接口，用于获取列定义中的类型
+type MysqlTypedColumn interface {
+	SQLType() string //列定义中的类型，如int(10) unsigned、varchar(64)
+}
+
+//TablePrimaryKey 获取表的主键的所有列，table实现了MysqlKeyTable时使用PrimaryKey，
+//否则使用实现了MysqlKeyColumn的列，都没有实现或者表没有主键时返回nil
+func TablePrimaryKey(table MysqlTable) []string {
+	if t, ok := table.(MysqlKeyTable); ok {
+		return t.PrimaryKey()
+	}
+	var keys []string
+	for _, c := range table.Columns() {
+		if k, ok := c.(MysqlKeyColumn); ok && k.IsPrimaryKey() {
+			keys = append(keys, c.Field())
+		}
+	}
+	return keys
+}
+
 //MysqlTableName mysql的表名
 type MysqlTableName struct {
 	DbName    string `json:"db"`    //数据库名
@@ -1,6 +1,9 @@
 package binlog
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestMysqlTableName_String(t *testing.T) {
 	testCases := []struct {
@@ -20,3 +23,48 @@ func TestMysqlTableName_String(t *testing.T) {
 		}
 	}
 }
+
+type testKeyTable struct {
+	mysqlTableInfo
+	primaryKey []string
+}
+
+func (m *testKeyTable) PrimaryKey() []string {
+	return m.primaryKey
+}
+
+func (m *testKeyTable) UniqueKeys() [][]string {
+	return nil
+}
+
+func TestTablePrimaryKey(t *testing.T) {
+	testCases := []struct {
+		input MysqlTable
+		want  []string
+	}{
+		{
+			input: tesInfo,
+			want:  []string{"id"},
+		},
+		{
+			input: &testKeyTable{mysqlTableInfo: *tesInfo, primaryKey: []string{"message", "id"}},
+			want:  []string{"message", "id"},
+		},
+		{
+			input: &mysqlTableInfo{
+				name: tesInfo.name,
+				columns: []MysqlColumn{
+					&mysqlColumnAttribute{field: "id", typ: "int(11)", key: mysqlUniqueKeyDescription},
+				},
+			},
+			want: nil,
+		},
+	}
+
+	for _, v := range testCases {
+		out := TablePrimaryKey(v.input)
+		if fmt.Sprint(out) != fmt.Sprint(v.want) || len(out) != len(v.want) {
+			t.Fatalf("TablePrimaryKey want: %v out: %v", v.want, out)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package binlog
 
+import (
+	"strconv"
+	"strings"
+)
+
 // Position 指定binlog的位置，以文件名和位移
 type Position struct {
 	Filename string `json:"filename"` //binlog文件名
@@ -10,3 +15,50 @@ type Position struct {
 func (p Position) IsZero() bool {
 	return p.Filename == "" || p.Offset == 0
 }
+
+// Compare 比较两个binlog位置，p在other之前返回-1，相同返回0，在other之后返回1
+// binlog文件名按照其序号比较，如mysql-bin.000009在mysql-bin.000010之前
+func (p Position) Compare(other Position) int {
+	if c := compareBinlogFilename(p.Filename, other.Filename); c != 0 {
+		return c
+	}
+	switch {
+	case p.Offset < other.Offset:
+		return -1
+	case p.Offset > other.Offset:
+		return 1
+	}
+	return 0
+}
+
+//compareBinlogFilename 比较binlog文件名，优先使用文件名后缀的序号进行比较
+func compareBinlogFilename(left, right string) int {
+	if left == right {
+		return 0
+	}
+	l, lok := binlogFileSequence(left)
+	r, rok := binlogFileSequence(right)
+	if lok && rok && l != r {
+		if l < r {
+			return -1
+		}
+		return 1
+	}
+	if left < right {
+		return -1
+	}
+	return 1
+}
+
+//binlogFileSequence 获取binlog文件名的序号，如mysql-bin.000003的序号为3
+func binlogFileSequence(filename string) (uint64, bool) {
+	i := strings.LastIndexByte(filename, '.')
+	if i < 0 {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(filename[i+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
@@ -48,3 +48,44 @@ func TestPosition_IsZero(t *testing.T) {
 		}
 	}
 }
+
+func TestPosition_Compare(t *testing.T) {
+	testCases := []struct {
+		left  Position
+		right Position
+		want  int
+	}{
+		{
+			left:  Position{Filename: "mysql-bin.000001", Offset: 4},
+			right: Position{Filename: "mysql-bin.000001", Offset: 4},
+			want:  0,
+		},
+		{
+			left:  Position{Filename: "mysql-bin.000001", Offset: 4},
+			right: Position{Filename: "mysql-bin.000001", Offset: 120},
+			want:  -1,
+		},
+		{
+			left:  Position{Filename: "mysql-bin.000002", Offset: 4},
+			right: Position{Filename: "mysql-bin.000001", Offset: 120},
+			want:  1,
+		},
+		{
+			left:  Position{Filename: "mysql-bin.999999", Offset: 120},
+			right: Position{Filename: "mysql-bin.1000000", Offset: 4},
+			want:  -1,
+		},
+		{
+			left:  Position{Filename: "b", Offset: 4},
+			right: Position{Filename: "a", Offset: 4},
+			want:  1,
+		},
+	}
+
+	for _, v := range testCases {
+		out := v.left.Compare(v.right)
+		if v.want != out {
+			t.Fatalf("want != out left: %+v right: %+v want: %v, out: %v", v.left, v.right, v.want, out)
+		}
+	}
+}
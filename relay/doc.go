@@ -0,0 +1,22 @@
+/*
+Package relay binlog中继，作为从库从主库dump binlog并原样归档到本地的binlog文件中，
+文件名以及每个binlog event的位置与主库完全一致，同时作为主库接受下游的mysql连接，
+使用本地归档的binlog文件应答COM_BINLOG_DUMP，读完归档后继续等待新的binlog event，
+多个下游的CDC消费者只需要连接中继，不需要各自在主库上建立dump连接:
+
+	r, err := relay.NewRelay("root:123456@tcp(127.0.0.1:3306)/mysql", 1234, "/data/relay")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	r.SetUser("repl", "123456")
+	if err := r.Listen("127.0.0.1:3307"); err != nil {
+		return err
+	}
+	return r.Run(ctx)
+
+下游可以直接使用binlog.NewRowStreamer连接中继的地址，Run在与主库的连接断开后返回，
+再次调用Run会从归档的末尾继续dump
+*/
+package relay
@@ -0,0 +1,453 @@
+package relay
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+const (
+	//indexBaseName 归档的binlog索引文件为relay.index
+	indexBaseName   = "relay"
+	binlogMagicSize = 4
+
+	//binlog event header中各个字段的偏移
+	eventTypeOffset         = 4
+	eventServerIDOffset     = 5
+	eventNextPositionOffset = 13
+	eventFlagsOffset        = 17
+	eventHeaderSize         = 19
+
+	eventTypeRotate            = 4
+	eventTypeFormatDescription = 15
+	eventTypeHeartbeat         = 27
+
+	//LOG_EVENT_BINLOG_IN_USE_F binlog文件正在写入的标志
+	logEventBinlogInUseF = 0x1
+	//LOG_EVENT_ARTIFICIAL_F 主库在dump时发送的fake ROTATE_EVENT的标志，不会写入binlog文件
+	logEventArtificialF = 0x20
+)
+
+//Relay binlog中继，Run负责从主库归档binlog，Listen负责应答下游的binlog dump，
+//所有的方法都可以并发调用
+type Relay struct {
+	dsn      string
+	serverID uint32
+	dir      string
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	closed    bool
+	running   bool
+	startFile string
+	users     map[string]string
+	writer    *replication.BinlogWriter
+	format    replication.BinlogFormat
+	files     []string
+	size      int64
+	nextFile  string //Run继续dump的binlog文件
+	nextPos   int64  //Run继续dump的位置，为0时从nextFile的开头dump并由FORMAT_DESCRIPTION_EVENT创建该文件
+	conns     map[*dump.ServerConn]struct{}
+	nextID    uint32
+}
+
+//NewRelay dsn是主库的连接信息，serverID是中继作为从库时使用的编号，同时也是下游看到的主库编号，
+//dir是归档binlog的目录。如果dir中已经有归档，会检查最后一个binlog文件，
+//未以ROTATE_EVENT结束的文件会被删除并在Run时从头重新dump
+func NewRelay(dsn string, serverID uint32, dir string) (*Relay, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("NewRelay create dir fail. err: %v", err)
+	}
+
+	r := &Relay{
+		dsn:      dsn,
+		serverID: serverID,
+		dir:      dir,
+		users:    make(map[string]string),
+		conns:    make(map[*dump.ServerConn]struct{}),
+	}
+	r.cond = sync.NewCond(&r.mu)
+	if err := r.loadArchive(); err != nil {
+		return nil, fmt.Errorf("NewRelay load archive fail. err: %v", err)
+	}
+	return r, nil
+}
+
+//SetStartFile 设置本地没有归档时从主库的哪个binlog文件开始dump，默认为SHOW MASTER STATUS的当前文件
+func (r *Relay) SetStartFile(filename string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.startFile = filename
+}
+
+//SetUser 设置下游允许登录的账号
+func (r *Relay) SetUser(user, password string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user] = password
+}
+
+//Position 获取归档的当前位置，即下一个binlog event在主库中的位置
+func (r *Relay) Position() (string, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.files) == 0 {
+		return "", 0
+	}
+	return r.files[len(r.files)-1], r.size
+}
+
+//Run 连接主库并从归档的末尾开始dump，将binlog event写入本地的binlog文件，
+//直到ctx结束、与主库的连接断开或者Close被调用，同一时刻只能有一个Run在执行，
+//返回后可以再次调用Run从上一次归档的位置继续
+func (r *Relay) Run(ctx context.Context) error {
+	r.mu.Lock()
+	if r.running || r.closed {
+		r.mu.Unlock()
+		return fmt.Errorf("Run relay is running or closed")
+	}
+	r.running = true
+	filename, offset := r.nextFile, r.nextPos
+	if filename == "" {
+		filename = r.startFile
+	}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+	}()
+
+	conn, err := dump.NewMysqlConn(r.dsn)
+	if err != nil {
+		return fmt.Errorf("Run newMysqlConn fail. err: %v", err)
+	}
+	//连接只在Run中关闭，ctx结束时只中断阻塞的ReadPacket
+	interrupt := conn.ReadInterrupter()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			interrupt()
+		case <-done:
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+		conn.Close()
+	}()
+
+	if filename == "" {
+		if filename, err = showMasterStatus(conn); err != nil {
+			return fmt.Errorf("Run showMasterStatus fail. err: %v", err)
+		}
+	}
+	if err := conn.Exec("SET @master_binlog_checksum=@@global.binlog_checksum"); err != nil {
+		return fmt.Errorf("Run set @master_binlog_checksum fail. err: %v", err)
+	}
+	//从文件中间继续dump时该文件已经在归档中，忽略主库发送的FORMAT_DESCRIPTION_EVENT
+	pending := filename
+	if offset > 0 {
+		pending = ""
+	} else {
+		offset = binlogMagicSize
+	}
+	if err := conn.NoticeDump(r.serverID, uint32(offset), filename, 0); err != nil {
+		return fmt.Errorf("Run noticeDump fail. err: %v", err)
+	}
+
+	for {
+		//interrupt可能在ReadPacket重新设置读超时之前被调用而失效，因此每次读取前都检查ctx
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		data, err := conn.ReadPacket()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("Run readPacket fail. err: %v", err)
+		}
+
+		switch data[0] {
+		case dump.PacketEOF:
+			return nil
+		case dump.PacketERR:
+			return conn.HandleErrorPacket(data)
+		}
+		if err := r.archive(data[1:], &pending); err != nil {
+			return fmt.Errorf("Run archive fail. err: %v", err)
+		}
+	}
+}
+
+//archive 将主库发送的binlog event写入归档，pending是下一个FORMAT_DESCRIPTION_EVENT所属的binlog文件，
+//写入ROTATE_EVENT时会由BinlogWriter切换文件，并保证归档中的位置与主库一致
+func (r *Relay) archive(data []byte, pending *string) error {
+	if len(data) < eventHeaderSize {
+		return fmt.Errorf("invalid binlog event with %v bytes", len(data))
+	}
+	typ := data[eventTypeOffset]
+	flags := binary.LittleEndian.Uint16(data[eventFlagsOffset:])
+	//fake ROTATE_EVENT中的文件名已经由dump的起始文件或者前一个ROTATE_EVENT得到
+	if typ == eventTypeHeartbeat || (typ == eventTypeRotate && flags&logEventArtificialF != 0) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return fmt.Errorf("relay closed")
+	}
+
+	ev := replication.NewMysql56BinlogEvent(data)
+	switch typ {
+	case eventTypeFormatDescription:
+		if *pending == "" {
+			return nil
+		}
+		format, err := ev.Format()
+		if err != nil {
+			return err
+		}
+		if r.writer == nil {
+			serverID := binary.LittleEndian.Uint32(data[eventServerIDOffset:])
+			r.writer, err = replication.NewBinlogWriter(r.dir, indexBaseName, format, serverID, math.MaxInt64)
+			if err != nil {
+				return err
+			}
+		}
+		if err := r.rotateTo(*pending); err != nil {
+			return err
+		}
+		r.format = format
+		*pending = ""
+	case eventTypeRotate:
+		if err := r.checkPosition(ev); err != nil {
+			return err
+		}
+		name, err := rotateFilename(r.format, ev)
+		if err != nil {
+			return err
+		}
+		if err := r.rotateTo(name); err != nil {
+			return err
+		}
+	default:
+		if r.writer == nil || *pending != "" {
+			return fmt.Errorf("received binlog event before FORMAT_DESCRIPTION_EVENT")
+		}
+		if err := r.checkPosition(ev); err != nil {
+			return err
+		}
+		if err := r.writer.WriteEvent(ev); err != nil {
+			return err
+		}
+	}
+
+	//下游在读取归档时需要看到完整的binlog event
+	if err := r.writer.Flush(); err != nil {
+		return err
+	}
+	_, r.size = r.writer.Position()
+	r.nextFile, r.nextPos = r.files[len(r.files)-1], r.size
+	r.cond.Broadcast()
+	return nil
+}
+
+//checkPosition 检查binlog event在归档中的位置是否与主库一致，需要持有锁
+func (r *Relay) checkPosition(ev replication.BinlogEvent) error {
+	next := ev.NextPosition()
+	if want := r.size + int64(len(ev.Bytes())); next != 0 && next != want {
+		return fmt.Errorf("position mismatch, master: %v relay: %v", next, want)
+	}
+	return nil
+}
+
+//rotateTo 切换到新的归档文件，需要持有锁，filename必须在归档的最后一个文件之后，
+//否则BinlogWriter会在当前文件中写入指向旧文件的ROTATE_EVENT而破坏归档
+func (r *Relay) rotateTo(filename string) error {
+	if len(r.files) > 0 && !binlogFileAfter(filename, r.files[len(r.files)-1]) {
+		return fmt.Errorf("rotate to %v which is not after %v", filename, r.files[len(r.files)-1])
+	}
+	if err := r.writer.RotateTo(filename); err != nil {
+		return err
+	}
+	r.files = append(r.files, filename)
+	return nil
+}
+
+//Close 关闭所有的下游连接以及当前的归档文件，在此之前应当结束Run的ctx
+func (r *Relay) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	for c := range r.conns {
+		c.Close()
+	}
+	r.cond.Broadcast()
+	listener := r.listener
+	r.mu.Unlock()
+
+	var err error
+	if listener != nil {
+		err = listener.Close()
+	}
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer != nil {
+		if cerr := r.writer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+//loadArchive 读取归档的索引，最后一个文件以ROTATE_EVENT结束时从下一个文件继续dump，否则删除并重新dump该文件
+func (r *Relay) loadArchive() error {
+	files, err := replication.ReadBinlogIndex(r.indexPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	last := files[len(files)-1]
+	format, size, next, err := scanBinlogFile(filepath.Join(r.dir, last))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if next != "" {
+		r.files, r.format, r.size, r.nextFile = files, format, size, next
+		return nil
+	}
+
+	if err := os.Remove(filepath.Join(r.dir, last)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	files = files[:len(files)-1]
+	if err := writeBinlogIndex(r.indexPath(), files); err != nil {
+		return err
+	}
+	r.files, r.nextFile = files, last
+	if len(files) > 0 {
+		path := filepath.Join(r.dir, files[len(files)-1])
+		if r.format, r.size, _, err = scanBinlogFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//binlogFileAfter binlog文件name是否在last之后，按照文件名的数字后缀比较，没有数字后缀时按照字符串比较
+func binlogFileAfter(name, last string) bool {
+	seq, err1 := strconv.ParseUint(name[strings.LastIndexByte(name, '.')+1:], 10, 64)
+	lastSeq, err2 := strconv.ParseUint(last[strings.LastIndexByte(last, '.')+1:], 10, 64)
+	if err1 != nil || err2 != nil {
+		return name > last
+	}
+	return seq > lastSeq
+}
+
+func (r *Relay) indexPath() string {
+	return filepath.Join(r.dir, indexBaseName+".index")
+}
+
+//scanBinlogFile 读取binlog文件，返回文件的格式、完整的binlog event的结束位置以及ROTATE_EVENT指向的下一个文件
+func scanBinlogFile(path string) (format replication.BinlogFormat, size int64, next string, err error) {
+	reader, err := replication.NewBinlogReader(path)
+	if err != nil {
+		return format, 0, "", err
+	}
+	defer reader.Close()
+
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			return format, reader.Position(), next, nil
+		}
+		if err != nil {
+			return format, 0, "", err
+		}
+
+		next = ""
+		switch ev.Bytes()[eventTypeOffset] {
+		case eventTypeFormatDescription:
+			if format, err = ev.Format(); err != nil {
+				return format, 0, "", err
+			}
+		case eventTypeRotate:
+			if next, err = rotateFilename(format, ev); err != nil {
+				return format, 0, "", err
+			}
+		}
+	}
+}
+
+//rotateFilename 获取ROTATE_EVENT指向的binlog文件
+func rotateFilename(format replication.BinlogFormat, ev replication.BinlogEvent) (string, error) {
+	stripped, _, err := ev.StripChecksum(format)
+	if err != nil {
+		return "", err
+	}
+	name, _, err := stripped.Rotate(format)
+	return name, err
+}
+
+//writeBinlogIndex 重写binlog索引文件
+func writeBinlogIndex(path string, files []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if _, err := f.WriteString("./" + name + "\n"); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+//showMasterStatus 获取主库当前的binlog文件
+func showMasterStatus(conn *dump.MysqlConn) (string, error) {
+	rows, err := conn.Query("SHOW MASTER STATUS")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	dest := make([]interface{}, len(rows.Columns()))
+	if err := rows.Next(dest); err != nil {
+		if err == io.EOF {
+			return "", fmt.Errorf("binary logging is not enabled")
+		}
+		return "", err
+	}
+	name, ok := dest[0].([]byte)
+	if !ok {
+		return "", fmt.Errorf("invalid log name: %v", dest[0])
+	}
+	return string(name), nil
+}
@@ -0,0 +1,355 @@
+package relay
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/fakemaster"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+type testEvent struct {
+	Type     byte
+	Length   int
+	Position uint32
+}
+
+func newTestMaster(t *testing.T) *fakemaster.Master {
+	m, err := fakemaster.NewMaster("127.0.0.1:0", "root", "123456")
+	if err != nil {
+		t.Fatalf("NewMaster err: %v", err)
+	}
+	f, s := m.Format(), m.Stream()
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	m.RotateTo("mysql-bin.000002")
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	return m
+}
+
+func newTestRelay(t *testing.T, m *fakemaster.Master, dir string) (*Relay, func()) {
+	r, err := NewRelay(m.DSN(), 100, dir)
+	if err != nil {
+		t.Fatalf("NewRelay err: %v", err)
+	}
+	r.SetUser("repl", "654321")
+	r.SetStartFile("mysql-bin.000001")
+	if err := r.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen err: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx)
+	}()
+	return r, func() {
+		cancel()
+		<-done
+		if err := r.Close(); err != nil {
+			t.Fatalf("Close err: %v", err)
+		}
+	}
+}
+
+//waitRelay 等待中继归档到主库的当前位置
+func waitRelay(t *testing.T, m *fakemaster.Master, r *Relay) {
+	name, pos := m.Position()
+	for i := 0; i < 500; i++ {
+		if n, p := r.Position(); n == name && p == pos {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n, p := r.Position()
+	t.Fatalf("relay position want: %v:%v out: %v:%v", name, pos, n, p)
+}
+
+func dumpConn(t *testing.T, dsn, filename string, offset uint32, flags uint16) *dump.MysqlConn {
+	conn, err := dump.NewMysqlConn(dsn)
+	if err != nil {
+		t.Fatalf("NewMysqlConn err: %v", err)
+	}
+	if err := conn.Exec("SET @master_binlog_checksum=@@global.binlog_checksum"); err != nil {
+		t.Fatalf("Exec err: %v", err)
+	}
+	if err := conn.NoticeDump(1234, offset, filename, flags); err != nil {
+		t.Fatalf("NoticeDump err: %v", err)
+	}
+	return conn
+}
+
+func readTestEvent(t *testing.T, conn *dump.MysqlConn) (testEvent, bool) {
+	data, err := conn.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket err: %v", err)
+	}
+	switch data[0] {
+	case dump.PacketEOF:
+		return testEvent{}, false
+	case dump.PacketERR:
+		t.Fatalf("binlog dump err: %v", conn.HandleErrorPacket(data))
+	}
+	data = data[1:]
+	return testEvent{
+		Type:     data[eventTypeOffset],
+		Length:   len(data),
+		Position: binary.LittleEndian.Uint32(data[eventNextPositionOffset:]),
+	}, true
+}
+
+//dumpEvents 以非阻塞的方式dump，返回所有binlog event的类型、长度以及位置
+func dumpEvents(t *testing.T, dsn, filename string, offset uint32) []testEvent {
+	conn := dumpConn(t, dsn, filename, offset, dump.BinlogDumpNonBlock)
+	defer conn.Close()
+
+	var events []testEvent
+	for {
+		ev, ok := readTestEvent(t, conn)
+		if !ok {
+			return events
+		}
+		events = append(events, ev)
+	}
+}
+
+func queryRows(t *testing.T, dsn, query string) [][]string {
+	conn, err := dump.NewMysqlConn(dsn)
+	if err != nil {
+		t.Fatalf("NewMysqlConn err: %v", err)
+	}
+	defer conn.Close()
+	rows, err := conn.Query(query)
+	if err != nil {
+		t.Fatalf("Query %v err: %v", query, err)
+	}
+	defer rows.Close()
+
+	var out [][]string
+	for {
+		dest := make([]interface{}, len(rows.Columns()))
+		if err = rows.Next(dest); err == io.EOF {
+			return out
+		} else if err != nil {
+			t.Fatalf("Next err: %v", err)
+		}
+		row := make([]string, len(dest))
+		for i := range dest {
+			if b, ok := dest[i].([]byte); ok {
+				row[i] = string(b)
+			}
+		}
+		out = append(out, row)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := newTestMaster(t)
+	defer m.Close()
+	r, stop := newTestRelay(t, m, dir)
+	defer stop()
+	waitRelay(t, m, r)
+
+	dsn := "repl:654321@tcp(" + r.Addr() + ")/mysql"
+	if _, err := dump.NewMysqlConn("repl:wrong@tcp(" + r.Addr() + ")/mysql"); err == nil {
+		t.Fatalf("NewMysqlConn with wrong password want err")
+	}
+
+	for _, query := range []string{"SHOW BINARY LOGS", "SHOW MASTER STATUS"} {
+		want := queryRows(t, m.DSN(), query)
+		out := queryRows(t, dsn, query)
+		if len(want) != len(out) {
+			t.Fatalf("%v want: %v out: %v", query, want, out)
+		}
+		for i := range want {
+			if want[i][0] != out[i][0] || want[i][1] != out[i][1] {
+				t.Fatalf("%v want: %v out: %v", query, want, out)
+			}
+		}
+	}
+
+	testCases := []struct {
+		filename string
+		offset   uint32
+	}{
+		{filename: "mysql-bin.000001", offset: 4},
+		{filename: "mysql-bin.000001", offset: 164},
+		{filename: "mysql-bin.000002", offset: 4},
+	}
+	for _, v := range testCases {
+		want := dumpEvents(t, m.DSN(), v.filename, v.offset)
+		out := dumpEvents(t, dsn, v.filename, v.offset)
+		if !reflect.DeepEqual(want, out) {
+			t.Fatalf("dump %v:%v want: %+v out: %+v", v.filename, v.offset, want, out)
+		}
+	}
+
+	//读完归档后继续等待主库新的binlog event
+	name, pos := m.Position()
+	conn := dumpConn(t, dsn, name, uint32(pos), 0)
+	defer conn.Close()
+
+	f, s := m.Format(), m.Stream()
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	m.RotateTo("mysql-bin.000003")
+
+	wantTypes := []byte{eventTypeRotate, eventTypeFormatDescription, 2, 16, eventTypeRotate, eventTypeFormatDescription}
+	for i, typ := range wantTypes {
+		ev, _ := readTestEvent(t, conn)
+		if ev.Type != typ {
+			t.Fatalf("live event %v type want: %v out: %+v", i, typ, ev)
+		}
+	}
+	waitRelay(t, m, r)
+}
+
+func TestRelay_Resume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := newTestMaster(t)
+	defer m.Close()
+	r, stop := newTestRelay(t, m, dir)
+	waitRelay(t, m, r)
+	stop()
+
+	//mysql-bin.000002没有以ROTATE_EVENT结束，重新打开时会被删除
+	r, err = NewRelay(m.DSN(), 100, dir)
+	if err != nil {
+		t.Fatalf("NewRelay err: %v", err)
+	}
+	if name, pos := r.Position(); name != "mysql-bin.000001" || pos != 242 {
+		t.Fatalf("Position want: mysql-bin.000001:242 out: %v:%v", name, pos)
+	}
+	if _, err := os.Stat(dir + "/mysql-bin.000002"); !os.IsNotExist(err) {
+		t.Fatalf("mysql-bin.000002 should be removed, err: %v", err)
+	}
+	r.Close()
+
+	f, s := m.Format(), m.Stream()
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	r, stop = newTestRelay(t, m, dir)
+	defer stop()
+	waitRelay(t, m, r)
+
+	dsn := "repl:654321@tcp(" + r.Addr() + ")/mysql"
+	want := dumpEvents(t, m.DSN(), "mysql-bin.000001", 4)
+	if out := dumpEvents(t, dsn, "mysql-bin.000001", 4); !reflect.DeepEqual(want, out) {
+		t.Fatalf("dump want: %+v out: %+v", want, out)
+	}
+}
+
+func TestRelay_RunTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := newTestMaster(t)
+	defer m.Close()
+	r, err := NewRelay(m.DSN(), 100, dir)
+	if err != nil {
+		t.Fatalf("NewRelay err: %v", err)
+	}
+	defer r.Close()
+	r.SetUser("repl", "654321")
+	r.SetStartFile("mysql-bin.000001")
+	if err := r.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen err: %v", err)
+	}
+	run := func() func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- r.Run(ctx)
+		}()
+		waitRelay(t, m, r)
+		return func() {
+			cancel()
+			if err := <-done; err != context.Canceled {
+				t.Fatalf("Run want err: %v out: %v", context.Canceled, err)
+			}
+		}
+	}
+
+	//第一次Run停在mysql-bin.000002的中间，第二次Run从该位置继续并切换到新的文件
+	run()()
+	f, s := m.Format(), m.Stream()
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	m.RotateTo("mysql-bin.000003")
+	m.AppendEvents(
+		replication.NewQueryEvent(f, s, replication.Query{Database: "db", SQL: "BEGIN"}),
+		replication.NewXIDEvent(f, s),
+	)
+	run()()
+
+	dsn := "repl:654321@tcp(" + r.Addr() + ")/mysql"
+	for _, name := range []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003"} {
+		want := dumpEvents(t, m.DSN(), name, 4)
+		if out := dumpEvents(t, dsn, name, 4); !reflect.DeepEqual(want, out) {
+			t.Fatalf("dump %v want: %+v out: %+v", name, want, out)
+		}
+	}
+	files, err := replication.ReadBinlogIndex(r.indexPath())
+	if err != nil {
+		t.Fatalf("ReadBinlogIndex err: %v", err)
+	}
+	if want := []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("index want: %v out: %v", want, files)
+	}
+}
+
+func TestRelay_rotateTo(t *testing.T) {
+	r := &Relay{files: []string{"mysql-bin.000002"}}
+	for _, name := range []string{"mysql-bin.000001", "mysql-bin.000002"} {
+		if err := r.rotateTo(name); err == nil {
+			t.Fatalf("rotateTo %v want err", name)
+		}
+	}
+	testCases := []struct {
+		name string
+		last string
+		want bool
+	}{
+		{name: "mysql-bin.000003", last: "mysql-bin.000002", want: true},
+		{name: "mysql-bin.1000000", last: "mysql-bin.999999", want: true},
+		{name: "mysql-bin.000002", last: "mysql-bin.000002", want: false},
+		{name: "b", last: "a", want: true},
+	}
+	for _, v := range testCases {
+		if out := binlogFileAfter(v.name, v.last); out != v.want {
+			t.Fatalf("binlogFileAfter(%v, %v) want: %v out: %v", v.name, v.last, v.want, out)
+		}
+	}
+}
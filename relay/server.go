@@ -0,0 +1,354 @@
+package relay
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+const (
+	defaultServerVersion = "5.7.25-log"
+	maxAllowedPacket     = "1073741824"
+
+	mysqlErrUnknownCommand        = 1047
+	mysqlErrNotSupported          = 1235
+	mysqlErrMasterFatal           = 1236
+	mysqlErrUnknownSystemVariable = 1193
+)
+
+//Listen 在addr上监听下游的连接，如127.0.0.1:0使用随机端口
+func (r *Relay) Listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("Listen fail. err: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.listener != nil {
+		l.Close()
+		return fmt.Errorf("Listen relay is listening or closed")
+	}
+	r.listener = l
+	r.wg.Add(1)
+	go r.serve(l)
+	return nil
+}
+
+//Addr 获取监听的地址，未调用Listen时为空
+func (r *Relay) Addr() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.listener == nil {
+		return ""
+	}
+	return r.listener.Addr().String()
+}
+
+func (r *Relay) serve(l net.Listener) {
+	defer r.wg.Done()
+	for {
+		netConn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			netConn.Close()
+			return
+		}
+		r.nextID++
+		c := dump.NewServerConn(netConn, r.nextID)
+		r.conns[c] = struct{}{}
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go r.handle(c)
+	}
+}
+
+func (r *Relay) handle(c *dump.ServerConn) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, c)
+		r.mu.Unlock()
+		c.Close()
+	}()
+
+	r.mu.Lock()
+	users := make(map[string]string, len(r.users))
+	for user, password := range r.users {
+		users[user] = password
+	}
+	r.mu.Unlock()
+	if err := c.Handshake(r.serverVersion(), users); err != nil {
+		return
+	}
+
+	for {
+		cmd, data, err := c.ReadCommand()
+		if err != nil {
+			return
+		}
+
+		switch cmd {
+		case dump.ComQuit:
+			return
+		case dump.ComPing, dump.ComInitDB:
+			err = c.WriteOK(0, 0)
+		case dump.ComQuery:
+			err = r.handleQuery(c, string(data))
+		case dump.ComBinlogDump:
+			err = r.handleBinlogDump(c, data)
+		default:
+			err = c.WriteError(mysqlErrUnknownCommand, "08S01", fmt.Sprintf("unknown command %d", cmd))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+//serverVersion 下游看到的主库版本与归档的binlog一致
+func (r *Relay) serverVersion() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.format.IsZero() {
+		return defaultServerVersion
+	}
+	return r.format.ServerVersion
+}
+
+//variables 下游可以通过SELECT @@name查询的系统变量
+func (r *Relay) variables() map[string]string {
+	r.mu.Lock()
+	checksum := "NONE"
+	if r.format.ChecksumAlgorithm == replication.BinlogChecksumAlgCRC32 {
+		checksum = "CRC32"
+	}
+	r.mu.Unlock()
+
+	return map[string]string{
+		"max_allowed_packet": maxAllowedPacket,
+		"version":            r.serverVersion(),
+		"server_id":          strconv.FormatUint(uint64(r.serverID), 10),
+		"binlog_checksum":    checksum,
+		"binlog_format":      "ROW",
+		"log_bin":            "1",
+	}
+}
+
+//handleQuery 支持dump需要的SELECT @@name、SET、SHOW BINARY LOGS以及SHOW MASTER STATUS
+func (r *Relay) handleQuery(c *dump.ServerConn, query string) error {
+	query = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+	lower := strings.ToLower(query)
+
+	switch {
+	case strings.HasPrefix(lower, "select @@"):
+		column := strings.TrimSpace(query[len("select "):])
+		name := strings.TrimPrefix(strings.ToLower(column), "@@")
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "global."), "session.")
+		value, ok := r.variables()[name]
+		if !ok {
+			return c.WriteError(mysqlErrUnknownSystemVariable, "HY000", fmt.Sprintf("Unknown system variable '%s'", name))
+		}
+		return c.WriteTextResult([]string{column}, [][][]byte{{[]byte(value)}})
+	case lower == "select unix_timestamp()":
+		return c.WriteTextResult([]string{"UNIX_TIMESTAMP()"},
+			[][][]byte{{[]byte(strconv.FormatInt(time.Now().Unix(), 10))}})
+	case strings.HasPrefix(lower, "set "):
+		return c.WriteOK(0, 0)
+	case lower == "show binary logs" || lower == "show master logs":
+		r.mu.Lock()
+		files := append([]string(nil), r.files...)
+		r.mu.Unlock()
+		rows := make([][][]byte, 0, len(files))
+		for _, name := range files {
+			info, err := os.Stat(filepath.Join(r.dir, name))
+			if err != nil {
+				return c.WriteError(mysqlErrMasterFatal, "HY000", err.Error())
+			}
+			rows = append(rows, [][]byte{[]byte(name), []byte(strconv.FormatInt(info.Size(), 10))})
+		}
+		return c.WriteTextResult([]string{"Log_name", "File_size"}, rows)
+	case lower == "show master status":
+		var rows [][][]byte
+		if name, size := r.Position(); name != "" {
+			rows = append(rows, [][]byte{[]byte(name), []byte(strconv.FormatInt(size, 10)), nil, nil, nil})
+		}
+		return c.WriteTextResult([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}, rows)
+	}
+	return c.WriteError(mysqlErrNotSupported, "42000", fmt.Sprintf("relay does not support query: %s", query))
+}
+
+//handleBinlogDump 先发送fake ROTATE_EVENT，然后从归档的binlog文件中发送binlog event，
+//读完归档后阻塞方式等待Run写入新的binlog event，非阻塞方式发送EOF包
+func (r *Relay) handleBinlogDump(c *dump.ServerConn, data []byte) error {
+	_, offset, filename, flags, err := dump.ParseBinlogDump(data)
+	if err != nil {
+		return c.WriteError(mysqlErrMasterFatal, "HY000", err.Error())
+	}
+	nonBlock := flags&dump.BinlogDumpNonBlock != 0
+
+	r.mu.Lock()
+	found := r.hasFile(filename)
+	format := r.format
+	r.mu.Unlock()
+	if !found {
+		return c.WriteError(mysqlErrMasterFatal, "HY000",
+			"Could not find first log file name in binary log index file")
+	}
+	if offset < binlogMagicSize {
+		offset = binlogMagicSize
+	}
+
+	s := &replication.FakeBinlogStream{ServerID: r.serverID}
+	rotate := replication.NewRotateEvent(format, s, uint64(offset), filename).Bytes()
+	binary.LittleEndian.PutUint16(rotate[eventFlagsOffset:], logEventArtificialF)
+	replication.UpdateChecksum(format, rotate)
+	if err := c.WriteBinlogEvent(rotate); err != nil {
+		return err
+	}
+
+	for {
+		next, err := r.sendBinlogFile(c, filename, int64(offset), nonBlock)
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return c.WriteEOF()
+		}
+
+		ok, err := r.waitFile(next, nonBlock)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return c.WriteEOF()
+		}
+		filename, offset = next, binlogMagicSize
+	}
+}
+
+//sendBinlogFile 发送binlog文件中从offset开始的binlog event，返回ROTATE_EVENT指向的下一个文件，
+//非阻塞方式读完归档时返回空字符串
+func (r *Relay) sendBinlogFile(c *dump.ServerConn, filename string, offset int64, nonBlock bool) (string, error) {
+	reader, err := replication.NewBinlogReader(filepath.Join(r.dir, filename))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	var format replication.BinlogFormat
+	complete := false
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			if complete {
+				return "", fmt.Errorf("binlog file %v ends without ROTATE_EVENT", filename)
+			}
+			var ok bool
+			if ok, complete, err = r.waitEvent(filename, reader.Position(), nonBlock); err != nil || !ok {
+				return "", err
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		data := ev.Bytes()
+		start := reader.Position() - int64(len(data))
+		switch data[eventTypeOffset] {
+		case eventTypeFormatDescription:
+			if format, err = ev.Format(); err != nil {
+				return "", err
+			}
+			//从文件中间开始dump时仍然需要发送FORMAT_DESCRIPTION_EVENT，并且next_position为0
+			data = append([]byte(nil), data...)
+			flags := binary.LittleEndian.Uint16(data[eventFlagsOffset:]) &^ logEventBinlogInUseF
+			binary.LittleEndian.PutUint16(data[eventFlagsOffset:], flags)
+			if start < offset {
+				binary.LittleEndian.PutUint32(data[eventNextPositionOffset:], 0)
+			}
+			replication.UpdateChecksum(format, data)
+		case eventTypeRotate:
+			if start >= offset {
+				if err := c.WriteBinlogEvent(data); err != nil {
+					return "", err
+				}
+			}
+			return rotateFilename(format, ev)
+		default:
+			if start < offset {
+				continue
+			}
+		}
+
+		if err := c.WriteBinlogEvent(data); err != nil {
+			return "", err
+		}
+	}
+}
+
+//waitEvent 在读完binlog文件时等待Run写入新的binlog event，ok为false代表非阻塞方式读完了归档，
+//complete代表该文件已经不再写入
+func (r *Relay) waitEvent(filename string, pos int64, nonBlock bool) (ok, complete bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		if r.closed {
+			return false, false, fmt.Errorf("relay closed")
+		}
+		if filename != r.files[len(r.files)-1] {
+			return true, true, nil
+		}
+		if pos < r.size {
+			return true, false, nil
+		}
+		if nonBlock {
+			return false, false, nil
+		}
+		r.cond.Wait()
+	}
+}
+
+//waitFile 等待Run开始归档filename，ok为false代表非阻塞方式读完了归档
+func (r *Relay) waitFile(filename string, nonBlock bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		if r.closed {
+			return false, fmt.Errorf("relay closed")
+		}
+		if r.hasFile(filename) {
+			return true, nil
+		}
+		if nonBlock {
+			return false, nil
+		}
+		r.cond.Wait()
+	}
+}
+
+//hasFile 归档中是否有filename，需要持有锁
+func (r *Relay) hasFile(filename string) bool {
+	for _, name := range r.files {
+		if name == filename {
+			return true
+		}
+	}
+	return false
+}
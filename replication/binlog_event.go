@@ -107,6 +107,9 @@ type BinlogEvent interface {
 	// NextPosition return Next binlog event position from the event header.
 	NextPosition() int64
 
+	// ServerID returns the server ID of the server that wrote the event.
+	ServerID() uint32
+
 	// Format returns a BinlogFormat struct based on the event data.
 	// This is only valid if IsFormatDescription() returns true.
 	Format() (BinlogFormat, error)
@@ -114,7 +117,7 @@ type BinlogEvent interface {
 	// GTID returns the GTID from the event, and if this event
 	// also serves as a BEGIN statement.
 	// This is only valid if IsGTID() returns true.
-	// todo GTID(BinlogFormat) (GTID, bool, error)
+	GTID(BinlogFormat) (GTID, bool, error)
 
 	// Query returns a Query struct representing data from a QUERY_EVENT.
 	// This is only valid if IsQuery() returns true.
@@ -234,6 +237,17 @@ type TableMap struct {
 	// - If the metadata is one byte, only the lower 8 bits are used.
 	// - If the metadata is two bytes, all 16 bits are used.
 	Metadata []uint16
+
+	// ColumnCollations is the collation ID of each column, taken from the
+	// optional metadata written by MySQL 8.0.1+. It is 0 for the columns
+	// without a character set, and nil if the metadata is not present.
+	ColumnCollations []uint64
+
+	// EnumValues is the member list of each ENUM and SET column, taken from
+	// the optional metadata written with binlog_row_metadata=FULL. It is nil
+	// for the other columns, and nil if the metadata is not present. The
+	// members are encoded in the character set of the column.
+	EnumValues [][]string
 }
 
 // Rows contains data from a {WRITE,UPDATE,DELETE}_ROWS_EVENT.
@@ -62,6 +62,17 @@ func (ev binlogEvent) Type() byte {
 	return ev.Bytes()[4]
 }
 
+// EventTypeName returns the name of the event type as printed by
+// mysqlbinlog, such as Query or Write_rows, or Unknown(n) for an unknown type.
+// The event must be valid.
+func EventTypeName(ev BinlogEvent) string {
+	typ := ev.Bytes()[4]
+	if name, ok := eventTypeNames[typ]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", typ)
+}
+
 // Flags returns the flags field from the header.
 func (ev binlogEvent) Flags() uint16 {
 	return binary.LittleEndian.Uint16(ev.Bytes()[17 : 17+2])
@@ -64,6 +64,25 @@ func TestBinlogEventType(t *testing.T) {
 	}
 }
 
+func TestEventTypeName(t *testing.T) {
+	testCases := []struct {
+		input []byte
+		want  string
+	}{
+		{input: googleRotateEvent, want: "Rotate"},
+		{input: googleFormatEvent, want: "Format_desc"},
+		{input: googleQueryEvent, want: "Query"},
+		{input: googleXIDEvent, want: "Xid"},
+		{input: googleIntVarEvent1, want: "Intvar"},
+		{input: []byte{0, 0, 0, 0, 0x64}, want: "Unknown(100)"},
+	}
+	for _, v := range testCases {
+		if got := EventTypeName(NewMysql56BinlogEvent(v.input)); got != v.want {
+			t.Errorf("EventTypeName(%#v) = %v, want %v", v.input, got, v.want)
+		}
+	}
+}
+
 func TestBinlogEventFlags(t *testing.T) {
 	input := binlogEvent(googleRotateEvent)
 	want := uint16(0x20)
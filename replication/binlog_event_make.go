@@ -234,7 +234,7 @@ func NewIntVarEvent(f BinlogFormat, s *FakeBinlogStream, typ byte, value uint64)
 
 // NewMariaDBGTIDEvent returns a MariaDB specific GTID event.
 // It ignores the Server in the gtid, instead uses the FakeBinlogStream.ServerID.
-/*func NewMariaDBGTIDEvent(f BinlogFormat, s *FakeBinlogStream, gtid MariadbGTID, hasBegin bool) BinlogEvent {
+func NewMariaDBGTIDEvent(f BinlogFormat, s *FakeBinlogStream, gtid MariadbGTID, hasBegin bool) BinlogEvent {
 	length := 8 + // sequence
 		4 + // domain
 		1 // flags2
@@ -262,7 +262,22 @@ func NewIntVarEvent(f BinlogFormat, s *FakeBinlogStream, typ byte, value uint64)
 
 	ev := s.Packetize(f, eMariaGTIDEvent, 0, data)
 	return NewMariadbBinlogEvent(ev)
-}*/
+}
+
+// NewMySQL56GTIDEvent returns a MySQL 5.6 GTID event.
+// Only works with post_header_length=25.
+func NewMySQL56GTIDEvent(f BinlogFormat, s *FakeBinlogStream, gtid Mysql56GTID) BinlogEvent {
+	length := 1 + // flags
+		16 + // SID
+		8 // GNO
+	data := make([]byte, length)
+	data[0] = 1 // commit flag
+	copy(data[1:17], gtid.Server[:])
+	binary.LittleEndian.PutUint64(data[17:25], uint64(gtid.Sequence))
+
+	ev := s.Packetize(f, eGTIDEvent, 0, data)
+	return NewMysql56BinlogEvent(ev)
+}
 
 // NewTableMapEvent returns a TableMap event.
 // Only works with post_header_length=8.
@@ -272,6 +287,7 @@ func NewTableMapEvent(f BinlogFormat, s *FakeBinlogStream, tableID uint64, tm *T
 	}
 
 	metadataLength := metadataTotalLength(tm.Types)
+	optionalMetadata := tm.optionalMetadata()
 
 	length := 6 + // table_id
 		2 + // flags
@@ -285,7 +301,8 @@ func NewTableMapEvent(f BinlogFormat, s *FakeBinlogStream, tableID uint64, tm *T
 		len(tm.Types) +
 		1 + // lenenc-str column-meta-def FIXME(alainjobart) len enc
 		metadataLength +
-		len(tm.CanBeNull.data)
+		len(tm.CanBeNull.data) +
+		len(optionalMetadata)
 	data := make([]byte, length)
 
 	data[0] = byte(tableID)
@@ -319,6 +336,7 @@ func NewTableMapEvent(f BinlogFormat, s *FakeBinlogStream, tableID uint64, tm *T
 	}
 
 	pos += copy(data[pos:], tm.CanBeNull.data)
+	pos += copy(data[pos:], optionalMetadata)
 	if pos != len(data) {
 		panic("bad encoding")
 	}
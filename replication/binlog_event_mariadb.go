@@ -1,5 +1,10 @@
 package replication
 
+import (
+	"encoding/binary"
+	"fmt"
+)
+
 // mariadbBinlogEvent wraps a raw packet buffer and provides methods to examine
 // it by implementing BinlogEvent. Some methods are pulled in from
 // binlogEvent.
@@ -24,10 +29,13 @@ func (ev mariadbBinlogEvent) IsGTID() bool {
 //   8         sequence number
 //   4         domain ID
 //   1         flags2
-/*func (ev mariadbBinlogEvent) GTID(f BinlogFormat) (GTID, bool, error) {
+func (ev mariadbBinlogEvent) GTID(f BinlogFormat) (GTID, bool, error) {
 	const FLStandalone = 1
 
 	data := ev.Bytes()[f.HeaderLength:]
+	if len(data) < 8+4+1 {
+		return nil, false, fmt.Errorf("GTID event too short: %v bytes", len(data))
+	}
 	flags2 := data[8+4]
 
 	return MariadbGTID{
@@ -38,7 +46,7 @@ func (ev mariadbBinlogEvent) IsGTID() bool {
 }
 
 // PreviousGTIDs implements BinlogEvent.PreviousGTIDs().
-func (ev mariadbBinlogEvent) PreviousGTIDs(f BinlogFormat) (Position, error) {
+/*func (ev mariadbBinlogEvent) PreviousGTIDs(f BinlogFormat) (Position, error) {
 	return Position{}, fmt.Errorf("MariaDB should not provide PREVIOUS_GTIDS_EVENT events")
 }*/
 
@@ -1,6 +1,7 @@
 package replication
 
 import (
+	"encoding/binary"
 	"fmt"
 )
 
@@ -28,16 +29,19 @@ func (ev mysql56BinlogEvent) IsGTID() bool {
 //   1         flags
 //   16        SID (server UUID)
 //   8         GNO (sequence number, signed int)
-/*func (ev mysql56BinlogEvent) GTID(f BinlogFormat) (GTID, bool, error) {
+func (ev mysql56BinlogEvent) GTID(f BinlogFormat) (GTID, bool, error) {
 	data := ev.Bytes()[f.HeaderLength:]
+	if len(data) < 1+16+8 {
+		return nil, false, fmt.Errorf("GTID event too short: %v bytes", len(data))
+	}
 	var sid SID
 	copy(sid[:], data[1:1+16])
 	gno := int64(binary.LittleEndian.Uint64(data[1+16 : 1+16+8]))
-	return Mysql56GTID{Server: sid, Sequence: gno}, false , nil
+	return Mysql56GTID{Server: sid, Sequence: gno}, false, nil
 }
 
 // PreviousGTIDs implements BinlogEvent.PreviousGTIDs().
-func (ev mysql56BinlogEvent) PreviousGTIDs(f BinlogFormat) (Position, error) {
+/*func (ev mysql56BinlogEvent) PreviousGTIDs(f BinlogFormat) (Position, error) {
 	data := ev.Bytes()[f.HeaderLength:]
 	set, err := NewMysql56GTIDSetFromSIDBlock(data)
 	if err != nil {
@@ -30,6 +30,7 @@ var ZeroTimestamp = []byte("0000-00-00 00:00:00")
 //  cc        column-def, one byte per column
 //  <var>     column-meta-def (var-len encoded string)
 //  n         NULL-bitmask, length: (cc + 7) / 8
+//  <var>     optional metadata, see parseOptionalMetadata
 func (ev binlogEvent) TableMap(f BinlogFormat) (*TableMap, error) {
 	data := ev.Bytes()[f.HeaderLength:]
 
@@ -91,8 +92,11 @@ func (ev binlogEvent) TableMap(f BinlogFormat) (*TableMap, error) {
 	}
 
 	// A bit array that says if each colum can be NULL.
-	result.CanBeNull, _ = newBitmap(data, pos, columnCount)
+	result.CanBeNull, pos = newBitmap(data, pos, columnCount)
 
+	if err := result.parseOptionalMetadata(data[pos:]); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
@@ -310,14 +314,14 @@ func cellLength(data []byte, pos int, typ byte, metadata uint16) (int, error) {
 }
 
 // printTimestamp is a helper method to append a timestamp into a bytes.Buffer,
-// it returns a local time
+// it returns the time in loc
 // and return the Buffer.
-func printTimestamp(v uint32) *bytes.Buffer {
+func printTimestamp(v uint32, loc *time.Location) *bytes.Buffer {
 	if v == 0 {
 		return bytes.NewBuffer(ZeroTimestamp)
 	}
 
-	t := time.Unix(int64(v), 0).Local()
+	t := time.Unix(int64(v), 0).In(loc)
 	year, month, day := t.Date()
 	hour, minute, second := t.Clock()
 
@@ -471,7 +475,16 @@ func (ev binlogEvent) Rows(f BinlogFormat, tm *TableMap) (Rows, error) {
 //	metadata      input it may contains the length or the precision of the column data
 //	isUnSignedInt input it is unsigned int for this column
 //	return []byte as value, int as the of length of value occupied in data
+// TIMESTAMP columns are printed in the local time zone, use CellBytesInLocation
+// to choose the time zone.
 func CellBytes(data []byte, pos int, typ byte, metadata uint16, isUnSignedInt bool) ([]byte, int, error) {
+	return CellBytesInLocation(data, pos, typ, metadata, isUnSignedInt, time.Local)
+}
+
+// CellBytesInLocation is the same as CellBytes, but TIMESTAMP columns, which
+// are stored as seconds since the epoch, are printed in loc.
+func CellBytesInLocation(data []byte, pos int, typ byte, metadata uint16, isUnSignedInt bool,
+	loc *time.Location) ([]byte, int, error) {
 	switch typ {
 	case TypeTiny:
 		if isUnSignedInt {
@@ -524,7 +537,7 @@ func CellBytes(data []byte, pos int, typ byte, metadata uint16, isUnSignedInt bo
 		return strconv.AppendFloat(nil, fVal, 'f', -1, 64), 8, nil
 	case TypeTimestamp:
 		val := binary.LittleEndian.Uint32(data[pos : pos+4])
-		txt := printTimestamp(val)
+		txt := printTimestamp(val, loc)
 		return txt.Bytes(), 4, nil
 
 	case TypeLongLong:
@@ -591,7 +604,7 @@ func CellBytes(data []byte, pos int, typ byte, metadata uint16, isUnSignedInt bo
 		return data[pos : pos+l], l, nil
 	case TypeTimestamp2:
 		second := binary.BigEndian.Uint32(data[pos : pos+4])
-		txt := printTimestamp(second)
+		txt := printTimestamp(second, loc)
 		switch metadata {
 		case 1:
 			decimals := int(data[pos+4])
@@ -440,3 +440,50 @@ func TestCellLengthAndData(t *testing.T) {
 		}
 	}
 }
+
+func TestCellBytesInLocation(t *testing.T) {
+	utc8 := time.FixedZone("UTC+8", 8*3600)
+	testCases := []struct {
+		typ      byte
+		metadata uint16
+		data     []byte
+		loc      *time.Location
+		out      string
+	}{{
+		// 0x58d137c5 = 1490106309 = 2017-03-21 14:25:09 utc
+		typ:  TypeTimestamp,
+		data: []byte{0xc5, 0x37, 0xd1, 0x58},
+		loc:  time.UTC,
+		out:  "2017-03-21 14:25:09",
+	}, {
+		typ:  TypeTimestamp,
+		data: []byte{0xc5, 0x37, 0xd1, 0x58},
+		loc:  utc8,
+		out:  "2017-03-21 22:25:09",
+	}, {
+		typ:      TypeTimestamp2,
+		metadata: 2,
+		data:     []byte{0x58, 0xd1, 0x37, 0xc5, 0x63},
+		loc:      utc8,
+		out:      "2017-03-21 22:25:09.99",
+	}, {
+		// The zero timestamp does not depend on the time zone.
+		typ:  TypeTimestamp,
+		data: []byte{0x00, 0x00, 0x00, 0x00},
+		loc:  utc8,
+		out:  "0000-00-00 00:00:00",
+	}, {
+		// DATETIME is not stored as seconds since the epoch.
+		typ:  TypeDateTime,
+		data: []byte{0xed, 0xa2, 0xd3, 0x44, 0x58, 0x12, 0x00, 0x00},
+		loc:  utc8,
+		out:  "2017-03-21 14:25:09",
+	}}
+
+	for _, c := range testCases {
+		out, l, err := CellBytesInLocation(c.data, 0, c.typ, c.metadata, false, c.loc)
+		if err != nil || l != len(c.data) || string(out) != c.out {
+			t.Errorf("CellBytesInLocation(%v,%v,%v) = %s %v %v, want %v %v <nil>", c.typ, c.data, c.loc, out, l, err, c.out, len(c.data))
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package replication
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BinlogFileMagic is the header of every binlog file.
+var BinlogFileMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+const (
+	// logEventBinlogInUseF is set in the FORMAT_DESCRIPTION_EVENT of a binlog
+	// file while it is being written, and cleared when the file is closed.
+	logEventBinlogInUseF = 0x1
+
+	// Offsets of the fields in the common event header.
+	eventLengthOffset       = 9
+	eventNextPositionOffset = 13
+	eventFlagsOffset        = 17
+
+	// DefaultBinlogMaxSize is the default size limit of a binlog file, the
+	// same as the default max_binlog_size of MySQL.
+	DefaultBinlogMaxSize = 1 << 30
+)
+
+// BinlogWriter writes binlog events into binlog files that can be read by
+// mysqlbinlog or served to slaves. Every file starts with the magic header
+// and a FORMAT_DESCRIPTION_EVENT, the NextPosition of each event is chained
+// by the writer, checksums are computed if the format uses CRC32, and the
+// file is rotated at a transaction boundary once it exceeds the size limit.
+// The names of all the files are kept in the <baseName>.index file.
+type BinlogWriter struct {
+	dir      string
+	baseName string
+	maxSize  int64
+	format   BinlogFormat
+	serverID uint32
+	now      func() time.Time
+
+	file     *os.File
+	writer   *bufio.Writer
+	filename string
+	size     int64
+	seq      int
+	fde      []byte
+
+	inTransaction bool
+}
+
+// NewBinlogWriter creates a BinlogWriter writing files named
+// <baseName>.000001, <baseName>.000002... in dir. If the index file already
+// exists, a new file following the last one in the index is started, like
+// MySQL does on restart. maxSize <= 0 means DefaultBinlogMaxSize.
+func NewBinlogWriter(dir, baseName string, format BinlogFormat, serverID uint32, maxSize int64) (*BinlogWriter, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultBinlogMaxSize
+	}
+	w := &BinlogWriter{
+		dir:      dir,
+		baseName: baseName,
+		maxSize:  maxSize,
+		format:   format,
+		serverID: serverID,
+		now:      time.Now,
+	}
+
+	files, err := ReadBinlogIndex(w.indexPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(files) > 0 {
+		last := files[len(files)-1]
+		if w.seq, err = binlogFileSeq(last); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Format returns the BinlogFormat of the written events.
+func (w *BinlogWriter) Format() BinlogFormat {
+	return w.format
+}
+
+// Position returns the current file name and the position of the next event.
+func (w *BinlogWriter) Position() (string, int64) {
+	return w.filename, w.size
+}
+
+// WriteEvent writes an event in the format of the writer to the current
+// file, opening the first file if needed. The NextPosition and the checksum
+// of the event are rewritten. If the file exceeds the size limit after a
+// transaction ends, it is rotated.
+func (w *BinlogWriter) WriteEvent(ev BinlogEvent) error {
+	if w.file == nil {
+		if err := w.RotateTo(w.nextFilename()); err != nil {
+			return err
+		}
+	}
+	if _, err := w.writeEvent(ev.Bytes()); err != nil {
+		return err
+	}
+
+	if w.isTransactionEnd(ev) && w.size >= w.maxSize {
+		return w.Rotate()
+	}
+	return nil
+}
+
+// Rotate writes a ROTATE_EVENT to the current file and starts the next file.
+func (w *BinlogWriter) Rotate() error {
+	return w.RotateTo(w.nextFilename())
+}
+
+// RotateTo starts a new binlog file with the given name, which must not
+// exist yet. The current file, if any, is ended with a ROTATE_EVENT pointing
+// to the new file.
+func (w *BinlogWriter) RotateTo(filename string) error {
+	if filepath.Base(filename) != filename {
+		return fmt.Errorf("invalid binlog file name %v", filename)
+	}
+	if w.file != nil {
+		s := &FakeBinlogStream{ServerID: w.serverID, Timestamp: uint32(w.now().Unix())}
+		if _, err := w.writeEvent(NewRotateEvent(w.format, s, 4, filename).Bytes()); err != nil {
+			return err
+		}
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(w.dir, filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.writer = bufio.NewWriter(f)
+	w.filename = filename
+	w.size = 0
+	if seq, err := binlogFileSeq(filename); err == nil && seq > w.seq {
+		w.seq = seq
+	}
+
+	if err := w.appendIndex(filename); err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(BinlogFileMagic); err != nil {
+		return err
+	}
+	w.size = int64(len(BinlogFileMagic))
+
+	s := &FakeBinlogStream{ServerID: w.serverID, Timestamp: uint32(w.now().Unix())}
+	fde := NewFormatDescriptionEvent(w.format, s).Bytes()
+	binary.LittleEndian.PutUint16(fde[eventFlagsOffset:], logEventBinlogInUseF)
+	w.fde, err = w.writeEvent(fde)
+	return err
+}
+
+// Flush writes the buffered events to the current file.
+func (w *BinlogWriter) Flush() error {
+	if w.writer == nil {
+		return nil
+	}
+	return w.writer.Flush()
+}
+
+// Close flushes and closes the current file, clearing its in-use flag.
+func (w *BinlogWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.closeFile()
+}
+
+// writeEvent chains the NextPosition, computes the checksum and writes the
+// event. It returns the written copy of the event.
+func (w *BinlogWriter) writeEvent(data []byte) ([]byte, error) {
+	if len(data) < int(w.format.HeaderLength) {
+		return nil, fmt.Errorf("event too short: %v bytes", len(data))
+	}
+	ev := make([]byte, len(data))
+	copy(ev, data)
+
+	next := w.size + int64(len(ev))
+	binary.LittleEndian.PutUint32(ev[eventLengthOffset:], uint32(len(ev)))
+	binary.LittleEndian.PutUint32(ev[eventNextPositionOffset:], uint32(next))
+	if w.format.ChecksumAlgorithm == BinlogChecksumAlgCRC32 {
+		putChecksum(ev)
+	}
+
+	if _, err := w.writer.Write(ev); err != nil {
+		return nil, err
+	}
+	w.size = next
+	return ev, nil
+}
+
+// isTransactionEnd returns true if ev ends a transaction, or is a statement
+// outside of any transaction such as DDL. It keeps track of BEGIN.
+func (w *BinlogWriter) isTransactionEnd(ev BinlogEvent) bool {
+	if ev.IsXID() {
+		w.inTransaction = false
+		return true
+	}
+	if !ev.IsQuery() {
+		return false
+	}
+	stripped, _, err := ev.StripChecksum(w.format)
+	if err != nil {
+		return false
+	}
+	q, err := stripped.Query(w.format)
+	if err != nil {
+		return false
+	}
+	switch strings.ToUpper(strings.TrimSpace(q.SQL)) {
+	case "BEGIN":
+		w.inTransaction = true
+		return false
+	case "COMMIT", "ROLLBACK":
+		w.inTransaction = false
+		return true
+	default:
+		return !w.inTransaction
+	}
+}
+
+// closeFile clears the in-use flag of the FORMAT_DESCRIPTION_EVENT and closes the file.
+func (w *BinlogWriter) closeFile() error {
+	defer func() {
+		w.file = nil
+		w.writer = nil
+	}()
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+
+	// The FORMAT_DESCRIPTION_EVENT follows the magic header.
+	fde := w.fde
+	flags := binary.LittleEndian.Uint16(fde[eventFlagsOffset:]) &^ logEventBinlogInUseF
+	binary.LittleEndian.PutUint16(fde[eventFlagsOffset:], flags)
+	if w.format.ChecksumAlgorithm == BinlogChecksumAlgCRC32 {
+		putChecksum(fde)
+	}
+	if _, err := w.file.WriteAt(fde, int64(len(BinlogFileMagic))); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func (w *BinlogWriter) indexPath() string {
+	return filepath.Join(w.dir, w.baseName+".index")
+}
+
+func (w *BinlogWriter) appendIndex(filename string) error {
+	f, err := os.OpenFile(w.indexPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteString("./" + filename + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (w *BinlogWriter) nextFilename() string {
+	return fmt.Sprintf("%s.%06d", w.baseName, w.seq+1)
+}
+
+// ReadBinlogIndex returns the names of the binlog files in an index file.
+func ReadBinlogIndex(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			files = append(files, filepath.Base(line))
+		}
+	}
+	return files, scanner.Err()
+}
+
+// binlogFileSeq returns the sequence number of a binlog file name.
+func binlogFileSeq(filename string) (int, error) {
+	i := strings.LastIndexByte(filename, '.')
+	if i < 0 {
+		return 0, fmt.Errorf("invalid binlog file name %v", filename)
+	}
+	seq, err := strconv.Atoi(filename[i+1:])
+	if err != nil {
+		return 0, fmt.Errorf("invalid binlog file name %v: %v", filename, err)
+	}
+	return seq, nil
+}
+
+// putChecksum computes the CRC32 of the event and stores it in the last 4 bytes.
+func putChecksum(ev []byte) {
+	n := len(ev) - 4
+	binary.LittleEndian.PutUint32(ev[n:], crc32.ChecksumIEEE(ev[:n]))
+}
+
+// UpdateChecksum recomputes the checksum of a raw event after its header was
+// modified. It does nothing if the format does not use CRC32.
+func UpdateChecksum(f BinlogFormat, ev []byte) {
+	if f.ChecksumAlgorithm == BinlogChecksumAlgCRC32 && len(ev) >= 4 {
+		putChecksum(ev)
+	}
+}
+
+// VerifyChecksum returns an error if the CRC32 checksum of the event does not match.
+func VerifyChecksum(f BinlogFormat, ev BinlogEvent) error {
+	data := ev.Bytes()
+	if f.ChecksumAlgorithm != BinlogChecksumAlgCRC32 || len(data) < 4 {
+		return nil
+	}
+	n := len(data) - 4
+	if got, want := binary.LittleEndian.Uint32(data[n:]), crc32.ChecksumIEEE(data[:n]); got != want {
+		return fmt.Errorf("checksum mismatch: got %08x want %08x", got, want)
+	}
+	return nil
+}
+
+// BinlogReader reads the events of a binlog file written by BinlogWriter or MySQL.
+type BinlogReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	pos    int64
+}
+
+// NewBinlogReader opens a binlog file and checks the magic header.
+func NewBinlogReader(path string) (*BinlogReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &BinlogReader{file: f, reader: bufio.NewReader(f)}
+
+	magic := make([]byte, len(BinlogFileMagic))
+	if _, err = io.ReadFull(r.reader, magic); err != nil || string(magic) != string(BinlogFileMagic) {
+		f.Close()
+		return nil, fmt.Errorf("%v is not a binlog file", path)
+	}
+	r.pos = int64(len(magic))
+	return r, nil
+}
+
+// Position returns the position of the next event in the file.
+func (r *BinlogReader) Position() int64 {
+	return r.pos
+}
+
+// Next returns the next event, or io.EOF at the end of the file. A partial
+// event at the end of the file, which is still being written, also returns io.EOF.
+func (r *BinlogReader) Next() (BinlogEvent, error) {
+	header, err := r.reader.Peek(eventNextPositionOffset)
+	if err != nil {
+		if err == io.EOF {
+			return nil, r.rewind()
+		}
+		return nil, err
+	}
+	length := int(binary.LittleEndian.Uint32(header[eventLengthOffset:]))
+	if length < eventNextPositionOffset {
+		return nil, fmt.Errorf("invalid event length %v at position %v", length, r.pos)
+	}
+
+	data := make([]byte, length)
+	if _, err = io.ReadFull(r.reader, data); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, r.rewind()
+		}
+		return nil, err
+	}
+	r.pos += int64(length)
+	return NewMysql56BinlogEvent(data), nil
+}
+
+// rewind moves back to the start of the partial event, so that it can be
+// read again once the writer has finished it.
+func (r *BinlogReader) rewind() error {
+	if _, err := r.file.Seek(r.pos, io.SeekStart); err != nil {
+		return err
+	}
+	r.reader.Reset(r.file)
+	return io.EOF
+}
+
+// Close closes the file.
+func (r *BinlogReader) Close() error {
+	return r.file.Close()
+}
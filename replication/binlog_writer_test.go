@@ -0,0 +1,197 @@
+package replication
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestBinlogWriter(t *testing.T, dir string, maxSize int64) *BinlogWriter {
+	w, err := NewBinlogWriter(dir, "mysql-bin", NewMySQL56BinlogFormat(), 1, maxSize)
+	if err != nil {
+		t.Fatalf("NewBinlogWriter error: %v", err)
+	}
+	w.now = func() time.Time { return time.Unix(1407805592, 0) }
+	return w
+}
+
+func writeTestTransaction(t *testing.T, w *BinlogWriter) {
+	f := w.Format()
+	s := NewFakeBinlogStream()
+	events := []BinlogEvent{
+		NewQueryEvent(f, s, Query{Database: "vt_test_keyspace", SQL: "BEGIN"}),
+		NewQueryEvent(f, s, Query{Database: "vt_test_keyspace", SQL: "insert into t values(1)"}),
+		NewXIDEvent(f, s),
+	}
+	for _, ev := range events {
+		if err := w.WriteEvent(ev); err != nil {
+			t.Fatalf("WriteEvent error: %v", err)
+		}
+	}
+}
+
+func readTestBinlogFile(t *testing.T, path string) []BinlogEvent {
+	r, err := NewBinlogReader(path)
+	if err != nil {
+		t.Fatalf("NewBinlogReader error: %v", err)
+	}
+	defer r.Close()
+
+	var events []BinlogEvent
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Next error: %v", err)
+		}
+		events = append(events, ev)
+	}
+}
+
+func TestBinlogWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog_writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := newTestBinlogWriter(t, dir, 200)
+	writeTestTransaction(t, w)
+	writeTestTransaction(t, w)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	files, err := ReadBinlogIndex(filepath.Join(dir, "mysql-bin.index"))
+	if err != nil {
+		t.Fatalf("ReadBinlogIndex error: %v", err)
+	}
+	if want := []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("index want: %v out: %v", want, files)
+	}
+
+	f := NewMySQL56BinlogFormat()
+	testCases := []struct {
+		filename string
+		types    []byte
+		rotateTo string
+	}{
+		{
+			filename: "mysql-bin.000001",
+			types:    []byte{eFormatDescriptionEvent, eQueryEvent, eQueryEvent, eXIDEvent, eRotateEvent},
+			rotateTo: "mysql-bin.000002",
+		},
+		{
+			filename: "mysql-bin.000002",
+			types:    []byte{eFormatDescriptionEvent, eQueryEvent, eQueryEvent, eXIDEvent, eRotateEvent},
+			rotateTo: "mysql-bin.000003",
+		},
+		{
+			filename: "mysql-bin.000003",
+			types:    []byte{eFormatDescriptionEvent},
+		},
+	}
+
+	for _, v := range testCases {
+		events := readTestBinlogFile(t, filepath.Join(dir, v.filename))
+		if len(events) != len(v.types) {
+			t.Fatalf("%v events want: %v out: %v", v.filename, len(v.types), len(events))
+		}
+
+		pos := int64(len(BinlogFileMagic))
+		for i, ev := range events {
+			if typ := ev.Bytes()[4]; typ != v.types[i] {
+				t.Fatalf("%v event %v type want: %v out: %v", v.filename, i, v.types[i], typ)
+			}
+			pos += int64(len(ev.Bytes()))
+			if ev.NextPosition() != pos {
+				t.Fatalf("%v event %v next position want: %v out: %v", v.filename, i, pos, ev.NextPosition())
+			}
+			if err := VerifyChecksum(f, ev); err != nil {
+				t.Fatalf("%v event %v %v", v.filename, i, err)
+			}
+		}
+
+		fde := events[0].Bytes()
+		if flags := binary.LittleEndian.Uint16(fde[eventFlagsOffset:]); flags&logEventBinlogInUseF != 0 {
+			t.Fatalf("%v in use flag is not cleared: %v", v.filename, flags)
+		}
+
+		if v.rotateTo == "" {
+			continue
+		}
+		rotate, _, err := events[len(events)-1].StripChecksum(f)
+		if err != nil {
+			t.Fatalf("StripChecksum error: %v", err)
+		}
+		name, position, err := rotate.Rotate(f)
+		if err != nil {
+			t.Fatalf("Rotate error: %v", err)
+		}
+		if name != v.rotateTo || position != 4 {
+			t.Fatalf("rotate want: %v:4 out: %v:%v", v.rotateTo, name, position)
+		}
+	}
+
+	// a new writer continues after the last file of the index
+	w = newTestBinlogWriter(t, dir, 0)
+	writeTestTransaction(t, w)
+	if name, _ := w.Position(); name != "mysql-bin.000004" {
+		t.Fatalf("Position want: mysql-bin.000004 out: %v", name)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+}
+
+func TestBinlogWriter_InUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog_writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := newTestBinlogWriter(t, dir, 0)
+	defer w.Close()
+	writeTestTransaction(t, w)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	name, pos := w.Position()
+	events := readTestBinlogFile(t, filepath.Join(dir, name))
+	if len(events) != 4 {
+		t.Fatalf("events want: 4 out: %v", len(events))
+	}
+	if events[3].NextPosition() != pos {
+		t.Fatalf("next position want: %v out: %v", pos, events[3].NextPosition())
+	}
+	fde := events[0].Bytes()
+	if flags := binary.LittleEndian.Uint16(fde[eventFlagsOffset:]); flags&logEventBinlogInUseF == 0 {
+		t.Fatalf("in use flag is not set: %v", flags)
+	}
+	if err := VerifyChecksum(NewMySQL56BinlogFormat(), events[0]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewBinlogReader(t *testing.T) {
+	f, err := ioutil.TempFile("", "binlog_reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write([]byte("not a binlog"))
+	f.Close()
+
+	if _, err := NewBinlogReader(f.Name()); err == nil {
+		t.Fatalf("NewBinlogReader want error")
+	}
+}
@@ -186,6 +186,54 @@ const (
 	eMariaStartEncryptionEvent  = 164
 )
 
+// eventTypeNames are the names of the event types printed by mysqlbinlog.
+var eventTypeNames = map[byte]string{
+	eStartEventV3:            "Start_v3",
+	eQueryEvent:              "Query",
+	eStopEvent:               "Stop",
+	eRotateEvent:             "Rotate",
+	eIntVarEvent:             "Intvar",
+	eLoadEvent:               "Load",
+	eSlaveEvent:              "Slave",
+	eCreateFileEvent:         "Create_file",
+	eAppendBlockEvent:        "Append_block",
+	eExecLoadEvent:           "Exec_load",
+	eDeleteFileEvent:         "Delete_file",
+	eNewLoadEvent:            "New_load",
+	eRandEvent:               "RAND",
+	eUserVarEvent:            "User var",
+	eFormatDescriptionEvent:  "Format_desc",
+	eXIDEvent:                "Xid",
+	eBeginLoadQueryEvent:     "Begin_load_query",
+	eExecuteLoadQueryEvent:   "Execute_load_query",
+	eTableMapEvent:           "Table_map",
+	eWriteRowsEventV0:        "Write_rows_v0",
+	eUpdateRowsEventV0:       "Update_rows_v0",
+	eDeleteRowsEventV0:       "Delete_rows_v0",
+	eWriteRowsEventV1:        "Write_rows_v1",
+	eUpdateRowsEventV1:       "Update_rows_v1",
+	eDeleteRowsEventV1:       "Delete_rows_v1",
+	eIncidentEvent:           "Incident",
+	eHeartbeatEvent:          "Heartbeat",
+	eIgnorableEvent:          "Ignorable",
+	eRowsQueryEvent:          "Rows_query",
+	eWriteRowsEventV2:        "Write_rows",
+	eUpdateRowsEventV2:       "Update_rows",
+	eDeleteRowsEventV2:       "Delete_rows",
+	eGTIDEvent:               "Gtid",
+	eAnonymousGTIDEvent:      "Anonymous_Gtid",
+	ePreviousGTIDsEvent:      "Previous_gtids",
+	eTransactionContextEvent: "Transaction_context",
+	eViewChangeEvent:         "View_change",
+	eXAPrepareLogEvent:       "XA_prepare",
+
+	eMariaAnnotateRowsEvent:     "Annotate_rows",
+	eMariaBinlogCheckpointEvent: "Binlog_checkpoint",
+	eMariaGTIDEvent:             "Gtid",
+	eMariaGTIDListEvent:         "Gtid_list",
+	eMariaStartEncryptionEvent:  "Start_encryption",
+}
+
 // These constants describe the type of status variables in q Query packet.
 const (
 	// QFlags2Code is Q_FLAGS2_CODE
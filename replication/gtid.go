@@ -0,0 +1,164 @@
+package replication
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GTID represents a Global Transaction ID, also known as Transaction Group ID.
+// Each flavor has its own format for the GTID. This interface is used along with
+// various MysqlFlavor implementations to abstract the differences.
+type GTID interface {
+	// String returns the canonical form of the GTID as expected by a particular
+	// flavor of MySQL.
+	String() string
+
+	// Flavor returns the key under which the corresponding GTID parser function
+	// is registered.
+	Flavor() string
+}
+
+// Flavor names of the GTID.
+const (
+	Mysql56FlavorID = "MySQL56"
+	MariadbFlavorID = "MariaDB"
+)
+
+// SID is the 16-byte unique ID of a MySQL 5.6 server.
+type SID [16]byte
+
+// String prints an SID in the form used by MySQL 5.6.
+func (sid SID) String() string {
+	dst := []byte("xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx")
+	hex.Encode(dst, sid[:4])
+	hex.Encode(dst[9:], sid[4:6])
+	hex.Encode(dst[14:], sid[6:8])
+	hex.Encode(dst[19:], sid[8:10])
+	hex.Encode(dst[24:], sid[10:16])
+	return string(dst)
+}
+
+// ParseSID parses an SID in the form used by MySQL 5.6.
+func ParseSID(s string) (sid SID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return sid, fmt.Errorf("invalid MySQL 5.6 SID %q", s)
+	}
+
+	// Drop the dashes so we can just check the error of Decode once.
+	b := make([]byte, 0, 32)
+	b = append(b, s[:8]...)
+	b = append(b, s[9:13]...)
+	b = append(b, s[14:18]...)
+	b = append(b, s[19:23]...)
+	b = append(b, s[24:]...)
+
+	if _, err := hex.Decode(sid[:], b); err != nil {
+		return sid, fmt.Errorf("invalid MySQL 5.6 SID %q: %v", s, err)
+	}
+	return sid, nil
+}
+
+// Mysql56GTID implements GTID, the form is server_uuid:sequence
+type Mysql56GTID struct {
+	// Server is the SID of the server that originally committed the transaction.
+	Server SID
+	// Sequence is the sequence number of the transaction within a given Server's
+	// scope.
+	Sequence int64
+}
+
+// String implements GTID.String().
+func (gtid Mysql56GTID) String() string {
+	return gtid.Server.String() + ":" + strconv.FormatInt(gtid.Sequence, 10)
+}
+
+// Flavor implements GTID.Flavor().
+func (gtid Mysql56GTID) Flavor() string {
+	return Mysql56FlavorID
+}
+
+// ParseMysql56GTID is registered as a GTID parser.
+func ParseMysql56GTID(s string) (Mysql56GTID, error) {
+	// Split into parts.
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return Mysql56GTID{}, fmt.Errorf("invalid MySQL 5.6 GTID (%v): expecting UUID:Sequence", s)
+	}
+
+	// Parse Server ID.
+	sid, err := ParseSID(parts[0])
+	if err != nil {
+		return Mysql56GTID{}, fmt.Errorf("invalid MySQL 5.6 GTID Server ID (%v): %v", parts[0], err)
+	}
+
+	// Parse Sequence number.
+	seq, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Mysql56GTID{}, fmt.Errorf("invalid MySQL 5.6 GTID Sequence number (%v): %v", parts[1], err)
+	}
+
+	return Mysql56GTID{Server: sid, Sequence: seq}, nil
+}
+
+// MariadbGTID implements GTID, the form is domain-server-sequence
+type MariadbGTID struct {
+	// Domain is the ID number of the domain within which sequence numbers apply.
+	Domain uint32
+	// Server is the ID of the server that generated the transaction.
+	Server uint32
+	// Sequence is the sequence number of the transaction within the domain.
+	Sequence uint64
+}
+
+// String implements GTID.String().
+func (gtid MariadbGTID) String() string {
+	return fmt.Sprintf("%d-%d-%d", gtid.Domain, gtid.Server, gtid.Sequence)
+}
+
+// Flavor implements GTID.Flavor().
+func (gtid MariadbGTID) Flavor() string {
+	return MariadbFlavorID
+}
+
+// ParseMariadbGTID is registered as a GTID parser.
+func ParseMariadbGTID(s string) (MariadbGTID, error) {
+	// Split into parts.
+	parts := strings.Split(s, "-")
+	if len(parts) != 3 {
+		return MariadbGTID{}, fmt.Errorf("invalid MariaDB GTID (%v): expecting Domain-Server-Sequence", s)
+	}
+
+	// Parse Domain ID.
+	domain, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return MariadbGTID{}, fmt.Errorf("invalid MariaDB GTID Domain ID (%v): %v", parts[0], err)
+	}
+
+	// Parse Server ID.
+	server, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return MariadbGTID{}, fmt.Errorf("invalid MariaDB GTID Server ID (%v): %v", parts[1], err)
+	}
+
+	// Parse Sequence number.
+	seq, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return MariadbGTID{}, fmt.Errorf("invalid MariaDB GTID Sequence number (%v): %v", parts[2], err)
+	}
+
+	return MariadbGTID{
+		Domain:   uint32(domain),
+		Server:   uint32(server),
+		Sequence: seq,
+	}, nil
+}
+
+// ParseGTID 解析mysql 5.6(server_uuid:sequence)或者mariadb(domain-server-sequence)格式的GTID
+func ParseGTID(s string) (GTID, error) {
+	if strings.Contains(s, ":") {
+		return ParseMysql56GTID(s)
+	}
+	return ParseMariadbGTID(s)
+}
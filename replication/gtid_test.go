@@ -0,0 +1,90 @@
+package replication
+
+import (
+	"testing"
+)
+
+func TestParseGTID(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    GTID
+		wantErr bool
+	}{
+		{
+			input: "439192bd-f37c-11e4-bbeb-0242ac11035a:4",
+			want: Mysql56GTID{
+				Server:   SID{0x43, 0x91, 0x92, 0xbd, 0xf3, 0x7c, 0x11, 0xe4, 0xbb, 0xeb, 0x2, 0x42, 0xac, 0x11, 0x3, 0x5a},
+				Sequence: 4,
+			},
+		},
+		{
+			input: "0-62344-9",
+			want:  MariadbGTID{Domain: 0, Server: 62344, Sequence: 9},
+		},
+		{input: "439192bd-f37c-11e4-bbeb-0242ac11035a", wantErr: true},
+		{input: "439192bd-f37c-11e4-bbeb-0242ac11035x:4", wantErr: true},
+		{input: "439192bd-f37c-11e4-bbeb-0242ac11035a:x", wantErr: true},
+		{input: "0-62344", wantErr: true},
+		{input: "0-x-9", wantErr: true},
+	}
+
+	for _, v := range testCases {
+		out, err := ParseGTID(v.input)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("ParseGTID(%v) wantErr: %v err: %v", v.input, v.wantErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if out != v.want {
+			t.Fatalf("ParseGTID(%v) want: %#v out: %#v", v.input, v.want, out)
+		}
+		if out.String() != v.input {
+			t.Fatalf("String() want: %v out: %v", v.input, out.String())
+		}
+	}
+}
+
+func TestBinlogEvent_GTID(t *testing.T) {
+	format, err := mysql56FormatEvent.Format()
+	if err != nil {
+		t.Fatalf("Format() error: %v", err)
+	}
+	gtid, hasBegin, err := mysql56GTIDEvent.GTID(format)
+	if err != nil {
+		t.Fatalf("GTID() error: %v", err)
+	}
+	if want := "439192bd-f37c-11e4-bbeb-0242ac11035a:4"; gtid.String() != want || hasBegin {
+		t.Fatalf("GTID() want: %v, false out: %v, %v", want, gtid, hasBegin)
+	}
+
+	f := NewMySQL56BinlogFormat()
+	s := NewFakeBinlogStream()
+	s.ServerID = 62344
+	testCases := []struct {
+		input    MariadbGTID
+		hasBegin bool
+	}{
+		{input: MariadbGTID{Domain: 1, Server: 62344, Sequence: 9}, hasBegin: false},
+		{input: MariadbGTID{Domain: 0, Server: 62344, Sequence: 10}, hasBegin: true},
+	}
+	for _, v := range testCases {
+		ev := NewMariaDBGTIDEvent(f, s, v.input, v.hasBegin)
+		gtid, hasBegin, err := ev.GTID(f)
+		if err != nil {
+			t.Fatalf("GTID() error: %v", err)
+		}
+		if gtid != v.input || hasBegin != v.hasBegin {
+			t.Fatalf("GTID() want: %v, %v out: %v, %v", v.input, v.hasBegin, gtid, hasBegin)
+		}
+	}
+
+	want := Mysql56GTID{Server: SID{0x1, 0x2}, Sequence: 100}
+	gtid, _, err = NewMySQL56GTIDEvent(f, s, want).GTID(f)
+	if err != nil {
+		t.Fatalf("GTID() error: %v", err)
+	}
+	if gtid != want {
+		t.Fatalf("GTID() want: %v out: %v", want, gtid)
+	}
+}
@@ -0,0 +1,258 @@
+package replication
+
+import (
+	"fmt"
+)
+
+// Optional metadata field types of a TABLE_MAP_EVENT. They are written by
+// MySQL 8.0.1+ after the NULL-bitmask, depending on binlog_row_metadata
+// (MINIMAL or FULL).
+const (
+	tableMapSignedness               = 1
+	tableMapDefaultCharset           = 2
+	tableMapColumnCharset            = 3
+	tableMapColumnName               = 4
+	tableMapSetStrValue              = 5
+	tableMapEnumStrValue             = 6
+	tableMapGeometryType             = 7
+	tableMapSimplePrimaryKey         = 8
+	tableMapPrimaryKeyWithPrefix     = 9
+	tableMapEnumAndSetDefaultCharset = 10
+	tableMapEnumAndSetColumnCharset  = 11
+	tableMapColumnVisibility         = 12
+)
+
+// RealType returns the real type of a column. CHAR, ENUM and SET columns are
+// all written as TypeString, and the real type is kept in the high byte of
+// the metadata. For CHAR columns longer than 255 bytes, two bits of the real
+// type are used to store the length, see the TypeString branch of CellBytes.
+func RealType(typ byte, metadata uint16) byte {
+	if typ != TypeString {
+		return typ
+	}
+	t := byte(metadata >> 8)
+	if t&0x30 != 0x30 {
+		t |= 0x30
+	}
+	return t
+}
+
+// IsCharacterType returns true if the column has a character set in the
+// optional metadata, which is the case for CHAR, VARCHAR, TEXT and BLOB.
+func IsCharacterType(typ byte, metadata uint16) bool {
+	switch RealType(typ, metadata) {
+	case TypeString, TypeVarString, TypeVarchar,
+		TypeTinyBlob, TypeMediumBlob, TypeLongBlob, TypeBlob:
+		return true
+	}
+	return false
+}
+
+// IsEnumOrSetType returns true if the column is an ENUM or a SET.
+func IsEnumOrSetType(typ byte, metadata uint16) bool {
+	t := RealType(typ, metadata)
+	return t == TypeEnum || t == TypeSet
+}
+
+// columnsOf returns the indexes of the columns matching fn.
+func (tm *TableMap) columnsOf(fn func(typ byte, metadata uint16) bool) []int {
+	var columns []int
+	for c := range tm.Types {
+		if fn(tm.Types[c], tm.Metadata[c]) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// parseOptionalMetadata parses the optional metadata fields which follow the
+// NULL-bitmask. Each field is:
+//  # bytes   field
+//  1         type
+//  <var>     length l (var-len encoded)
+//  l         value
+// Fields of an unknown type are skipped.
+func (tm *TableMap) parseOptionalMetadata(data []byte) error {
+	pos := 0
+	for pos < len(data) {
+		typ := data[pos]
+		l, nPos, ok := readLenEncInt(data, pos+1)
+		if !ok || uint64(len(data)-nPos) < l {
+			return fmt.Errorf("optional metadata %v is too short (data: %v pos: %v)", typ, data, pos)
+		}
+		value := data[nPos : nPos+int(l)]
+		pos = nPos + int(l)
+
+		var err error
+		switch typ {
+		case tableMapDefaultCharset, tableMapColumnCharset:
+			err = tm.parseCollations(value, typ == tableMapDefaultCharset, tm.columnsOf(IsCharacterType))
+		case tableMapEnumAndSetDefaultCharset, tableMapEnumAndSetColumnCharset:
+			err = tm.parseCollations(value, typ == tableMapEnumAndSetDefaultCharset, tm.columnsOf(IsEnumOrSetType))
+		case tableMapEnumStrValue:
+			err = tm.parseEnumValues(value, tm.columnsOf(isRealType(TypeEnum)))
+		case tableMapSetStrValue:
+			err = tm.parseEnumValues(value, tm.columnsOf(isRealType(TypeSet)))
+		}
+		if err != nil {
+			return fmt.Errorf("optional metadata %v: %v", typ, err)
+		}
+	}
+	return nil
+}
+
+// parseCollations sets ColumnCollations for columns, which are the indexes of
+// the character (or ENUM and SET) columns.
+//
+// The DEFAULT_CHARSET format is the default collation followed by the
+// (index in columns, collation) pairs of the columns that are not using the
+// default. The COLUMN_CHARSET format is a collation for each of columns.
+// All numbers are var-len encoded.
+func (tm *TableMap) parseCollations(value []byte, isDefault bool, columns []int) error {
+	var numbers []uint64
+	for pos := 0; pos < len(value); {
+		n, nPos, ok := readLenEncInt(value, pos)
+		if !ok {
+			return fmt.Errorf("collation is too short (data: %v pos: %v)", value, pos)
+		}
+		numbers = append(numbers, n)
+		pos = nPos
+	}
+
+	if tm.ColumnCollations == nil {
+		tm.ColumnCollations = make([]uint64, len(tm.Types))
+	}
+	if !isDefault {
+		if len(numbers) != len(columns) {
+			return fmt.Errorf("got %v collations for %v columns", len(numbers), len(columns))
+		}
+		for i, c := range columns {
+			tm.ColumnCollations[c] = numbers[i]
+		}
+		return nil
+	}
+
+	if len(numbers) == 0 || len(numbers)%2 != 1 {
+		return fmt.Errorf("bad default collation list %v", numbers)
+	}
+	for _, c := range columns {
+		tm.ColumnCollations[c] = numbers[0]
+	}
+	for i := 1; i < len(numbers); i += 2 {
+		if numbers[i] >= uint64(len(columns)) {
+			return fmt.Errorf("column index %v out of range %v", numbers[i], len(columns))
+		}
+		tm.ColumnCollations[columns[numbers[i]]] = numbers[i+1]
+	}
+	return nil
+}
+
+// isRealType returns a function which tells if a column is of the real type t.
+func isRealType(t byte) func(typ byte, metadata uint16) bool {
+	return func(typ byte, metadata uint16) bool {
+		return RealType(typ, metadata) == t
+	}
+}
+
+// parseEnumValues sets EnumValues for columns, which are the indexes of the
+// ENUM (or SET) columns. For each of columns, the format is:
+//  <var>     member count n (var-len encoded)
+//  -- for each member
+//  <var>     member length l (var-len encoded)
+//  l         member
+func (tm *TableMap) parseEnumValues(value []byte, columns []int) error {
+	if tm.EnumValues == nil {
+		tm.EnumValues = make([][]string, len(tm.Types))
+	}
+	pos := 0
+	for _, c := range columns {
+		n, nPos, ok := readLenEncInt(value, pos)
+		if !ok || n > uint64(len(value)) {
+			return fmt.Errorf("member count is too short (data: %v pos: %v)", value, pos)
+		}
+		pos = nPos
+		members := make([]string, 0, int(n))
+		for i := uint64(0); i < n; i++ {
+			l, nPos, ok := readLenEncInt(value, pos)
+			if !ok || uint64(len(value)-nPos) < l {
+				return fmt.Errorf("member is too short (data: %v pos: %v)", value, pos)
+			}
+			members = append(members, string(value[nPos:nPos+int(l)]))
+			pos = nPos + int(l)
+		}
+		tm.EnumValues[c] = members
+	}
+	if pos != len(value) {
+		return fmt.Errorf("unexpected members end: got %v was expecting %v", pos, len(value))
+	}
+	return nil
+}
+
+// optionalMetadata returns the optional metadata written for
+// ColumnCollations, using the COLUMN_CHARSET format, and for EnumValues.
+func (tm *TableMap) optionalMetadata() []byte {
+	var b []byte
+	if tm.ColumnCollations != nil {
+		for _, field := range []struct {
+			typ byte
+			fn  func(typ byte, metadata uint16) bool
+		}{
+			{tableMapColumnCharset, IsCharacterType},
+			{tableMapEnumAndSetColumnCharset, IsEnumOrSetType},
+		} {
+			columns := tm.columnsOf(field.fn)
+			if len(columns) == 0 {
+				continue
+			}
+			var value []byte
+			for _, c := range columns {
+				value = appendLenEncInt(value, tm.ColumnCollations[c])
+			}
+			b = appendOptionalMetadata(b, field.typ, value)
+		}
+	}
+	if tm.EnumValues != nil {
+		for _, field := range []struct {
+			typ      byte
+			realType byte
+		}{
+			{tableMapSetStrValue, TypeSet},
+			{tableMapEnumStrValue, TypeEnum},
+		} {
+			columns := tm.columnsOf(isRealType(field.realType))
+			if len(columns) == 0 {
+				continue
+			}
+			var value []byte
+			for _, c := range columns {
+				value = appendLenEncInt(value, uint64(len(tm.EnumValues[c])))
+				for _, member := range tm.EnumValues[c] {
+					value = appendLenEncInt(value, uint64(len(member)))
+					value = append(value, member...)
+				}
+			}
+			b = appendOptionalMetadata(b, field.typ, value)
+		}
+	}
+	return b
+}
+
+func appendOptionalMetadata(b []byte, typ byte, value []byte) []byte {
+	b = append(b, typ)
+	b = appendLenEncInt(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendLenEncInt(b []byte, v uint64) []byte {
+	switch {
+	case v < 251:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return append(b, 0xfc, byte(v), byte(v>>8))
+	case v < 1<<24:
+		return append(b, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		return append(b, 0xfe, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+			byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+	}
+}
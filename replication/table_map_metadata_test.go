@@ -0,0 +1,168 @@
+package replication
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRealType(t *testing.T) {
+	testCases := []struct {
+		typ      byte
+		metadata uint16
+		want     byte
+	}{
+		{TypeLong, 0, TypeLong},
+		{TypeString, uint16(TypeString)<<8 | 8, TypeString},
+		{TypeString, uint16(TypeEnum)<<8 | 1, TypeEnum},
+		{TypeString, uint16(TypeSet)<<8 | 2, TypeSet},
+		// CHAR(255) in utf8mb4, 1020 bytes, two bits of the type are used by the length.
+		{TypeString, 0xce<<8 | 0xfc, TypeString},
+	}
+	for _, v := range testCases {
+		if out := RealType(v.typ, v.metadata); out != v.want {
+			t.Errorf("RealType(%v, %x) = %v, want %v", v.typ, v.metadata, out, v.want)
+		}
+	}
+}
+
+func TestTableMap_OptionalMetadata(t *testing.T) {
+	f := NewMySQL56BinlogFormat()
+	s := NewFakeBinlogStream()
+
+	tm := &TableMap{
+		Database: "my_database",
+		Name:     "my_table",
+		Types: []byte{
+			TypeLong,
+			TypeVarchar,
+			TypeString,
+			TypeBlob,
+			TypeString,
+		},
+		CanBeNull: NewServerBitmap(5),
+		Metadata: []uint16{
+			0,
+			384,
+			uint16(TypeString)<<8 | 8,
+			2,
+			uint16(TypeEnum)<<8 | 1,
+		},
+		ColumnCollations: []uint64{0, 8, 45, 63, 255},
+	}
+
+	ev := NewTableMapEvent(f, s, 0x102030405060, tm)
+	ev, _, err := ev.StripChecksum(f)
+	if err != nil {
+		t.Fatalf("StripChecksum failed: %v", err)
+	}
+	out, err := ev.TableMap(f)
+	if err != nil {
+		t.Fatalf("TableMap got error: %v", err)
+	}
+	if !reflect.DeepEqual(out.ColumnCollations, tm.ColumnCollations) {
+		t.Errorf("ColumnCollations = %v, want %v", out.ColumnCollations, tm.ColumnCollations)
+	}
+
+	tm.ColumnCollations = nil
+	ev = NewTableMapEvent(f, s, 0x102030405060, tm)
+	ev, _, _ = ev.StripChecksum(f)
+	if out, err = ev.TableMap(f); err != nil || out.ColumnCollations != nil {
+		t.Errorf("TableMap without optional metadata = %v, %v", out.ColumnCollations, err)
+	}
+}
+
+func TestTableMap_parseOptionalMetadata(t *testing.T) {
+	tm := &TableMap{
+		Types:    []byte{TypeVarchar, TypeLong, TypeVarchar, TypeBlob, TypeString},
+		Metadata: []uint16{30, 0, 30, 2, uint16(TypeSet)<<8 | 1},
+	}
+	testCases := []struct {
+		data    []byte
+		want    []uint64
+		wantErr bool
+	}{
+		{
+			// DEFAULT_CHARSET: default 45, the third character column uses 63.
+			data: []byte{tableMapDefaultCharset, 3, 45, 2, 63},
+			want: []uint64{45, 0, 45, 63, 0},
+		},
+		{
+			// SIGNEDNESS is skipped, COLUMN_CHARSET with a 2 bytes collation,
+			// ENUM_AND_SET_DEFAULT_CHARSET.
+			data: []byte{tableMapSignedness, 1, 0x80,
+				tableMapColumnCharset, 5, 8, 0xfc, 0x00, 0x01, 63,
+				tableMapEnumAndSetDefaultCharset, 1, 33},
+			want: []uint64{8, 0, 256, 63, 33},
+		},
+		{
+			data:    []byte{tableMapColumnCharset, 2, 8, 8},
+			wantErr: true,
+		},
+		{
+			data:    []byte{tableMapDefaultCharset, 3, 45, 5, 63},
+			wantErr: true,
+		},
+		{
+			data:    []byte{tableMapColumnCharset, 10, 8},
+			wantErr: true,
+		},
+	}
+
+	for _, v := range testCases {
+		tm.ColumnCollations = nil
+		err := tm.parseOptionalMetadata(v.data)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("parseOptionalMetadata(%v) wantErr: %v err: %v", v.data, v.wantErr, err)
+		}
+		if err == nil && !reflect.DeepEqual(tm.ColumnCollations, v.want) {
+			t.Fatalf("parseOptionalMetadata(%v) = %v, want %v", v.data, tm.ColumnCollations, v.want)
+		}
+	}
+}
+
+func TestTableMap_EnumValues(t *testing.T) {
+	f := NewMySQL56BinlogFormat()
+	s := NewFakeBinlogStream()
+
+	tm := &TableMap{
+		Database:  "my_database",
+		Name:      "my_table",
+		Types:     []byte{TypeLong, TypeString, TypeString, TypeString},
+		CanBeNull: NewServerBitmap(4),
+		Metadata: []uint16{
+			0,
+			uint16(TypeEnum)<<8 | 1,
+			uint16(TypeSet)<<8 | 1,
+			uint16(TypeString)<<8 | 8,
+		},
+		EnumValues: [][]string{nil, {"a", "b", ""}, {"x", "y"}, nil},
+	}
+
+	ev := NewTableMapEvent(f, s, 0x102030405060, tm)
+	ev, _, err := ev.StripChecksum(f)
+	if err != nil {
+		t.Fatalf("StripChecksum failed: %v", err)
+	}
+	out, err := ev.TableMap(f)
+	if err != nil {
+		t.Fatalf("TableMap got error: %v", err)
+	}
+	if !reflect.DeepEqual(out.EnumValues, tm.EnumValues) {
+		t.Errorf("EnumValues = %q, want %q", out.EnumValues, tm.EnumValues)
+	}
+
+	testCases := [][]byte{
+		// The member count is larger than the data.
+		{tableMapEnumStrValue, 2, 5, 1},
+		// The member is too short.
+		{tableMapEnumStrValue, 3, 1, 4, 'a'},
+		// There are more data than the members.
+		{tableMapSetStrValue, 4, 1, 1, 'x', 'y'},
+	}
+	for _, data := range testCases {
+		tm.EnumValues = nil
+		if err = tm.parseOptionalMetadata(data); err == nil {
+			t.Errorf("parseOptionalMetadata(%v) want error", data)
+		}
+	}
+}
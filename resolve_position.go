@@ -0,0 +1,390 @@
+package binlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+//解析binlog位置时使用的serverID，与非阻塞dump配合时主库发送完binlog后会返回EOF包
+const resolveServerID = 0
+
+//queryConn 用于执行查询语句的接口
+type queryConn interface {
+	Query(string) (dump.MyRows, error)
+}
+
+//ResolvePositionForTime 获取执行时间不早于t的第一个事务的开始位置，可以直接用于SetStartBinlogPosition，
+//通过SHOW BINARY LOGS获取所有的binlog文件，二分查找第一个事件的时间戳找到t所在的binlog文件，
+//然后在该文件中找到对应的事务边界。如果t晚于所有的事务，返回当前binlog的结束位置
+func ResolvePositionForTime(ctx context.Context, dsn string, t time.Time) (Position, error) {
+	conn, err := dump.NewMysqlConn(dsn)
+	if err != nil {
+		return Position{}, fmt.Errorf("ResolvePositionForTime newMysqlConn fail. err: %v", err)
+	}
+	files, err := showBinaryLogs(conn)
+	conn.Close()
+	if err != nil {
+		return Position{}, fmt.Errorf("ResolvePositionForTime showBinaryLogs fail. err: %v", err)
+	}
+
+	return resolvePositionForTime(ctx, func() (dumpConn, error) {
+		return dump.NewMysqlConn(dsn)
+	}, files, t.Unix())
+}
+
+//binlogFileSize SHOW BINARY LOGS返回的binlog文件以及其大小
+type binlogFileSize struct {
+	name string
+	size int64
+}
+
+//showBinaryLogs 通过SHOW BINARY LOGS获取所有的binlog文件名
+func showBinaryLogs(conn queryConn) ([]string, error) {
+	files, err := showBinaryLogSizes(conn)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	return names, nil
+}
+
+//showBinaryLogSizes 通过SHOW BINARY LOGS获取所有的binlog文件以及其大小
+func showBinaryLogSizes(conn queryConn) ([]binlogFileSize, error) {
+	rows, err := conn.Query("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []binlogFileSize
+	dest := make([]interface{}, len(rows.Columns()))
+	if len(dest) < 2 {
+		return nil, fmt.Errorf("showBinaryLogs unexpected columns: %v", rows.Columns())
+	}
+	for {
+		if err = rows.Next(dest); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		name, ok := dest[0].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("showBinaryLogs invalid log name: %v", dest[0])
+		}
+		size, ok := dest[1].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("showBinaryLogs invalid file size: %v", dest[1])
+		}
+		f := binlogFileSize{name: string(name)}
+		if f.size, err = strconv.ParseInt(string(size), 10, 64); err != nil {
+			return nil, fmt.Errorf("showBinaryLogs invalid file size: %v", string(size))
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("showBinaryLogs no binary log found, is binary logging enabled")
+	}
+	return files, nil
+}
+
+//showMasterStatus 通过SHOW MASTER STATUS获取主库当前的binlog位置
+func showMasterStatus(conn queryConn) (Position, error) {
+	rows, err := conn.Query("SHOW MASTER STATUS")
+	if err != nil {
+		return Position{}, err
+	}
+	defer rows.Close()
+
+	dest := make([]interface{}, len(rows.Columns()))
+	if len(dest) < 2 {
+		return Position{}, fmt.Errorf("showMasterStatus unexpected columns: %v", rows.Columns())
+	}
+	if err = rows.Next(dest); err != nil {
+		if err == io.EOF {
+			return Position{}, fmt.Errorf("showMasterStatus binary logging is not enabled")
+		}
+		return Position{}, err
+	}
+	name, ok := dest[0].([]byte)
+	if !ok {
+		return Position{}, fmt.Errorf("showMasterStatus invalid file: %v", dest[0])
+	}
+	offset, ok := dest[1].([]byte)
+	if !ok {
+		return Position{}, fmt.Errorf("showMasterStatus invalid position: %v", dest[1])
+	}
+	pos := Position{Filename: string(name)}
+	if pos.Offset, err = strconv.ParseInt(string(offset), 10, 64); err != nil {
+		return Position{}, fmt.Errorf("showMasterStatus invalid position: %v", string(offset))
+	}
+	return pos, nil
+}
+
+func resolvePositionForTime(ctx context.Context, newConn func() (dumpConn, error),
+	files []string, timestamp int64) (Position, error) {
+	index, err := searchBinlogFile(files, timestamp, func(filename string) (int64, error) {
+		return firstEventTimestamp(ctx, newConn, filename)
+	})
+	if err != nil {
+		return Position{}, err
+	}
+
+	start := Position{Filename: files[index], Offset: 4}
+	var pos Position
+	err = dumpNonBlock(ctx, newConn, start, func(events <-chan replication.BinlogEvent) error {
+		pos, err = scanPositionForTime(ctx, events, start, timestamp)
+		return err
+	})
+	return pos, err
+}
+
+//searchBinlogFile 二分查找第一个事件时间戳不晚于timestamp的最后一个binlog文件，
+//所有文件都晚于timestamp时返回第一个文件
+func searchBinlogFile(files []string, timestamp int64, firstTimestamp func(string) (int64, error)) (int, error) {
+	var err error
+	i := sort.Search(len(files), func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var ts int64
+		if ts, err = firstTimestamp(files[i]); err != nil {
+			return true
+		}
+		return ts > timestamp
+	})
+	if err != nil {
+		return 0, fmt.Errorf("searchBinlogFile get first timestamp fail. err: %v", err)
+	}
+	if i > 0 {
+		i--
+	}
+	return i, nil
+}
+
+//dumpNonBlock 从start开始以非阻塞的方式dump binlog，将binlog event交给handle处理
+func dumpNonBlock(ctx context.Context, newConn func() (dumpConn, error), start Position,
+	handle func(<-chan replication.BinlogEvent) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn, err := newSlaveConn(newConn, nil, nil)
+	if err != nil {
+		return fmt.Errorf("newMysqlConn fail. err: %v", err)
+	}
+	defer conn.close()
+
+	events, err := conn.startDumpFromBinlogPosition(ctx, resolveServerID, start, dump.BinlogDumpNonBlock)
+	if err != nil {
+		return fmt.Errorf("startDumpFromBinlogPosition fail in pos: %+v error: %v", start, err)
+	}
+	return handle(events)
+}
+
+//firstEventTimestamp 获取binlog文件中FORMAT_DESCRIPTION_EVENT的时间戳，即该文件的创建时间
+func firstEventTimestamp(ctx context.Context, newConn func() (dumpConn, error), filename string) (int64, error) {
+	var timestamp int64
+	start := Position{Filename: filename, Offset: 4}
+	err := dumpNonBlock(ctx, newConn, start, func(events <-chan replication.BinlogEvent) error {
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return fmt.Errorf("firstEventTimestamp no FORMAT_DESCRIPTION_EVENT in %v", filename)
+				}
+				if !ev.IsValid() {
+					return fmt.Errorf("firstEventTimestamp invalid data: %+v", ev)
+				}
+				if ev.IsFormatDescription() {
+					timestamp = int64(ev.Timestamp())
+					return nil
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+	return timestamp, err
+}
+
+//scanPositionForTime 从start开始查找提交时间不早于timestamp的第一个事务，返回该事务的开始位置，
+//binlog结束时仍然没有找到则返回最后一个事务的结束位置
+func scanPositionForTime(ctx context.Context, events <-chan replication.BinlogEvent, start Position,
+	timestamp int64) (Position, error) {
+	var format replication.BinlogFormat
+	var err error
+	boundary := start
+
+	for {
+		var ev replication.BinlogEvent
+		var ok bool
+		select {
+		case ev, ok = <-events:
+			if !ok {
+				return boundary, nil
+			}
+		case <-ctx.Done():
+			return boundary, ctx.Err()
+		}
+
+		if !ev.IsValid() {
+			return boundary, fmt.Errorf("scanPositionForTime can't parse binlog event, invalid data: %+v", ev)
+		}
+		if ev.IsFormatDescription() {
+			if format, err = ev.Format(); err != nil {
+				return boundary, fmt.Errorf("scanPositionForTime can't parse FORMAT_DESCRIPTION_EVENT: %v", err)
+			}
+			continue
+		}
+		if format.IsZero() {
+			//主库发送的第一个fake ROTATE_EVENT
+			continue
+		}
+		if ev, _, err = ev.StripChecksum(format); err != nil {
+			return boundary, fmt.Errorf("scanPositionForTime can't strip checksum: %v", err)
+		}
+
+		switch {
+		case ev.IsRotate():
+			filename, offset, err := ev.Rotate(format)
+			if err != nil {
+				return boundary, err
+			}
+			boundary = Position{Filename: filename, Offset: offset}
+			continue
+		case ev.IsXID():
+		case ev.IsQuery():
+			q, err := ev.Query(format)
+			if err != nil {
+				return boundary, fmt.Errorf("scanPositionForTime can't get query: %v", err)
+			}
+			if GetStatementCategory(q.SQL) == StatementBegin {
+				continue
+			}
+		default:
+			continue
+		}
+
+		//事务或者语句在此结束
+		if int64(ev.Timestamp()) >= timestamp {
+			return boundary, nil
+		}
+		boundary.Offset = ev.NextPosition()
+	}
+}
+
+//ResolvePositionForGTID 获取GTID所在事务的开始位置(GTID_EVENT的位置)，可以直接用于SetStartBinlogPosition，
+//从该事务开始处理。通过SHOW BINARY LOGS获取所有的binlog文件，从最新的文件开始向前逐个扫描，
+//所有文件中都没有该GTID时返回错误
+func ResolvePositionForGTID(ctx context.Context, dsn string, gtid string) (Position, error) {
+	target, err := replication.ParseGTID(gtid)
+	if err != nil {
+		return Position{}, fmt.Errorf("ResolvePositionForGTID parse gtid fail. err: %v", err)
+	}
+	conn, err := dump.NewMysqlConn(dsn)
+	if err != nil {
+		return Position{}, fmt.Errorf("ResolvePositionForGTID newMysqlConn fail. err: %v", err)
+	}
+	files, err := showBinaryLogs(conn)
+	conn.Close()
+	if err != nil {
+		return Position{}, fmt.Errorf("ResolvePositionForGTID showBinaryLogs fail. err: %v", err)
+	}
+
+	return resolvePositionForGTID(ctx, func() (dumpConn, error) {
+		return dump.NewMysqlConn(dsn)
+	}, files, target)
+}
+
+func resolvePositionForGTID(ctx context.Context, newConn func() (dumpConn, error),
+	files []string, gtid replication.GTID) (Position, error) {
+	for i := len(files) - 1; i >= 0; i-- {
+		start := Position{Filename: files[i], Offset: 4}
+		var pos Position
+		var found bool
+		err := dumpNonBlock(ctx, newConn, start, func(events <-chan replication.BinlogEvent) error {
+			var err error
+			pos, found, err = scanPositionForGTID(ctx, events, start, gtid)
+			return err
+		})
+		if err != nil {
+			return Position{}, err
+		}
+		if found {
+			return pos, nil
+		}
+	}
+	return Position{}, fmt.Errorf("resolvePositionForGTID gtid %v not found in binlog files %v", gtid, files)
+}
+
+//scanPositionForGTID 在start所在的binlog文件中查找gtid的GTID_EVENT，返回其开始位置，
+//遇到下一个文件的ROTATE_EVENT或者binlog结束时返回false
+func scanPositionForGTID(ctx context.Context, events <-chan replication.BinlogEvent, start Position,
+	gtid replication.GTID) (Position, bool, error) {
+	var format replication.BinlogFormat
+	var err error
+	pos := start
+	want := gtid.String()
+
+	for {
+		var ev replication.BinlogEvent
+		var ok bool
+		select {
+		case ev, ok = <-events:
+			if !ok {
+				return pos, false, nil
+			}
+		case <-ctx.Done():
+			return pos, false, ctx.Err()
+		}
+
+		if !ev.IsValid() {
+			return pos, false, fmt.Errorf("scanPositionForGTID can't parse binlog event, invalid data: %+v", ev)
+		}
+		if ev.IsFormatDescription() {
+			if format, err = ev.Format(); err != nil {
+				return pos, false, fmt.Errorf("scanPositionForGTID can't parse FORMAT_DESCRIPTION_EVENT: %v", err)
+			}
+			pos.Offset = ev.NextPosition()
+			continue
+		}
+		if format.IsZero() {
+			//主库发送的第一个fake ROTATE_EVENT
+			continue
+		}
+		if ev, _, err = ev.StripChecksum(format); err != nil {
+			return pos, false, fmt.Errorf("scanPositionForGTID can't strip checksum: %v", err)
+		}
+
+		switch {
+		case ev.IsRotate():
+			filename, _, err := ev.Rotate(format)
+			if err != nil {
+				return pos, false, err
+			}
+			if filename != start.Filename {
+				return pos, false, nil
+			}
+			continue
+		case ev.IsGTID():
+			g, _, err := ev.GTID(format)
+			if err != nil {
+				return pos, false, fmt.Errorf("scanPositionForGTID can't get GTID: %v", err)
+			}
+			if g.String() == want {
+				return pos, true, nil
+			}
+		}
+		pos.Offset = ev.NextPosition()
+	}
+}
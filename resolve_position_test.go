@@ -0,0 +1,294 @@
+package binlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+type mockRows struct {
+	columns []string
+	values  [][]interface{}
+}
+
+func (m *mockRows) Columns() []string {
+	return m.columns
+}
+
+func (m *mockRows) Close() error {
+	return nil
+}
+
+func (m *mockRows) Next(dest []interface{}) error {
+	if len(m.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, m.values[0])
+	m.values = m.values[1:]
+	return nil
+}
+
+type mockQueryConn struct {
+	rows map[string]*mockRows
+}
+
+func (m *mockQueryConn) Query(query string) (dump.MyRows, error) {
+	rows, ok := m.rows[query]
+	if !ok {
+		return nil, fmt.Errorf("unexpected query: %v", query)
+	}
+	return rows, nil
+}
+
+//getTimeInputData 生成一个binlog文件的binlog event，每个时间戳对应一个事务，第i个事务的结束位置为(i+1)*100
+func getTimeInputData(filename string, timestamps []uint32) []replication.BinlogEvent {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	s.Timestamp = timestamps[0]
+
+	events := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, 4, filename),
+		replication.NewFormatDescriptionEvent(f, s),
+	}
+	for i, ts := range timestamps {
+		s.Timestamp = ts
+		events = append(events, replication.NewQueryEvent(f, s, replication.Query{
+			Database: "vt_test_keyspace",
+			SQL:      "BEGIN"}))
+		s.LogPosition = uint32((i + 1) * 100)
+		events = append(events, replication.NewXIDEvent(f, s))
+	}
+	return events
+}
+
+//mockBinlogFiles 模拟主库上的多个binlog文件，dump时从指定的文件开始发送之后所有文件的binlog event
+type mockBinlogFiles struct {
+	files map[string][]replication.BinlogEvent
+	dumps int
+}
+
+func (m *mockBinlogFiles) names() []string {
+	var names []string
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *mockBinlogFiles) NewDumpConn() (dumpConn, error) {
+	return &mockBinlogFilesConn{files: m}, nil
+}
+
+type mockBinlogFilesConn struct {
+	mockPacketConn
+	files *mockBinlogFiles
+}
+
+func (m *mockBinlogFilesConn) NoticeDump(_ uint32, _ uint32, filename string, flags uint16) error {
+	m.files.dumps++
+	var events []replication.BinlogEvent
+	for _, name := range m.files.names() {
+		if name >= filename {
+			events = append(events, m.files.files[name]...)
+		}
+	}
+	m.packets = newMockPacketConn(events).packets
+	m.packets = append(m.packets, []byte{dump.PacketEOF})
+	m.flags = flags
+	return nil
+}
+
+func TestShowBinaryLogs(t *testing.T) {
+	conn := &mockQueryConn{
+		rows: map[string]*mockRows{
+			"SHOW BINARY LOGS": {
+				columns: []string{"Log_name", "File_size"},
+				values: [][]interface{}{
+					{[]byte("binlog.000001"), []byte("1024")},
+					{[]byte("binlog.000002"), []byte("2048")},
+				},
+			},
+		},
+	}
+	files, err := showBinaryLogs(conn)
+	if err != nil {
+		t.Fatalf("showBinaryLogs err: %v", err)
+	}
+	if len(files) != 2 || files[0] != "binlog.000001" || files[1] != "binlog.000002" {
+		t.Fatalf("showBinaryLogs out: %v", files)
+	}
+
+	if _, err = showBinaryLogs(&mockQueryConn{
+		rows: map[string]*mockRows{"SHOW BINARY LOGS": {columns: []string{"Log_name", "File_size"}}},
+	}); err == nil {
+		t.Fatalf("showBinaryLogs with no binary log want err")
+	}
+}
+
+func TestSearchBinlogFile(t *testing.T) {
+	files := []string{"binlog.000001", "binlog.000002", "binlog.000003"}
+	first := map[string]int64{
+		"binlog.000001": 100,
+		"binlog.000002": 200,
+		"binlog.000003": 300,
+	}
+	testCases := []struct {
+		timestamp int64
+		want      int
+	}{
+		{timestamp: 50, want: 0},
+		{timestamp: 100, want: 0},
+		{timestamp: 199, want: 0},
+		{timestamp: 200, want: 1},
+		{timestamp: 250, want: 1},
+		{timestamp: 1000, want: 2},
+	}
+	for _, v := range testCases {
+		out, err := searchBinlogFile(files, v.timestamp, func(name string) (int64, error) {
+			return first[name], nil
+		})
+		if err != nil {
+			t.Fatalf("searchBinlogFile err: %v", err)
+		}
+		if out != v.want {
+			t.Fatalf("searchBinlogFile timestamp: %v want: %v out: %v", v.timestamp, v.want, out)
+		}
+	}
+
+	if _, err := searchBinlogFile(files, 100, func(name string) (int64, error) {
+		return 0, fmt.Errorf("test error")
+	}); err == nil {
+		t.Fatalf("searchBinlogFile want err")
+	}
+}
+
+func TestScanPositionForTime(t *testing.T) {
+	start := Position{Filename: "binlog.000001", Offset: 4}
+	testCases := []struct {
+		timestamp int64
+		want      Position
+	}{
+		{timestamp: 100, want: Position{Filename: "binlog.000001", Offset: 4}},
+		{timestamp: 101, want: Position{Filename: "binlog.000001", Offset: 100}},
+		{timestamp: 110, want: Position{Filename: "binlog.000001", Offset: 100}},
+		{timestamp: 111, want: Position{Filename: "binlog.000001", Offset: 300}},
+	}
+	for _, v := range testCases {
+		input := getTimeInputData(start.Filename, []uint32{100, 110, 110})
+		events := make(chan replication.BinlogEvent, len(input))
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+
+		out, err := scanPositionForTime(context.Background(), events, start, v.timestamp)
+		if err != nil {
+			t.Fatalf("scanPositionForTime err: %v", err)
+		}
+		if out != v.want {
+			t.Fatalf("scanPositionForTime timestamp: %v want: %+v out: %+v", v.timestamp, v.want, out)
+		}
+	}
+}
+
+func TestResolvePositionForTime(t *testing.T) {
+	files := &mockBinlogFiles{
+		files: map[string][]replication.BinlogEvent{
+			"binlog.000001": getTimeInputData("binlog.000001", []uint32{100, 110}),
+			"binlog.000002": getTimeInputData("binlog.000002", []uint32{200, 210, 220}),
+			"binlog.000003": getTimeInputData("binlog.000003", []uint32{300}),
+		},
+	}
+	testCases := []struct {
+		t    time.Time
+		want Position
+	}{
+		{t: time.Unix(50, 0), want: Position{Filename: "binlog.000001", Offset: 4}},
+		{t: time.Unix(105, 0), want: Position{Filename: "binlog.000001", Offset: 100}},
+		{t: time.Unix(150, 0), want: Position{Filename: "binlog.000002", Offset: 4}},
+		{t: time.Unix(215, 0), want: Position{Filename: "binlog.000002", Offset: 200}},
+		{t: time.Unix(300, 0), want: Position{Filename: "binlog.000003", Offset: 4}},
+		{t: time.Unix(400, 0), want: Position{Filename: "binlog.000003", Offset: 100}},
+	}
+	for _, v := range testCases {
+		files.dumps = 0
+		out, err := resolvePositionForTime(context.Background(), files.NewDumpConn, files.names(), v.t.Unix())
+		if err != nil {
+			t.Fatalf("resolvePositionForTime err: %v", err)
+		}
+		if out != v.want {
+			t.Fatalf("resolvePositionForTime t: %v want: %+v out: %+v", v.t.Unix(), v.want, out)
+		}
+		if files.dumps > 3 {
+			t.Fatalf("resolvePositionForTime dumps too many times: %v", files.dumps)
+		}
+	}
+}
+
+//getGTIDInputData 生成一个binlog文件的binlog event，每个GTID对应一个事务，
+//FORMAT_DESCRIPTION_EVENT的结束位置为100，第i个事务的开始位置为(i+1)*100
+func getGTIDInputData(filename string, sequences []int64) []replication.BinlogEvent {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+
+	s.LogPosition = 100
+	events := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, 4, filename),
+		replication.NewFormatDescriptionEvent(f, s),
+	}
+	for i, seq := range sequences {
+		start := uint32((i + 1) * 100)
+		s.LogPosition = start + 20
+		events = append(events, replication.NewMySQL56GTIDEvent(f, s,
+			replication.Mysql56GTID{Server: testStopSID, Sequence: seq}))
+		s.LogPosition = start + 40
+		events = append(events, replication.NewQueryEvent(f, s, replication.Query{
+			Database: "vt_test_keyspace",
+			SQL:      "BEGIN"}))
+		s.LogPosition = start + 100
+		events = append(events, replication.NewXIDEvent(f, s))
+	}
+	return events
+}
+
+func TestResolvePositionForGTID(t *testing.T) {
+	files := &mockBinlogFiles{
+		files: map[string][]replication.BinlogEvent{
+			"binlog.000001": getGTIDInputData("binlog.000001", []int64{1, 2}),
+			"binlog.000002": getGTIDInputData("binlog.000002", []int64{3, 4, 5}),
+			"binlog.000003": getGTIDInputData("binlog.000003", []int64{6}),
+		},
+	}
+	testCases := []struct {
+		sequence  int64
+		want      Position
+		wantDumps int
+		wantErr   bool
+	}{
+		{sequence: 6, want: Position{Filename: "binlog.000003", Offset: 100}, wantDumps: 1},
+		{sequence: 4, want: Position{Filename: "binlog.000002", Offset: 200}, wantDumps: 2},
+		{sequence: 1, want: Position{Filename: "binlog.000001", Offset: 100}, wantDumps: 3},
+		{sequence: 7, wantDumps: 3, wantErr: true},
+	}
+	for _, v := range testCases {
+		files.dumps = 0
+		gtid := replication.Mysql56GTID{Server: testStopSID, Sequence: v.sequence}
+		out, err := resolvePositionForGTID(context.Background(), files.NewDumpConn, files.names(), gtid)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("resolvePositionForGTID %v wantErr: %v err: %v", gtid, v.wantErr, err)
+		}
+		if out != v.want {
+			t.Fatalf("resolvePositionForGTID %v want: %+v out: %+v", gtid, v.want, out)
+		}
+		if files.dumps != v.wantDumps {
+			t.Fatalf("resolvePositionForGTID %v dumps want: %v out: %v", gtid, v.wantDumps, files.dumps)
+		}
+	}
+}
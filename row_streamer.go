@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/onlyac0611/binlog/dump"
 	"github.com/onlyac0611/binlog/replication"
@@ -20,14 +21,39 @@ type MysqlTableMapper interface {
 	MysqlTable(name MysqlTableName) (MysqlTable, error)
 }
 
+//MysqlTableInvalidator MysqlTableMapper可以选择实现的接口，RowStreamer在遇到DDL时调用，用于清除表信息的缓存，
+//name.TableName为空时代表name.DbName下的所有表
+type MysqlTableInvalidator interface {
+	InvalidateMysqlTable(name MysqlTableName)
+}
+
 //RowStreamer 从github.com/youtube/vitess/go/vt/binlog/binlog_streamer.go的基础上移植过来
 //专门用来RowStreamer解析row模式的binlog event，将其变为对应的事务
 type RowStreamer struct {
 	dsn             string
 	serverID        uint32
 	startPos        atomic.Value
+	nonBlock        bool
+	stop            *stopChecker
 	tableMapper     MysqlTableMapper
 	sendTransaction SendTransactionFunc
+	newDumpConn     func() (dumpConn, error)
+	newStatusConn   func() (sideConn, error)
+	metrics         MetricsHook
+	progress        atomic.Value
+	logger          StructuredLogger
+	connLogger      bool
+	decodeOptions   decodeOptions
+	chunkOptions    chunkOptions
+}
+
+//decodeOptions 列数据的解析选项
+type decodeOptions struct {
+	keepRawBytes     bool
+	location         *time.Location
+	zeroDatePolicy   ZeroDatePolicy
+	zeroDateSentinel time.Time
+	geometryFormat   GeometryFormat
 }
 
 //SendTransactionFunc 处理事务信息函数，你可以将一个chan注册到这个函数中如
@@ -39,18 +65,40 @@ type RowStreamer struct {
 type SendTransactionFunc func(*Transaction) error
 
 type tableCache struct {
-	tableMap *replication.TableMap
-	table    MysqlTable
+	tableMap   *replication.TableMap
+	table      MysqlTable
+	primaryKey []string
+	charsets   []string
+	enumValues [][]string
+	options    *decodeOptions
+}
+
+//location 格式化TIMESTAMP列使用的时区
+func (tc *tableCache) location() *time.Location {
+	if tc.options == nil || tc.options.location == nil {
+		return time.UTC
+	}
+	return tc.options.location
 }
 
 //NewRowStreamer dsn是mysql数据库的信息，serverID是标识该数据库的信息
 func NewRowStreamer(dsn string, serverID uint32,
 	tableMapper MysqlTableMapper) (*RowStreamer, error) {
-	return &RowStreamer{
+	s := &RowStreamer{
 		dsn:         dsn,
 		serverID:    serverID,
 		tableMapper: tableMapper,
-	}, nil
+		metrics:     nopMetricsHook{},
+		logger:      newGlobalLogger(),
+	}
+	s.decodeOptions.location = time.UTC
+	s.newDumpConn = func() (dumpConn, error) {
+		return dump.NewMysqlConn(s.dsn)
+	}
+	s.newStatusConn = func() (sideConn, error) {
+		return dump.NewMysqlConn(s.dsn)
+	}
+	return s, nil
 }
 
 //SetStartBinlogPosition 设置开始的binlog位置
@@ -59,31 +107,160 @@ func (s *RowStreamer) SetStartBinlogPosition(startPos Position) {
 }
 
 func (s *RowStreamer) startBinlogPosition() Position {
-	return s.startPos.Load().(Position)
+	pos, _ := s.startPos.Load().(Position)
+	return pos
+}
+
+//BinlogPosition 获取已经处理完的binlog位置，Stream结束后即为最终的binlog位置，
+//可以用于下一次SetStartBinlogPosition，Stream返回错误(如ctx取消或者SendTransactionFunc返回错误)时
+//也会更新为最后一个完整发送的事务的结束位置，从该位置重新开始不会丢失事务
+func (s *RowStreamer) BinlogPosition() Position {
+	return s.startBinlogPosition()
+}
+
+//SetNonBlock 设置是否以非阻塞的方式dump binlog，非阻塞时会发送BINLOG_DUMP_NON_BLOCK标志，
+//主库发送完当前所有的binlog后返回EOF包，Stream将其视为正常结束并返回nil，
+//适用于只同步到当前为止的binlog的场景
+func (s *RowStreamer) SetNonBlock(nonBlock bool) {
+	s.nonBlock = nonBlock
+}
+
+//SetEndBinlogPosition 设置结束的binlog位置，等同于设置StopCondition的Position并使用StopAsEnd，
+//事务的结束位置到达或者超过该位置时，处理完该事务后Stream正常结束并返回nil
+func (s *RowStreamer) SetEndBinlogPosition(endPos Position) {
+	cond := s.stopCondition()
+	cond.Position = endPos
+	cond.Mode = StopAsEnd
+	s.setStopChecker(cond)
+}
+
+//SetEndTimestamp 设置结束的时间戳(秒)，等同于设置StopCondition的Timestamp并使用StopAsEnd，
+//当事务的执行时间超过该时间戳时，该事务不会被处理，Stream正常结束并返回nil
+func (s *RowStreamer) SetEndTimestamp(timestamp int64) {
+	cond := s.stopCondition()
+	cond.Timestamp = timestamp
+	cond.Mode = StopAsEnd
+	s.setStopChecker(cond)
+}
+
+//SetStopCondition 设置停止条件，会替换SetEndBinlogPosition以及SetEndTimestamp设置的条件，
+//包含停止条件边界的事务都会被处理，之后Stream按照cond.Mode返回，GTID格式不正确时返回错误
+func (s *RowStreamer) SetStopCondition(cond StopCondition) error {
+	if cond.IsZero() {
+		s.stop = nil
+		return nil
+	}
+	stop, err := newStopChecker(cond)
+	if err != nil {
+		return err
+	}
+	s.stop = stop
+	return nil
+}
+
+//stopCondition 获取当前的停止条件
+func (s *RowStreamer) stopCondition() StopCondition {
+	if s.stop == nil {
+		return StopCondition{}
+	}
+	return s.stop.cond
+}
+
+//setStopChecker cond的GTID已经被SetStopCondition检查过，不会出错
+func (s *RowStreamer) setStopChecker(cond StopCondition) {
+	if err := s.SetStopCondition(cond); err != nil {
+		s.log().Error("setStopChecker fail", F("condition", cond), F("error", err))
+	}
+}
+
+//SetMetricsHook 设置收集运行指标的MetricsHook，如NewPrometheusMetrics，为nil时不收集指标
+func (s *RowStreamer) SetMetricsHook(metrics MetricsHook) {
+	s.metrics = metricsOrNop(metrics)
+}
+
+//SetKeepRawBytes 设置是否在ColumnData.Raw中保留字符类型列转为UTF-8之前的原始数据，默认不保留
+func (s *RowStreamer) SetKeepRawBytes(keep bool) {
+	s.decodeOptions.keepRawBytes = keep
+}
+
+//SetLocation 设置TIMESTAMP列以及Transaction.Location使用的时区，默认为UTC，为nil时也使用UTC，
+//需要与mysql的time_zone一致时可以使用time.LoadLocation加载对应的时区
+func (s *RowStreamer) SetLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	s.decodeOptions.location = loc
+}
+
+//SetLogger 设置该RowStreamer使用的日志，日志中会带有server id、binlog位置、表名以及binlog event类型等键值对，
+//同时用于该RowStreamer的dump连接，为nil时使用SetLogger设置的全局日志
+func (s *RowStreamer) SetLogger(logger StructuredLogger) {
+	if logger == nil {
+		s.logger, s.connLogger = newGlobalLogger(), false
+		return
+	}
+	s.logger, s.connLogger = logger, true
+}
+
+func (s *RowStreamer) log() StructuredLogger {
+	return s.logger.With(F(FieldServerID, s.serverID))
+}
+
+//dumpConnFunc 设置了SetLogger时dump连接也使用该日志
+func (s *RowStreamer) dumpConnFunc(logger StructuredLogger) func() (dumpConn, error) {
+	if !s.connLogger {
+		return s.newDumpConn
+	}
+	return func() (dumpConn, error) {
+		conn, err := s.newDumpConn()
+		if c, ok := conn.(interface {
+			SetLogger(dump.Logger)
+		}); ok && err == nil {
+			c.SetLogger(dumpLogger{logger: logger})
+		}
+		return conn, err
+	}
 }
 
 //Stream 注册一个处理事务信息函数到Stream中
 func (s *RowStreamer) Stream(ctx context.Context, sendTransaction SendTransactionFunc) error {
-	conn, err := newSlaveConn(func() (conn dumpConn, e error) {
-		return dump.NewMysqlConn(s.dsn)
-	})
+	logger := s.log()
+	conn, err := newSlaveConn(s.dumpConnFunc(logger), s.metrics, logger)
 	if err != nil {
 		return fmt.Errorf("newMysqlConn fail. err: %v", err)
 	}
 	defer conn.close()
 	s.sendTransaction = sendTransaction
+
+	var flags uint16
+	if s.nonBlock {
+		flags |= dump.BinlogDumpNonBlock
+	}
+
 	var events <-chan replication.BinlogEvent
 	var pos Position
-	events, err = conn.startDumpFromBinlogPosition(ctx, s.serverID, s.startBinlogPosition())
+	startPos := s.startBinlogPosition()
+	events, err = conn.startDumpFromBinlogPosition(ctx, s.serverID, startPos, flags)
 	if err != nil {
-		return fmt.Errorf("startDumpFromBinlogPosition fail in pos: %+v error: %v", s.startPos, err)
+		return fmt.Errorf("startDumpFromBinlogPosition fail in pos: %+v error: %v", startPos, err)
 	}
 
+	//pos是最后一个完整发送的事务的结束位置，出错时也可以从该位置重新开始
 	pos, err = s.parseEvents(ctx, events)
-	if err != nil {
-		return fmt.Errorf("parseEvents fail in pos: %+v error: %v", s.startPos, err)
-	}
 	s.SetStartBinlogPosition(pos)
+	switch {
+	case err == ErrStopConditionReached:
+		logger.Info("Stream reached stop condition", F(FieldPosition, pos))
+		if s.stopCondition().Mode == StopAsEnd {
+			return nil
+		}
+		return err
+	case err == ErrStreamEOF && s.nonBlock && conn.reachedEOF():
+		logger.Info("Stream reached EOF of non-blocking dump", F(FieldPosition, pos))
+		return nil
+	case err != nil:
+		return fmt.Errorf("parseEvents fail in pos: %+v error: %v", startPos, err)
+	}
 	return nil
 }
 
@@ -92,28 +269,93 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 	var format replication.BinlogFormat
 	var err error
 	pos := s.startBinlogPosition()
+	s.setProgressPosition(pos)
 	tablesMaps := make(map[uint64]*tableCache)
 	autocommit := true
+	var gtid replication.GTID
+	var chunk *ChunkInfo
+	var chunkBytes int64
+	logger := s.log()
 
 	begin := func() {
 		if tranEvents != nil {
 			// If this happened, it would be a legitimate error.
-			lw.logger().Errorf("parseEvents BEGIN in binlog stream while still in another transaction; dropping %d transactionEvents: %+v", len(tranEvents), tranEvents)
+			logger.Error("parseEvents BEGIN in binlog stream while still in another transaction; dropping transactionEvents",
+				F(FieldPosition, pos), F("dropped", len(tranEvents)), F("events", tranEvents))
 		}
 		tranEvents = make([]*StreamEvent, 0, 10)
 		autocommit = false
+		chunk, chunkBytes = nil, 0
+	}
+
+	send := func(now, next Position, ev replication.BinlogEvent, info *ChunkInfo) (*Transaction, error) {
+		tran := NewTransaction(now, next, int64(ev.Timestamp()), tranEvents)
+		tran.ServerID = ev.ServerID()
+		tran.Location = s.decodeOptions.location
+		tran.Chunk = info
+		if gtid != nil {
+			tran.GTID = gtid.String()
+		}
+		sendStart := time.Now()
+		if err := s.sendTransaction(tran); err != nil {
+			return nil, fmt.Errorf("parseEvents sendTransaction error: %v", err)
+		}
+		s.metrics.ObserveTransaction(tran, time.Since(sendStart))
+		return tran, nil
 	}
 
 	commit := func(ev replication.BinlogEvent) error {
 		now := pos
-		pos.Offset = ev.NextPosition()
+		//已经发送了部分块的事务必须发送完
+		if chunk == nil {
+			if s.stop.before(now, int64(ev.Timestamp()), gtid) {
+				return ErrStopConditionReached
+			}
+		} else {
+			chunk.Last = true
+		}
+		//发送成功后位置才前进，出错时pos仍然是该事务开始的位置
 		next := pos
-		tran := NewTransaction(now, next, int64(ev.Timestamp()), tranEvents)
-		if err = s.sendTransaction(tran); err != nil {
-			return fmt.Errorf("parseEvents sendTransaction error: %v", err)
+		next.Offset = ev.NextPosition()
+		tran, err := send(now, next, ev, chunk)
+		if err != nil {
+			return err
 		}
+		pos = next
+		s.metrics.ObservePosition(next, tran.Timestamp)
+		s.setProgress(next, tran.Timestamp)
 		tranEvents = nil
 		autocommit = true
+		chunk, chunkBytes = nil, 0
+		if s.stop.after(next, gtid) {
+			return ErrStopConditionReached
+		}
+		gtid = nil
+		return nil
+	}
+
+	//sendChunk 事务中缓存的数据到达分块的阈值时发送一块，位置停留在事务开始处，直到最后一块才前进
+	sendChunk := func(ev replication.BinlogEvent) error {
+		if !s.chunkOptions.enabled() {
+			return nil
+		}
+		chunkBytes += int64(len(ev.Bytes()))
+		if !s.chunkOptions.reached(len(tranEvents), chunkBytes) {
+			return nil
+		}
+		if chunk == nil {
+			if s.stop.before(pos, int64(ev.Timestamp()), gtid) {
+				return ErrStopConditionReached
+			}
+			chunk = newChunkInfo(pos, gtid)
+		}
+		info := *chunk
+		if _, err := send(pos, pos, ev, &info); err != nil {
+			return err
+		}
+		chunk.Sequence++
+		tranEvents = make([]*StreamEvent, 0, 10)
+		chunkBytes = 0
 		return nil
 	}
 
@@ -123,11 +365,12 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 		select {
 		case ev, ok = <-events:
 			if !ok {
-				lw.logger().Infof("parseEvents reached end of binlog event stream")
+				logger.Info("parseEvents reached end of binlog event stream", F(FieldPosition, pos))
 				return pos, ErrStreamEOF
 			}
 		case <-ctx.Done():
-			lw.logger().Infof("parseEvents stopping early due to binlog Streamer service shutdown or client disconnect")
+			logger.Info("parseEvents stopping early due to binlog Streamer service shutdown or client disconnect",
+				F(FieldPosition, pos))
 			return pos, ctx.Err()
 		}
 
@@ -135,6 +378,7 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 		if !ev.IsValid() {
 			return pos, fmt.Errorf("parseEvents can't parse binlog event, invalid data: %+v", ev)
 		}
+		start := time.Now()
 
 		// We need to keep checking for FORMAT_DESCRIPTION_EVENT even after we've
 		// seen one, because another one might come along (e.g. on lw.logger() rotate due to
@@ -144,7 +388,9 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 			if err != nil {
 				return pos, fmt.Errorf("parseEvents can't parse FORMAT_DESCRIPTION_EVENT: %v, event data: %+v", err, ev)
 			}
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a format description event:%+v", ev.NextPosition(), format)
+			logger.Debug("parseEvents binlog event is a format description event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeFormatDescription), F("format", format))
+			s.metrics.ObserveEvent(EventTypeFormatDescription, time.Since(start))
 			continue
 		}
 
@@ -155,6 +401,7 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 			// is a fake ROTATE_EVENT, which the master sends to tell us the name
 			// of the current lw.logger() file.
 			if ev.IsRotate() {
+				s.metrics.ObserveEvent(EventTypeRotate, time.Since(start))
 				continue
 			}
 			return pos, fmt.Errorf("parseEvents got a real event before FORMAT_DESCRIPTION_EVENT: %+v", ev)
@@ -168,13 +415,16 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 
 		switch {
 		case ev.IsXID(): // XID_EVENT (equivalent to COMMIT)
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a xid event: %v:", pos, ev)
+			logger.Debug("parseEvents binlog event is a xid event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeXID))
+			s.metrics.ObserveEvent(EventTypeXID, time.Since(start))
 			if err = commit(ev); err != nil {
 				return pos, err
 			}
 
 		case ev.IsRotate():
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a xid event %v:", pos, ev)
+			logger.Debug("parseEvents binlog event is a rotate event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeRotate))
 			var filename string
 			var offset int64
 			if filename, offset, err = ev.Rotate(format); err != nil {
@@ -182,29 +432,60 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 			}
 			pos.Filename = filename
 			pos.Offset = offset
+			s.metrics.ObserveEvent(EventTypeRotate, time.Since(start))
+			s.metrics.ObservePosition(pos, int64(ev.Timestamp()))
+			s.setProgressPosition(pos)
+			if s.stop.passed(pos) {
+				return pos, ErrStopConditionReached
+			}
 		case ev.IsQuery():
 			q, err := ev.Query(format)
 			if err != nil {
 				return pos, fmt.Errorf("parseEvents can't get query from binlog event: %v, event data: %+v", err, ev)
 			}
 			typ := GetStatementCategory(q.SQL)
+			s.metrics.ObserveEvent(EventTypeQuery, time.Since(start))
 
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a query event: %+v query: %v", pos, ev, q.SQL)
+			logger.Debug("parseEvents binlog event is a query event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeQuery), F("query", q.SQL))
 
 			switch typ {
 			case StatementBegin:
 				begin()
 			case StatementRollback:
 				tranEvents = nil
+				if chunk != nil {
+					chunk.Rollback = true
+				}
 				fallthrough
 			case StatementCommit:
 				if err = commit(ev); err != nil {
 					return pos, err
 				}
 			default:
-				lw.logger().Errorf("parseEvents we have a sql in binlog position: %+v error: %v", pos,
-					fmt.Errorf("parseEvents SQL query %s  statement in row binlog SQL: %s", typ.String(), q.SQL))
+				if typ.IsDDL() {
+					names := ddlTableNames(q.Database, q.SQL)
+					logger.Info("parseEvents DDL in binlog, invalidate table cache", F(FieldPosition, pos),
+						F(FieldEventType, EventTypeQuery), F("query", q.SQL), F("tables", names))
+					s.invalidateTables(tablesMaps, names)
+				} else {
+					logger.Error("parseEvents we have a sql in row binlog", F(FieldPosition, pos),
+						F(FieldEventType, EventTypeQuery), F("statement", typ.String()), F("query", q.SQL))
+				}
 				//return pos, fmt.Errorf("parseEvents SQL query %s  statement in row binlog SQL: %s", typ.String(), q.SQL)
+				//事务外的语句已经处理完，只前进进度位置，断点位置仍然停在最后发送的事务
+				//DDL自带GTID，同样要检查结束条件，并且GTID不能带到下一个事务
+				if tranEvents == nil {
+					next := pos
+					if ev.NextPosition() > 0 {
+						next.Offset = ev.NextPosition()
+						s.setProgressPosition(next)
+					}
+					if s.stop.after(next, gtid) {
+						return pos, ErrStopConditionReached
+					}
+					gtid = nil
+				}
 			}
 
 		case ev.IsTableMap():
@@ -214,16 +495,21 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 			if err != nil {
 				return pos, err
 			}
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a table map event, tableID: %v table map: %+v",
-				pos, tableID, *tm)
+			logger.Debug("parseEvents binlog event is a table map event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeTableMap), F(FieldTable, NewMysqlTableName(tm.Database, tm.Name)),
+				F("table_id", tableID))
 
 			if _, ok = tablesMaps[tableID]; ok {
 				tablesMaps[tableID].tableMap = tm
+				tablesMaps[tableID].charsets = columnCharsets(tm, tablesMaps[tableID].table)
+				tablesMaps[tableID].enumValues = columnEnumValues(tm, tablesMaps[tableID].table)
+				s.metrics.ObserveEvent(EventTypeTableMap, time.Since(start))
 				continue
 			}
 
 			tc := &tableCache{
 				tableMap: tm,
+				options:  &s.decodeOptions,
 			}
 
 			name := NewMysqlTableName(tm.Database, tm.Name)
@@ -234,13 +520,18 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 			}
 
 			if len(info.Columns()) != tm.CanBeNull.Count() {
-				return Position{},
+				return pos,
 					fmt.Errorf("parseEvents the length of column in tableMap(%d) "+
 						"did not equal to the length of column in table info(%d)", tm.CanBeNull.Count(),
 						len(info.Columns()))
 			}
 			tc.table = info
+			tc.primaryKey = TablePrimaryKey(info)
+			tc.charsets = columnCharsets(tm, info)
+			tc.enumValues = columnEnumValues(tm, info)
 			tablesMaps[tableID] = tc
+			s.metrics.ObserveEvent(EventTypeTableMap, time.Since(start))
+			s.metrics.ObserveTableCache(len(tablesMaps))
 
 		case ev.IsWriteRows():
 			tableID := ev.TableID(format)
@@ -248,25 +539,28 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 			if !ok {
 				return pos, fmt.Errorf("parseEvents unknown tableID %v in WriteRows event", tableID)
 			}
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a write rows event, tableID: %v tc.tableMap: %+v",
-				pos, tableID, tc.tableMap)
+			logger.Debug("parseEvents binlog event is a write rows event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeWriteRows), F(FieldTable, tc.table.Name()), F("table_id", tableID))
 			rows, err := ev.Rows(format, tc.tableMap)
 			if err != nil {
 				return pos, err
 			}
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a write rows event, tableID: %v rows: %+v",
-				pos, tableID, rows)
+			logger.Debug("parseEvents decoded rows", F(FieldPosition, pos), F(FieldEventType, EventTypeWriteRows),
+				F(FieldTable, tc.table.Name()), F("rows", len(rows.Rows)))
 
 			tranEvent, err := appendInsertEventFromRows(tc, &rows, int64(ev.Timestamp()))
 			if err != nil {
 				return pos, err
 			}
+			s.metrics.ObserveEvent(EventTypeWriteRows, time.Since(start))
 
 			tranEvents = append(tranEvents, tranEvent)
 			if autocommit {
 				if err = commit(ev); err != nil {
 					return pos, err
 				}
+			} else if err = sendChunk(ev); err != nil {
+				return pos, err
 			}
 
 		case ev.IsUpdateRows():
@@ -275,25 +569,28 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 			if !ok {
 				return pos, fmt.Errorf("parseEvents unknown tableID %v in UpdateRows event", tableID)
 			}
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a update rows event, tableID: %v tc.tableMap: %+v",
-				pos, tableID, tc.tableMap)
+			logger.Debug("parseEvents binlog event is a update rows event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeUpdateRows), F(FieldTable, tc.table.Name()), F("table_id", tableID))
 			rows, err := ev.Rows(format, tc.tableMap)
 			if err != nil {
 				return pos, err
 			}
 
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a update rows event, tableID: %v rows: %+v",
-				pos, tableID, rows)
+			logger.Debug("parseEvents decoded rows", F(FieldPosition, pos), F(FieldEventType, EventTypeUpdateRows),
+				F(FieldTable, tc.table.Name()), F("rows", len(rows.Rows)))
 
 			tranEvent, err := appendUpdateEventFromRows(tc, &rows, int64(ev.Timestamp()))
 			if err != nil {
 				return pos, err
 			}
+			s.metrics.ObserveEvent(EventTypeUpdateRows, time.Since(start))
 			tranEvents = append(tranEvents, tranEvent)
 			if autocommit {
 				if err = commit(ev); err != nil {
 					return pos, err
 				}
+			} else if err = sendChunk(ev); err != nil {
+				return pos, err
 			}
 		case ev.IsDeleteRows():
 			tableID := ev.TableID(format)
@@ -302,31 +599,45 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 				return pos, fmt.Errorf("parseEvents unknown tableID %v in DeleteRows event", tableID)
 			}
 
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a delete rows event, tableID: %v tc.tableMap: %+v",
-				pos, tableID, tc.tableMap)
+			logger.Debug("parseEvents binlog event is a delete rows event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeDeleteRows), F(FieldTable, tc.table.Name()), F("table_id", tableID))
 
 			rows, err := ev.Rows(format, tc.tableMap)
 			if err != nil {
 				return pos, err
 			}
 
-			lw.logger().Debugf("parseEvents pos: %+v", "binlog event is a delete rows event, tableID: %v rows: %+v",
-				pos, tableID, rows)
+			logger.Debug("parseEvents decoded rows", F(FieldPosition, pos), F(FieldEventType, EventTypeDeleteRows),
+				F(FieldTable, tc.table.Name()), F("rows", len(rows.Rows)))
 			tranEvent, err := appendDeleteEventFromRows(tc, &rows, int64(ev.Timestamp()))
 			if err != nil {
 				return pos, err
 			}
+			s.metrics.ObserveEvent(EventTypeDeleteRows, time.Since(start))
 
 			tranEvents = append(tranEvents, tranEvent)
 			if autocommit {
 				if err = commit(ev); err != nil {
 					return pos, err
 				}
+			} else if err = sendChunk(ev); err != nil {
+				return pos, err
 			}
 		case ev.IsPreviousGTIDs():
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a PreviousGTIDs event: %+v", pos, ev)
+			logger.Debug("parseEvents binlog event is a PreviousGTIDs event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypePreviousGTIDs))
+			s.metrics.ObserveEvent(EventTypePreviousGTIDs, time.Since(start))
 		case ev.IsGTID():
-			lw.logger().Debugf("parseEvents pos: %+v binlog event is a GTID event: %+v", pos, ev)
+			logger.Debug("parseEvents binlog event is a GTID event", F(FieldPosition, pos),
+				F(FieldEventType, EventTypeGTID))
+			var hasBegin bool
+			if gtid, hasBegin, err = ev.GTID(format); err != nil {
+				return pos, fmt.Errorf("parseEvents can't get GTID from binlog event: %v, event data: %+v", err, ev)
+			}
+			s.metrics.ObserveEvent(EventTypeGTID, time.Since(start))
+			if hasBegin {
+				begin()
+			}
 
 		case ev.IsRand():
 			//todo deal with the Rand error
@@ -337,13 +648,33 @@ func (s *RowStreamer) parseEvents(ctx context.Context, events <-chan replication
 		case ev.IsRowsQuery():
 			//todo deal with the RowsQuery error
 			return pos, fmt.Errorf("binlog event is a RowsQuery event: %+v", ev)
+		default:
+			s.metrics.ObserveEvent(EventTypeOther, time.Since(start))
 		}
 
 	}
 }
 
+//invalidateTables DDL之后清除表信息的缓存，之后的TABLE_MAP_EVENT会重新获取表信息
+func (s *RowStreamer) invalidateTables(tablesMaps map[uint64]*tableCache, names []MysqlTableName) {
+	invalidator, _ := s.tableMapper.(MysqlTableInvalidator)
+	for _, name := range names {
+		if invalidator != nil {
+			invalidator.InvalidateMysqlTable(name)
+		}
+		for tableID, tc := range tablesMaps {
+			cached := tc.table.Name()
+			if cached == name || (name.TableName == "" && cached.DbName == name.DbName) {
+				delete(tablesMaps, tableID)
+			}
+		}
+	}
+	s.metrics.ObserveTableCache(len(tablesMaps))
+}
+
 func appendUpdateEventFromRows(tc *tableCache, rows *replication.Rows, timestamp int64) (*StreamEvent, error) {
 	ev := NewStreamEvent(StatementUpdate, timestamp, tc.table.Name())
+	ev.PrimaryKey = tc.primaryKey
 	for i := range rows.Rows {
 		identifies, err := getIdentifiesFromRow(tc, rows, i)
 		if err != nil {
@@ -363,6 +694,7 @@ func appendUpdateEventFromRows(tc *tableCache, rows *replication.Rows, timestamp
 
 func appendInsertEventFromRows(tc *tableCache, rows *replication.Rows, timestamp int64) (*StreamEvent, error) {
 	ev := NewStreamEvent(StatementInsert, timestamp, tc.table.Name())
+	ev.PrimaryKey = tc.primaryKey
 	for i := range rows.Rows {
 		values, err := getValuesFromRow(tc, rows, i)
 		if err != nil {
@@ -375,6 +707,7 @@ func appendInsertEventFromRows(tc *tableCache, rows *replication.Rows, timestamp
 
 func appendDeleteEventFromRows(tc *tableCache, rows *replication.Rows, timestamp int64) (*StreamEvent, error) {
 	ev := NewStreamEvent(StatementDelete, timestamp, tc.table.Name())
+	ev.PrimaryKey = tc.primaryKey
 	for i := range rows.Rows {
 		identifies, err := getIdentifiesFromRow(tc, rows, i)
 		if err != nil {
@@ -416,12 +749,24 @@ func getValuesFromRow(tc *tableCache, rs *replication.Rows, rowIndex int) (*RowD
 		var l int
 		var err error
 
-		column.Data, l, err = replication.CellBytes(data, pos, tc.tableMap.Types[c], tc.tableMap.Metadata[c],
-			tc.table.Columns()[c].IsUnSignedInt())
+		column.Data, l, err = replication.CellBytesInLocation(data, pos, tc.tableMap.Types[c],
+			tc.tableMap.Metadata[c], tc.table.Columns()[c].IsUnSignedInt(), tc.location())
 
 		if err != nil {
 			return nil, err
 		}
+		if err = tc.decodeString(c, column); err != nil {
+			return nil, err
+		}
+		if err = tc.decodeEnum(c, column); err != nil {
+			return nil, err
+		}
+		if err = tc.checkZeroDate(column); err != nil {
+			return nil, err
+		}
+		if err = tc.decodeGeometry(column); err != nil {
+			return nil, err
+		}
 
 		values.Columns = append(values.Columns, column)
 
@@ -461,11 +806,23 @@ func getIdentifiesFromRow(tc *tableCache, rs *replication.Rows, rowIndex int) (*
 		var l int
 		var err error
 
-		column.Data, l, err = replication.CellBytes(data, pos, tc.tableMap.Types[c], tc.tableMap.Metadata[c],
-			tc.table.Columns()[c].IsUnSignedInt())
+		column.Data, l, err = replication.CellBytesInLocation(data, pos, tc.tableMap.Types[c],
+			tc.tableMap.Metadata[c], tc.table.Columns()[c].IsUnSignedInt(), tc.location())
 		if err != nil {
 			return nil, err
 		}
+		if err = tc.decodeString(c, column); err != nil {
+			return nil, err
+		}
+		if err = tc.decodeEnum(c, column); err != nil {
+			return nil, err
+		}
+		if err = tc.checkZeroDate(column); err != nil {
+			return nil, err
+		}
+		if err = tc.decodeGeometry(column); err != nil {
+			return nil, err
+		}
 
 		identifies.Columns = append(identifies.Columns, column)
 
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"testing"
+	"time"
 
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/fakemaster"
 	"github.com/onlyac0611/binlog/replication"
 )
 
@@ -184,6 +188,9 @@ func checkStreamEventEqual(s *StreamEvent, right *StreamEvent) error {
 	if s.SQL != right.SQL {
 		return fmt.Errorf("sql is not equal. left: %v, right: %v", s.SQL, right.SQL)
 	}
+	if fmt.Sprint(s.PrimaryKey) != fmt.Sprint(right.PrimaryKey) {
+		return fmt.Errorf("primary key is not equal. left: %v, right: %v", s.PrimaryKey, right.PrimaryKey)
+	}
 
 	if len(s.RowValues) != len(right.RowValues) {
 		return fmt.Errorf("len of RowValues is not match.left: %v right: %v",
@@ -252,10 +259,11 @@ func TestRowStreamer_parseEvents(t *testing.T) {
 		},
 		Events: []*StreamEvent{
 			{
-				Type:      StatementInsert,
-				Timestamp: 1407805592,
-				Table:     tesInfo.name,
-				SQL:       "",
+				Type:       StatementInsert,
+				PrimaryKey: []string{"id"},
+				Timestamp:  1407805592,
+				Table:      tesInfo.name,
+				SQL:        "",
 				RowValues: []*RowData{
 					{
 						Columns: []*ColumnData{
@@ -274,9 +282,10 @@ func TestRowStreamer_parseEvents(t *testing.T) {
 				},
 			},
 			{
-				Type:      StatementUpdate,
-				Table:     tesInfo.name,
-				Timestamp: 1407805592,
+				Type:       StatementUpdate,
+				PrimaryKey: []string{"id"},
+				Table:      tesInfo.name,
+				Timestamp:  1407805592,
 				RowIdentifies: []*RowData{
 					{
 						Columns: []*ColumnData{
@@ -311,9 +320,10 @@ func TestRowStreamer_parseEvents(t *testing.T) {
 				},
 			},
 			{
-				Type:      StatementDelete,
-				Timestamp: 1407805592,
-				Table:     tesInfo.name,
+				Type:       StatementDelete,
+				PrimaryKey: []string{"id"},
+				Timestamp:  1407805592,
+				Table:      tesInfo.name,
 				RowIdentifies: []*RowData{
 					{
 						Columns: []*ColumnData{
@@ -382,3 +392,313 @@ func TestRowStreamer_SetStartBinlogPosition(t *testing.T) {
 		t.Fatalf("want != out, input:%+v want:%+v out %+v", testBinlogPosParseEvents, testBinlogPosParseEvents, r.startPos)
 	}
 }
+
+type mockPacketConn struct {
+	packets [][]byte
+	flags   uint16
+}
+
+func newMockPacketConn(events []replication.BinlogEvent) *mockPacketConn {
+	m := &mockPacketConn{}
+	for _, ev := range events {
+		m.packets = append(m.packets, append([]byte{dump.PacketOK}, ev.Bytes()...))
+	}
+	return m
+}
+
+func (m *mockPacketConn) Close() error {
+	return nil
+}
+
+func (m *mockPacketConn) Exec(_ string) error {
+	return nil
+}
+
+func (m *mockPacketConn) NoticeDump(_ uint32, _ uint32, _ string, flags uint16) error {
+	m.flags = flags
+	return nil
+}
+
+func (m *mockPacketConn) ReadPacket() ([]byte, error) {
+	if len(m.packets) == 0 {
+		return nil, io.EOF
+	}
+	buf := m.packets[0]
+	m.packets = m.packets[1:]
+	return buf, nil
+}
+
+func (m *mockPacketConn) HandleErrorPacket(data []byte) error {
+	return fmt.Errorf("%v", string(data))
+}
+
+func TestRowStreamer_Stream_NonBlock(t *testing.T) {
+	testCases := []struct {
+		nonBlock  bool
+		wantFlags uint16
+		wantErr   bool
+	}{
+		{
+			nonBlock:  true,
+			wantFlags: dump.BinlogDumpNonBlock,
+			wantErr:   false,
+		},
+		{
+			nonBlock:  false,
+			wantFlags: 0,
+			wantErr:   true,
+		},
+	}
+
+	for _, v := range testCases {
+		conn := newMockPacketConn(getInputData())
+		conn.packets = append(conn.packets, []byte{dump.PacketEOF})
+
+		r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.newDumpConn = func() (dumpConn, error) {
+			return conn, nil
+		}
+		r.SetStartBinlogPosition(testBinlogPosParseEvents)
+		r.SetNonBlock(v.nonBlock)
+
+		cnt := 0
+		err = r.Stream(context.Background(), func(tran *Transaction) error {
+			cnt++
+			return nil
+		})
+		if (err != nil) != v.wantErr {
+			t.Fatalf("Stream nonBlock: %v wantErr: %v err: %v", v.nonBlock, v.wantErr, err)
+		}
+		if conn.flags != v.wantFlags {
+			t.Fatalf("NoticeDump flags want: %v out: %v", v.wantFlags, conn.flags)
+		}
+		if cnt != 1 {
+			t.Fatalf("transaction count want: 1 out: %v", cnt)
+		}
+		want := Position{Filename: testBinlogPosParseEvents.Filename, Offset: 4}
+		if out := r.BinlogPosition(); out != want {
+			t.Fatalf("BinlogPosition want: %+v out: %+v", want, out)
+		}
+	}
+}
+
+func TestRowStreamer_parseEvents_End(t *testing.T) {
+	testCases := []struct {
+		endPos       Position
+		endTimestamp int64
+		wantErr      error
+		wantCnt      int
+	}{
+		{
+			endTimestamp: 1407805591,
+			wantErr:      ErrStopConditionReached,
+			wantCnt:      0,
+		},
+		{
+			endTimestamp: 1407805592,
+			wantErr:      ErrStreamEOF,
+			wantCnt:      1,
+		},
+		{
+			endPos:  Position{Filename: "binlog.000004", Offset: 4},
+			wantErr: ErrStopConditionReached,
+			wantCnt: 0,
+		},
+		{
+			endPos:  Position{Filename: testBinlogPosParseEvents.Filename, Offset: 4},
+			wantErr: ErrStopConditionReached,
+			wantCnt: 1,
+		},
+	}
+
+	for _, v := range testCases {
+		r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.SetStartBinlogPosition(testBinlogPosParseEvents)
+		r.SetEndBinlogPosition(v.endPos)
+		r.SetEndTimestamp(v.endTimestamp)
+
+		cnt := 0
+		r.sendTransaction = func(tran *Transaction) error {
+			cnt++
+			return nil
+		}
+
+		input := getInputData()
+		events := make(chan replication.BinlogEvent, len(input))
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+
+		_, err = r.parseEvents(context.Background(), events)
+		if err != v.wantErr {
+			t.Fatalf("parseEvents want err: %v, out: %v", v.wantErr, err)
+		}
+		if cnt != v.wantCnt {
+			t.Fatalf("transaction count want: %v out: %v", v.wantCnt, cnt)
+		}
+		if mode := r.stopCondition().Mode; mode != StopAsEnd {
+			t.Fatalf("stop mode want: %v out: %v", StopAsEnd, mode)
+		}
+	}
+}
+
+func TestRowStreamer_Stream_FakeMaster(t *testing.T) {
+	m, err := fakemaster.NewMaster("127.0.0.1:0", "root", "123456")
+	if err != nil {
+		t.Fatalf("NewMaster err: %v", err)
+	}
+	defer m.Close()
+	//去掉getInputData中的ROTATE_EVENT以及FORMAT_DESCRIPTION_EVENT，由主库发送
+	m.AppendEvents(getInputData()[2:]...)
+	filename, end := m.Position()
+
+	r, err := NewRowStreamer(m.DSN(), testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewRowStreamer err: %v", err)
+	}
+	r.SetStartBinlogPosition(Position{Filename: filename, Offset: 4})
+	r.SetNonBlock(true)
+
+	var trans []*Transaction
+	err = r.Stream(context.Background(), func(tran *Transaction) error {
+		trans = append(trans, tran)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream err: %v", err)
+	}
+	if len(trans) != 1 || len(trans[0].Events) != 3 {
+		t.Fatalf("Stream want 1 transaction with 3 events, out: %+v", trans)
+	}
+	want := Position{Filename: filename, Offset: end}
+	if out := r.BinlogPosition(); out != want {
+		t.Fatalf("BinlogPosition want: %+v out: %+v", want, out)
+	}
+}
+
+func TestRowStreamer_Stream_End(t *testing.T) {
+	m, err := fakemaster.NewMaster("127.0.0.1:0", "root", "123456")
+	if err != nil {
+		t.Fatalf("NewMaster err: %v", err)
+	}
+	defer m.Close()
+	m.AppendEvents(getInputData()[2:]...)
+	filename, end := m.Position()
+	start := Position{Filename: filename, Offset: 4}
+
+	testCases := []struct {
+		sendErr error
+		wantErr bool
+		wantPos Position
+	}{
+		//阻塞模式下到达结束位置时正常结束
+		{wantPos: Position{Filename: filename, Offset: end}},
+		//出错时位置停留在最后一个完整发送的事务
+		{sendErr: fmt.Errorf("send fail"), wantErr: true, wantPos: start},
+	}
+	for _, v := range testCases {
+		r, err := NewRowStreamer(m.DSN(), testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.SetStartBinlogPosition(start)
+		r.SetEndBinlogPosition(Position{Filename: filename, Offset: end})
+		err = r.Stream(context.Background(), func(tran *Transaction) error {
+			return v.sendErr
+		})
+		if (err != nil) != v.wantErr {
+			t.Fatalf("Stream wantErr: %v err: %v", v.wantErr, err)
+		}
+		if out := r.BinlogPosition(); out != v.wantPos {
+			t.Fatalf("BinlogPosition want: %+v out: %+v", v.wantPos, out)
+		}
+	}
+}
+
+func TestRowStreamer_SetLocation(t *testing.T) {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	tableID := uint64(0x102030405060)
+	tm := &replication.TableMap{
+		Database:  "vt_test_keyspace",
+		Name:      "vt_a",
+		Types:     []byte{replication.TypeLong, replication.TypeTimestamp2},
+		CanBeNull: replication.NewServerBitmap(2),
+		Metadata:  []uint16{0, 0},
+	}
+	insertRows := replication.Rows{
+		DataColumns: replication.NewServerBitmap(2),
+		Rows: []replication.Row{
+			{
+				NullColumns: replication.NewServerBitmap(2),
+				// 0x58d137c5 = 2017-03-21 14:25:09 UTC
+				Data: []byte{0x01, 0x00, 0x00, 0x00, 0x58, 0xd1, 0x37, 0xc5},
+			},
+		},
+	}
+	insertRows.DataColumns.Set(0, true)
+	insertRows.DataColumns.Set(1, true)
+	input := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, uint64(testBinlogPosParseEvents.Offset), testBinlogPosParseEvents.Filename),
+		replication.NewFormatDescriptionEvent(f, s),
+		replication.NewTableMapEvent(f, s, tableID, tm),
+		replication.NewQueryEvent(f, s, replication.Query{Database: "vt_test_keyspace", SQL: "BEGIN"}),
+		replication.NewWriteRowsEvent(f, s, tableID, insertRows),
+		replication.NewXIDEvent(f, s),
+	}
+	m := &staticMapper{
+		table: &mysqlTableInfo{
+			name: NewMysqlTableName("vt_test_keyspace", "vt_a"),
+			columns: []MysqlColumn{
+				&mysqlColumnAttribute{field: "id"},
+				&mysqlColumnAttribute{field: "created"},
+			},
+		},
+	}
+
+	utc8 := time.FixedZone("UTC+8", 8*3600)
+	testCases := []struct {
+		loc     *time.Location
+		set     bool
+		want    string
+		wantLoc *time.Location
+	}{
+		{want: "2017-03-21 14:25:09", wantLoc: time.UTC},
+		{loc: utc8, set: true, want: "2017-03-21 22:25:09", wantLoc: utc8},
+		{loc: nil, set: true, want: "2017-03-21 14:25:09", wantLoc: time.UTC},
+	}
+	for _, v := range testCases {
+		r, err := NewRowStreamer(testDSN, testServerID, m)
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.SetStartBinlogPosition(testBinlogPosParseEvents)
+		if v.set {
+			r.SetLocation(v.loc)
+		}
+		var out *Transaction
+		r.sendTransaction = func(tran *Transaction) error {
+			out = tran
+			return nil
+		}
+
+		events := make(chan replication.BinlogEvent, len(input))
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+		if _, err = r.parseEvents(context.Background(), events); err != ErrStreamEOF {
+			t.Fatalf("parseEvents err != %v, err: %v", ErrStreamEOF, err)
+		}
+		if c := out.Events[0].RowValues[0].Columns[1]; string(c.Data) != v.want || out.Location != v.wantLoc {
+			t.Fatalf("SetLocation(%v) want: %v %v out: %s %v", v.loc, v.want, v.wantLoc, c.Data, out.Location)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/onlyac0611/binlog/dump"
 	"github.com/onlyac0611/binlog/replication"
@@ -17,6 +18,12 @@ type dumpConn interface {
 	HandleErrorPacket([]byte) error
 }
 
+//readInterrupter dumpConn可以选择实现的接口，返回的函数用于在其他goroutine中中断阻塞的ReadPacket，
+//dump.MysqlConn实现了该接口，没有实现时close会等待ReadPacket返回
+type readInterrupter interface {
+	ReadInterrupter() func()
+}
+
 // slaveConn 从github.com/youtube/vitess/go/vt/mysqlctl/slave_connection.go的基础上移植过来
 // slaveConn通过StartDumpFromBinlogPosition和mysql库进行binlog dump，将自己伪装成slave，
 // 先执行SET @master_binlog_checksum=@@global.binlog_checksum，然后发送 binlog dump包，
@@ -24,33 +31,56 @@ type dumpConn interface {
 type slaveConn struct {
 	dc          dumpConn
 	cancel      context.CancelFunc
+	interrupt   func()
+	readDone    chan struct{} //读取binlog的goroutine退出时关闭，之后才能关闭dc
 	destruction sync.Once
+	eof         int32 //是否收到了主库的EOF包
+	metrics     MetricsHook
+	logger      StructuredLogger
 }
 
-func newSlaveConn(conn func() (dumpConn, error)) (*slaveConn, error) {
+//newSlaveConn metrics为nil时不收集指标，logger为nil时使用SetLogger设置的全局日志
+func newSlaveConn(conn func() (dumpConn, error), metrics MetricsHook, logger StructuredLogger) (*slaveConn, error) {
+	metrics = metricsOrNop(metrics)
+	if logger == nil {
+		logger = newGlobalLogger()
+	}
 	m, err := conn()
 	if err != nil {
+		metrics.ObserveConnect(err)
 		return nil, err
 	}
 
 	s := &slaveConn{
-		dc: m,
+		dc:      m,
+		metrics: metrics,
+		logger:  logger,
 	}
 
 	if err := s.prepareForReplication(); err != nil {
+		metrics.ObserveConnect(err)
 		s.close()
 		return nil, err
 	}
+	metrics.ObserveConnect(nil)
 
 	return s, nil
 }
 
+//close dc只能由一个goroutine关闭，先中断读取binlog的goroutine并等待其退出，再关闭dc
 func (s *slaveConn) close() {
 	s.destruction.Do(
 		func() {
+			if s.readDone != nil {
+				s.cancel()
+				if s.interrupt != nil {
+					s.interrupt()
+				}
+				<-s.readDone
+			}
 			if s.dc != nil {
 				s.dc.Close()
-				lw.logger().Infof("Close closing slave socket to unblock reads")
+				s.logger.Info("Close closing slave socket")
 			}
 		})
 }
@@ -63,12 +93,18 @@ func (s *slaveConn) prepareForReplication() error {
 	return nil
 }
 
+//reachedEOF 主库是否发送了EOF包，在非阻塞dump时代表所有binlog已经发送完毕
+func (s *slaveConn) reachedEOF() bool {
+	return atomic.LoadInt32(&s.eof) == 1
+}
+
 func (s *slaveConn) startDumpFromBinlogPosition(ctx context.Context, serverID uint32,
-	pos Position) (<-chan replication.BinlogEvent, error) {
+	pos Position, flags uint16) (<-chan replication.BinlogEvent, error) {
 	ctx, s.cancel = context.WithCancel(ctx)
 
-	lw.logger().Infof("startDumpFromBinlogPosition sending binlog dump command: startPos: %+v slaveID: %v", pos, serverID)
-	if err := s.dc.NoticeDump(serverID, uint32(pos.Offset), pos.Filename, 0); err != nil {
+	s.logger.Info("startDumpFromBinlogPosition sending binlog dump command", F(FieldPosition, pos),
+		F("slave_id", serverID), F("flags", flags))
+	if err := s.dc.NoticeDump(serverID, uint32(pos.Offset), pos.Filename, flags); err != nil {
 		return nil, fmt.Errorf("noticeDump fail. err: %v", err)
 	}
 
@@ -76,36 +112,45 @@ func (s *slaveConn) startDumpFromBinlogPosition(ctx context.Context, serverID ui
 	if err != nil {
 		return nil, fmt.Errorf("readPacket fail. err: %v", err)
 	}
+	s.metrics.ObserveBytes(len(buf))
+
+	if ri, ok := s.dc.(readInterrupter); ok {
+		s.interrupt = ri.ReadInterrupter()
+	}
+	s.readDone = make(chan struct{})
 
 	// FIXME(xd.fang) I think we can use a buffered channel for better performance.
 	eventChan := make(chan replication.BinlogEvent)
 
 	go func() {
+		defer close(s.readDone)
 		defer close(eventChan)
 
 		for {
 			switch buf[0] {
 			case dump.PacketEOF:
-				lw.logger().Infof("startDumpFromBinlogPosition received EOF packet in binlog dump: %+v", buf)
+				s.logger.Info("startDumpFromBinlogPosition received EOF packet in binlog dump", F("packet", buf))
+				atomic.StoreInt32(&s.eof, 1)
 				return
 			case dump.PacketERR:
 				err := s.dc.HandleErrorPacket(buf)
-				lw.logger().Errorf("startDumpFromBinlogPosition received error packet in binlog dump. error: %v", err)
+				s.logger.Error("startDumpFromBinlogPosition received error packet in binlog dump", F("error", err))
 				return
 			}
 
 			select {
 			case eventChan <- replication.NewMysql56BinlogEvent(buf[1:]):
 			case <-ctx.Done():
-				lw.logger().Infof("startDumpFromBinlogPosition stop by ctx. reason: %v", ctx.Err())
+				s.logger.Info("startDumpFromBinlogPosition stop by ctx", F("reason", ctx.Err()))
 				return
 			}
 
 			buf, err = s.dc.ReadPacket()
 			if err != nil {
-				lw.logger().Errorf("startDumpFromBinlogPosition ReadPacket fail. error: %v", err)
+				s.logger.Error("startDumpFromBinlogPosition ReadPacket fail", F("error", err))
 				return
 			}
+			s.metrics.ObserveBytes(len(buf))
 		}
 	}()
 
@@ -45,7 +45,7 @@ func (m *mockDumpConn) HandleErrorPacket(data []byte) error {
 func Test_newSlaveConn(t *testing.T) {
 	_, err := newSlaveConn(func() (conn dumpConn, e error) {
 		return newMockDumpConn(bytes.NewBuffer(nil)), nil
-	})
+	}, nil, nil)
 	if err != nil {
 		t.Fatalf("newSlaveConn fail. err: %v", err)
 	}
@@ -57,7 +57,7 @@ func Test_slaveConn_startDumpFromBinlogPosition(t *testing.T) {
 	connBuf := bytes.NewBuffer(nil)
 	s, err := newSlaveConn(func() (conn dumpConn, e error) {
 		return newMockDumpConn(connBuf), nil
-	})
+	}, nil, nil)
 	if err != nil {
 		t.Fatalf("newSlaveConn fail. err: %v", err)
 	}
@@ -85,7 +85,7 @@ func Test_slaveConn_startDumpFromBinlogPosition(t *testing.T) {
 		connBuf.Write(v.input)
 	}
 
-	events, err := s.startDumpFromBinlogPosition(context.Background(), 1, Position{})
+	events, err := s.startDumpFromBinlogPosition(context.Background(), 1, Position{}, 0)
 	if err != nil {
 		t.Fatalf("startDumpFromBinlogPosition fail. err: %v", err)
 	}
@@ -111,7 +111,7 @@ func Test_slaveConn_startDumpFromBinlogPosition_Error(t *testing.T) {
 	connBuf := bytes.NewBuffer(nil)
 	s, err := newSlaveConn(func() (conn dumpConn, e error) {
 		return newMockDumpConn(connBuf), nil
-	})
+	}, nil, nil)
 	if err != nil {
 		t.Fatalf("newSlaveConn fail. err: %v", err)
 	}
@@ -131,11 +131,14 @@ func Test_slaveConn_startDumpFromBinlogPosition_Error(t *testing.T) {
 		connBuf.Write(v.input)
 	}
 
-	event, err := s.startDumpFromBinlogPosition(context.Background(), 1, Position{})
+	event, err := s.startDumpFromBinlogPosition(context.Background(), 1, Position{}, 0)
 	if err != nil {
 		t.Fatalf("startDumpFromBinlogPosition fail. err: %v", err)
 	}
 	<-event
+	if s.reachedEOF() {
+		t.Fatalf("reachedEOF want false after error packet")
+	}
 	for _, v := range testCases {
 		out := logBuf.String()
 		if !strings.Contains(out, v.want) {
@@ -152,7 +155,7 @@ func Test_slaveConn_startDumpFromBinlogPosition_EOF(t *testing.T) {
 	connBuf := bytes.NewBuffer(nil)
 	s, err := newSlaveConn(func() (conn dumpConn, e error) {
 		return newMockDumpConn(connBuf), nil
-	})
+	}, nil, nil)
 	if err != nil {
 		t.Fatalf("newSlaveConn fail. err: %v", err)
 	}
@@ -172,11 +175,14 @@ func Test_slaveConn_startDumpFromBinlogPosition_EOF(t *testing.T) {
 		connBuf.Write(v.input)
 	}
 
-	event, err := s.startDumpFromBinlogPosition(context.Background(), 1, Position{})
+	event, err := s.startDumpFromBinlogPosition(context.Background(), 1, Position{}, 0)
 	if err != nil {
 		t.Fatalf("startDumpFromBinlogPosition fail. err: %v", err)
 	}
 	<-event
+	if !s.reachedEOF() {
+		t.Fatalf("reachedEOF want true after EOF packet")
+	}
 	for _, v := range testCases {
 		out := logBuf.String()
 		if !strings.Contains(out, v.want) {
@@ -184,3 +190,61 @@ func Test_slaveConn_startDumpFromBinlogPosition_EOF(t *testing.T) {
 		}
 	}
 }
+
+//mockBlockingConn 第一个包之后ReadPacket一直阻塞，直到ReadInterrupter返回的函数被调用
+type mockBlockingConn struct {
+	mockDumpConn
+	packets     int
+	interrupted chan struct{}
+	reading     bool //ReadPacket是否正在执行，Close时应当为false
+	closed      bool
+}
+
+func (m *mockBlockingConn) ReadPacket() ([]byte, error) {
+	m.packets++
+	if m.packets == 1 {
+		return []byte{dump.PacketOK, 'a'}, nil
+	}
+	m.reading = true
+	<-m.interrupted
+	m.reading = false
+	return nil, dump.ErrBadConn
+}
+
+func (m *mockBlockingConn) ReadInterrupter() func() {
+	return func() {
+		close(m.interrupted)
+	}
+}
+
+func (m *mockBlockingConn) Close() error {
+	if m.reading {
+		return fmt.Errorf("Close while ReadPacket is running")
+	}
+	m.closed = true
+	return nil
+}
+
+func Test_slaveConn_close(t *testing.T) {
+	m := &mockBlockingConn{interrupted: make(chan struct{})}
+	s, err := newSlaveConn(func() (conn dumpConn, e error) {
+		return m, nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("newSlaveConn fail. err: %v", err)
+	}
+	events, err := s.startDumpFromBinlogPosition(context.Background(), 1, Position{}, 0)
+	if err != nil {
+		t.Fatalf("startDumpFromBinlogPosition fail. err: %v", err)
+	}
+	<-events
+
+	//close中断阻塞的ReadPacket，等待读取的goroutine退出后再关闭连接
+	s.close()
+	if !m.closed {
+		t.Fatalf("close should close the dump connection after ReadPacket returned")
+	}
+	if _, ok := <-events; ok {
+		t.Fatalf("events should be closed")
+	}
+}
@@ -0,0 +1,290 @@
+package binlog
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/onlyac0611/binlog/dump"
+)
+
+//SQLRenderer 将StreamEvent变为可以执行的mysql语句，可以用于将变更重放到其他的mysql或者生成可读的审计日志，
+//没有变化(IsEmpty)的列不会出现在语句中
+type SQLRenderer struct {
+	primaryKeys        map[MysqlTableName][]string
+	replace            bool
+	noBackslashEscapes bool
+}
+
+//NewSQLRenderer 创建SQLRenderer
+func NewSQLRenderer() *SQLRenderer {
+	return &SQLRenderer{
+		primaryKeys: make(map[MysqlTableName][]string),
+	}
+}
+
+//SetPrimaryKey 设置表的主键列，设置后UPDATE以及DELETE的where条件只使用主键列，
+//没有设置时使用StreamEvent.PrimaryKey，都没有时使用所有有数据的列
+func (r *SQLRenderer) SetPrimaryKey(table MysqlTableName, fields ...string) {
+	if len(fields) == 0 {
+		delete(r.primaryKeys, table)
+		return
+	}
+	r.primaryKeys[table] = fields
+}
+
+//SetReplace 设置是否使用REPLACE代替INSERT，用于重放时覆盖已经存在的数据
+func (r *SQLRenderer) SetReplace(replace bool) {
+	r.replace = replace
+}
+
+//SetNoBackslashEscapes 设置目标mysql是否开启了NO_BACKSLASH_ESCAPES，开启时字符串只转义单引号
+func (r *SQLRenderer) SetNoBackslashEscapes(noBackslashEscapes bool) {
+	r.noBackslashEscapes = noBackslashEscapes
+}
+
+//RenderTransaction 将事务中的所有事件按顺序变为mysql语句
+func (r *SQLRenderer) RenderTransaction(tran *Transaction) ([]string, error) {
+	var sqls []string
+	for _, ev := range tran.Events {
+		s, err := r.Render(ev)
+		if err != nil {
+			return nil, fmt.Errorf("RenderTransaction in pos: %+v error: %v", tran.NowPosition, err)
+		}
+		sqls = append(sqls, s...)
+	}
+	return sqls, nil
+}
+
+//Render 将StreamEvent变为mysql语句，每一行数据生成一条语句，
+//StreamEvent为sql语句时直接返回该语句
+func (r *SQLRenderer) Render(ev *StreamEvent) ([]string, error) {
+	if ev.SQL != "" {
+		return []string{ev.SQL}, nil
+	}
+
+	var sqls []string
+	switch ev.Type {
+	case StatementInsert:
+		for _, row := range ev.RowValues {
+			s, err := r.insertSQL(ev.Table, row)
+			if err != nil {
+				return nil, err
+			}
+			sqls = append(sqls, s)
+		}
+	case StatementUpdate:
+		if len(ev.RowIdentifies) != len(ev.RowValues) {
+			return nil, fmt.Errorf("the length of RowIdentifies(%d) did not equal to the length of RowValues(%d)",
+				len(ev.RowIdentifies), len(ev.RowValues))
+		}
+		for i := range ev.RowValues {
+			s, err := r.updateSQL(ev.Table, ev.PrimaryKey, ev.RowValues[i], ev.RowIdentifies[i])
+			if err != nil {
+				return nil, err
+			}
+			sqls = append(sqls, s)
+		}
+	case StatementDelete:
+		for _, row := range ev.RowIdentifies {
+			s, err := r.deleteSQL(ev.Table, ev.PrimaryKey, row)
+			if err != nil {
+				return nil, err
+			}
+			sqls = append(sqls, s)
+		}
+	default:
+		return nil, fmt.Errorf("can't render %v statement of table %v without sql", ev.Type, ev.Table.String())
+	}
+	return sqls, nil
+}
+
+//insertSQL 使用row中有数据的列生成INSERT或者REPLACE
+func (r *SQLRenderer) insertSQL(table MysqlTableName, row *RowData) (string, error) {
+	return r.insertSQLWithVerb(table, row, r.replace)
+}
+
+func (r *SQLRenderer) insertSQLWithVerb(table MysqlTableName, row *RowData, replace bool) (string, error) {
+	columns := nonEmptyColumns(row)
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no column can be inserted into table %v", table.String())
+	}
+
+	verb := "INSERT"
+	if replace {
+		verb = "REPLACE"
+	}
+	buf := []byte(verb + " INTO " + quoteTableName(table) + " (")
+	for i, c := range columns {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, quoteIdentifier(c.Filed)...)
+	}
+	buf = append(buf, ") VALUES ("...)
+	for i, c := range columns {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = r.appendColumnValue(buf, c)
+	}
+	buf = append(buf, ')')
+	return string(buf), nil
+}
+
+//updateSQL 使用set中有数据的列作为修改的值，where作为条件生成UPDATE，keys为事件中的主键列
+func (r *SQLRenderer) updateSQL(table MysqlTableName, keys []string, set, where *RowData) (string, error) {
+	columns := nonEmptyColumns(set)
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no column can be updated in table %v", table.String())
+	}
+
+	buf := []byte("UPDATE " + quoteTableName(table) + " SET ")
+	for i, c := range columns {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, quoteIdentifier(c.Filed)...)
+		buf = append(buf, '=')
+		buf = r.appendColumnValue(buf, c)
+	}
+	buf = append(buf, " WHERE "...)
+	buf, err := r.appendWhere(buf, table, keys, where)
+	if err != nil {
+		return "", err
+	}
+	buf = append(buf, " LIMIT 1"...)
+	return string(buf), nil
+}
+
+//deleteSQL 使用where作为条件生成DELETE，keys为事件中的主键列
+func (r *SQLRenderer) deleteSQL(table MysqlTableName, keys []string, where *RowData) (string, error) {
+	buf := []byte("DELETE FROM " + quoteTableName(table) + " WHERE ")
+	buf, err := r.appendWhere(buf, table, keys, where)
+	if err != nil {
+		return "", err
+	}
+	buf = append(buf, " LIMIT 1"...)
+	return string(buf), nil
+}
+
+//appendWhere 生成where条件，有主键时只使用主键列，SetPrimaryKey设置的主键优先于keys，否则使用所有有数据的列，
+//float的文本无法精确匹配，在还有其他列时不作为条件
+func (r *SQLRenderer) appendWhere(buf []byte, table MysqlTableName, keys []string, row *RowData) ([]byte, error) {
+	if set, ok := r.primaryKeys[table]; ok {
+		keys = set
+	}
+	var conds []*ColumnData
+	if len(keys) > 0 {
+		for _, key := range keys {
+			c := findColumnData(row, key)
+			if c == nil || c.IsEmpty {
+				return nil, fmt.Errorf("primary key %v of table %v is not in row", key, table.String())
+			}
+			conds = append(conds, c)
+		}
+	} else {
+		var floats []*ColumnData
+		for _, c := range nonEmptyColumns(row) {
+			if c.Type == ColumnTypeFloat {
+				floats = append(floats, c)
+				continue
+			}
+			conds = append(conds, c)
+		}
+		if len(conds) == 0 {
+			conds = floats
+		}
+	}
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("no column can be used in where condition of table %v", table.String())
+	}
+
+	for i, c := range conds {
+		if i > 0 {
+			buf = append(buf, " AND "...)
+		}
+		buf = append(buf, quoteIdentifier(c.Filed)...)
+		if c.Data == nil {
+			buf = append(buf, " IS NULL"...)
+			continue
+		}
+		buf = append(buf, '=')
+		buf = r.appendColumnValue(buf, c)
+	}
+	return buf, nil
+}
+
+//appendColumnValue 将列数据按照列类型变为sql字面量并追加到buf中:
+//NULL直接输出，数值类型原样输出，bit与几何类型输出16进制(几何类型使用mysql格式的数据)，BLOB使用_binary前缀，
+//有非binary字符集的TEXT已经转为UTF-8，使用_utf8mb4前缀，其他(包括ENUM以及SET的成员)使用带转义的字符串
+func (r *SQLRenderer) appendColumnValue(buf []byte, c *ColumnData) []byte {
+	if c.Data == nil {
+		return append(buf, "NULL"...)
+	}
+
+	typ := c.Type
+	switch {
+	case typ.IsInteger(), typ.IsFloat(), typ.IsDecimal(), typ == ColumnTypeYear:
+		return append(buf, c.Data...)
+	case typ.IsBit():
+		if len(c.Data) == 0 {
+			return append(buf, "''"...)
+		}
+		buf = append(buf, "0x"...)
+		return append(buf, hex.EncodeToString(c.Data)...)
+	case typ.IsGeometry():
+		data := c.geometryWKB()
+		if len(data) == 0 {
+			return append(buf, "''"...)
+		}
+		buf = append(buf, "0x"...)
+		return append(buf, hex.EncodeToString(data)...)
+	case typ.IsBlob() && c.Charset != "" && c.Charset != "binary":
+		buf = append(buf, "_utf8mb4'"...)
+	case typ.IsBlob():
+		buf = append(buf, "_binary'"...)
+	default:
+		buf = append(buf, '\'')
+	}
+	if r.noBackslashEscapes {
+		buf = dump.EscapeBytesQuotes(buf, c.Data)
+	} else {
+		buf = dump.EscapeBytesBackslash(buf, c.Data)
+	}
+	return append(buf, '\'')
+}
+
+//quoteIdentifier 使用反引号引用表名或者列名
+func quoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+//quoteTableName 使用反引号引用带数据库名的表名
+func quoteTableName(name MysqlTableName) string {
+	if name.DbName == "" {
+		return quoteIdentifier(name.TableName)
+	}
+	return quoteIdentifier(name.DbName) + "." + quoteIdentifier(name.TableName)
+}
+
+//nonEmptyColumns 获取行数据中有数据的列
+func nonEmptyColumns(row *RowData) []*ColumnData {
+	columns := make([]*ColumnData, 0, len(row.Columns))
+	for _, c := range row.Columns {
+		if !c.IsEmpty {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+//findColumnData 根据列名查找列数据
+func findColumnData(row *RowData, field string) *ColumnData {
+	for _, c := range row.Columns {
+		if c.Filed == field {
+			return c
+		}
+	}
+	return nil
+}
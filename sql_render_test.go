@@ -0,0 +1,179 @@
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAppendColumnValue(t *testing.T) {
+	testCases := []struct {
+		input *ColumnData
+		want  string
+	}{
+		{input: &ColumnData{Type: ColumnTypeLong, Data: nil}, want: "NULL"},
+		{input: &ColumnData{Type: ColumnTypeLong, Data: []byte("-12")}, want: "-12"},
+		{input: &ColumnData{Type: ColumnTypeDouble, Data: []byte("1.5")}, want: "1.5"},
+		{input: &ColumnData{Type: ColumnTypeNewDecimal, Data: []byte("10.01")}, want: "10.01"},
+		{input: &ColumnData{Type: ColumnTypeYear, Data: []byte("2019")}, want: "2019"},
+		{input: &ColumnData{Type: ColumnTypeVarchar, Data: []byte("it's\n")}, want: `'it\'s\n'`},
+		{input: &ColumnData{Type: ColumnTypeVarchar, Data: []byte("")}, want: `''`},
+		{input: &ColumnData{Type: ColumnTypeDateTime2, Data: []byte("2019-01-01 00:00:00")},
+			want: `'2019-01-01 00:00:00'`},
+		{input: &ColumnData{Type: ColumnTypeBlob, Data: []byte{0x00, '\\'}}, want: `_binary'\0\\'`},
+		{input: &ColumnData{Type: ColumnTypeBlob, Data: []byte("\xff'"), Charset: "binary"},
+			want: "_binary'\xff\\''"},
+		{input: &ColumnData{Type: ColumnTypeBlob, Data: []byte("café"), Charset: "latin1"}, want: `_utf8mb4'café'`},
+		{input: &ColumnData{Type: ColumnTypeBit, Data: []byte{0x01, 0xff}}, want: "0x01ff"},
+		{input: &ColumnData{Type: ColumnTypeGeometry, Data: []byte{}}, want: "''"},
+		{input: &ColumnData{Type: ColumnTypeGeometry, Data: []byte("POINT(1 2)"), Raw: []byte{0x01, 0x02}}, want: "0x0102"},
+		{input: &ColumnData{Type: ColumnTypeEnum, Data: []byte("it's")}, want: `'it\'s'`},
+		{input: &ColumnData{Type: ColumnTypeSet, Data: []byte("a,b")}, want: `'a,b'`},
+	}
+	r := NewSQLRenderer()
+	for _, v := range testCases {
+		if out := string(r.appendColumnValue(nil, v.input)); out != v.want {
+			t.Fatalf("appendColumnValue type: %v want: %v out: %v", v.input.Type, v.want, out)
+		}
+	}
+}
+
+func TestSQLRenderer_NoBackslashEscapes(t *testing.T) {
+	r := NewSQLRenderer()
+	r.SetNoBackslashEscapes(true)
+	c := &ColumnData{Type: ColumnTypeVarchar, Data: []byte(`it's \`)}
+	if out, want := string(r.appendColumnValue(nil, c)), `'it''s \'`; out != want {
+		t.Fatalf("appendColumnValue want: %v out: %v", want, out)
+	}
+}
+
+func TestSQLRenderer_Render(t *testing.T) {
+	table := NewMysqlTableName("vt_test_keyspace", "vt_a")
+	minimal := newTestRowData("1", "c")
+	minimal.Columns[0].IsEmpty = true
+	nullRow := newTestRowData("3", "")
+	nullRow.Columns[1].Data = nil
+	latin1Row := NewRowData(2)
+	latin1Row.Columns = append(latin1Row.Columns,
+		&ColumnData{Filed: "id", Type: ColumnTypeLong, Data: []byte("4")},
+		&ColumnData{Filed: "message", Type: ColumnTypeBlob, Data: []byte("café"), Charset: "latin1"})
+
+	testCases := []struct {
+		primaryKey []string
+		replace    bool
+		input      *StreamEvent
+		want       []string
+		wantErr    bool
+	}{
+		{
+			input: &StreamEvent{
+				Type:      StatementInsert,
+				Table:     table,
+				RowValues: []*RowData{newTestRowData("1", "a"), nullRow},
+			},
+			want: []string{
+				"INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (1,'a')",
+				"INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (3,NULL)",
+			},
+		},
+		{
+			input: &StreamEvent{
+				Type:      StatementInsert,
+				Table:     table,
+				RowValues: []*RowData{latin1Row},
+			},
+			want: []string{"INSERT INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (4,_utf8mb4'café')"},
+		},
+		{
+			replace: true,
+			input: &StreamEvent{
+				Type:      StatementInsert,
+				Table:     table,
+				RowValues: []*RowData{newTestRowData("1", "a")},
+			},
+			want: []string{"REPLACE INTO `vt_test_keyspace`.`vt_a` (`id`,`message`) VALUES (1,'a')"},
+		},
+		{
+			input: &StreamEvent{
+				Type:          StatementUpdate,
+				Table:         table,
+				RowIdentifies: []*RowData{nullRow},
+				RowValues:     []*RowData{minimal},
+			},
+			want: []string{"UPDATE `vt_test_keyspace`.`vt_a` SET `message`='c' WHERE `id`=3 AND `message` IS NULL LIMIT 1"},
+		},
+		{
+			primaryKey: []string{"id"},
+			input: &StreamEvent{
+				Type:          StatementDelete,
+				Table:         table,
+				RowIdentifies: []*RowData{newTestRowData("2", "b")},
+			},
+			want: []string{"DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `id`=2 LIMIT 1"},
+		},
+		{
+			input: &StreamEvent{
+				Type:          StatementUpdate,
+				Table:         table,
+				PrimaryKey:    []string{"id"},
+				RowIdentifies: []*RowData{newTestRowData("2", "b")},
+				RowValues:     []*RowData{newTestRowData("2", "c")},
+			},
+			want: []string{"UPDATE `vt_test_keyspace`.`vt_a` SET `id`=2,`message`='c' WHERE `id`=2 LIMIT 1"},
+		},
+		{
+			primaryKey: []string{"message"},
+			input: &StreamEvent{
+				Type:          StatementDelete,
+				Table:         table,
+				PrimaryKey:    []string{"id"},
+				RowIdentifies: []*RowData{newTestRowData("2", "b")},
+			},
+			want: []string{"DELETE FROM `vt_test_keyspace`.`vt_a` WHERE `message`='b' LIMIT 1"},
+		},
+		{
+			input: &StreamEvent{Type: StatementAlter, Table: table, SQL: "alter table vt_a add column c int"},
+			want:  []string{"alter table vt_a add column c int"},
+		},
+		{
+			input:   &StreamEvent{Type: StatementAlter, Table: table},
+			wantErr: true,
+		},
+		{
+			primaryKey: []string{"id"},
+			input: &StreamEvent{
+				Type:          StatementDelete,
+				Table:         table,
+				RowIdentifies: []*RowData{minimal},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, v := range testCases {
+		r := NewSQLRenderer()
+		r.SetPrimaryKey(table, v.primaryKey...)
+		r.SetReplace(v.replace)
+		out, err := r.RenderTransaction(&Transaction{Events: []*StreamEvent{v.input}})
+		if (err != nil) != v.wantErr {
+			t.Fatalf("RenderTransaction %v wantErr: %v err: %v", v.input.Type, v.wantErr, err)
+		}
+		if !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("RenderTransaction want: %q out: %q", v.want, out)
+		}
+	}
+}
+
+func TestQuoteTableName(t *testing.T) {
+	testCases := []struct {
+		input MysqlTableName
+		want  string
+	}{
+		{input: NewMysqlTableName("db", "t"), want: "`db`.`t`"},
+		{input: NewMysqlTableName("", "t`1"), want: "`t``1`"},
+	}
+	for _, v := range testCases {
+		if out := quoteTableName(v.input); out != v.want {
+			t.Fatalf("quoteTableName want: %v out: %v", v.want, out)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package binlog
+
+import (
+	"fmt"
+	"time"
+)
+
+//StreamerStatus RowStreamer的同步进度
+type StreamerStatus struct {
+	Position            Position //已经处理完的binlog位置
+	LastEventTimestamp  int64    //最后处理完的事务的执行时间，还没有处理过事务时为0
+	SecondsBehindMaster int64    //落后主库的秒数，已经追上主库时为0，还没有处理过事务时为-1
+	MasterPosition      Position //主库当前的binlog位置
+	BytesBehindMaster   int64    //从Position到MasterPosition之间所有binlog文件剩余的字节数
+}
+
+//sideConn 执行查询语句的单独连接，如获取主库状态以及表信息
+type sideConn interface {
+	queryConn
+	Close() error
+}
+
+//streamProgress Stream当前处理到的位置以及事务的执行时间
+type streamProgress struct {
+	pos       Position
+	timestamp int64
+}
+
+func (s *RowStreamer) setProgress(pos Position, timestamp int64) {
+	s.progress.Store(streamProgress{pos: pos, timestamp: timestamp})
+}
+
+//setProgressPosition 只更新位置，保留最后处理完的事务的执行时间
+func (s *RowStreamer) setProgressPosition(pos Position) {
+	progress, _ := s.progress.Load().(streamProgress)
+	s.setProgress(pos, progress.timestamp)
+}
+
+//Status 获取同步进度，每次调用都会新建一个连接执行SHOW MASTER STATUS以及SHOW BINARY LOGS，
+//可以在Stream运行时在其他goroutine中调用
+func (s *RowStreamer) Status() (StreamerStatus, error) {
+	progress, ok := s.progress.Load().(streamProgress)
+	if !ok {
+		progress.pos = s.startBinlogPosition()
+	}
+
+	conn, err := s.newStatusConn()
+	if err != nil {
+		return StreamerStatus{}, fmt.Errorf("Status newMysqlConn fail. err: %v", err)
+	}
+	defer conn.Close()
+
+	master, err := showMasterStatus(conn)
+	if err != nil {
+		return StreamerStatus{}, fmt.Errorf("Status showMasterStatus fail. err: %v", err)
+	}
+	files, err := showBinaryLogSizes(conn)
+	if err != nil {
+		return StreamerStatus{}, fmt.Errorf("Status showBinaryLogs fail. err: %v", err)
+	}
+
+	status := StreamerStatus{
+		Position:           progress.pos,
+		LastEventTimestamp: progress.timestamp,
+		MasterPosition:     master,
+		BytesBehindMaster:  bytesBehindMaster(progress.pos, master, files),
+	}
+	status.SecondsBehindMaster = secondsBehindMaster(progress.pos, master, progress.timestamp, time.Now())
+	return status, nil
+}
+
+//bytesBehindMaster 计算从pos到master之间的binlog字节数，master所在的文件以master.Offset为结束位置
+func bytesBehindMaster(pos, master Position, files []binlogFileSize) int64 {
+	if pos.Compare(master) >= 0 {
+		return 0
+	}
+	var n int64
+	for _, f := range files {
+		if compareBinlogFilename(f.name, pos.Filename) < 0 ||
+			compareBinlogFilename(f.name, master.Filename) > 0 {
+			continue
+		}
+		end := f.size
+		if f.name == master.Filename {
+			end = master.Offset
+		}
+		start := int64(0)
+		if f.name == pos.Filename {
+			start = pos.Offset
+		}
+		if end > start {
+			n += end - start
+		}
+	}
+	return n
+}
+
+//secondsBehindMaster 已经追上主库时为0，否则为当前时间与最后处理完的事务的执行时间的差
+func secondsBehindMaster(pos, master Position, timestamp int64, now time.Time) int64 {
+	if pos.Compare(master) >= 0 {
+		return 0
+	}
+	if timestamp == 0 {
+		return -1
+	}
+	if seconds := now.Unix() - timestamp; seconds > 0 {
+		return seconds
+	}
+	return 0
+}
@@ -0,0 +1,184 @@
+package binlog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onlyac0611/binlog/fakemaster"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+func TestBytesBehindMaster(t *testing.T) {
+	files := []binlogFileSize{
+		{name: "binlog.000001", size: 1000},
+		{name: "binlog.000002", size: 2000},
+		{name: "binlog.000003", size: 500},
+	}
+	master := Position{Filename: "binlog.000003", Offset: 400}
+	testCases := []struct {
+		pos  Position
+		want int64
+	}{
+		{pos: Position{Filename: "binlog.000003", Offset: 400}, want: 0},
+		{pos: Position{Filename: "binlog.000003", Offset: 500}, want: 0},
+		{pos: Position{Filename: "binlog.000003", Offset: 100}, want: 300},
+		{pos: Position{Filename: "binlog.000002", Offset: 1500}, want: 500 + 400},
+		{pos: Position{Filename: "binlog.000001", Offset: 4}, want: 996 + 2000 + 400},
+		{pos: Position{Filename: "binlog.000000", Offset: 4}, want: 1000 + 2000 + 400},
+	}
+	for _, v := range testCases {
+		if out := bytesBehindMaster(v.pos, master, files); out != v.want {
+			t.Errorf("bytesBehindMaster pos: %+v want: %v out: %v", v.pos, v.want, out)
+		}
+	}
+}
+
+func TestSecondsBehindMaster(t *testing.T) {
+	master := Position{Filename: "binlog.000002", Offset: 400}
+	now := time.Unix(1000, 0)
+	testCases := []struct {
+		pos       Position
+		timestamp int64
+		want      int64
+	}{
+		{pos: master, timestamp: 100, want: 0},
+		{pos: Position{Filename: "binlog.000001", Offset: 4}, timestamp: 0, want: -1},
+		{pos: Position{Filename: "binlog.000001", Offset: 4}, timestamp: 990, want: 10},
+		{pos: Position{Filename: "binlog.000001", Offset: 4}, timestamp: 1010, want: 0},
+	}
+	for _, v := range testCases {
+		if out := secondsBehindMaster(v.pos, master, v.timestamp, now); out != v.want {
+			t.Errorf("secondsBehindMaster pos: %+v timestamp: %v want: %v out: %v",
+				v.pos, v.timestamp, v.want, out)
+		}
+	}
+}
+
+func TestRowStreamer_Status(t *testing.T) {
+	m, err := fakemaster.NewMaster("127.0.0.1:0", "root", "123456")
+	if err != nil {
+		t.Fatalf("NewMaster err: %v", err)
+	}
+	defer m.Close()
+	m.AppendEvents(getInputData()[2:]...)
+	filename, end := m.Position()
+
+	r, err := NewRowStreamer(m.DSN(), testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewRowStreamer err: %v", err)
+	}
+	start := Position{Filename: filename, Offset: 4}
+	r.SetStartBinlogPosition(start)
+
+	status, err := r.Status()
+	if err != nil {
+		t.Fatalf("Status err: %v", err)
+	}
+	want := StreamerStatus{
+		Position:            start,
+		SecondsBehindMaster: -1,
+		MasterPosition:      Position{Filename: filename, Offset: end},
+		BytesBehindMaster:   end - 4,
+	}
+	if status != want {
+		t.Fatalf("Status before Stream want: %+v out: %+v", want, status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Stream(ctx, func(tran *Transaction) error {
+			return nil
+		})
+	}()
+
+	want = StreamerStatus{
+		Position:           Position{Filename: filename, Offset: end},
+		LastEventTimestamp: 1407805592,
+		MasterPosition:     Position{Filename: filename, Offset: end},
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if status, err = r.Status(); err != nil {
+			t.Fatalf("Status err: %v", err)
+		}
+		if status == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Status while streaming want: %+v out: %+v", want, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+	m.RotateTo("mysql-bin.000002")
+	m.AppendEvents(getInputData()[2:]...)
+	newFile, newEnd := m.Position()
+	if status, err = r.Status(); err != nil {
+		t.Fatalf("Status err: %v", err)
+	}
+	if status.MasterPosition != (Position{Filename: newFile, Offset: newEnd}) {
+		t.Fatalf("Status after rotate MasterPosition out: %+v", status.MasterPosition)
+	}
+	//第一个文件剩余的ROTATE_EVENT加上第二个文件
+	if status.BytesBehindMaster <= newEnd {
+		t.Fatalf("Status after rotate BytesBehindMaster out: %v", status.BytesBehindMaster)
+	}
+	if status.SecondsBehindMaster <= 0 {
+		t.Fatalf("Status after rotate SecondsBehindMaster out: %v", status.SecondsBehindMaster)
+	}
+}
+
+func TestRowStreamer_parseEvents_ProgressQuery(t *testing.T) {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	s.ServerID = 62344
+	s.Timestamp = 1407805600
+	s.LogPosition = 150
+
+	//第一个事务之后是一个事务外的DDL，随后binlog结束
+	input := getStopInputData()[:7]
+	input = append(input,
+		replication.NewMySQL56GTIDEvent(f, s, replication.Mysql56GTID{Server: testStopSID, Sequence: 2}),
+		replication.NewQueryEvent(f, s, replication.Query{
+			Database: "vt_test_keyspace",
+			SQL:      "alter table vt_a add column c int"}),
+	)
+
+	r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewRowStreamer err: %v", err)
+	}
+	r.SetStartBinlogPosition(testBinlogPosParseEvents)
+	r.sendTransaction = func(tran *Transaction) error { return nil }
+
+	events := make(chan replication.BinlogEvent, len(input))
+	for i := range input {
+		events <- input[i]
+	}
+	close(events)
+
+	pos, err := r.parseEvents(context.Background(), events)
+	if err != ErrStreamEOF {
+		t.Fatalf("parseEvents want err: %v, out: %v", ErrStreamEOF, err)
+	}
+	want := Position{Filename: testBinlogPosParseEvents.Filename, Offset: 100}
+	if pos != want {
+		t.Fatalf("parseEvents position want: %+v out: %+v", want, pos)
+	}
+
+	progress := r.progress.Load().(streamProgress)
+	want.Offset = 150
+	if progress.pos != want {
+		t.Fatalf("progress position want: %+v out: %+v", want, progress.pos)
+	}
+	if progress.timestamp != 1407805592 {
+		t.Fatalf("progress timestamp want: %v out: %v", 1407805592, progress.timestamp)
+	}
+	if seconds := secondsBehindMaster(progress.pos, want, progress.timestamp, time.Now()); seconds != 0 {
+		t.Fatalf("secondsBehindMaster want: 0 out: %v", seconds)
+	}
+}
@@ -0,0 +1,98 @@
+package binlog
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/onlyac0611/binlog/replication"
+)
+
+//ErrStopConditionReached 信息流到达停止条件，停止条件所在的事务已经被处理，用于区分ErrStreamEOF
+var ErrStopConditionReached = errors.New("stream reached stop condition")
+
+//StopMode 到达停止条件之后Stream的返回方式
+type StopMode int
+
+//到达停止条件之后Stream的返回方式
+const (
+	StopWithError StopMode = iota //返回ErrStopConditionReached，默认的方式
+	StopAsEnd                     //视为信息流正常结束并返回nil，SetEndBinlogPosition以及SetEndTimestamp使用该方式
+)
+
+//StopCondition 停止条件，包含条件所在边界的事务都会被处理，之后Stream按照Mode返回，
+//多个条件同时设置时，任意一个条件满足即停止
+type StopCondition struct {
+	Position  Position //binlog位置，事务的结束位置到达或者超过该位置时，处理完该事务后停止
+	Timestamp int64    //时间戳(秒)，执行时间不超过该时间戳的事务都会被处理，遇到之后的事务时停止
+	GTID      string   //GTID，如3e11fa47-71ca-11e1-9e33-c80aa9429562:23或者0-1-100，处理完该事务后停止
+	Mode      StopMode //到达停止条件之后Stream的返回方式
+}
+
+//IsZero 是否没有设置任何停止条件
+func (c StopCondition) IsZero() bool {
+	return c.Position.IsZero() && c.Timestamp <= 0 && c.GTID == ""
+}
+
+//stopChecker 检查事务是否到达了停止条件
+type stopChecker struct {
+	cond StopCondition
+	gtid replication.GTID
+}
+
+func newStopChecker(cond StopCondition) (*stopChecker, error) {
+	c := &stopChecker{cond: cond}
+	if cond.GTID != "" {
+		gtid, err := replication.ParseGTID(cond.GTID)
+		if err != nil {
+			return nil, fmt.Errorf("newStopChecker parse gtid fail. err: %v", err)
+		}
+		c.gtid = gtid
+	}
+	return c, nil
+}
+
+//before 事务开始之前检查，事务已经超过了停止条件时返回true，该事务不会被处理
+func (c *stopChecker) before(now Position, timestamp int64, gtid replication.GTID) bool {
+	if c == nil {
+		return false
+	}
+	if !c.cond.Position.IsZero() && now.Compare(c.cond.Position) >= 0 {
+		return true
+	}
+	if c.cond.Timestamp > 0 && timestamp > c.cond.Timestamp {
+		return true
+	}
+	return c.gtid != nil && gtid != nil && isGTIDAfter(gtid, c.gtid)
+}
+
+//after 事务处理之后检查，事务到达了停止条件的边界时返回true
+func (c *stopChecker) after(next Position, gtid replication.GTID) bool {
+	if c == nil {
+		return false
+	}
+	if !c.cond.Position.IsZero() && next.Compare(c.cond.Position) >= 0 {
+		return true
+	}
+	return c.gtid != nil && gtid != nil && gtid.String() == c.gtid.String()
+}
+
+//passed 非事务的binlog位置(如rotate)是否已经超过停止条件
+func (c *stopChecker) passed(pos Position) bool {
+	if c == nil {
+		return false
+	}
+	return !c.cond.Position.IsZero() && pos.Compare(c.cond.Position) >= 0
+}
+
+//isGTIDAfter gtid与stop来自同一个server(mysql)或者同一个domain(mariadb)且序号更大时返回true
+func isGTIDAfter(gtid, stop replication.GTID) bool {
+	switch s := stop.(type) {
+	case replication.Mysql56GTID:
+		g, ok := gtid.(replication.Mysql56GTID)
+		return ok && g.Server == s.Server && g.Sequence > s.Sequence
+	case replication.MariadbGTID:
+		g, ok := gtid.(replication.MariadbGTID)
+		return ok && g.Domain == s.Domain && g.Sequence > s.Sequence
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package binlog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/onlyac0611/binlog/replication"
+)
+
+var testStopSID = replication.SID{0x43, 0x91, 0x92, 0xbd, 0xf3, 0x7c, 0x11, 0xe4,
+	0xbb, 0xeb, 0x02, 0x42, 0xac, 0x11, 0x03, 0x5a}
+
+//getStopInputData 三个事务，GTID序号为1到3，时间戳为1407805592到1407805594，
+//结束位置为100、200、300
+func getStopInputData() []replication.BinlogEvent {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	s.ServerID = 62344
+
+	tableID := uint64(0x102030405060)
+	tm := &replication.TableMap{
+		Flags:    0x8090,
+		Database: "vt_test_keyspace",
+		Name:     "vt_a",
+		Types: []byte{
+			replication.TypeLong,
+			replication.TypeVarchar,
+		},
+		CanBeNull: replication.NewServerBitmap(2),
+		Metadata: []uint16{
+			0,
+			384,
+		},
+	}
+	tm.CanBeNull.Set(1, true)
+
+	insertRows := replication.Rows{
+		Flags:       0x1234,
+		DataColumns: replication.NewServerBitmap(2),
+		Rows: []replication.Row{
+			{
+				NullColumns: replication.NewServerBitmap(2),
+				Data: []byte{
+					0x10, 0x20, 0x30, 0x40, // long
+					0x04, 0x00, // len('abcd')
+					'a', 'b', 'c', 'd', // 'abcd'
+				},
+			},
+		},
+	}
+	insertRows.DataColumns.Set(0, true)
+	insertRows.DataColumns.Set(1, true)
+
+	events := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, uint64(testBinlogPosParseEvents.Offset), testBinlogPosParseEvents.Filename),
+		replication.NewFormatDescriptionEvent(f, s),
+	}
+	for i := 1; i <= 3; i++ {
+		events = append(events,
+			replication.NewMySQL56GTIDEvent(f, s, replication.Mysql56GTID{Server: testStopSID, Sequence: int64(i)}),
+			replication.NewQueryEvent(f, s, replication.Query{
+				Database: "vt_test_keyspace",
+				SQL:      "BEGIN"}),
+			replication.NewTableMapEvent(f, s, tableID, tm),
+			replication.NewWriteRowsEvent(f, s, tableID, insertRows),
+		)
+		s.LogPosition = uint32(i * 100)
+		events = append(events, replication.NewXIDEvent(f, s))
+		s.Timestamp++
+	}
+	return events
+}
+
+func TestRowStreamer_parseEvents_StopCondition(t *testing.T) {
+	gtid := func(seq int) string {
+		return replication.Mysql56GTID{Server: testStopSID, Sequence: int64(seq)}.String()
+	}
+	testCases := []struct {
+		cond    StopCondition
+		wantErr error
+		wantCnt int
+		wantPos Position
+	}{
+		{
+			cond:    StopCondition{},
+			wantErr: ErrStreamEOF,
+			wantCnt: 3,
+			wantPos: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 300},
+		},
+		{
+			cond:    StopCondition{Position: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 200}},
+			wantErr: ErrStopConditionReached,
+			wantCnt: 2,
+			wantPos: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 200},
+		},
+		{
+			cond:    StopCondition{Position: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 150}},
+			wantErr: ErrStopConditionReached,
+			wantCnt: 2,
+			wantPos: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 200},
+		},
+		{
+			cond:    StopCondition{Position: Position{Filename: "binlog.000004", Offset: 4}},
+			wantErr: ErrStopConditionReached,
+			wantCnt: 0,
+			wantPos: testBinlogPosParseEvents,
+		},
+		{
+			cond:    StopCondition{Timestamp: 1407805592},
+			wantErr: ErrStopConditionReached,
+			wantCnt: 1,
+			wantPos: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 100},
+		},
+		{
+			cond:    StopCondition{Timestamp: 1407805594},
+			wantErr: ErrStreamEOF,
+			wantCnt: 3,
+			wantPos: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 300},
+		},
+		{
+			cond:    StopCondition{GTID: gtid(2)},
+			wantErr: ErrStopConditionReached,
+			wantCnt: 2,
+			wantPos: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 200},
+		},
+		{
+			cond:    StopCondition{GTID: "00000000-0000-0000-0000-000000000000:1"},
+			wantErr: ErrStreamEOF,
+			wantCnt: 3,
+			wantPos: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 300},
+		},
+	}
+
+	for _, v := range testCases {
+		r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.SetStartBinlogPosition(testBinlogPosParseEvents)
+		if err = r.SetStopCondition(v.cond); err != nil {
+			t.Fatalf("SetStopCondition err: %v", err)
+		}
+
+		var trans []*Transaction
+		r.sendTransaction = func(tran *Transaction) error {
+			trans = append(trans, tran)
+			return nil
+		}
+
+		input := getStopInputData()
+		events := make(chan replication.BinlogEvent, len(input))
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+
+		pos, err := r.parseEvents(context.Background(), events)
+		if err != v.wantErr {
+			t.Fatalf("parseEvents cond: %+v want err: %v, out: %v", v.cond, v.wantErr, err)
+		}
+		if len(trans) != v.wantCnt {
+			t.Fatalf("parseEvents cond: %+v transaction count want: %v out: %v", v.cond, v.wantCnt, len(trans))
+		}
+		if pos != v.wantPos {
+			t.Fatalf("parseEvents cond: %+v position want: %+v out: %+v", v.cond, v.wantPos, pos)
+		}
+		for i, tran := range trans {
+			if tran.GTID != gtid(i+1) {
+				t.Fatalf("transaction gtid want: %v out: %v", gtid(i+1), tran.GTID)
+			}
+		}
+	}
+}
+
+func TestRowStreamer_SetStopCondition(t *testing.T) {
+	testCases := []struct {
+		cond    StopCondition
+		wantErr bool
+	}{
+		{cond: StopCondition{}, wantErr: false},
+		{cond: StopCondition{GTID: "4391 92bd"}, wantErr: true},
+		{cond: StopCondition{GTID: "0-1-100"}, wantErr: false},
+		{cond: StopCondition{GTID: "439192bd-f37c-11e4-bbeb-0242ac11035a:23"}, wantErr: false},
+	}
+
+	for _, v := range testCases {
+		r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		if err = r.SetStopCondition(v.cond); (err != nil) != v.wantErr {
+			t.Fatalf("SetStopCondition cond: %+v wantErr: %v err: %v", v.cond, v.wantErr, err)
+		}
+	}
+}
+
+func TestRowStreamer_parseEvents_StopConditionDDL(t *testing.T) {
+	gtid := func(seq int) string {
+		return replication.Mysql56GTID{Server: testStopSID, Sequence: int64(seq)}.String()
+	}
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	s.ServerID = 62344
+
+	//事务1结束于100，GTID为2的DDL结束于150，之后是一个没有GTID事件的事务，结束于200
+	stop := getStopInputData()
+	input := append([]replication.BinlogEvent{}, stop[:7]...)
+	s.LogPosition = 150
+	input = append(input,
+		replication.NewMySQL56GTIDEvent(f, s, replication.Mysql56GTID{Server: testStopSID, Sequence: 2}),
+		replication.NewQueryEvent(f, s, replication.Query{
+			Database: "vt_test_keyspace",
+			SQL:      "alter table vt_a add column c int"}),
+	)
+	input = append(input, stop[3:6]...)
+	s.LogPosition = 200
+	input = append(input, replication.NewXIDEvent(f, s))
+
+	testCases := []struct {
+		cond      StopCondition
+		wantErr   error
+		wantGTIDs []string
+		wantPos   Position
+	}{
+		{
+			cond:      StopCondition{},
+			wantErr:   ErrStreamEOF,
+			wantGTIDs: []string{gtid(1), ""},
+			wantPos:   Position{Filename: testBinlogPosParseEvents.Filename, Offset: 200},
+		},
+		{
+			cond:      StopCondition{GTID: gtid(2)},
+			wantErr:   ErrStopConditionReached,
+			wantGTIDs: []string{gtid(1)},
+			wantPos:   Position{Filename: testBinlogPosParseEvents.Filename, Offset: 100},
+		},
+		{
+			cond:      StopCondition{Position: Position{Filename: testBinlogPosParseEvents.Filename, Offset: 150}},
+			wantErr:   ErrStopConditionReached,
+			wantGTIDs: []string{gtid(1)},
+			wantPos:   Position{Filename: testBinlogPosParseEvents.Filename, Offset: 100},
+		},
+	}
+
+	for _, v := range testCases {
+		r, err := NewRowStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("NewRowStreamer err: %v", err)
+		}
+		r.SetStartBinlogPosition(testBinlogPosParseEvents)
+		if err = r.SetStopCondition(v.cond); err != nil {
+			t.Fatalf("SetStopCondition err: %v", err)
+		}
+
+		var gtids []string
+		r.sendTransaction = func(tran *Transaction) error {
+			gtids = append(gtids, tran.GTID)
+			return nil
+		}
+
+		events := make(chan replication.BinlogEvent, len(input))
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+
+		pos, err := r.parseEvents(context.Background(), events)
+		if err != v.wantErr {
+			t.Fatalf("parseEvents cond: %+v want err: %v, out: %v", v.cond, v.wantErr, err)
+		}
+		if !reflect.DeepEqual(gtids, v.wantGTIDs) {
+			t.Fatalf("parseEvents cond: %+v transaction gtids want: %q out: %q", v.cond, v.wantGTIDs, gtids)
+		}
+		if pos != v.wantPos {
+			t.Fatalf("parseEvents cond: %+v position want: %+v out: %+v", v.cond, v.wantPos, pos)
+		}
+	}
+}
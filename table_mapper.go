@@ -0,0 +1,358 @@
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/onlyac0611/binlog/dump"
+)
+
+//schemaColumn 从information_schema.COLUMNS获取的列信息
+type schemaColumn struct {
+	field         string
+	columnType    string //COLUMN_TYPE，如int(10) unsigned
+	dataType      string //DATA_TYPE，如int
+	nullable      bool
+	defaultValue  []byte //没有默认值或者默认值为NULL时为nil
+	charset       string
+	collation     string
+	primaryKey    bool
+	unique        bool
+	autoIncrement bool
+	enumValues    []string
+}
+
+//Field 列名
+func (c *schemaColumn) Field() string {
+	return c.field
+}
+
+//IsUnSignedInt 是否是无符号类型，zerofill的列也是无符号的
+func (c *schemaColumn) IsUnSignedInt() bool {
+	typ := strings.ToLower(c.columnType)
+	return strings.Contains(typ, "unsigned") || strings.Contains(typ, "zerofill")
+}
+
+//SQLType 完整的列类型，如int(10) unsigned、varchar(64)、enum('a','b')
+func (c *schemaColumn) SQLType() string {
+	return c.columnType
+}
+
+//DataType 不带长度等属性的列类型，如int、varchar、enum
+func (c *schemaColumn) DataType() string {
+	return c.dataType
+}
+
+//IsNullable 是否允许为NULL
+func (c *schemaColumn) IsNullable() bool {
+	return c.nullable
+}
+
+//Default 默认值，没有默认值或者默认值为NULL时为nil
+func (c *schemaColumn) Default() []byte {
+	return c.defaultValue
+}
+
+//Charset 字符集，非字符串类型为空
+func (c *schemaColumn) Charset() string {
+	return c.charset
+}
+
+//Collation 排序规则，非字符串类型为空
+func (c *schemaColumn) Collation() string {
+	return c.collation
+}
+
+//IsPrimaryKey 是否是主键的一部分
+func (c *schemaColumn) IsPrimaryKey() bool {
+	return c.primaryKey
+}
+
+//IsUnique 是否是主键或者唯一索引的一部分
+func (c *schemaColumn) IsUnique() bool {
+	return c.unique
+}
+
+//IsAutoIncrement 是否是自增列
+func (c *schemaColumn) IsAutoIncrement() bool {
+	return c.autoIncrement
+}
+
+//EnumValues enum或者set类型的所有成员，其他类型为nil
+func (c *schemaColumn) EnumValues() []string {
+	return c.enumValues
+}
+
+//schemaTable 从information_schema获取的表信息
+type schemaTable struct {
+	name       MysqlTableName
+	columns    []MysqlColumn
+	primaryKey []string
+	uniqueKeys [][]string
+}
+
+//Name 表名
+func (t *schemaTable) Name() MysqlTableName {
+	return t.name
+}
+
+//Columns 按照ORDINAL_POSITION排序的所有列
+func (t *schemaTable) Columns() []MysqlColumn {
+	return t.columns
+}
+
+//PrimaryKey 按照索引顺序的主键列名，没有主键时为nil
+func (t *schemaTable) PrimaryKey() []string {
+	return t.primaryKey
+}
+
+//UniqueKeys 所有唯一索引(不包括主键)的列名，按照索引名排序
+func (t *schemaTable) UniqueKeys() [][]string {
+	return t.uniqueKeys
+}
+
+//SchemaTableMapper 内置的MysqlTableMapper，通过单独的连接查询information_schema.COLUMNS以及STATISTICS获取
+//表的完整信息，包括无符号、enum以及set的成员、字符集、默认值、主键以及唯一索引。表信息按照表名缓存，
+//实现了MysqlTableInvalidator，RowStreamer遇到DDL时会清除对应表的缓存，可以在多个goroutine中使用
+type SchemaTableMapper struct {
+	newConn func() (sideConn, error)
+
+	mu     sync.Mutex
+	conn   sideConn
+	tables map[MysqlTableName]*schemaTable
+}
+
+//NewSchemaTableMapper dsn是mysql数据库的信息，连接在第一次获取表信息时建立，不再使用时需要调用Close
+func NewSchemaTableMapper(dsn string) *SchemaTableMapper {
+	return newSchemaTableMapper(func() (sideConn, error) {
+		return dump.NewMysqlConn(dsn)
+	})
+}
+
+func newSchemaTableMapper(newConn func() (sideConn, error)) *SchemaTableMapper {
+	return &SchemaTableMapper{
+		newConn: newConn,
+		tables:  make(map[MysqlTableName]*schemaTable),
+	}
+}
+
+//MysqlTable 实现MysqlTableMapper，优先使用缓存
+func (m *SchemaTableMapper) MysqlTable(name MysqlTableName) (MysqlTable, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if table, ok := m.tables[name]; ok {
+		return table, nil
+	}
+
+	var table *schemaTable
+	err := m.query(func(conn queryConn) error {
+		var err error
+		table, err = loadSchemaTable(conn, name)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SchemaTableMapper load table %v fail. err: %v", name.String(), err)
+	}
+	if table == nil {
+		return nil, fmt.Errorf("SchemaTableMapper table %v not found in information_schema.COLUMNS", name.String())
+	}
+	m.tables[name] = table
+	return table, nil
+}
+
+//InvalidateMysqlTable 实现MysqlTableInvalidator，name.TableName为空时清除该数据库所有表的缓存
+func (m *SchemaTableMapper) InvalidateMysqlTable(name MysqlTableName) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if name.TableName != "" {
+		delete(m.tables, name)
+		return
+	}
+	for cached := range m.tables {
+		if cached.DbName == name.DbName {
+			delete(m.tables, cached)
+		}
+	}
+}
+
+//Close 关闭查询使用的连接
+func (m *SchemaTableMapper) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		return nil
+	}
+	err := m.conn.Close()
+	m.conn = nil
+	return err
+}
+
+//query 使用已有的连接执行fn，连接异常时重新连接并重试一次，mysql返回的错误不重试
+func (m *SchemaTableMapper) query(fn func(queryConn) error) error {
+	for retry := 0; ; retry++ {
+		if m.conn == nil {
+			conn, err := m.newConn()
+			if err != nil {
+				return err
+			}
+			m.conn = conn
+		}
+		err := fn(m.conn)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*dump.MySQLError); ok {
+			return err
+		}
+		m.conn.Close()
+		m.conn = nil
+		if retry > 0 {
+			return err
+		}
+	}
+}
+
+//loadSchemaTable 从information_schema获取表的所有列以及索引，表不存在时返回nil
+func loadSchemaTable(conn queryConn, name MysqlTableName) (*schemaTable, error) {
+	where := " WHERE TABLE_SCHEMA = " + quoteSchemaString(name.DbName) +
+		" AND TABLE_NAME = " + quoteSchemaString(name.TableName)
+
+	rows, err := queryAllRows(conn, "SELECT COLUMN_NAME, COLUMN_TYPE, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, "+
+		"CHARACTER_SET_NAME, COLLATION_NAME, COLUMN_KEY, EXTRA FROM information_schema.COLUMNS"+
+		where+" ORDER BY ORDINAL_POSITION")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		//表不存在不是连接的问题，由调用者处理
+		return nil, nil
+	}
+
+	table := &schemaTable{name: name, columns: make([]MysqlColumn, 0, len(rows))}
+	columns := make(map[string]*schemaColumn, len(rows))
+	for _, row := range rows {
+		c := &schemaColumn{
+			field:         string(row[0]),
+			columnType:    string(row[1]),
+			dataType:      strings.ToLower(string(row[2])),
+			nullable:      strings.EqualFold(string(row[3]), "YES"),
+			defaultValue:  row[4],
+			charset:       string(row[5]),
+			collation:     string(row[6]),
+			autoIncrement: strings.Contains(strings.ToLower(string(row[8])), "auto_increment"),
+		}
+		if c.dataType == "enum" || c.dataType == "set" {
+			c.enumValues = parseEnumValues(c.columnType)
+		}
+		table.columns = append(table.columns, c)
+		columns[c.field] = c
+	}
+
+	rows, err = queryAllRows(conn, "SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME FROM information_schema.STATISTICS"+
+		where+" ORDER BY INDEX_NAME, SEQ_IN_INDEX")
+	if err != nil {
+		return nil, err
+	}
+	var index string
+	for _, row := range rows {
+		indexName, field := string(row[0]), string(row[2])
+		if string(row[1]) != "0" {
+			continue
+		}
+		if _, ok := columns[field]; !ok {
+			//函数索引等没有对应的列
+			continue
+		}
+		if indexName == "PRIMARY" {
+			columns[field].primaryKey = true
+			table.primaryKey = append(table.primaryKey, field)
+			continue
+		}
+		if indexName != index {
+			table.uniqueKeys = append(table.uniqueKeys, nil)
+			index = indexName
+		}
+		last := len(table.uniqueKeys) - 1
+		table.uniqueKeys[last] = append(table.uniqueKeys[last], field)
+	}
+
+	//只有单独作为主键或者唯一索引的列才是唯一的
+	if len(table.primaryKey) == 1 {
+		columns[table.primaryKey[0]].unique = true
+	}
+	for _, key := range table.uniqueKeys {
+		if len(key) == 1 {
+			columns[key[0]].unique = true
+		}
+	}
+	return table, nil
+}
+
+//queryAllRows 执行查询并读取所有的行，NULL为nil
+func queryAllRows(conn queryConn, query string) ([][][]byte, error) {
+	rows, err := conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out [][][]byte
+	dest := make([]interface{}, len(rows.Columns()))
+	for {
+		if err = rows.Next(dest); err != nil {
+			if err == io.EOF {
+				return out, nil
+			}
+			return nil, err
+		}
+		row := make([][]byte, len(dest))
+		for i, v := range dest {
+			if b, ok := v.([]byte); ok {
+				row[i] = append([]byte{}, b...)
+			}
+		}
+		out = append(out, row)
+	}
+}
+
+//quoteSchemaString 将字符串变为带转义的sql字面量
+func quoteSchemaString(s string) string {
+	buf := dump.EscapeBytesBackslash([]byte{'\''}, []byte(s))
+	return string(append(buf, '\''))
+}
+
+//parseEnumValues 从enum('a','b')或者set('a','b')中获取所有成员，成员中的''代表单引号
+func parseEnumValues(columnType string) []string {
+	start := strings.IndexByte(columnType, '(')
+	end := strings.LastIndexByte(columnType, ')')
+	if start < 0 || end < start {
+		return nil
+	}
+	body := columnType[start+1 : end]
+
+	var values []string
+	var value []byte
+	inQuote := false
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+		switch {
+		case !inQuote && ch == '\'':
+			inQuote = true
+			value = value[:0]
+		case inQuote && ch == '\'' && i+1 < len(body) && body[i+1] == '\'':
+			value = append(value, '\'')
+			i++
+		case inQuote && ch == '\'':
+			inQuote = false
+			values = append(values, string(value))
+		case inQuote && ch == '\\' && i+1 < len(body):
+			value = append(value, body[i+1])
+			i++
+		case inQuote:
+			value = append(value, ch)
+		}
+	}
+	return values
+}
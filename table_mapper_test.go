@@ -0,0 +1,314 @@
+package binlog
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/onlyac0611/binlog/dump"
+	"github.com/onlyac0611/binlog/fakemaster"
+	"github.com/onlyac0611/binlog/replication"
+)
+
+const (
+	testSchemaColumnsQuery = "SELECT COLUMN_NAME, COLUMN_TYPE, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, " +
+		"CHARACTER_SET_NAME, COLLATION_NAME, COLUMN_KEY, EXTRA FROM information_schema.COLUMNS " +
+		"WHERE TABLE_SCHEMA = 'vt_test_keyspace' AND TABLE_NAME = 'vt_a' ORDER BY ORDINAL_POSITION"
+	testSchemaStatisticsQuery = "SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME FROM information_schema.STATISTICS " +
+		"WHERE TABLE_SCHEMA = 'vt_test_keyspace' AND TABLE_NAME = 'vt_a' ORDER BY INDEX_NAME, SEQ_IN_INDEX"
+)
+
+var (
+	testSchemaColumnNames = []string{"COLUMN_NAME", "COLUMN_TYPE", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT",
+		"CHARACTER_SET_NAME", "COLLATION_NAME", "COLUMN_KEY", "EXTRA"}
+	testSchemaStatisticsNames = []string{"INDEX_NAME", "NON_UNIQUE", "COLUMN_NAME"}
+)
+
+func testSchemaColumns() [][]interface{} {
+	return [][]interface{}{
+		{"id", "int(10) unsigned", "int", "NO", nil, nil, nil, "PRI", "auto_increment"},
+		{"name", "varchar(64)", "varchar", "YES", "x", "utf8mb4", "utf8mb4_general_ci", "", ""},
+		{"status", "enum('a','it''s')", "enum", "NO", "a", "utf8mb4", "utf8mb4_general_ci", "", ""},
+		{"code", "char(8)", "char", "NO", nil, "latin1", "latin1_swedish_ci", "UNI", ""},
+		{"amount", "bigint(20) zerofill", "bigint", "NO", "0", nil, nil, "", ""},
+	}
+}
+
+func testSchemaStatistics() [][]interface{} {
+	return [][]interface{}{
+		{"PRIMARY", 0, "id"},
+		{"idx_name", 1, "name"},
+		{"uk_code", 0, "code"},
+		{"uk_name_status", 0, "name"},
+		{"uk_name_status", 0, "status"},
+	}
+}
+
+//toRawValues 将测试数据变为连接返回的格式，每一列为[]byte或者nil
+func toRawValues(rows [][]interface{}) [][]interface{} {
+	for _, row := range rows {
+		for i, v := range row {
+			if v != nil {
+				row[i] = []byte(fmt.Sprint(v))
+			}
+		}
+	}
+	return rows
+}
+
+func newTestSchemaConn() *mockSideConn {
+	return &mockSideConn{
+		mockQueryConn: mockQueryConn{
+			rows: map[string]*mockRows{
+				testSchemaColumnsQuery: {
+					columns: testSchemaColumnNames,
+					values:  toRawValues(testSchemaColumns()),
+				},
+				testSchemaStatisticsQuery: {
+					columns: testSchemaStatisticsNames,
+					values:  toRawValues(testSchemaStatistics()),
+				},
+			},
+		},
+	}
+}
+
+type mockSideConn struct {
+	mockQueryConn
+	err    error
+	closed bool
+}
+
+func (m *mockSideConn) Query(query string) (dump.MyRows, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.mockQueryConn.Query(query)
+}
+
+func (m *mockSideConn) Close() error {
+	m.closed = true
+	return nil
+}
+
+func checkSchemaTable(table MysqlTable) error {
+	if name := table.Name(); name != NewMysqlTableName("vt_test_keyspace", "vt_a") {
+		return fmt.Errorf("name is not match: %v", name.String())
+	}
+	if len(table.Columns()) != 5 {
+		return fmt.Errorf("len of columns want: 5 out: %v", len(table.Columns()))
+	}
+
+	testCases := []struct {
+		field      string
+		unsigned   bool
+		nullable   bool
+		def        []byte
+		charset    string
+		primaryKey bool
+		unique     bool
+		autoInc    bool
+		enumValues []string
+	}{
+		{field: "id", unsigned: true, primaryKey: true, unique: true, autoInc: true},
+		{field: "name", nullable: true, def: []byte("x"), charset: "utf8mb4"},
+		{field: "status", def: []byte("a"), charset: "utf8mb4", enumValues: []string{"a", "it's"}},
+		{field: "code", charset: "latin1", unique: true},
+		{field: "amount", unsigned: true, def: []byte("0")},
+	}
+	for i, v := range testCases {
+		c := table.Columns()[i].(*schemaColumn)
+		if c.Field() != v.field || c.IsUnSignedInt() != v.unsigned || c.IsNullable() != v.nullable ||
+			string(c.Default()) != string(v.def) || c.Charset() != v.charset || c.IsPrimaryKey() != v.primaryKey ||
+			c.IsUnique() != v.unique || c.IsAutoIncrement() != v.autoInc || !reflect.DeepEqual(c.EnumValues(), v.enumValues) {
+			return fmt.Errorf("column %d want: %+v out: %+v", i, v, *c)
+		}
+	}
+
+	st := table.(*schemaTable)
+	if !reflect.DeepEqual(st.PrimaryKey(), []string{"id"}) {
+		return fmt.Errorf("primary key is not match: %v", st.PrimaryKey())
+	}
+	if want := [][]string{{"code"}, {"name", "status"}}; !reflect.DeepEqual(st.UniqueKeys(), want) {
+		return fmt.Errorf("unique keys want: %v out: %v", want, st.UniqueKeys())
+	}
+	return nil
+}
+
+func TestParseEnumValues(t *testing.T) {
+	testCases := []struct {
+		columnType string
+		want       []string
+	}{
+		{columnType: "enum('a','b')", want: []string{"a", "b"}},
+		{columnType: "set('x','it''s','a,b')", want: []string{"x", "it's", "a,b"}},
+		{columnType: `enum('a\\b','')`, want: []string{`a\b`, ""}},
+		{columnType: "int(11)", want: nil},
+	}
+	for _, v := range testCases {
+		if out := parseEnumValues(v.columnType); !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("parseEnumValues %v want: %q out: %q", v.columnType, v.want, out)
+		}
+	}
+}
+
+func TestSchemaTableMapper_MysqlTable(t *testing.T) {
+	connects := 0
+	m := newSchemaTableMapper(func() (sideConn, error) {
+		connects++
+		return newTestSchemaConn(), nil
+	})
+	name := NewMysqlTableName("vt_test_keyspace", "vt_a")
+
+	table, err := m.MysqlTable(name)
+	if err != nil {
+		t.Fatalf("MysqlTable err: %v", err)
+	}
+	if err = checkSchemaTable(table); err != nil {
+		t.Fatalf("MysqlTable %v", err)
+	}
+
+	//第二次从缓存中获取，mockRows已经读完，再次查询会得到空的结果
+	if cached, err := m.MysqlTable(name); err != nil || cached != table {
+		t.Fatalf("MysqlTable should use cache. err: %v", err)
+	}
+
+	m.InvalidateMysqlTable(NewMysqlTableName("vt_test_keyspace", ""))
+	if _, err = m.MysqlTable(name); err == nil {
+		t.Fatalf("MysqlTable after invalidate should query again and fail on empty result")
+	}
+	if connects != 1 {
+		t.Fatalf("table not found should not reconnect. connects: %v", connects)
+	}
+
+	if _, err = m.MysqlTable(NewMysqlTableName("vt_test_keyspace", "vt_b")); err == nil {
+		t.Fatalf("MysqlTable unknown table want err")
+	}
+	if err = m.Close(); err != nil {
+		t.Fatalf("Close err: %v", err)
+	}
+}
+
+func TestSchemaTableMapper_Retry(t *testing.T) {
+	testCases := []struct {
+		err          error
+		wantConnects int
+		wantErr      bool
+	}{
+		{err: fmt.Errorf("broken pipe"), wantConnects: 2},
+		{err: &dump.MySQLError{Number: 1142, Message: "SELECT command denied"}, wantConnects: 1, wantErr: true},
+	}
+
+	for _, v := range testCases {
+		var conns []*mockSideConn
+		m := newSchemaTableMapper(func() (sideConn, error) {
+			conn := newTestSchemaConn()
+			if len(conns) == 0 {
+				conn.err = v.err
+			}
+			conns = append(conns, conn)
+			return conn, nil
+		})
+
+		table, err := m.MysqlTable(NewMysqlTableName("vt_test_keyspace", "vt_a"))
+		if (err != nil) != v.wantErr {
+			t.Fatalf("MysqlTable with %v want err: %v out: %v", v.err, v.wantErr, err)
+		}
+		if err == nil {
+			if err = checkSchemaTable(table); err != nil {
+				t.Fatalf("MysqlTable %v", err)
+			}
+		}
+		if len(conns) != v.wantConnects {
+			t.Fatalf("connects with %v want: %v out: %v", v.err, v.wantConnects, len(conns))
+		}
+		if len(conns) > 1 && !conns[0].closed {
+			t.Fatalf("broken connection should be closed")
+		}
+	}
+}
+
+func TestSchemaTableMapper_FakeMaster(t *testing.T) {
+	master, err := fakemaster.NewMaster("127.0.0.1:0", "root", "123456")
+	if err != nil {
+		t.Fatalf("NewMaster err: %v", err)
+	}
+	defer master.Close()
+	master.SetQueryResult(testSchemaColumnsQuery, &fakemaster.Result{
+		Columns: testSchemaColumnNames,
+		Rows:    testSchemaColumns(),
+	})
+	master.SetQueryResult(testSchemaStatisticsQuery, &fakemaster.Result{
+		Columns: testSchemaStatisticsNames,
+		Rows:    testSchemaStatistics(),
+	})
+
+	m := NewSchemaTableMapper(master.DSN())
+	defer m.Close()
+	table, err := m.MysqlTable(NewMysqlTableName("vt_test_keyspace", "vt_a"))
+	if err != nil {
+		t.Fatalf("MysqlTable err: %v", err)
+	}
+	if err = checkSchemaTable(table); err != nil {
+		t.Fatalf("MysqlTable %v", err)
+	}
+}
+
+type invalidateMapper struct {
+	mockMapper
+	loads       int
+	invalidated []MysqlTableName
+}
+
+func (m *invalidateMapper) MysqlTable(name MysqlTableName) (MysqlTable, error) {
+	m.loads++
+	return m.mockMapper.MysqlTable(name)
+}
+
+func (m *invalidateMapper) InvalidateMysqlTable(name MysqlTableName) {
+	m.invalidated = append(m.invalidated, name)
+}
+
+func TestRowStreamer_parseEvents_DDL(t *testing.T) {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	input := getInputData()
+	ddl := replication.NewQueryEvent(f, s, replication.Query{
+		Database: "vt_test_keyspace",
+		SQL:      "ALTER TABLE vt_a ADD COLUMN c int"})
+	//第二个事务的TABLE_MAP_EVENT使用同样的table id，DDL之后需要重新获取表信息
+	input = append(input, ddl, input[2], input[3], input[4], input[7])
+
+	m := &invalidateMapper{}
+	r, err := NewRowStreamer(testDSN, testServerID, m)
+	if err != nil {
+		t.Fatalf("NewRowStreamer err: %v", err)
+	}
+	r.SetStartBinlogPosition(testBinlogPosParseEvents)
+	cnt := 0
+	r.sendTransaction = func(tran *Transaction) error {
+		cnt++
+		return nil
+	}
+
+	events := make(chan replication.BinlogEvent, len(input))
+	for i := range input {
+		events <- input[i]
+	}
+	close(events)
+
+	if _, err = r.parseEvents(context.Background(), events); err != ErrStreamEOF {
+		t.Fatalf("parseEvents err != %v, err: %v", ErrStreamEOF, err)
+	}
+	if cnt < 2 {
+		t.Fatalf("transaction count want at least 2 out: %v", cnt)
+	}
+	if m.loads != 2 {
+		t.Fatalf("MysqlTable should be loaded again after DDL. loads: %v", m.loads)
+	}
+	want := []MysqlTableName{NewMysqlTableName("vt_test_keyspace", "vt_a")}
+	if !reflect.DeepEqual(m.invalidated, want) {
+		t.Fatalf("invalidated want: %v out: %v", want, m.invalidated)
+	}
+}
@@ -2,6 +2,7 @@ package binlog
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -10,7 +11,11 @@ type Transaction struct {
 	NowPosition  Position       //在binlog中的当前位置
 	NextPosition Position       //在binlog中的下一个位置
 	Timestamp    int64          //执行时间
+	GTID         string         //事务的GTID，没有开启GTID时为空
+	ServerID     uint32         //执行该事务的mysql的server id
 	Events       []*StreamEvent //一组有事务的binlog evnet
+	Location     *time.Location //TIMESTAMP列以及json中的时间使用的时区，nil时为UTC
+	Chunk        *ChunkInfo     //分块发送的大事务中该块的信息，没有分块时为nil，见RowStreamer.SetChunkSize
 }
 
 //NewTransaction 创建Transaction
@@ -27,19 +32,49 @@ func NewTransaction(now, next Position, timestamp int64,
 //MarshalJSON 实现Transaction的json序列化
 func (t *Transaction) MarshalJSON() ([]byte, error) {
 	tJSON := struct {
-		NowPosition  Position       `json:"nowPosition"`
-		NextPosition Position       `json:"nextPosition"`
-		Timestamp    string         `json:"timestamp"`
-		Events       []*StreamEvent `json:"events"`
+		NowPosition  Position         `json:"nowPosition"`
+		NextPosition Position         `json:"nextPosition"`
+		Timestamp    string           `json:"timestamp"`
+		GTID         string           `json:"gtid,omitempty"`
+		Events       []json.Marshaler `json:"events"`
+		Chunk        *ChunkInfo       `json:"chunk,omitempty"`
 	}{
 		NowPosition:  t.NowPosition,
 		NextPosition: t.NextPosition,
-		Timestamp:    time.Unix(t.Timestamp, 0).Local().String(),
-		Events:       t.Events,
+		Timestamp:    formatTimestamp(t.Timestamp, t.Location),
+		GTID:         t.GTID,
+		Chunk:        t.Chunk,
+	}
+	if t.Events != nil {
+		tJSON.Events = make([]json.Marshaler, 0, len(t.Events))
+		for _, ev := range t.Events {
+			tJSON.Events = append(tJSON.Events, &streamEventJSON{event: ev, loc: t.Location})
+		}
 	}
 	return json.Marshal(tJSON)
 }
 
+//formatTimestamp 将unix时间戳格式化为loc中的时间，loc为nil时使用UTC
+func formatTimestamp(timestamp int64, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.Unix(timestamp, 0).In(loc).String()
+}
+
+//streamEventJSON 使用Transaction的时区序列化StreamEvent
+type streamEventJSON struct {
+	event *StreamEvent
+	loc   *time.Location
+}
+
+func (s *streamEventJSON) MarshalJSON() ([]byte, error) {
+	if s.event == nil {
+		return []byte("null"), nil
+	}
+	return s.event.marshalJSON(s.loc)
+}
+
 //StreamEvent means a SQL or a rows in binlog
 type StreamEvent struct {
 	Type          StatementType  //语句类型
@@ -48,6 +83,7 @@ type StreamEvent struct {
 	Timestamp     int64          //执行时间
 	RowValues     []*RowData     //which data come to used for StatementInsert and  StatementUpdate
 	RowIdentifies []*RowData     //which data come from used for  StatementUpdate and StatementDelete
+	PrimaryKey    []string       //表的主键列，表信息中没有主键时为空
 }
 
 //NewStreamEvent 创建StreamEvent
@@ -63,18 +99,48 @@ func NewStreamEvent(tranType StatementType,
 	}
 }
 
+//PrimaryKeyValues 按照PrimaryKey的顺序获取每一行的主键值，StatementInsert使用RowValues，
+//StatementUpdate以及StatementDelete使用修改前的RowIdentifies，没有主键时返回nil
+func (s *StreamEvent) PrimaryKeyValues() ([][]*ColumnData, error) {
+	if len(s.PrimaryKey) == 0 {
+		return nil, nil
+	}
+	rows := s.RowIdentifies
+	if s.Type == StatementInsert {
+		rows = s.RowValues
+	}
+
+	values := make([][]*ColumnData, 0, len(rows))
+	for i, row := range rows {
+		keys := make([]*ColumnData, 0, len(s.PrimaryKey))
+		for _, key := range s.PrimaryKey {
+			c := findColumnData(row, key)
+			if c == nil || c.IsEmpty {
+				return nil, fmt.Errorf("primary key %v of table %v is not in row %d", key, s.Table.String(), i)
+			}
+			keys = append(keys, c)
+		}
+		values = append(values, keys)
+	}
+	return values, nil
+}
+
 type baseStreamEventJSON struct {
 	Table     MysqlTableName `json:"name"`
 	Type      string         `json:"type"`
 	Timestamp string         `json:"timestamp"`
 }
 
-//MarshalJSON 实现StreamEvent的json序列化
+//MarshalJSON 实现StreamEvent的json序列化，时间使用UTC，作为Transaction的一部分序列化时使用Transaction.Location
 func (s *StreamEvent) MarshalJSON() ([]byte, error) {
+	return s.marshalJSON(nil)
+}
+
+func (s *StreamEvent) marshalJSON(loc *time.Location) ([]byte, error) {
 	b := baseStreamEventJSON{
 		Table:     s.Table,
 		Type:      s.Type.String(),
-		Timestamp: time.Unix(s.Timestamp, 0).Local().String(),
+		Timestamp: formatTimestamp(s.Timestamp, loc),
 	}
 	if s.SQL != "" {
 		sqlJSON := struct {
@@ -116,6 +182,9 @@ type ColumnData struct {
 	Type    ColumnType // binlog中的列类型
 	IsEmpty bool       // data is empty,即该列没有变化
 	Data    []byte     // the data
+	Charset string     // 字符类型列的字符集，未知时为空，非UTF-8字符集的Data已经转为UTF-8
+	Raw     []byte     // 转码前的原始数据，只有SetKeepRawBytes(true)并且进行了转码，或者SetGeometryFormat转换了几何列时才有
+	Labels  []string   // ENUM以及SET列的成员名，Data为以逗号连接的成员名，成员未知时为nil且Data为索引或者位图
 }
 
 //NewColumnData 创建ColumnData
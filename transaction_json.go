@@ -0,0 +1,302 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//json序列化格式的版本
+const (
+	JSONVersion1 = 1 //MarshalJSON使用的格式，所有的数据都是字符串，时间使用Transaction.Location的时区
+	JSONVersion2 = 2 //MarshalJSONV2使用的格式，可以通过UnmarshalJSONV2还原
+)
+
+//transactionJSONV2 Transaction在v2格式中的结构
+type transactionJSONV2 struct {
+	Version      int                  `json:"version"`
+	NowPosition  Position             `json:"nowPosition"`
+	NextPosition Position             `json:"nextPosition"`
+	Timestamp    string               `json:"timestamp"`
+	GTID         string               `json:"gtid,omitempty"`
+	ServerID     uint32               `json:"serverId,omitempty"`
+	Events       []*streamEventJSONV2 `json:"events"`
+	Chunk        *ChunkInfo           `json:"chunk,omitempty"`
+}
+
+//streamEventJSONV2 StreamEvent在v2格式中的结构
+type streamEventJSONV2 struct {
+	Table         MysqlTableName   `json:"table"`
+	Type          string           `json:"type"`
+	Timestamp     string           `json:"timestamp"`
+	SQL           string           `json:"sql,omitempty"`
+	RowValues     []*rowDataJSONV2 `json:"rowValues"`
+	RowIdentifies []*rowDataJSONV2 `json:"rowIdentifies"`
+	PrimaryKey    []string         `json:"primaryKey,omitempty"`
+}
+
+//rowDataJSONV2 RowData在v2格式中的结构
+type rowDataJSONV2 struct {
+	Columns []*columnJSONV2 `json:"columns"`
+}
+
+//columnJSONV2 ColumnData在v2格式中的结构，data根据列类型以及字符集为数字、字符串、base64或者null
+type columnJSONV2 struct {
+	Field   string          `json:"field"`
+	Type    string          `json:"type"`
+	IsEmpty bool            `json:"isEmpty"`
+	Charset string          `json:"charset,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+//MarshalJSONV2 使用v2格式序列化事务：带有version字段，整形以及实数为json数字，精确实数为字符串，
+//二进制数据(binary字符集以及字符集未知的blob)、bit以及几何类型(mysql格式的数据)为base64，时间戳为RFC3339格式的UTC时间，列名的键为field
+func (t *Transaction) MarshalJSONV2() ([]byte, error) {
+	tJSON := &transactionJSONV2{
+		Version:      JSONVersion2,
+		NowPosition:  t.NowPosition,
+		NextPosition: t.NextPosition,
+		Timestamp:    formatTimestampV2(t.Timestamp),
+		GTID:         t.GTID,
+		ServerID:     t.ServerID,
+		Events:       make([]*streamEventJSONV2, 0, len(t.Events)),
+		Chunk:        t.Chunk,
+	}
+	for _, ev := range t.Events {
+		evJSON := &streamEventJSONV2{
+			Table:      ev.Table,
+			Type:       ev.Type.String(),
+			Timestamp:  formatTimestampV2(ev.Timestamp),
+			SQL:        ev.SQL,
+			PrimaryKey: ev.PrimaryKey,
+		}
+		var err error
+		if evJSON.RowValues, err = rowsToJSONV2(ev.RowValues); err != nil {
+			return nil, err
+		}
+		if evJSON.RowIdentifies, err = rowsToJSONV2(ev.RowIdentifies); err != nil {
+			return nil, err
+		}
+		tJSON.Events = append(tJSON.Events, evJSON)
+	}
+	return json.Marshal(tJSON)
+}
+
+//UnmarshalJSONV2 还原MarshalJSONV2序列化的事务，不支持MarshalJSON的v1格式，
+//所以没有实现json.Unmarshaler，json.Marshal以及json.Unmarshal仍然使用v1格式
+func (t *Transaction) UnmarshalJSONV2(data []byte) error {
+	var tJSON transactionJSONV2
+	if err := json.Unmarshal(data, &tJSON); err != nil {
+		return err
+	}
+	if tJSON.Version != JSONVersion2 {
+		return fmt.Errorf("UnmarshalJSONV2 unsupported json version %v", tJSON.Version)
+	}
+
+	timestamp, err := parseTimestampV2(tJSON.Timestamp)
+	if err != nil {
+		return err
+	}
+	tran := Transaction{
+		NowPosition:  tJSON.NowPosition,
+		NextPosition: tJSON.NextPosition,
+		Timestamp:    timestamp,
+		GTID:         tJSON.GTID,
+		ServerID:     tJSON.ServerID,
+		Events:       make([]*StreamEvent, 0, len(tJSON.Events)),
+		Chunk:        tJSON.Chunk,
+	}
+	for _, evJSON := range tJSON.Events {
+		if evJSON == nil {
+			return fmt.Errorf("UnmarshalJSONV2 null event")
+		}
+		ev := &StreamEvent{
+			Table:      evJSON.Table,
+			Type:       parseStatementType(evJSON.Type),
+			SQL:        evJSON.SQL,
+			PrimaryKey: evJSON.PrimaryKey,
+		}
+		if ev.Timestamp, err = parseTimestampV2(evJSON.Timestamp); err != nil {
+			return err
+		}
+		if ev.RowValues, err = rowsFromJSONV2(evJSON.RowValues); err != nil {
+			return err
+		}
+		if ev.RowIdentifies, err = rowsFromJSONV2(evJSON.RowIdentifies); err != nil {
+			return err
+		}
+		tran.Events = append(tran.Events, ev)
+	}
+	*t = tran
+	return nil
+}
+
+func formatTimestampV2(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
+
+func parseTimestampV2(s string) (int64, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %v: %v", s, err)
+	}
+	return t.Unix(), nil
+}
+
+//parseStatementType 与StatementType.String相反
+func parseStatementType(s string) StatementType {
+	if typ, ok := statementPrefixes[s]; ok {
+		return typ
+	}
+	return StatementUnknown
+}
+
+//parseColumnType 与ColumnType.String相反
+func parseColumnType(s string) (ColumnType, error) {
+	for typ, name := range columnTypeStrings {
+		if name == s {
+			return typ, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid column type %v", s)
+}
+
+func rowsToJSONV2(rows []*RowData) ([]*rowDataJSONV2, error) {
+	out := make([]*rowDataJSONV2, 0, len(rows))
+	for _, row := range rows {
+		rowJSON := &rowDataJSONV2{Columns: make([]*columnJSONV2, 0, len(row.Columns))}
+		for _, c := range row.Columns {
+			data, err := columnDataToJSONV2(c)
+			if err != nil {
+				return nil, err
+			}
+			rowJSON.Columns = append(rowJSON.Columns, &columnJSONV2{
+				Field:   c.Filed,
+				Type:    c.Type.String(),
+				IsEmpty: c.IsEmpty,
+				Charset: c.Charset,
+				Data:    data,
+			})
+		}
+		out = append(out, rowJSON)
+	}
+	return out, nil
+}
+
+func rowsFromJSONV2(rows []*rowDataJSONV2) ([]*RowData, error) {
+	out := make([]*RowData, 0, len(rows))
+	for _, rowJSON := range rows {
+		if rowJSON == nil {
+			return nil, fmt.Errorf("rowsFromJSONV2 null row")
+		}
+		row := NewRowData(len(rowJSON.Columns))
+		for _, cJSON := range rowJSON.Columns {
+			if cJSON == nil {
+				return nil, fmt.Errorf("rowsFromJSONV2 null column")
+			}
+			typ, err := parseColumnType(cJSON.Type)
+			if err != nil {
+				return nil, err
+			}
+			c := NewColumnData(cJSON.Field, typ, cJSON.IsEmpty)
+			c.Charset = cJSON.Charset
+			if c.Data, err = columnDataFromJSONV2(c, cJSON.Data); err != nil {
+				return nil, fmt.Errorf("rowsFromJSONV2 column %v err: %v", cJSON.Field, err)
+			}
+			row.Columns = append(row.Columns, c)
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+//isNumberColumn 在v2格式中使用json数字的列类型
+func isNumberColumn(typ ColumnType) bool {
+	return typ.IsInteger() || typ.IsFloat() || typ == ColumnTypeYear
+}
+
+//isBinaryColumn 在v2格式中使用base64的列：bit以及几何类型、binary字符集的列(BINARY、VARBINARY以及BLOB)，
+//TEXT与BLOB的列类型相同，字符集未知时无法区分，按照二进制数据处理
+func isBinaryColumn(c *ColumnData) bool {
+	switch {
+	case c.Type.IsBit() || c.Type.IsGeometry():
+		return true
+	case c.Charset == "binary":
+		return true
+	default:
+		return c.Type.IsBlob() && c.Charset == ""
+	}
+}
+
+func columnDataToJSONV2(c *ColumnData) (json.RawMessage, error) {
+	switch {
+	case c.Data == nil:
+		return json.RawMessage("null"), nil
+	case isNumberColumn(c.Type):
+		data, err := json.Marshal(json.Number(canonicalNumber(c)))
+		if err != nil {
+			return nil, fmt.Errorf("columnDataToJSONV2 invalid number %s of %v", c.Data, c.Filed)
+		}
+		return data, nil
+	case c.Type.IsGeometry():
+		return json.Marshal(c.geometryWKB())
+	case isBinaryColumn(c):
+		return json.Marshal(c.Data)
+	default:
+		return json.Marshal(string(c.Data))
+	}
+}
+
+//canonicalNumber 整形以及YEAR去掉前导0，如零值的YEAR为0000，json数字不能有前导0
+func canonicalNumber(c *ColumnData) string {
+	s := string(c.Data)
+	if !c.Type.IsInteger() && c.Type != ColumnTypeYear {
+		return s
+	}
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return strconv.FormatInt(v, 10)
+	}
+	if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return strconv.FormatUint(v, 10)
+	}
+	return s
+}
+
+func columnDataFromJSONV2(c *ColumnData, data json.RawMessage) ([]byte, error) {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	switch {
+	case isNumberColumn(c.Type):
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return nil, err
+		}
+		//CellBytes得到的YEAR为4位数字
+		if c.Type == ColumnTypeYear {
+			v, err := n.Int64()
+			if err != nil {
+				return nil, err
+			}
+			return []byte(fmt.Sprintf("%04d", v)), nil
+		}
+		return []byte(n.String()), nil
+	case isBinaryColumn(c):
+		var b []byte
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, err
+		}
+		if b == nil {
+			b = []byte{}
+		}
+		return b, nil
+	default:
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+}
@@ -0,0 +1,161 @@
+package binlog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func newTestTransactionV2() *Transaction {
+	return &Transaction{
+		NowPosition:  Position{Filename: "binlog.000001", Offset: 4},
+		NextPosition: Position{Filename: "binlog.000001", Offset: 300},
+		Timestamp:    1407805592,
+		GTID:         "439192bd-f37c-11e4-bbeb-0242ac11035a:4",
+		ServerID:     62344,
+		Events: []*StreamEvent{
+			{
+				Type:       StatementInsert,
+				Table:      NewMysqlTableName("vt_test_keyspace", "vt_a"),
+				Timestamp:  1407805592,
+				PrimaryKey: []string{"id"},
+				RowValues: []*RowData{
+					{
+						Columns: []*ColumnData{
+							{Filed: "id", Type: ColumnTypeLongLong, Data: []byte("18446744073709551615")},
+							{Filed: "price", Type: ColumnTypeNewDecimal, Data: []byte("1.50")},
+							{Filed: "ratio", Type: ColumnTypeDouble, Data: []byte("0.25")},
+							{Filed: "y", Type: ColumnTypeYear, Data: []byte("0000")},
+							{Filed: "born", Type: ColumnTypeYear, Data: []byte("1999")},
+							{Filed: "data", Type: ColumnTypeBlob, Data: []byte{0, 0xff}},
+							{Filed: "message", Type: ColumnTypeVarchar, Data: []byte("abc")},
+							{Filed: "note", Type: ColumnTypeBlob, Data: []byte("café"), Charset: "latin1"},
+							{Filed: "hash", Type: ColumnTypeVarchar, Data: []byte{0xde, 0xad}, Charset: "binary"},
+							{Filed: "deleted", Type: ColumnTypeTiny},
+							{Filed: "created", Type: ColumnTypeTimestamp2, IsEmpty: true},
+						},
+					},
+				},
+				RowIdentifies: []*RowData{},
+			},
+			{
+				Type:          StatementAlter,
+				Table:         NewMysqlTableName("vt_test_keyspace", "vt_a"),
+				Timestamp:     1407805592,
+				SQL:           "alter table vt_a add column c int",
+				RowValues:     []*RowData{},
+				RowIdentifies: []*RowData{},
+			},
+		},
+	}
+}
+
+func TestTransaction_MarshalJSONV2(t *testing.T) {
+	out, err := newTestTransactionV2().MarshalJSONV2()
+	if err != nil {
+		t.Fatalf("MarshalJSONV2 err: %v", err)
+	}
+
+	want := `{"version":2,"nowPosition":{"filename":"binlog.000001","offset":4},` +
+		`"nextPosition":{"filename":"binlog.000001","offset":300},"timestamp":"2014-08-12T01:06:32Z",` +
+		`"gtid":"439192bd-f37c-11e4-bbeb-0242ac11035a:4","serverId":62344,"events":[` +
+		`{"table":{"db":"vt_test_keyspace","table":"vt_a"},"type":"insert","timestamp":"2014-08-12T01:06:32Z",` +
+		`"rowValues":[{"columns":[` +
+		`{"field":"id","type":"LongLong","isEmpty":false,"data":18446744073709551615},` +
+		`{"field":"price","type":"NewDecimal","isEmpty":false,"data":"1.50"},` +
+		`{"field":"ratio","type":"Double","isEmpty":false,"data":0.25},` +
+		`{"field":"y","type":"Year","isEmpty":false,"data":0},` +
+		`{"field":"born","type":"Year","isEmpty":false,"data":1999},` +
+		`{"field":"data","type":"Blob","isEmpty":false,"data":"AP8="},` +
+		`{"field":"message","type":"Varchar","isEmpty":false,"data":"abc"},` +
+		`{"field":"note","type":"Blob","isEmpty":false,"charset":"latin1","data":"café"},` +
+		`{"field":"hash","type":"Varchar","isEmpty":false,"charset":"binary","data":"3q0="},` +
+		`{"field":"deleted","type":"Tiny","isEmpty":false,"data":null},` +
+		`{"field":"created","type":"Timestamp2","isEmpty":true,"data":null}]}],"rowIdentifies":[],` +
+		`"primaryKey":["id"]},` +
+		`{"table":{"db":"vt_test_keyspace","table":"vt_a"},"type":"alter","timestamp":"2014-08-12T01:06:32Z",` +
+		`"sql":"alter table vt_a add column c int","rowValues":[],"rowIdentifies":[]}]}`
+	if string(out) != want {
+		t.Fatalf("MarshalJSONV2\nwant: %v\nout:  %s", want, out)
+	}
+
+	tran := newTestTransactionV2()
+	tran.Events[0].RowValues[0].Columns[0].Data = []byte("abc")
+	if _, err := tran.MarshalJSONV2(); err == nil {
+		t.Fatalf("MarshalJSONV2 with invalid number want err")
+	}
+}
+
+func TestTransaction_UnmarshalJSONV2(t *testing.T) {
+	want := newTestTransactionV2()
+	data, err := want.MarshalJSONV2()
+	if err != nil {
+		t.Fatalf("MarshalJSONV2 err: %v", err)
+	}
+
+	out := &Transaction{}
+	if err := out.UnmarshalJSONV2(data); err != nil {
+		t.Fatalf("UnmarshalJSONV2 err: %v", err)
+	}
+	if !reflect.DeepEqual(want, out) {
+		wantJSON, _ := json.Marshal(want)
+		outJSON, _ := json.Marshal(out)
+		t.Fatalf("UnmarshalJSONV2\nwant: %s\nout:  %s", wantJSON, outJSON)
+	}
+
+	testCases := []string{
+		`{"nowPosition":{"filename":"binlog.000001","offset":4}}`,
+		`{"version":2,"timestamp":"2014-08-12 01:06:32"}`,
+		`{"version":2,"timestamp":"2014-08-12T01:06:32Z","events":[{"timestamp":"2014-08-12T01:06:32Z",` +
+			`"rowValues":[{"columns":[{"field":"id","type":"Unknown"}]}]}]}`,
+		`{"version":2,"timestamp":"2014-08-12T01:06:32Z","events":[{"timestamp":"2014-08-12T01:06:32Z",` +
+			`"rowValues":[{"columns":[{"field":"id","type":"Long","data":"abc"}]}]}]}`,
+	}
+	for _, v := range testCases {
+		if err := (&Transaction{}).UnmarshalJSONV2([]byte(v)); err == nil {
+			t.Fatalf("UnmarshalJSONV2(%v) want err", v)
+		}
+	}
+}
+
+func TestJSONEncoder_SetVersion(t *testing.T) {
+	e := NewJSONEncoder()
+	if err := e.SetVersion(3); err == nil {
+		t.Fatalf("SetVersion(3) want err")
+	}
+	if err := e.SetVersion(JSONVersion2); err != nil {
+		t.Fatalf("SetVersion err: %v", err)
+	}
+
+	tran := newTestTransactionV2()
+	msgs, err := e.Encode(tran)
+	if err != nil {
+		t.Fatalf("Encode err: %v", err)
+	}
+	want, _ := tran.MarshalJSONV2()
+	if len(msgs) != 1 || string(msgs[0]) != string(want) {
+		t.Fatalf("Encode want: %s out: %s", want, msgs)
+	}
+}
+
+func TestTransaction_JSONV2Geometry(t *testing.T) {
+	//SetGeometryFormat转换过的几何列仍然输出mysql格式的数据，还原后可以通过Geometry解析
+	wkb, _ := hex.DecodeString("e61000000101000000000000000000f03f0000000000000040")
+	tran := newTestTransactionV2()
+	tran.Events[0].RowValues[0].Columns = []*ColumnData{
+		{Filed: "geo", Type: ColumnTypeGeometry, Data: []byte("POINT(1 2)"), Raw: wkb},
+	}
+	data, err := tran.MarshalJSONV2()
+	if err != nil {
+		t.Fatalf("MarshalJSONV2 err: %v", err)
+	}
+	out := &Transaction{}
+	if err = out.UnmarshalJSONV2(data); err != nil {
+		t.Fatalf("UnmarshalJSONV2 err: %v", err)
+	}
+	g, err := out.Events[0].RowValues[0].Columns[0].Geometry()
+	if err != nil || g.WKT() != "POINT(1 2)" || g.SRID != 4326 {
+		t.Fatalf("Geometry err: %v out: %+v json: %s", err, g, data)
+	}
+}
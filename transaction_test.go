@@ -1,12 +1,14 @@
 package binlog
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
 
 const (
 	mysqlPrimaryKeyDescription    = "PRI"            //主键
+	mysqlUniqueKeyDescription     = "UNI"            //唯一索引
 	mysqlAutoIncrementDescription = "auto_increment" //自增
 )
 
@@ -90,10 +92,29 @@ func TestTransaction_MarshalJSON(t *testing.T) {
 				},
 			},
 			want: `{"nowPosition":{"filename":"binlog.000005","offset":0},"nextPosition":{"filename":"binlog.000005","offset":4},"timestamp":"` +
-				time.Unix(0, 0).Local().String() + `","events":[{"name":{"db":"vt_test_keyspace","table":"vt_a"},"type":"insert","timestamp":"` +
-				time.Date(2014, time.August, 12, 1, 6, 32, 0, time.UTC).Local().String() + `","sql":"insert into vt_test_keyspace.vt_a(id,message)values(1076895760,'abcd')"},{"name":{"db":"vt_test_keyspace","table":"vt_a"},"type":"update","timestamp":"` +
-				time.Date(2014, time.August, 12, 1, 6, 32, 0, time.UTC).Local().String() + `","rowValues":[{"Columns":[{"filed":"id","type":"Long","isEmpty":false,"data":"1076895760"},{"filed":"message","type":"Varchar","isEmpty":false,"data":"abcd"}]}],"rowIdentifies":[{"Columns":[{"filed":"id","type":"Long","isEmpty":false,"data":"1076895760"},{"filed":"message","type":"Varchar","isEmpty":false,"data":"abc"}]}]},{"name":{"db":"vt_test_keyspace","table":"vt_a"},"type":"delete","timestamp":"` +
-				time.Date(2014, time.August, 12, 1, 6, 32, 0, time.UTC).Local().String() + `","rowValues":null,"rowIdentifies":[{"Columns":[{"filed":"id","type":"Long","isEmpty":false,"data":"1076895760"},{"filed":"message","type":"Varchar","isEmpty":false,"data":null}]}]}]}`,
+				time.Unix(0, 0).UTC().String() + `","events":[{"name":{"db":"vt_test_keyspace","table":"vt_a"},"type":"insert","timestamp":"` +
+				time.Date(2014, time.August, 12, 1, 6, 32, 0, time.UTC).UTC().String() + `","sql":"insert into vt_test_keyspace.vt_a(id,message)values(1076895760,'abcd')"},{"name":{"db":"vt_test_keyspace","table":"vt_a"},"type":"update","timestamp":"` +
+				time.Date(2014, time.August, 12, 1, 6, 32, 0, time.UTC).UTC().String() + `","rowValues":[{"Columns":[{"filed":"id","type":"Long","isEmpty":false,"data":"1076895760"},{"filed":"message","type":"Varchar","isEmpty":false,"data":"abcd"}]}],"rowIdentifies":[{"Columns":[{"filed":"id","type":"Long","isEmpty":false,"data":"1076895760"},{"filed":"message","type":"Varchar","isEmpty":false,"data":"abc"}]}]},{"name":{"db":"vt_test_keyspace","table":"vt_a"},"type":"delete","timestamp":"` +
+				time.Date(2014, time.August, 12, 1, 6, 32, 0, time.UTC).UTC().String() + `","rowValues":null,"rowIdentifies":[{"Columns":[{"filed":"id","type":"Long","isEmpty":false,"data":"1076895760"},{"filed":"message","type":"Varchar","isEmpty":false,"data":null}]}]}]}`,
+		},
+		{
+			input: &Transaction{
+				NowPosition:  testBinlogPosParseEvents,
+				NextPosition: testBinlogPosParseEvents,
+				Timestamp:    1407805592,
+				Location:     time.FixedZone("UTC+8", 8*3600),
+				Events: []*StreamEvent{
+					{
+						Type:      StatementInsert,
+						Timestamp: 1407805592,
+						Table:     NewMysqlTableName("vt_test_keyspace", "vt_a"),
+						SQL:       "insert into vt_test_keyspace.vt_a(id)values(1)",
+					},
+				},
+			},
+			want: `{"nowPosition":{"filename":"binlog.000005","offset":0},"nextPosition":{"filename":"binlog.000005","offset":0},` +
+				`"timestamp":"2014-08-12 09:06:32 +0800 UTC+8","events":[{"name":{"db":"vt_test_keyspace","table":"vt_a"},` +
+				`"type":"insert","timestamp":"2014-08-12 09:06:32 +0800 UTC+8","sql":"insert into vt_test_keyspace.vt_a(id)values(1)"}]}`,
 		},
 	}
 	for _, v := range testCases {
@@ -107,3 +128,70 @@ func TestTransaction_MarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestStreamEvent_PrimaryKeyValues(t *testing.T) {
+	table := NewMysqlTableName("vt_test_keyspace", "vt_a")
+	minimal := newTestRowData("1", "c")
+	minimal.Columns[0].IsEmpty = true
+
+	testCases := []struct {
+		input   *StreamEvent
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			input: &StreamEvent{
+				Type:       StatementInsert,
+				Table:      table,
+				PrimaryKey: []string{"id"},
+				RowValues:  []*RowData{newTestRowData("1", "a"), newTestRowData("2", "b")},
+			},
+			want: [][]string{{"1"}, {"2"}},
+		},
+		{
+			input: &StreamEvent{
+				Type:          StatementUpdate,
+				Table:         table,
+				PrimaryKey:    []string{"message", "id"},
+				RowIdentifies: []*RowData{newTestRowData("1", "a")},
+				RowValues:     []*RowData{newTestRowData("3", "c")},
+			},
+			want: [][]string{{"a", "1"}},
+		},
+		{
+			input: &StreamEvent{
+				Type:          StatementDelete,
+				Table:         table,
+				RowIdentifies: []*RowData{newTestRowData("1", "a")},
+			},
+			want: nil,
+		},
+		{
+			input: &StreamEvent{
+				Type:          StatementDelete,
+				Table:         table,
+				PrimaryKey:    []string{"id"},
+				RowIdentifies: []*RowData{minimal},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, v := range testCases {
+		values, err := v.input.PrimaryKeyValues()
+		if (err != nil) != v.wantErr {
+			t.Fatalf("PrimaryKeyValues %v wantErr: %v err: %v", v.input.Type, v.wantErr, err)
+		}
+		var out [][]string
+		for _, row := range values {
+			var keys []string
+			for _, c := range row {
+				keys = append(keys, string(c.Data))
+			}
+			out = append(out, keys)
+		}
+		if !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("PrimaryKeyValues %v want: %v out: %v", v.input.Type, v.want, out)
+		}
+	}
+}
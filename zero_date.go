@@ -0,0 +1,108 @@
+package binlog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ZeroDatePolicy 零值日期('0000-00-00')以及非法日期('2019-02-00')的处理方式，
+//作用于DATE、DATETIME、TIMESTAMP以及YEAR列，YEAR列只有零值'0000'
+type ZeroDatePolicy int
+
+//零值日期以及非法日期的处理方式
+const (
+	ZeroDateKeep     ZeroDatePolicy = iota //保持CellBytes得到的文本，默认的处理方式
+	ZeroDateNull                           //变为NULL
+	ZeroDateSentinel                       //变为SetZeroDatePolicy设置的时间
+	ZeroDateError                          //返回错误，RowStreamer.Stream会停止
+)
+
+var zeroDatePolicyNames = map[ZeroDatePolicy]string{
+	ZeroDateKeep:     "Keep",
+	ZeroDateNull:     "Null",
+	ZeroDateSentinel: "Sentinel",
+	ZeroDateError:    "Error",
+}
+
+//String 打印
+func (p ZeroDatePolicy) String() string {
+	if name, ok := zeroDatePolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("ZeroDatePolicy(%d)", int(p))
+}
+
+//SetZeroDatePolicy 设置零值日期以及非法日期的处理方式，sentinel只在policy为ZeroDateSentinel时使用，
+//按照列类型格式化，如DATE为'2006-01-02'，YEAR为'2006'，DATETIME以及TIMESTAMP保留原有的小数位数
+func (s *RowStreamer) SetZeroDatePolicy(policy ZeroDatePolicy, sentinel time.Time) {
+	s.decodeOptions.zeroDatePolicy = policy
+	s.decodeOptions.zeroDateSentinel = sentinel
+}
+
+//isInvalidDate 判断DATE、DATETIME或者TIMESTAMP的文本中的日期部分是否是零值或者非法日期，
+//年份为0但月和日合法的日期(如'0000-01-01')是合法的
+func isInvalidDate(s string) bool {
+	if len(s) < len(dateLayout) || s[4] != '-' || s[7] != '-' {
+		return false
+	}
+	year, err1 := strconv.Atoi(s[0:4])
+	month, err2 := strconv.Atoi(s[5:7])
+	day, err3 := strconv.Atoi(s[8:10])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return false
+	}
+	if month < 1 || month > 12 || day < 1 {
+		return true
+	}
+	//time.Date会将超出的天数进位到下一个月
+	return day > time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+//formatSentinel 按照列类型以及原有文本的小数位数格式化sentinel
+func formatSentinel(typ ColumnType, sentinel time.Time, s string) []byte {
+	switch {
+	case typ == ColumnTypeYear:
+		return []byte(fmt.Sprintf("%04d", sentinel.Year()))
+	case typ.IsDate():
+		return []byte(sentinel.Format(dateLayout))
+	}
+	layout := "2006-01-02 15:04:05"
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		layout += "." + strings.Repeat("0", len(s)-i-1)
+	}
+	return []byte(sentinel.Format(layout))
+}
+
+//checkZeroDate 按照ZeroDatePolicy处理零值日期以及非法日期
+func (tc *tableCache) checkZeroDate(column *ColumnData) error {
+	if tc.options == nil || tc.options.zeroDatePolicy == ZeroDateKeep || column.Data == nil {
+		return nil
+	}
+	typ := column.Type
+	s := string(column.Data)
+	switch {
+	case typ == ColumnTypeYear:
+		if s != "0000" {
+			return nil
+		}
+	case typ.IsDate() || typ.IsDateTime() || typ.IsTimestamp():
+		if !isInvalidDate(s) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	switch tc.options.zeroDatePolicy {
+	case ZeroDateNull:
+		column.Data = nil
+	case ZeroDateSentinel:
+		column.Data = formatSentinel(typ, tc.options.zeroDateSentinel, s)
+	case ZeroDateError:
+		name := tc.table.Name()
+		return fmt.Errorf("column %v of table %v has zero or invalid date %v", column.Filed, name.String(), s)
+	}
+	return nil
+}
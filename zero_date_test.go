@@ -0,0 +1,75 @@
+package binlog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsInvalidDate(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  bool
+	}{
+		{input: "0000-00-00", want: true},
+		{input: "0000-00-00 00:00:00.000", want: true},
+		{input: "2019-02-00", want: true},
+		{input: "2019-00-10 10:00:00", want: true},
+		{input: "2019-02-29", want: true},
+		{input: "2019-04-31", want: true},
+		{input: "2020-02-29", want: false},
+		{input: "0000-01-01", want: false},
+		{input: "2019-12-31 23:59:59", want: false},
+		{input: "abc", want: false},
+	}
+	for _, v := range testCases {
+		if out := isInvalidDate(v.input); out != v.want {
+			t.Fatalf("isInvalidDate(%v) want: %v out: %v", v.input, v.want, out)
+		}
+	}
+}
+
+func TestTableCache_checkZeroDate(t *testing.T) {
+	sentinel := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		policy  ZeroDatePolicy
+		typ     ColumnType
+		data    string
+		want    []byte
+		wantErr bool
+	}{
+		{policy: ZeroDateKeep, typ: ColumnTypeDate, data: "0000-00-00", want: []byte("0000-00-00")},
+		{policy: ZeroDateNull, typ: ColumnTypeDate, data: "0000-00-00", want: nil},
+		{policy: ZeroDateNull, typ: ColumnTypeDate, data: "2019-02-00", want: nil},
+		{policy: ZeroDateNull, typ: ColumnTypeDate, data: "2019-02-01", want: []byte("2019-02-01")},
+		{policy: ZeroDateNull, typ: ColumnTypeYear, data: "0000", want: nil},
+		{policy: ZeroDateNull, typ: ColumnTypeYear, data: "2019", want: []byte("2019")},
+		{policy: ZeroDateNull, typ: ColumnTypeVarchar, data: "0000-00-00", want: []byte("0000-00-00")},
+		{policy: ZeroDateSentinel, typ: ColumnTypeDate, data: "2019-02-00", want: []byte("1970-01-01")},
+		{policy: ZeroDateSentinel, typ: ColumnTypeDateTime, data: "0000-00-00 00:00:00",
+			want: []byte("1970-01-01 00:00:00")},
+		{policy: ZeroDateSentinel, typ: ColumnTypeDateTime2, data: "2019-02-30 10:20:30.123",
+			want: []byte("1970-01-01 00:00:00.000")},
+		{policy: ZeroDateSentinel, typ: ColumnTypeTimestamp2, data: "0000-00-00 00:00:00.0",
+			want: []byte("1970-01-01 00:00:00.0")},
+		{policy: ZeroDateSentinel, typ: ColumnTypeYear, data: "0000", want: []byte("1970")},
+		{policy: ZeroDateError, typ: ColumnTypeTimestamp, data: "0000-00-00 00:00:00", wantErr: true},
+		{policy: ZeroDateError, typ: ColumnTypeNewDate, data: "2019-02-00", wantErr: true},
+		{policy: ZeroDateError, typ: ColumnTypeDate, data: "2019-02-01", want: []byte("2019-02-01")},
+	}
+
+	for i, v := range testCases {
+		tc := &tableCache{
+			table:   tesInfo,
+			options: &decodeOptions{zeroDatePolicy: v.policy, zeroDateSentinel: sentinel},
+		}
+		c := NewColumnData("d", v.typ, false)
+		c.Data = []byte(v.data)
+		err := tc.checkZeroDate(c)
+		if (err != nil) != v.wantErr {
+			t.Fatalf("case %d %v wantErr: %v err: %v", i, v.policy, v.wantErr, err)
+		}
+		if err == nil && (string(c.Data) != string(v.want) || (v.want == nil) != (c.Data == nil)) {
+			t.Fatalf("case %d %v want: %q out: %q", i, v.policy, v.want, c.Data)
+		}
+	}
+}